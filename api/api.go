@@ -0,0 +1,8 @@
+// Package api holds the OpenAPI description of the HTTP API. The spec is
+// written by hand: update it alongside any change to the routes it covers.
+package api
+
+import _ "embed"
+
+//go:embed openapi.yaml
+var OpenAPISpec []byte
@@ -0,0 +1,403 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/config"
+	httpDelivery "github.com/valentinfrappart/securerestapi/internal/delivery/http"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/mail"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/oauth"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/webhook"
+	"github.com/valentinfrappart/securerestapi/internal/usecase"
+)
+
+// healthCheckTimeout bounds a whole GET /health/detailed run.
+const healthCheckTimeout = 2 * time.Second
+
+type App struct {
+	config        config.Config
+	db            *sql.DB
+	server        *http.Server
+	metricsServer *http.Server
+	dispatcher    *webhook.Dispatcher
+	revocations   *security.MemoryRevocationStore
+	routes        []string
+}
+
+func NewApp(cfg config.Config) (*App, error) {
+	dbPath := cfg.DBPath
+	if cfg.DBDriver == config.DBDriverMemory {
+		// Users live in InMemoryUserRepository; the other stores still need
+		// SQLite, so give them a private in-memory database.
+		dbPath = ":memory:"
+	}
+
+	// A Config built in code may leave the pool unset.
+	pool := cfg.DBPool
+	if pool == (database.PoolConfig{}) || dbPath == ":memory:" {
+		pool = database.DefaultSQLitePool
+	}
+
+	log.Println("Initializing database...")
+	db, err := database.NewSQLiteDB(dbPath, database.WithPool(pool))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Database initialized successfully (pool: %s)", pool)
+
+	var userRepoOpts []repository.UserRepositoryOption
+	var dispatcher *webhook.Dispatcher
+	if cfg.WebhookURL != "" {
+		userRepoOpts = append(userRepoOpts, repository.WithOutboxEvents())
+		dispatcher = webhook.NewDispatcher(repository.NewSQLiteOutboxRepository(db), webhook.Config{
+			URL:          cfg.WebhookURL,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			RetryBackoff: cfg.WebhookRetryBackoff,
+			PollInterval: cfg.WebhookPollInterval,
+		})
+	}
+
+	var memoryRepoOpts []repository.InMemoryUserRepositoryOption
+	if cfg.UserDeleteMode == config.UserDeleteModeSoft {
+		userRepoOpts = append(userRepoOpts, repository.WithSoftDelete())
+		memoryRepoOpts = append(memoryRepoOpts, repository.WithInMemorySoftDelete())
+	}
+
+	var userRepo domain.UserRepository
+	if cfg.DBDriver == config.DBDriverMemory {
+		log.Println("⚠️  DB_DRIVER=memory: users are kept in memory and lost on restart")
+		userRepo = repository.NewInMemoryUserRepository(memoryRepoOpts...)
+	} else {
+		userRepo = repository.NewSQLiteUserRepository(db, userRepoOpts...)
+		// A zero config, e.g. a Config built in code, leaves writes
+		// unretried.
+		if cfg.DBRetry.MaxAttempts > 1 {
+			userRepo = repository.NewRetryingUserRepository(userRepo, cfg.DBRetry)
+		}
+	}
+	tokenRepo := repository.NewSQLiteTokenRepository(db)
+	auditRepo := repository.NewSQLiteAuditRepository(db)
+	sessionRepo := repository.NewSQLiteSessionRepository(db)
+	passwordHistoryRepo := repository.NewSQLitePasswordHistoryRepository(db)
+	passwordService, err := newPasswordHasher(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	revocations := security.NewMemoryRevocationStore()
+	// JWTSecret signs new tokens; previous keys only verify tokens issued
+	// before a rotation.
+	jwtKeys := map[string][]byte{cfg.JWTKeyID: []byte(cfg.JWTSecret)}
+	for kid, secret := range cfg.JWTPreviousKeys {
+		if kid != cfg.JWTKeyID {
+			jwtKeys[kid] = []byte(secret)
+		}
+	}
+	jwtService, err := security.NewJWTServiceWithKeys(jwtKeys, cfg.JWTKeyID, cfg.JWTIssuer, cfg.JWTDuration,
+		security.WithLeeway(cfg.JWTLeeway),
+		security.WithAudience(cfg.JWTAudience),
+		security.WithRevocationStore(revocations),
+		security.WithMissingJTIGrace(cfg.AllowTokensWithoutJTI),
+		security.WithRefreshDuration(cfg.RefreshTokenDuration),
+	)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	verificationService := security.NewVerificationService()
+	totpService := security.NewTOTPService(cfg.TOTPIssuer, cfg.TOTPEncryptionKey)
+
+	mailer := cfg.Mailer
+	if mailer == nil {
+		if cfg.SMTPHost != "" {
+			mailer = mail.NewSMTPMailer(mail.Config{
+				Host:     cfg.SMTPHost,
+				Port:     cfg.SMTPPort,
+				Username: cfg.SMTPUsername,
+				Password: cfg.SMTPPassword,
+				From:     cfg.MailFrom,
+			})
+		} else {
+			log.Println("⚠️  SMTP_HOST not set: emails are logged instead of sent")
+			mailer = mail.NewNoopMailer()
+		}
+	}
+
+	authOpts := []usecase.AuthOption{
+		usecase.WithEnumerationProtection(cfg.EnumerationProtection),
+		usecase.WithRequireVerifiedEmail(cfg.RequireVerifiedEmail),
+		usecase.WithLegacyTokenField(cfg.LegacyTokenField),
+		usecase.WithTOTP(totpService),
+		usecase.WithAuditLogger(auditRepo),
+		usecase.WithRegisterHooks(cfg.RegisterHooks...),
+		usecase.WithFatalRegisterHooks(cfg.RegisterHooksFatal),
+		usecase.WithSessions(sessionRepo),
+		usecase.WithMailer(mailer, cfg.PublicBaseURL),
+		usecase.WithLoginBackoff(cfg.LoginBackoff),
+		usecase.WithLoginThrottle(cfg.LoginThrottle),
+		usecase.WithEmailDomainPolicy(cfg.EmailDomainPolicy),
+		usecase.WithPasswordHistory(passwordHistoryRepo, cfg.PasswordHistorySize),
+	}
+	if cfg.GoogleOAuth.ClientID != "" {
+		authOpts = append(authOpts, usecase.WithIdentityProviders(oauth.NewGoogleProvider(cfg.GoogleOAuth)))
+	}
+	if cfg.ConfirmEmailChanges {
+		authOpts = append(authOpts, usecase.WithEmailChangeConfirmation(tokenRepo, verificationService, cfg.VerificationTokenTTL))
+	}
+	authUseCase := usecase.NewAuthUseCase(userRepo, passwordService, jwtService, authOpts...)
+	verificationUseCase := usecase.NewVerificationUseCase(userRepo, tokenRepo, verificationService, cfg.VerificationTokenTTL,
+		usecase.WithVerificationMailer(mailer, cfg.PublicBaseURL),
+	)
+	passwordResetUseCase := usecase.NewPasswordResetUseCase(userRepo, tokenRepo, passwordService, verificationService, cfg.PasswordResetTokenTTL,
+		usecase.WithPasswordResetAuditLogger(auditRepo),
+		usecase.WithPasswordResetMailer(mailer, cfg.PublicBaseURL),
+		usecase.WithPasswordResetHistory(passwordHistoryRepo, cfg.PasswordHistorySize),
+		usecase.WithPasswordResetCooldown(cfg.PasswordResetCooldown, nil),
+	)
+
+	health := httpDelivery.NewHealthChecker(healthCheckTimeout)
+	health.Register("db", true, httpDelivery.PingCheck(db))
+
+	handlerOpts := []httpDelivery.HandlerOption{
+		httpDelivery.WithDatabase(db),
+		httpDelivery.WithHealthChecker(health),
+		httpDelivery.WithAuditLog(usecase.NewAuditUseCase(auditRepo)),
+		httpDelivery.WithOAuthCookieSecure(cfg.AuthCookieSecure),
+		httpDelivery.WithPasswordKDFInfo(cfg.PasswordKDFInfo),
+	}
+	if cfg.CookieAuth {
+		handlerOpts = append(handlerOpts, httpDelivery.WithAuthCookie(httpDelivery.CookieConfig{
+			Name:     cfg.AuthCookieName,
+			Secure:   cfg.AuthCookieSecure,
+			SameSite: cfg.AuthCookieSameSite,
+		}))
+	}
+	handler := httpDelivery.NewHandler(authUseCase, verificationUseCase, passwordResetUseCase, jwtService, handlerOpts...)
+	authCORS := httpDelivery.DefaultCORSConfig()
+	if len(cfg.CORSAllowedOrigins) > 0 {
+		authCORS.AllowedOrigins = cfg.CORSAllowedOrigins
+	}
+	authCORS.AllowCredentials = cfg.CookieAuth
+
+	metrics := httpDelivery.NewMetrics()
+	var internalAllowlist *httpDelivery.IPAllowlist
+	if len(cfg.InternalAllowedCIDRs) > 0 {
+		if internalAllowlist, err = httpDelivery.ParseIPAllowlist(cfg.InternalAllowedCIDRs); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	var trustedProxies *httpDelivery.IPAllowlist
+	if len(cfg.TrustedProxies) > 0 {
+		if trustedProxies, err = httpDelivery.ParseIPAllowlist(cfg.TrustedProxies); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	var idempotency *httpDelivery.IdempotencyStore
+	if cfg.IdempotencyTTL > 0 {
+		idempotency = httpDelivery.NewIdempotencyStore(cfg.IdempotencyTTL)
+	}
+
+	if cfg.DebugHTTP {
+		log.Println("⚠️  DEBUG_HTTP=true: request and response bodies are logged")
+	}
+	router := httpDelivery.NewRouter(handler, jwtService, httpDelivery.RouterConfig{
+		Metrics:            metrics,
+		ExposeMetrics:      cfg.MetricsPort == "",
+		AuthCORS:           authCORS,
+		BasePath:           cfg.BasePath,
+		TrustedProxies:     trustedProxies,
+		InternalAllowlist:  internalAllowlist,
+		ResponseSigningKey: []byte(cfg.ResponseSigningKey),
+		RateLimiter: httpDelivery.NewRateLimiter(httpDelivery.RateLimitConfig{
+			Rate:           cfg.RateLimitRPS,
+			Burst:          cfg.RateLimitBurst,
+			TrustedProxies: trustedProxies,
+		}),
+		RequestTimeout:       cfg.RequestTimeout,
+		SlowRequestThreshold: cfg.SlowRequestThreshold,
+		SlowRequestLogger:    slog.New(slog.NewJSONHandler(os.Stderr, nil)),
+		AuthRealm:            cfg.AuthRealm,
+		CompressionMinSize:   cfg.CompressionMinSize,
+		MaxBodyBytes:         cfg.MaxBodyBytes,
+		Idempotency:          idempotency,
+		ReauthMaxAge:         cfg.ReauthMaxAge,
+		DebugBodies:          cfg.DebugHTTP,
+		IntrospectionAPIKey:  cfg.IntrospectionAPIKey,
+		SecurityHeaders: httpDelivery.SecurityHeadersConfig{
+			HSTSMaxAge:            cfg.HSTSMaxAge,
+			HSTSIncludeSubdomains: true,
+			HSTSPreload:           cfg.HSTSPreload,
+			ContentSecurityPolicy: cfg.ContentSecurityPolicy,
+		},
+	})
+
+	cfg.ServerTimeouts = cfg.ServerTimeouts.WithDefaults()
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           router.SetupRoutes(),
+		ReadTimeout:       cfg.ServerTimeouts.Read,
+		ReadHeaderTimeout: cfg.ServerTimeouts.ReadHeader,
+		WriteTimeout:      cfg.ServerTimeouts.Write,
+		IdleTimeout:       cfg.ServerTimeouts.Idle,
+	}
+	if cfg.TLSCertFile != "" {
+		server.TLSConfig = newTLSConfig(cfg.TLSMinVersion)
+	}
+
+	var metricsServer *http.Server
+	if cfg.MetricsPort != "" {
+		metricsMux := http.NewServeMux()
+		metricsHandler := metrics.Handler().ServeHTTP
+		if internalAllowlist != nil {
+			metricsHandler = httpDelivery.IPAllowlistMiddleware(internalAllowlist, trustedProxies)(metricsHandler)
+		}
+		metricsMux.HandleFunc("/metrics", metricsHandler)
+		metricsServer = &http.Server{
+			Addr:              ":" + cfg.MetricsPort,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	return &App{
+		config:        cfg,
+		db:            db,
+		server:        server,
+		metricsServer: metricsServer,
+		dispatcher:    dispatcher,
+		revocations:   revocations,
+		routes:        router.Routes(),
+	}, nil
+}
+
+// newTLSConfig never allows anything below TLS 1.2. With TLS 1.3 the cipher
+// suites are fixed by the standard library and not configurable.
+func newTLSConfig(minVersion uint16) *tls.Config {
+	if minVersion < tls.VersionTLS12 {
+		minVersion = tls.VersionTLS12
+	}
+	return &tls.Config{MinVersion: minVersion}
+}
+
+func (a *App) Handler() http.Handler {
+	return a.server.Handler
+}
+
+// Run serves until ctx is cancelled, then drains in-flight requests within
+// ShutdownTimeout and closes the database.
+func (a *App) Run(ctx context.Context) error {
+	log.Printf("🚀 Server starting on port %s", a.config.Port)
+	log.Printf("Server timeouts: %s", a.config.ServerTimeouts)
+	if a.config.BasePath != "" {
+		log.Printf("Serving under base path %s", a.config.BasePath)
+	}
+	log.Printf("📚 API endpoints:")
+	for _, route := range a.routes {
+		log.Printf("  - %s", route)
+	}
+	log.Println()
+
+	serverErrors := make(chan error, 2)
+	go func() {
+		if a.config.TLSCertFile != "" {
+			log.Printf("🔒 TLS enabled (minimum version %s)", tls.VersionName(a.server.TLSConfig.MinVersion))
+			serverErrors <- a.server.ListenAndServeTLS(a.config.TLSCertFile, a.config.TLSKeyFile)
+			return
+		}
+		serverErrors <- a.server.ListenAndServe()
+	}()
+
+	if a.metricsServer != nil {
+		log.Printf("📈 Metrics available on port %s at /metrics", a.config.MetricsPort)
+		go func() {
+			serverErrors <- a.metricsServer.ListenAndServe()
+		}()
+	} else {
+		log.Printf("📈 Metrics available at /metrics")
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	var background sync.WaitGroup
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		a.revocations.Run(backgroundCtx, a.config.RevocationCleanup)
+	}()
+
+	if a.dispatcher != nil {
+		log.Printf("📮 Delivering webhook events to %s", a.config.WebhookURL)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			a.dispatcher.Run(backgroundCtx)
+		}()
+	}
+
+	var runErr error
+	select {
+	case err := <-serverErrors:
+		if err != nil && err != http.ErrServerClosed {
+			runErr = err
+		}
+		log.Printf("Server stopped (%v), draining connections (timeout %s)...", err, a.config.ShutdownTimeout)
+	case <-ctx.Done():
+		log.Printf("Shutdown requested, draining connections (timeout %s)...", a.config.ShutdownTimeout)
+	}
+
+	// Whichever way Run ends, in-flight requests finish before the
+	// database closes under them.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown timed out, forcing close: %v", err)
+		a.server.Close()
+	} else {
+		log.Println("HTTP server drained")
+	}
+
+	if a.metricsServer != nil {
+		a.metricsServer.Close()
+	}
+
+	stopBackground()
+	background.Wait()
+
+	log.Println("Closing database...")
+	if err := a.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+	log.Println("Shutdown complete")
+
+	return runErr
+}
+
+func (a *App) Close() error {
+	return a.db.Close()
+}
+
+// newPasswordHasher hashes with the configured algorithm. Both verify either
+// kind of hash, and the Argon2id one rehashes bcrypt hashes at login.
+func newPasswordHasher(cfg config.Config) (domain.PasswordHasher, error) {
+	if cfg.PasswordHashAlgorithm == security.AlgorithmArgon2id {
+		return security.NewArgon2PasswordService(cfg.Argon2)
+	}
+	return security.NewPasswordServiceWithCost(cfg.BcryptCost)
+}
@@ -0,0 +1,453 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/config"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestApp(t *testing.T, overrides ...func(*config.Config)) *App {
+	t.Helper()
+
+	cfg := config.Config{
+		Port:                  "0",
+		DBPath:                ":memory:",
+		JWTSecret:             "test-secret",
+		JWTIssuer:             "test-issuer",
+		JWTDuration:           time.Hour,
+		BcryptCost:            bcrypt.MinCost,
+		ShutdownTimeout:       time.Second,
+		RateLimitRPS:          100,
+		RateLimitBurst:        100,
+		EnumerationProtection: true,
+		LegacyTokenField:      false,
+		VerificationTokenTTL:  time.Hour,
+		PasswordResetTokenTTL: time.Hour,
+		TOTPIssuer:            "SecureRestApi",
+		TOTPEncryptionKey:     "test-totp-key",
+	}
+	for _, override := range overrides {
+		override(&cfg)
+	}
+
+	application, err := NewApp(cfg)
+	if err != nil {
+		t.Fatalf("Failed to build app: %v", err)
+	}
+	t.Cleanup(func() { application.Close() })
+
+	return application
+}
+
+func TestNewApp_BuildsWorkingRouter(t *testing.T) {
+	handler := newTestApp(t).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected health status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register",
+		strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected register status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	var registered struct {
+		Token string `json:"access_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&registered); err != nil {
+		t.Fatalf("Failed to decode register response: %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+registered.Token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected me status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestNewApp_RegisterReportsAllFieldErrors(t *testing.T) {
+	handler := newTestApp(t).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(`{"email":"","password":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if _, ok := body.Fields["email"]; !ok {
+		t.Error("Expected an email field error")
+	}
+	if _, ok := body.Fields["password"]; !ok {
+		t.Error("Expected a password field error")
+	}
+}
+
+func TestNewApp_LogoutRevokesToken(t *testing.T) {
+	handler := newTestApp(t).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register",
+		strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var registered struct {
+		Token string `json:"access_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&registered); err != nil {
+		t.Fatalf("Failed to decode register response: %v", err)
+	}
+
+	doAuthenticated := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+registered.Token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := doAuthenticated(http.MethodPost, "/api/auth/logout"); code != http.StatusOK {
+		t.Fatalf("Expected logout status %d, got %d", http.StatusOK, code)
+	}
+	if code := doAuthenticated(http.MethodGet, "/api/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked token to be rejected with %d, got %d", http.StatusUnauthorized, code)
+	}
+}
+
+func TestNewApp_SessionsListAndRevoke(t *testing.T) {
+	handler := newTestApp(t).Handler()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		var req *http.Request
+		if body != "" {
+			req = httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set("User-Agent", "session-test")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	type tokens struct {
+		Token        string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	credentials := `{"email":"test@example.com","password":"password123"}`
+
+	var first tokens
+	if err := json.NewDecoder(do(http.MethodPost, "/api/auth/register", "", credentials).Body).Decode(&first); err != nil {
+		t.Fatalf("Failed to decode register response: %v", err)
+	}
+	var second tokens
+	if err := json.NewDecoder(do(http.MethodPost, "/api/auth/login", "", credentials).Body).Decode(&second); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+
+	rec := do(http.MethodGet, "/api/auth/sessions", second.Token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var list struct {
+		Sessions []struct {
+			ID        int64  `json:"id"`
+			UserAgent string `json:"user_agent"`
+			Current   bool   `json:"current"`
+		} `json:"data"`
+		Pagination struct {
+			Total int64 `json:"total"`
+		} `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode sessions: %v", err)
+	}
+	if len(list.Sessions) != 2 || list.Pagination.Total != 2 {
+		t.Fatalf("Expected 2 sessions, got %d of %d", len(list.Sessions), list.Pagination.Total)
+	}
+
+	var current, other int64
+	for _, session := range list.Sessions {
+		if session.UserAgent != "session-test" {
+			t.Errorf("Expected user agent to be recorded, got %q", session.UserAgent)
+		}
+		if session.Current {
+			current = session.ID
+		} else {
+			other = session.ID
+		}
+	}
+	if current == 0 || other == 0 {
+		t.Fatalf("Expected exactly one current session, got %+v", list.Sessions)
+	}
+
+	if rec := do(http.MethodDelete, fmt.Sprintf("/api/auth/sessions/%d", other), second.Token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected revoke status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := do(http.MethodDelete, fmt.Sprintf("/api/auth/sessions/%d", other), second.Token, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected second revoke status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/auth/refresh", "", `{"refresh_token":"`+first.RefreshToken+`"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked session's refresh token to be rejected with %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/auth/refresh", "", `{"refresh_token":"`+second.RefreshToken+`"}`); rec.Code != http.StatusOK {
+		t.Errorf("Expected current session to refresh with %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestNewApp_TOTPLoginFlow(t *testing.T) {
+	handler := newTestApp(t).Handler()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder, dst interface{}) {
+		t.Helper()
+		if err := json.NewDecoder(rec.Body).Decode(dst); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	credentials := `{"email":"test@example.com","password":"password123"}`
+	var registered struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(do(http.MethodPost, "/api/auth/register", "", credentials), &registered)
+
+	rec := do(http.MethodPost, "/api/auth/2fa/setup", registered.AccessToken, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected setup status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	decode(rec, &setup)
+
+	codes := security.NewTOTPService("", "")
+	now := time.Now()
+	confirmCode, _ := codes.GenerateCode(setup.Secret, now)
+	rec = do(http.MethodPost, "/api/auth/2fa/confirm", registered.AccessToken, `{"code":"`+confirmCode+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected confirm status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	var challenge struct {
+		AccessToken  string `json:"access_token"`
+		TOTPRequired bool   `json:"totp_required"`
+		PendingToken string `json:"pending_token"`
+	}
+	decode(do(http.MethodPost, "/api/auth/login", "", credentials), &challenge)
+	if !challenge.TOTPRequired || challenge.PendingToken == "" || challenge.AccessToken != "" {
+		t.Fatalf("Expected a TOTP challenge without access token, got %+v", challenge)
+	}
+
+	if rec := do(http.MethodGet, "/api/auth/me", challenge.PendingToken, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected pending token to be rejected with %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	rec = do(http.MethodPost, "/api/auth/2fa/verify", "", `{"pending_token":"`+challenge.PendingToken+`","code":"`+confirmCode+`"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected replayed code to be rejected with %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	nextCode, _ := codes.GenerateCode(setup.Secret, now.Add(30*time.Second))
+	rec = do(http.MethodPost, "/api/auth/2fa/verify", "", `{"pending_token":"`+challenge.PendingToken+`","code":"`+nextCode+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected verify status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var final struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(rec, &final)
+
+	if rec := do(http.MethodGet, "/api/auth/me", final.AccessToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected me status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestNewTLSConfig_MinVersion(t *testing.T) {
+	if got := newTLSConfig(tls.VersionTLS13).MinVersion; got != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %s", tls.VersionName(got))
+	}
+	if got := newTLSConfig(tls.VersionTLS10).MinVersion; got != tls.VersionTLS12 {
+		t.Errorf("Expected minimum to be raised to TLS 1.2, got %s", tls.VersionName(got))
+	}
+}
+
+func TestNewApp_ServerTimeouts(t *testing.T) {
+	application := newTestApp(t, func(cfg *config.Config) {
+		cfg.ServerTimeouts = config.ServerTimeouts{Write: 30 * time.Second}
+	})
+
+	server := application.server
+	if server.WriteTimeout != 30*time.Second {
+		t.Errorf("Expected the configured write timeout, got %s", server.WriteTimeout)
+	}
+	// Unset timeouts keep the defaults rather than disabling the limit.
+	if server.ReadTimeout != config.DefaultServerTimeouts.Read || server.ReadHeaderTimeout != config.DefaultServerTimeouts.ReadHeader || server.IdleTimeout != config.DefaultServerTimeouts.Idle {
+		t.Errorf("Expected default read, read header and idle timeouts, got %s, %s, %s", server.ReadTimeout, server.ReadHeaderTimeout, server.IdleTimeout)
+	}
+}
+
+// freePort returns a port nothing is listening on, for tests that need Run
+// to bind a known address.
+func freePort(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port
+}
+
+func TestApp_RunDrainsInFlightRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		stop func(application *App, cancel context.CancelFunc)
+	}{
+		{"context canceled", func(_ *App, cancel context.CancelFunc) { cancel() }},
+		// A failing metrics listener ends Run too, but must not cut off
+		// the main server's requests.
+		{"metrics server stopped", func(application *App, _ context.CancelFunc) { application.metricsServer.Close() }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			port := freePort(t)
+			application := newTestApp(t, func(cfg *config.Config) {
+				cfg.Port = port
+				cfg.MetricsPort = freePort(t)
+				cfg.ShutdownTimeout = 5 * time.Second
+			})
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			var finished atomic.Bool
+			application.server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.WriteHeader(http.StatusOK)
+				finished.Store(true)
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			runErr := make(chan error, 1)
+			go func() { runErr <- application.Run(ctx) }()
+
+			addr := "127.0.0.1:" + port
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				conn, err := net.Dial("tcp", addr)
+				if err == nil {
+					conn.Close()
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Server never started listening: %v", err)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			status := make(chan int, 1)
+			go func() {
+				resp, err := http.Get("http://" + addr + "/slow")
+				if err != nil {
+					t.Errorf("Expected the in-flight request to complete, got %v", err)
+					status <- 0
+					return
+				}
+				resp.Body.Close()
+				status <- resp.StatusCode
+			}()
+
+			<-started
+			tt.stop(application, cancel)
+
+			select {
+			case err := <-runErr:
+				t.Fatalf("Expected Run to wait for the in-flight request, returned %v", err)
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			close(release)
+			if code := <-status; code != http.StatusOK {
+				t.Errorf("Expected 200 for the drained request, got %d", code)
+			}
+
+			select {
+			case err := <-runErr:
+				if err != nil {
+					t.Fatalf("Expected a clean shutdown, got %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return after the request drained")
+			}
+			if !finished.Load() {
+				t.Error("Expected Run to return only after the handler finished")
+			}
+			if err := application.db.Ping(); err == nil {
+				t.Error("Expected the database to be closed when Run returns")
+			}
+		})
+	}
+}
+
+func TestNewApp_MemoryDriver(t *testing.T) {
+	handler := newTestApp(t, func(cfg *config.Config) { cfg.DBDriver = config.DBDriverMemory }).Handler()
+
+	for _, path := range []string{"/api/auth/register", "/api/auth/login"} {
+		req := httptest.NewRequest(http.MethodPost, path,
+			strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated && rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected success, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+}
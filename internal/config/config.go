@@ -0,0 +1,540 @@
+// Package config reads the service settings from the environment.
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	httpDelivery "github.com/valentinfrappart/securerestapi/internal/delivery/http"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/oauth"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/usecase"
+)
+
+// Config is the typed configuration the application is built from.
+type Config struct {
+	Port                  string
+	DBDriver              string
+	DBPath                string
+	DBPool                database.PoolConfig
+	DBRetry               repository.RetryConfig
+	UserDeleteMode        string
+	JWTSecret             string
+	JWTKeyID              string
+	JWTPreviousKeys       map[string]string
+	JWTIssuer             string
+	JWTAudience           string
+	JWTDuration           time.Duration
+	JWTLeeway             time.Duration
+	RefreshTokenDuration  time.Duration
+	BcryptCost            int
+	PasswordHashAlgorithm string
+	// PasswordHistorySize is how many of a user's last passwords, the
+	// current one included, a new password may not match; zero disables
+	// the check.
+	PasswordHistorySize int
+	Argon2              security.Argon2Params
+	// PasswordKDFInfo adds each account's hash algorithm and parameters
+	// to the admin user endpoints.
+	PasswordKDFInfo bool
+	ShutdownTimeout time.Duration
+	ServerTimeouts  ServerTimeouts
+	RequestTimeout  time.Duration
+	// SlowRequestThreshold logs requests slower than it as JSON on stderr;
+	// zero disables the log.
+	SlowRequestThreshold time.Duration
+	CompressionMinSize   int
+	MaxBodyBytes         int64
+	IdempotencyTTL       time.Duration
+	ReauthMaxAge         time.Duration
+	DebugHTTP            bool
+	ResponseSigningKey   string
+	// IntrospectionAPIKey enables POST /api/auth/introspect for services
+	// presenting it in X-API-Key.
+	IntrospectionAPIKey string
+	RateLimitRPS        float64
+	LoginBackoff        usecase.LoginBackoffConfig
+	LoginThrottle       usecase.LoginThrottleConfig
+	EmailDomainPolicy   usecase.EmailDomainPolicy
+	// GoogleOAuth enables sign-in with Google when ClientID is set.
+	GoogleOAuth           oauth.Config
+	RateLimitBurst        int
+	TrustedProxies        []string
+	EnumerationProtection bool
+	LegacyTokenField      bool
+	RequireVerifiedEmail  bool
+	// ConfirmEmailChanges keeps the old address as the login email
+	// until the new one is confirmed through an emailed link.
+	ConfirmEmailChanges   bool
+	VerificationTokenTTL  time.Duration
+	PasswordResetTokenTTL time.Duration
+	// PasswordResetCooldown is the minimum time between two reset links
+	// for one account; zero disables it.
+	PasswordResetCooldown time.Duration
+	CORSAllowedOrigins    []string
+	BasePath              string
+	MetricsPort           string
+	InternalAllowedCIDRs  []string
+	CookieAuth            bool
+	AuthCookieName        string
+	AuthCookieSecure      bool
+	AuthCookieSameSite    http.SameSite
+	HSTSMaxAge            time.Duration
+	HSTSPreload           bool
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSMinVersion         uint16
+	ContentSecurityPolicy string
+	RevocationCleanup     time.Duration
+	AllowTokensWithoutJTI bool
+	AuthRealm             string
+	TOTPIssuer            string
+	TOTPEncryptionKey     string
+	WebhookURL            string
+	WebhookMaxAttempts    int
+	WebhookRetryBackoff   time.Duration
+	WebhookPollInterval   time.Duration
+	// Emails go through SMTPHost when set and are only logged otherwise.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	// PublicBaseURL prefixes the links sent by email, including any
+	// BasePath the API is served under.
+	PublicBaseURL string
+	// Mailer replaces the SMTP settings, e.g. to capture emails in tests.
+	Mailer domain.Mailer
+	// RegisterHooks lets an embedding program run code after each signup.
+	RegisterHooks      []usecase.RegisterHook
+	RegisterHooksFatal bool
+}
+
+const (
+	DBDriverSQLite = "sqlite"
+	// DBDriverMemory keeps users in process memory, for demos and tests.
+	DBDriverMemory = "memory"
+)
+
+const (
+	UserDeleteModeHard = "hard"
+	// UserDeleteModeSoft keeps deleted users' rows, with deleted_at set,
+	// so an admin can restore them.
+	UserDeleteModeSoft = "soft"
+)
+
+// ServerTimeouts bound how long a connection may take at each stage.
+// ReadHeader closes connections that send their headers too slowly
+// (Slowloris); Write also caps the time to produce a response, so it must
+// stay above RequestTimeout.
+type ServerTimeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// DefaultServerTimeouts are used for any zero field of Config.ServerTimeouts.
+var DefaultServerTimeouts = ServerTimeouts{
+	Read:       15 * time.Second,
+	ReadHeader: 5 * time.Second,
+	Write:      15 * time.Second,
+	Idle:       60 * time.Second,
+}
+
+func (t ServerTimeouts) String() string {
+	return fmt.Sprintf("read %s, read header %s, write %s, idle %s", t.Read, t.ReadHeader, t.Write, t.Idle)
+}
+
+// WithDefaults fills the zero fields from DefaultServerTimeouts.
+func (t ServerTimeouts) WithDefaults() ServerTimeouts {
+	if t.Read <= 0 {
+		t.Read = DefaultServerTimeouts.Read
+	}
+	if t.ReadHeader <= 0 {
+		t.ReadHeader = DefaultServerTimeouts.ReadHeader
+	}
+	if t.Write <= 0 {
+		t.Write = DefaultServerTimeouts.Write
+	}
+	if t.Idle <= 0 {
+		t.Idle = DefaultServerTimeouts.Idle
+	}
+	return t
+}
+
+const defaultJWTSecret = "your-super-secret-key-change-this-in-production"
+
+// placeholderJWTSecrets are the secrets shipped as defaults in this file and
+// in .env.example; anyone can forge tokens signed with them.
+var placeholderJWTSecrets = []string{
+	defaultJWTSecret,
+	"your-super-secret-key-change-this-in-production-min-32-chars",
+}
+
+// validateJWTSecret refuses the placeholder secrets outside ENV=development.
+func validateJWTSecret(secret, env string) error {
+	if env == "development" {
+		return nil
+	}
+	for _, placeholder := range placeholderJWTSecrets {
+		if secret == placeholder {
+			return fmt.Errorf("JWT_SECRET must be changed from the placeholder value (only allowed with ENV=development)")
+		}
+	}
+	return nil
+}
+
+// validateTOTPEncryptionKey requires a TOTP key of its own outside
+// development: one shared with JWT_SECRET could not be rotated with it.
+func validateTOTPEncryptionKey(key, jwtSecret, env string) error {
+	if env == "development" {
+		return nil
+	}
+	if key == "" || key == jwtSecret {
+		return fmt.Errorf("TOTP_ENCRYPTION_KEY is required and must differ from JWT_SECRET (the JWT_SECRET default is only allowed with ENV=development)")
+	}
+	return nil
+}
+
+// Load reads the configuration from the environment, applying defaults,
+// and validates it. The error names the offending variable.
+func Load() (*Config, error) {
+	cfg := &Config{
+		Port:                  getEnv("PORT", "8080"),
+		DBDriver:              getEnv("DB_DRIVER", DBDriverSQLite),
+		DBPath:                getEnv("DB_PATH", "./data/app.db"),
+		UserDeleteMode:        getEnv("USER_DELETE_MODE", UserDeleteModeHard),
+		JWTSecret:             getEnv("JWT_SECRET", defaultJWTSecret),
+		JWTKeyID:              getEnv("JWT_KEY_ID", ""),
+		JWTIssuer:             getEnv("JWT_ISSUER", "secure-rest-api"),
+		JWTAudience:           getEnv("JWT_AUDIENCE", ""),
+		ResponseSigningKey:    getEnv("RESPONSE_SIGNING_KEY", ""),
+		IntrospectionAPIKey:   getEnv("INTROSPECTION_API_KEY", ""),
+		TrustedProxies:        getEnvList("TRUSTED_PROXIES"),
+		EnumerationProtection: getEnv("ENUMERATION_PROTECTION", "true") != "false",
+		RequireVerifiedEmail:  getEnv("REQUIRE_VERIFIED_EMAIL", "false") == "true",
+		ConfirmEmailChanges:   getEnv("EMAIL_CHANGE_CONFIRMATION", "true") != "false",
+		LegacyTokenField:      getEnv("LEGACY_TOKEN_FIELD", "true") != "false",
+		AllowTokensWithoutJTI: getEnv("ALLOW_TOKENS_WITHOUT_JTI", "false") == "true",
+		CORSAllowedOrigins:    getEnvList("CORS_ALLOWED_ORIGINS"),
+		BasePath:              getEnv("BASE_PATH", ""),
+		MetricsPort:           getEnv("METRICS_PORT", ""),
+		InternalAllowedCIDRs:  getEnvList("INTERNAL_ALLOWED_CIDRS"),
+		CookieAuth:            getEnv("USE_COOKIE_AUTH", "false") == "true",
+		AuthCookieName:        getEnv("AUTH_COOKIE_NAME", "access_token"),
+		AuthCookieSecure:      getEnv("AUTH_COOKIE_SECURE", "true") != "false",
+		ContentSecurityPolicy: getEnv("CONTENT_SECURITY_POLICY", ""),
+		HSTSPreload:           getEnv("HSTS_PRELOAD", "false") == "true",
+		PasswordKDFInfo:       getEnv("ADMIN_PASSWORD_KDF_INFO", "false") == "true",
+		TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		WebhookURL:            getEnv("WEBHOOK_URL", ""),
+		SMTPHost:              getEnv("SMTP_HOST", ""),
+		SMTPPort:              getEnv("SMTP_PORT", "587"),
+		SMTPUsername:          getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:          getEnv("SMTP_PASSWORD", ""),
+		MailFrom:              getEnv("MAIL_FROM", "no-reply@localhost"),
+		TOTPIssuer:            getEnv("TOTP_ISSUER", "SecureRestApi"),
+		RegisterHooksFatal:    getEnv("REGISTER_HOOKS_FATAL", "false") == "true",
+		DebugHTTP:             getEnv("DEBUG_HTTP", "false") == "true",
+	}
+	if len(cfg.TrustedProxies) == 0 && getEnv("RATE_LIMIT_TRUST_PROXY", "false") == "true" {
+		log.Println("RATE_LIMIT_TRUST_PROXY is deprecated: X-Forwarded-For is trusted from any client, list your proxies in TRUSTED_PROXIES instead")
+		cfg.TrustedProxies = []string{"0.0.0.0/0", "::/0"}
+	}
+	if len(cfg.InternalAllowedCIDRs) == 0 {
+		cfg.InternalAllowedCIDRs = []string{"127.0.0.0/8", "::1"}
+	}
+	// Rotating JWT_SECRET would make stored TOTP secrets unreadable, so a
+	// dedicated key is required outside development.
+	cfg.TOTPEncryptionKey = getEnv("TOTP_ENCRYPTION_KEY", cfg.JWTSecret)
+	cfg.PublicBaseURL = getEnv("PUBLIC_BASE_URL", "http://localhost:"+cfg.Port)
+	if getEnv("WWW_AUTHENTICATE", "true") != "false" {
+		cfg.AuthRealm = getEnv("AUTH_REALM", cfg.JWTIssuer)
+	}
+
+	env := os.Getenv("ENV")
+	if os.Getenv("JWT_SECRET") == "" && env != "development" {
+		return nil, fmt.Errorf("JWT_SECRET is required (the built-in default is only allowed with ENV=development)")
+	}
+	if err := validateJWTSecret(cfg.JWTSecret, env); err != nil {
+		return nil, err
+	}
+	if err := validateTOTPEncryptionKey(cfg.TOTPEncryptionKey, cfg.JWTSecret, env); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if cfg.JWTPreviousKeys, err = parseJWTKeys(getEnvList("JWT_PREVIOUS_KEYS")); err != nil {
+		return nil, err
+	}
+	if cfg.JWTDuration, err = getEnvDuration("JWT_DURATION", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.RefreshTokenDuration, err = getEnvDuration("REFRESH_TOKEN_DURATION", security.DefaultRefreshTokenDuration); err != nil {
+		return nil, err
+	}
+	if cfg.JWTLeeway, err = getEnvDuration("JWT_LEEWAY", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DBPool, err = loadDBPool(); err != nil {
+		return nil, err
+	}
+	if cfg.DBRetry, err = loadDBRetry(); err != nil {
+		return nil, err
+	}
+	if cfg.BcryptCost, err = getEnvInt("BCRYPT_COST", 10); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHashAlgorithm, err = parsePasswordHashAlgorithm(getEnv("PASSWORD_HASH_ALGORITHM", security.AlgorithmBcrypt)); err != nil {
+		return nil, err
+	}
+	if cfg.Argon2, err = loadArgon2Params(); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHistorySize, err = getEnvIntOrZero("PASSWORD_HISTORY_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RequestTimeout, err = getEnvDuration("REQUEST_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ServerTimeouts, err = loadServerTimeouts(); err != nil {
+		return nil, err
+	}
+	// A handler still running when WriteTimeout passes cannot answer with
+	// the 503 REQUEST_TIMEOUT is meant to produce.
+	if cfg.RequestTimeout >= cfg.ServerTimeouts.Write {
+		return nil, fmt.Errorf("REQUEST_TIMEOUT (%s) must be shorter than WRITE_TIMEOUT (%s)", cfg.RequestTimeout, cfg.ServerTimeouts.Write)
+	}
+	if cfg.SlowRequestThreshold, err = getEnvDurationOrZero("SLOW_REQUEST_THRESHOLD", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CompressionMinSize, err = getEnvIntOrZero("COMPRESSION_MIN_SIZE", httpDelivery.DefaultCompressionMinSize); err != nil {
+		return nil, err
+	}
+	maxBodyBytes, err := getEnvInt("MAX_BODY_BYTES", int(httpDelivery.DefaultMaxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxBodyBytes = int64(maxBodyBytes)
+	if cfg.IdempotencyTTL, err = getEnvDurationOrZero("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.ReauthMaxAge, err = getEnvDurationOrZero("REAUTH_MAX_AGE", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RevocationCleanup, err = getEnvDuration("REVOCATION_CLEANUP_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.VerificationTokenTTL, err = getEnvDuration("VERIFICATION_TOKEN_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordResetTokenTTL, err = getEnvDuration("PASSWORD_RESET_TOKEN_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordResetCooldown, err = getEnvDurationOrZero("PASSWORD_RESET_COOLDOWN", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitRPS, err = getEnvFloat("RATE_LIMIT_RPS", 10); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimitBurst, err = getEnvInt("RATE_LIMIT_BURST", 20); err != nil {
+		return nil, err
+	}
+	if cfg.LoginBackoff.BaseDelay, err = getEnvDurationOrZero("LOGIN_BACKOFF_BASE", 250*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.LoginBackoff.Multiplier, err = getEnvFloat("LOGIN_BACKOFF_MULTIPLIER", 2); err != nil {
+		return nil, err
+	}
+	if cfg.LoginBackoff.Multiplier < 1 {
+		return nil, fmt.Errorf("LOGIN_BACKOFF_MULTIPLIER: must be at least 1, got %v", cfg.LoginBackoff.Multiplier)
+	}
+	if cfg.LoginBackoff.MaxDelay, err = getEnvDuration("LOGIN_BACKOFF_MAX", usecase.DefaultLoginBackoffMax); err != nil {
+		return nil, err
+	}
+	// A delayed failure must still be answered before the request deadline.
+	if cfg.LoginBackoff.BaseDelay > 0 && cfg.LoginBackoff.MaxDelay >= cfg.RequestTimeout {
+		return nil, fmt.Errorf("LOGIN_BACKOFF_MAX (%s) must be shorter than REQUEST_TIMEOUT (%s)", cfg.LoginBackoff.MaxDelay, cfg.RequestTimeout)
+	}
+	cfg.EmailDomainPolicy.Allow = getEnvList("EMAIL_DOMAIN_ALLOWLIST")
+	cfg.EmailDomainPolicy.Deny = getEnvList("EMAIL_DOMAIN_DENYLIST")
+	cfg.GoogleOAuth.ClientID = getEnv("GOOGLE_CLIENT_ID", "")
+	cfg.GoogleOAuth.ClientSecret = getEnv("GOOGLE_CLIENT_SECRET", "")
+	cfg.GoogleOAuth.RedirectURL = getEnv("GOOGLE_REDIRECT_URL", "")
+	if cfg.LoginThrottle.MaxPerIP, err = getEnvIntOrZero("LOGIN_THROTTLE_MAX_PER_IP", 100); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.MaxPerAccount, err = getEnvIntOrZero("LOGIN_THROTTLE_MAX_PER_ACCOUNT", 20); err != nil {
+		return nil, err
+	}
+	if cfg.LoginThrottle.Window, err = getEnvDuration("LOGIN_THROTTLE_WINDOW", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.AuthCookieSameSite, err = parseSameSite(getEnv("AUTH_COOKIE_SAMESITE", "strict")); err != nil {
+		return nil, err
+	}
+	if cfg.HSTSMaxAge, err = getEnvDuration("HSTS_MAX_AGE", 365*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TLSMinVersion, err = parseTLSVersion(getEnv("TLS_MIN_VERSION", "1.2")); err != nil {
+		return nil, err
+	}
+	if cfg.DBDriver != DBDriverSQLite && cfg.DBDriver != DBDriverMemory {
+		return nil, fmt.Errorf("DB_DRIVER: unsupported driver %q", cfg.DBDriver)
+	}
+	if cfg.UserDeleteMode != UserDeleteModeHard && cfg.UserDeleteMode != UserDeleteModeSoft {
+		return nil, fmt.Errorf("USER_DELETE_MODE must be hard or soft, got %q", cfg.UserDeleteMode)
+	}
+	// Webhook events are written in the same SQLite transaction as the user.
+	if cfg.DBDriver == DBDriverMemory && cfg.WebhookURL != "" {
+		return nil, fmt.Errorf("WEBHOOK_URL requires DB_DRIVER=%s", DBDriverSQLite)
+	}
+	if cfg.GoogleOAuth.ClientID != "" && (cfg.GoogleOAuth.ClientSecret == "" || cfg.GoogleOAuth.RedirectURL == "") {
+		return nil, fmt.Errorf("GOOGLE_CLIENT_SECRET and GOOGLE_REDIRECT_URL are required with GOOGLE_CLIENT_ID")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.WebhookMaxAttempts, err = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookRetryBackoff, err = getEnvDuration("WEBHOOK_RETRY_BACKOFF", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookPollInterval, err = getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// parseJWTKeys reads kid:secret pairs. The kid may be empty to accept
+// tokens issued before key IDs were configured.
+func parseJWTKeys(entries []string) (map[string]string, error) {
+	keys := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		kid, secret, ok := strings.Cut(entry, ":")
+		if !ok || secret == "" {
+			// The entry itself is not echoed: it may be a bare secret.
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEYS entry %d must be kid:secret", i+1)
+		}
+		keys[kid] = secret
+	}
+	return keys, nil
+}
+
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("AUTH_COOKIE_SAMESITE must be strict, lax or none, got %q", value)
+	}
+}
+
+func loadDBPool() (database.PoolConfig, error) {
+	pool := database.DefaultSQLitePool
+	var err error
+	if pool.MaxOpenConns, err = getEnvIntOrZero("DB_MAX_OPEN_CONNS", pool.MaxOpenConns); err != nil {
+		return pool, err
+	}
+	if pool.MaxIdleConns, err = getEnvIntOrZero("DB_MAX_IDLE_CONNS", pool.MaxIdleConns); err != nil {
+		return pool, err
+	}
+	if pool.ConnMaxLifetime, err = getEnvDurationOrZero("DB_CONN_MAX_LIFETIME", pool.ConnMaxLifetime); err != nil {
+		return pool, err
+	}
+	return pool, nil
+}
+
+func loadServerTimeouts() (ServerTimeouts, error) {
+	timeouts := DefaultServerTimeouts
+	var err error
+	if timeouts.Read, err = getEnvDuration("READ_TIMEOUT", timeouts.Read); err != nil {
+		return timeouts, err
+	}
+	if timeouts.ReadHeader, err = getEnvDuration("READ_HEADER_TIMEOUT", timeouts.ReadHeader); err != nil {
+		return timeouts, err
+	}
+	if timeouts.Write, err = getEnvDuration("WRITE_TIMEOUT", timeouts.Write); err != nil {
+		return timeouts, err
+	}
+	if timeouts.Idle, err = getEnvDuration("IDLE_TIMEOUT", timeouts.Idle); err != nil {
+		return timeouts, err
+	}
+	return timeouts, nil
+}
+
+func loadDBRetry() (repository.RetryConfig, error) {
+	retry := repository.DefaultRetryConfig
+	var err error
+	if retry.MaxAttempts, err = getEnvInt("DB_RETRY_MAX_ATTEMPTS", retry.MaxAttempts); err != nil {
+		return retry, err
+	}
+	if retry.BaseDelay, err = getEnvDuration("DB_RETRY_BASE_DELAY", retry.BaseDelay); err != nil {
+		return retry, err
+	}
+	if retry.MaxDelay, err = getEnvDuration("DB_RETRY_MAX_DELAY", retry.MaxDelay); err != nil {
+		return retry, err
+	}
+	return retry, nil
+}
+
+func parsePasswordHashAlgorithm(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case security.AlgorithmBcrypt:
+		return security.AlgorithmBcrypt, nil
+	case security.AlgorithmArgon2id:
+		return security.AlgorithmArgon2id, nil
+	default:
+		return "", fmt.Errorf("PASSWORD_HASH_ALGORITHM must be bcrypt or argon2id, got %q", value)
+	}
+}
+
+func loadArgon2Params() (security.Argon2Params, error) {
+	defaults := security.DefaultArgon2Params
+	memory, err := getEnvInt("ARGON2_MEMORY_KIB", int(defaults.Memory))
+	if err != nil {
+		return defaults, err
+	}
+	iterations, err := getEnvInt("ARGON2_ITERATIONS", int(defaults.Iterations))
+	if err != nil {
+		return defaults, err
+	}
+	parallelism, err := getEnvInt("ARGON2_PARALLELISM", int(defaults.Parallelism))
+	if err != nil {
+		return defaults, err
+	}
+	if parallelism > 255 {
+		return defaults, fmt.Errorf("ARGON2_PARALLELISM: must be at most 255, got %d", parallelism)
+	}
+	return security.Argon2Params{Memory: uint32(memory), Iterations: uint32(iterations), Parallelism: uint8(parallelism)}, nil
+}
+
+func parseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("TLS_MIN_VERSION must be 1.2 or 1.3, got %q", value)
+	}
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func TestValidateJWTSecret(t *testing.T) {
+	for _, placeholder := range placeholderJWTSecrets {
+		if err := validateJWTSecret(placeholder, "production"); err == nil {
+			t.Errorf("Expected placeholder %q to be refused", placeholder)
+		}
+		if err := validateJWTSecret(placeholder, ""); err == nil {
+			t.Errorf("Expected placeholder %q to be refused when ENV is unset", placeholder)
+		}
+		if err := validateJWTSecret(placeholder, "development"); err != nil {
+			t.Errorf("Expected placeholder to be allowed in development, got %v", err)
+		}
+	}
+
+	if err := validateJWTSecret("a-real-randomly-generated-secret", "production"); err != nil {
+		t.Errorf("Expected a custom secret to be accepted, got %v", err)
+	}
+}
+
+func TestLoad_TOTPEncryptionKey(t *testing.T) {
+	t.Setenv("ENV", "production")
+	t.Setenv("JWT_SECRET", "a-real-randomly-generated-secret")
+	t.Setenv("DB_DRIVER", "memory")
+
+	unsetEnv(t, "TOTP_ENCRYPTION_KEY")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "TOTP_ENCRYPTION_KEY") {
+		t.Errorf("Expected an error naming TOTP_ENCRYPTION_KEY, got %v", err)
+	}
+	t.Setenv("TOTP_ENCRYPTION_KEY", "a-real-randomly-generated-secret")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "TOTP_ENCRYPTION_KEY") {
+		t.Errorf("Expected a key shared with JWT_SECRET to be refused, got %v", err)
+	}
+
+	t.Setenv("ENV", "development")
+	unsetEnv(t, "TOTP_ENCRYPTION_KEY")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected the JWT_SECRET fallback to be allowed in development, got %v", err)
+	}
+	if cfg.TOTPEncryptionKey != cfg.JWTSecret {
+		t.Errorf("Expected the key to default to JWT_SECRET, got %q", cfg.TOTPEncryptionKey)
+	}
+}
+
+func TestParseJWTKeys(t *testing.T) {
+	keys, err := parseJWTKeys([]string{"k1:first:with-colon", ":legacy"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if keys["k1"] != "first:with-colon" || keys[""] != "legacy" {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	_, err = parseJWTKeys([]string{"bare-secret-value"})
+	if err == nil {
+		t.Fatal("Expected an error for an entry without a kid")
+	}
+	if strings.Contains(err.Error(), "bare-secret-value") {
+		t.Errorf("Expected the error not to echo the entry, got %v", err)
+	}
+}
+
+func TestLoad_Valid(t *testing.T) {
+	t.Setenv("ENV", "production")
+	t.Setenv("JWT_SECRET", "a-real-randomly-generated-secret")
+	t.Setenv("TOTP_ENCRYPTION_KEY", "another-randomly-generated-key")
+	t.Setenv("JWT_DURATION", "15m")
+	t.Setenv("DB_DRIVER", "memory")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.JWTSecret != "a-real-randomly-generated-secret" || cfg.JWTDuration != 15*time.Minute {
+		t.Errorf("Expected the environment to be read, got secret %q and duration %s", cfg.JWTSecret, cfg.JWTDuration)
+	}
+	if cfg.DBDriver != "memory" || len(cfg.CORSAllowedOrigins) != 2 {
+		t.Errorf("Unexpected config: driver %q, origins %v", cfg.DBDriver, cfg.CORSAllowedOrigins)
+	}
+	// Unset settings fall back to their defaults.
+	if cfg.RefreshTokenDuration <= 0 || cfg.TOTPIssuer != "SecureRestApi" {
+		t.Errorf("Expected defaults to be applied, got %+v", cfg)
+	}
+}
+
+func TestLoad_BadDuration(t *testing.T) {
+	t.Setenv("ENV", "development")
+	t.Setenv("JWT_DURATION", "bogus")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "JWT_DURATION") {
+		t.Errorf("Expected an error naming JWT_DURATION, got %v", err)
+	}
+}
+
+func TestLoad_PasswordHashAlgorithm(t *testing.T) {
+	t.Setenv("ENV", "development")
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "Argon2id")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.PasswordHashAlgorithm != security.AlgorithmArgon2id || cfg.Argon2 != security.DefaultArgon2Params {
+		t.Errorf("Expected argon2id with the default parameters, got %q %+v", cfg.PasswordHashAlgorithm, cfg.Argon2)
+	}
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "scrypt")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "PASSWORD_HASH_ALGORITHM") {
+		t.Errorf("Expected an error naming PASSWORD_HASH_ALGORITHM, got %v", err)
+	}
+
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_PARALLELISM", "300")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "ARGON2_PARALLELISM") {
+		t.Errorf("Expected an error naming ARGON2_PARALLELISM, got %v", err)
+	}
+}
+
+func TestLoad_UserDeleteMode(t *testing.T) {
+	t.Setenv("ENV", "development")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.UserDeleteMode != UserDeleteModeHard {
+		t.Errorf("Expected hard deletes by default, got %q", cfg.UserDeleteMode)
+	}
+
+	t.Setenv("USER_DELETE_MODE", "archive")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "USER_DELETE_MODE") {
+		t.Errorf("Expected an error naming USER_DELETE_MODE, got %v", err)
+	}
+}
+
+func TestLoad_GoogleOAuth(t *testing.T) {
+	t.Setenv("ENV", "development")
+	t.Setenv("GOOGLE_CLIENT_ID", "client-id")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "GOOGLE_REDIRECT_URL") {
+		t.Errorf("Expected an error naming the missing settings, got %v", err)
+	}
+
+	t.Setenv("GOOGLE_CLIENT_SECRET", "client-secret")
+	t.Setenv("GOOGLE_REDIRECT_URL", "https://api.example.com/api/auth/oauth/google/callback")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.GoogleOAuth.ClientID != "client-id" || cfg.GoogleOAuth.ClientSecret != "client-secret" || cfg.GoogleOAuth.RedirectURL == "" {
+		t.Errorf("Expected the Google client settings, got %+v", cfg.GoogleOAuth)
+	}
+}
+
+func TestLoad_DBPool(t *testing.T) {
+	t.Setenv("ENV", "development")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.DBPool != database.DefaultSQLitePool {
+		t.Errorf("Expected the SQLite default pool, got %+v", cfg.DBPool)
+	}
+	if cfg.DBRetry != repository.DefaultRetryConfig {
+		t.Errorf("Expected the default retry config, got %+v", cfg.DBRetry)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "0")
+	t.Setenv("DB_MAX_IDLE_CONNS", "5")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+	t.Setenv("DB_RETRY_MAX_ATTEMPTS", "1")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := database.PoolConfig{MaxOpenConns: 0, MaxIdleConns: 5, ConnMaxLifetime: 30 * time.Minute}
+	if cfg.DBPool != expected {
+		t.Errorf("Expected %+v, got %+v", expected, cfg.DBPool)
+	}
+	if cfg.DBRetry.MaxAttempts != 1 {
+		t.Errorf("Expected retries to be disabled, got %+v", cfg.DBRetry)
+	}
+}
+
+func TestLoad_ServerTimeouts(t *testing.T) {
+	t.Setenv("ENV", "development")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.ServerTimeouts != DefaultServerTimeouts {
+		t.Errorf("Expected the default timeouts, got %+v", cfg.ServerTimeouts)
+	}
+
+	t.Setenv("READ_TIMEOUT", "30s")
+	t.Setenv("READ_HEADER_TIMEOUT", "2s")
+	t.Setenv("WRITE_TIMEOUT", "1m")
+	t.Setenv("IDLE_TIMEOUT", "2m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := ServerTimeouts{Read: 30 * time.Second, ReadHeader: 2 * time.Second, Write: time.Minute, Idle: 2 * time.Minute}
+	if cfg.ServerTimeouts != expected {
+		t.Errorf("Expected %+v, got %+v", expected, cfg.ServerTimeouts)
+	}
+
+	for _, tt := range []struct{ key, value string }{
+		{"READ_TIMEOUT", "soon"},
+		{"READ_HEADER_TIMEOUT", "0"},
+		{"WRITE_TIMEOUT", "-5s"},
+		{"IDLE_TIMEOUT", "90"},
+	} {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("Expected an error naming %s for %q, got %v", tt.key, tt.value, err)
+			}
+		})
+	}
+
+	// The request deadline must fire before the server drops the response.
+	t.Setenv("WRITE_TIMEOUT", "10s")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WRITE_TIMEOUT") {
+		t.Errorf("Expected an error naming WRITE_TIMEOUT, got %v", err)
+	}
+	unsetEnv(t, "WRITE_TIMEOUT")
+
+	// Nor may the login backoff outlast the request deadline.
+	t.Setenv("LOGIN_BACKOFF_MAX", "10s")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LOGIN_BACKOFF_MAX") {
+		t.Errorf("Expected an error naming LOGIN_BACKOFF_MAX, got %v", err)
+	}
+	t.Setenv("LOGIN_BACKOFF_BASE", "0")
+	if _, err := Load(); err != nil {
+		t.Errorf("Expected the limit to be ignored with the backoff disabled, got %v", err)
+	}
+}
+
+func TestLoad_MissingSecretInProduction(t *testing.T) {
+	t.Setenv("ENV", "production")
+	unsetEnv(t, "JWT_SECRET")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Errorf("Expected an error naming JWT_SECRET, got %v", err)
+	}
+
+	t.Setenv("ENV", "development")
+	if _, err := Load(); err != nil {
+		t.Errorf("Expected the default secret to be allowed in development, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// LoadEnvFiles loads the profile file base.{APP_ENV} before base. godotenv
+// never overrides a variable that is already set, so the process environment
+// wins over the profile, which wins over the base file. APP_ENV itself may
+// come from either the environment or the base file.
+func LoadEnvFiles(base string) error {
+	profile := os.Getenv("APP_ENV")
+	if profile == "" {
+		if values, err := godotenv.Read(base); err == nil {
+			profile = values["APP_ENV"]
+		}
+	}
+
+	if profile != "" {
+		if err := loadEnvFile(base + "." + profile); err != nil {
+			return err
+		}
+	}
+	return loadEnvFile(base)
+}
+
+// loadEnvFile loads path into the environment. A missing file is fine, but a
+// malformed one is fatal unless ENV=development, where it is only logged.
+func loadEnvFile(path string) error {
+	err := godotenv.Load(path)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Printf("No %s file found, using environment variables or defaults", path)
+		return nil
+	}
+
+	err = fmt.Errorf("failed to parse %s: %w", path, err)
+	if os.Getenv("ENV") == "development" {
+		log.Printf("WARNING: %v; none of its variables were loaded", err)
+		return nil
+	}
+	return err
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s: must be positive, got %s", key, value)
+	}
+	return d, nil
+}
+
+// getEnvDurationOrZero is getEnvDuration for settings that 0 disables.
+func getEnvDurationOrZero(key string, defaultValue time.Duration) (time.Duration, error) {
+	if value := os.Getenv(key); value == "0" || value == "0s" {
+		return 0, nil
+	}
+	return getEnvDuration(key, defaultValue)
+}
+
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("%s: must be a positive number, got %q", key, value)
+	}
+	return f, nil
+}
+
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i <= 0 {
+		return 0, fmt.Errorf("%s: must be a positive integer, got %q", key, value)
+	}
+	return i, nil
+}
+
+// getEnvIntOrZero is getEnvInt for settings that 0 disables.
+func getEnvIntOrZero(key string, defaultValue int) (int, error) {
+	if os.Getenv(key) == "0" {
+		return 0, nil
+	}
+	return getEnvInt(key, defaultValue)
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	return path
+}
+
+func TestLoadEnvFile_MissingFileIsIgnored(t *testing.T) {
+	if err := loadEnvFile(filepath.Join(t.TempDir(), ".env")); err != nil {
+		t.Errorf("Expected missing file to be ignored, got %v", err)
+	}
+}
+
+func TestLoadEnvFile_MalformedFileFails(t *testing.T) {
+	t.Setenv("ENV", "production")
+	path := writeEnvFile(t, "JWT_SECRET=\"unterminated\n")
+
+	err := loadEnvFile(path)
+	if err == nil {
+		t.Fatal("Expected parse error to be surfaced")
+	}
+	if !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected error to name the file, got %v", err)
+	}
+}
+
+func TestLoadEnvFile_MalformedFileToleratedInDevelopment(t *testing.T) {
+	t.Setenv("ENV", "development")
+	path := writeEnvFile(t, "NOT A VALID LINE\n")
+
+	if err := loadEnvFile(path); err != nil {
+		t.Errorf("Expected parse error to be only logged in development, got %v", err)
+	}
+}
+
+func TestLoadEnvFile_LoadsValidFile(t *testing.T) {
+	unsetEnv(t, "SECURE_REST_API_TEST_VAR")
+	path := writeEnvFile(t, "SECURE_REST_API_TEST_VAR=loaded\n")
+
+	if err := loadEnvFile(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := os.Getenv("SECURE_REST_API_TEST_VAR"); got != "loaded" {
+		t.Errorf("Expected variable to be loaded, got %q", got)
+	}
+}
+
+func writeEnvFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return filepath.Join(dir, ".env")
+}
+
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func TestLoadEnvFiles_ProfileOverridesBase(t *testing.T) {
+	unsetEnv(t, "APP_ENV", "SECURE_REST_API_PORT", "SECURE_REST_API_DB")
+	t.Setenv("APP_ENV", "test")
+	base := writeEnvFiles(t, map[string]string{
+		".env":      "SECURE_REST_API_PORT=8080\nSECURE_REST_API_DB=base.db\n",
+		".env.test": "SECURE_REST_API_PORT=9090\n",
+	})
+
+	if err := LoadEnvFiles(base); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := os.Getenv("SECURE_REST_API_PORT"); got != "9090" {
+		t.Errorf("Expected profile value to win, got %q", got)
+	}
+	if got := os.Getenv("SECURE_REST_API_DB"); got != "base.db" {
+		t.Errorf("Expected base value to fill the gaps, got %q", got)
+	}
+}
+
+func TestLoadEnvFiles_ProfileSelectedFromBaseFile(t *testing.T) {
+	unsetEnv(t, "APP_ENV", "SECURE_REST_API_PORT")
+	base := writeEnvFiles(t, map[string]string{
+		".env":      "APP_ENV=test\nSECURE_REST_API_PORT=8080\n",
+		".env.test": "SECURE_REST_API_PORT=9090\n",
+	})
+
+	if err := LoadEnvFiles(base); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := os.Getenv("SECURE_REST_API_PORT"); got != "9090" {
+		t.Errorf("Expected profile named in .env to be loaded, got %q", got)
+	}
+}
+
+func TestLoadEnvFiles_EnvironmentWins(t *testing.T) {
+	unsetEnv(t, "APP_ENV")
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("SECURE_REST_API_PORT", "7070")
+	base := writeEnvFiles(t, map[string]string{
+		".env.test": "SECURE_REST_API_PORT=9090\n",
+	})
+
+	if err := LoadEnvFiles(base); err != nil {
+		t.Fatalf("Expected missing base file to be ignored, got %v", err)
+	}
+
+	if got := os.Getenv("SECURE_REST_API_PORT"); got != "7070" {
+		t.Errorf("Expected process environment to win, got %q", got)
+	}
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"", 24 * time.Hour, false},
+		{"15m", 15 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"24", 0, true},
+		{"soon", 0, true},
+		{"0s", 0, true},
+		{"-5m", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("JWT_DURATION", tt.value)
+		got, err := getEnvDuration("JWT_DURATION", 24*time.Hour)
+
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "JWT_DURATION") {
+				t.Errorf("%q: expected an error naming the variable, got %v", tt.value, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.value, tt.expected, got, err)
+		}
+	}
+}
+
+func TestGetEnvOrZero_ZeroDisables(t *testing.T) {
+	t.Setenv("COMPRESSION_MIN_SIZE", "0")
+	if got, err := getEnvIntOrZero("COMPRESSION_MIN_SIZE", 1024); err != nil || got != 0 {
+		t.Errorf("Expected 0, got %d (%v)", got, err)
+	}
+	t.Setenv("COMPRESSION_MIN_SIZE", "-1")
+	if _, err := getEnvIntOrZero("COMPRESSION_MIN_SIZE", 1024); err == nil {
+		t.Error("Expected a negative value to be rejected")
+	}
+
+	t.Setenv("IDEMPOTENCY_TTL", "0")
+	if got, err := getEnvDurationOrZero("IDEMPOTENCY_TTL", time.Hour); err != nil || got != 0 {
+		t.Errorf("Expected 0, got %s (%v)", got, err)
+	}
+	t.Setenv("IDEMPOTENCY_TTL", "")
+	if got, err := getEnvDurationOrZero("IDEMPOTENCY_TTL", time.Hour); err != nil || got != time.Hour {
+		t.Errorf("Expected the default, got %s (%v)", got, err)
+	}
+}
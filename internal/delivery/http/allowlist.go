@@ -0,0 +1,69 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPAllowlist matches client addresses against a set of networks. Plain IPs
+// are accepted and treated as single-host networks.
+type IPAllowlist struct {
+	networks []*net.IPNet
+}
+
+func ParseIPAllowlist(entries []string) (*IPAllowlist, error) {
+	list := &IPAllowlist{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		list.networks = append(list.networks, network)
+	}
+	return list, nil
+}
+
+func (l *IPAllowlist) Contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPAllowlistMiddleware answers 403 to clients outside list. It guards
+// internal endpoints such as /metrics that must not require a token but
+// should not be public either.
+func IPAllowlistMiddleware(list *IPAllowlist, trustedProxies *IPAllowlist) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !list.Contains(clientIP(r, trustedProxies)) {
+				respondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIPAllowlist(t *testing.T) {
+	list, err := ParseIPAllowlist([]string{"10.0.0.0/8", " 192.0.2.7 ", "::1", ""})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"::1", true},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := list.Contains(tt.addr); got != tt.expected {
+			t.Errorf("Contains(%q): expected %v, got %v", tt.addr, tt.expected, got)
+		}
+	}
+
+	if _, err := ParseIPAllowlist([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected invalid CIDR to be rejected")
+	}
+	if _, err := ParseIPAllowlist([]string{"localhost"}); err == nil {
+		t.Error("Expected invalid IP to be rejected")
+	}
+}
+
+func TestSetupRoutes_InternalAllowlist(t *testing.T) {
+	allowlist, _ := ParseIPAllowlist([]string{"10.0.0.0/8"})
+	handler := newTestRouter(RouterConfig{
+		Metrics:           NewMetrics(),
+		ExposeMetrics:     true,
+		InternalAllowlist: allowlist,
+	})
+
+	tests := []struct {
+		path       string
+		remoteAddr string
+		expected   int
+	}{
+		{"/metrics", "10.0.0.5:1234", http.StatusOK},
+		{"/metrics", "192.0.2.1:1234", http.StatusForbidden},
+		{"/health/detailed", "10.0.0.5:1234", http.StatusOK},
+		{"/health/detailed", "192.0.2.1:1234", http.StatusForbidden},
+		{"/health", "192.0.2.1:1234", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expected {
+			t.Errorf("%s from %s: expected status %d, got %d", tt.path, tt.remoteAddr, tt.expected, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+)
+
+// APIKeyHeader carries the key other services present to service-only
+// endpoints such as token introspection.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware answers 401 unless the request's X-API-Key header matches
+// key. The comparison takes the same time wherever the values differ.
+func APIKeyMiddleware(key string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get(APIKeyHeader)
+			if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(key)) != 1 {
+				log.Printf("Rejected request without a valid API key from %s", r.RemoteAddr)
+				respondWithError(w, http.StatusUnauthorized, "Invalid API key")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
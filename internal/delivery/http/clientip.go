@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address of the client behind r. X-Forwarded-For and
+// X-Real-IP are only honored when the connection comes from one of
+// trustedProxies (CIDRs or plain IPs): anyone else could set them to dodge
+// the rate limiter or forge audit entries. An invalid list trusts no one.
+func ClientIP(r *http.Request, trustedProxies []string) string {
+	trusted, err := ParseIPAllowlist(trustedProxies)
+	if err != nil {
+		trusted = nil
+	}
+	return clientIP(r, trusted)
+}
+
+// clientIP walks X-Forwarded-For from the right, skipping the hops added by
+// trusted proxies, and returns the first address they did not add. The
+// leftmost entries are client-controlled, so they are only reached when
+// every hop after them is trusted.
+func clientIP(r *http.Request, trustedProxies *IPAllowlist) string {
+	remote := remoteIP(r)
+	if trustedProxies == nil || !trustedProxies.Contains(remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			client = hop
+			if !trustedProxies.Contains(hop) {
+				break
+			}
+		}
+		return client
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
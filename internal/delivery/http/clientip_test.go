@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "2001:db8::1"}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		trusted      []string
+		expected     string
+	}{
+		{"no proxy configured", "203.0.113.7:1234", "198.51.100.1", "", nil, "203.0.113.7"},
+		{"untrusted source spoofs X-Forwarded-For", "203.0.113.7:1234", "198.51.100.1", "", proxies, "203.0.113.7"},
+		{"untrusted source spoofs X-Real-IP", "203.0.113.7:1234", "", "198.51.100.1", proxies, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:1234", "198.51.100.1", "", proxies, "198.51.100.1"},
+		{"trusted IPv6 proxy", "[2001:db8::1]:1234", "198.51.100.1", "", proxies, "198.51.100.1"},
+		{"trusted proxy chain", "10.0.0.1:1234", "198.51.100.1, 10.0.0.2", "", proxies, "198.51.100.1"},
+		{"client prepends a fake hop", "10.0.0.1:1234", "192.0.2.99, 198.51.100.1", "", proxies, "198.51.100.1"},
+		{"garbage hop", "10.0.0.1:1234", "not-an-ip, 198.51.100.1", "", proxies, "198.51.100.1"},
+		{"trusted proxy with X-Real-IP", "10.0.0.1:1234", "", "198.51.100.1", proxies, "198.51.100.1"},
+		{"trusted proxy without headers", "10.0.0.1:1234", "", "", proxies, "10.0.0.1"},
+		{"invalid proxy list trusts no one", "10.0.0.1:1234", "198.51.100.1", "", []string{"10.0.0.0/33"}, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+
+		if got := ClientIP(req, tt.trusted); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func largeJSONHandler(w http.ResponseWriter, r *http.Request) {
+	users := make([]map[string]string, 100)
+	for i := range users {
+		users[i] = map[string]string{"email": "user@example.com", "role": "user"}
+	}
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+func TestCompressionMiddleware_GzipsLargeResponse(t *testing.T) {
+	handler := CompressionMiddleware(DefaultCompressionMinSize)(largeJSONHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	req.Header.Set("Accept-Encoding", "br;q=1.0, gzip;q=0.8")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected Content-Type to be preserved, got %q", got)
+	}
+
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip body, got %v", err)
+	}
+	var users []map[string]string
+	if err := json.NewDecoder(reader).Decode(&users); err != nil {
+		t.Fatalf("Failed to decode decompressed body: %v", err)
+	}
+	if len(users) != 100 {
+		t.Errorf("Expected 100 users, got %d", len(users))
+	}
+}
+
+func TestCompressionMiddleware_PassesThroughWithoutAcceptEncoding(t *testing.T) {
+	handler := CompressionMiddleware(DefaultCompressionMinSize)(largeJSONHandler)
+
+	for _, acceptEncoding := range []string{"", "br", "gzip;q=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("%q: expected no Content-Encoding, got %q", acceptEncoding, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%q: expected Vary: Accept-Encoding, got %q", acceptEncoding, got)
+		}
+		var users []map[string]string
+		if err := json.NewDecoder(rec.Body).Decode(&users); err != nil {
+			t.Errorf("%q: expected plain JSON, got %v", acceptEncoding, err)
+		}
+	}
+}
+
+func TestCompressionMiddleware_SkipsSmallResponse(t *testing.T) {
+	handler := CompressionMiddleware(DefaultCompressionMinSize)(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expected no Content-Encoding, got %q", got)
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"status":"created"}` {
+		t.Errorf("Expected body to pass through, got %s", got)
+	}
+}
+
+func TestCompressionMiddleware_DoesNotDoubleCompress(t *testing.T) {
+	payload := strings.Repeat("already encoded ", 200)
+	handler := CompressionMiddleware(DefaultCompressionMinSize)(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, payload)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "br" {
+		t.Errorf("Expected Content-Encoding to stay br, got %q", got)
+	}
+	if rec.Body.String() != payload {
+		t.Error("Expected the pre-encoded body to pass through unchanged")
+	}
+}
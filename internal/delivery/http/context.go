@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// Each key is its own unexported type, so no other package can read or
+// overwrite these values by picking the same name.
+type (
+	userIDKey     struct{}
+	emailKey      struct{}
+	roleKey       struct{}
+	claimsKey     struct{}
+	cookieAuthKey struct{}
+)
+
+// contextWithAuth stores what the auth middleware learned from a valid
+// token. viaCookie is true when it came from the auth cookie rather than
+// the Authorization header.
+func contextWithAuth(ctx context.Context, claims *security.Claims, viaCookie bool) context.Context {
+	ctx = context.WithValue(ctx, userIDKey{}, claims.UserID)
+	ctx = context.WithValue(ctx, emailKey{}, claims.Email)
+	ctx = context.WithValue(ctx, roleKey{}, claims.Role)
+	ctx = context.WithValue(ctx, claimsKey{}, claims)
+	return context.WithValue(ctx, cookieAuthKey{}, viaCookie)
+}
+
+// UserIDFromContext returns the authenticated user's id; ok is false on
+// routes without the auth middleware.
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int64)
+	return userID, ok
+}
+
+// EmailFromContext returns the email in the presenting token, which may lag
+// behind a change made since it was issued.
+func EmailFromContext(ctx context.Context) (string, bool) {
+	email, ok := ctx.Value(emailKey{}).(string)
+	return email, ok
+}
+
+func roleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleKey{}).(string)
+	return role, ok
+}
+
+func claimsFromContext(ctx context.Context) (*security.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*security.Claims)
+	return claims, ok
+}
+
+func authenticatedViaCookie(ctx context.Context) bool {
+	viaCookie, _ := ctx.Value(cookieAuthKey{}).(bool)
+	return viaCookie
+}
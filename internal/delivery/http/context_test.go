@@ -0,0 +1,49 @@
+package http
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func TestContextAccessors_Present(t *testing.T) {
+	claims := &security.Claims{UserID: 42, Email: "test@example.com", Role: domain.RoleAdmin}
+	ctx := contextWithAuth(context.Background(), claims, true)
+
+	if userID, ok := UserIDFromContext(ctx); !ok || userID != 42 {
+		t.Errorf("Expected user id 42, got %d (%v)", userID, ok)
+	}
+	if email, ok := EmailFromContext(ctx); !ok || email != "test@example.com" {
+		t.Errorf("Expected test@example.com, got %q (%v)", email, ok)
+	}
+	if role, ok := roleFromContext(ctx); !ok || role != domain.RoleAdmin {
+		t.Errorf("Expected role admin, got %q (%v)", role, ok)
+	}
+	if got, ok := claimsFromContext(ctx); !ok || got != claims {
+		t.Errorf("Expected the stored claims, got %+v (%v)", got, ok)
+	}
+	if !authenticatedViaCookie(ctx) {
+		t.Error("Expected cookie auth to be recorded")
+	}
+}
+
+func TestContextAccessors_Absent(t *testing.T) {
+	// Another package's key with the same name must not be picked up.
+	type otherKey string
+	ctx := context.WithValue(context.Background(), otherKey("userID"), int64(42))
+
+	if userID, ok := UserIDFromContext(ctx); ok || userID != 0 {
+		t.Errorf("Expected no user id, got %d", userID)
+	}
+	if email, ok := EmailFromContext(ctx); ok || email != "" {
+		t.Errorf("Expected no email, got %q", email)
+	}
+	if _, ok := claimsFromContext(ctx); ok {
+		t.Error("Expected no claims")
+	}
+	if authenticatedViaCookie(ctx) {
+		t.Error("Expected no cookie auth")
+	}
+}
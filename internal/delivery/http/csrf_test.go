@@ -0,0 +1,82 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// doCSRFRequest authenticates with the access_token cookie unless bearer is
+// set, and sends csrfCookie and csrfHeaderValue when not empty.
+func doCSRFRequest(t *testing.T, method string, bearer bool, csrfCookie, csrfHeaderValue string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	token, err := jwtService.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	handler := applyMiddlewares(okHandler, CookieAuthMiddleware(jwtService, "access_token"), CSRFMiddleware)
+
+	req := httptest.NewRequest(method, "/api/auth/logout", nil)
+	if bearer {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	}
+	if csrfCookie != "" {
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: csrfCookie})
+	}
+	if csrfHeaderValue != "" {
+		req.Header.Set(csrfHeader, csrfHeaderValue)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestCSRFMiddleware_MissingToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+	}{
+		{"no token", "", ""},
+		{"header only", "", "forged"},
+		{"cookie only", "abc123", ""},
+		{"mismatch", "abc123", "abc124"},
+	}
+
+	for _, tt := range tests {
+		rec := doCSRFRequest(t, http.MethodPost, false, tt.cookie, tt.header)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusForbidden, rec.Code)
+			continue
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != csrfTokenInvalidCode {
+			t.Errorf("%s: expected code %q, got %+v (%v)", tt.name, csrfTokenInvalidCode, body, err)
+		}
+	}
+}
+
+func TestCSRFMiddleware_MatchingToken(t *testing.T) {
+	if rec := doCSRFRequest(t, http.MethodPost, false, "abc123", "abc123"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestCSRFMiddleware_Exemptions(t *testing.T) {
+	if rec := doCSRFRequest(t, http.MethodPost, true, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected bearer auth to be exempt, got %d", rec.Code)
+	}
+	if rec := doCSRFRequest(t, http.MethodGet, false, "", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected safe methods to be exempt, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// debugBodyLimit is how much of a body DebugBodyLoggingMiddleware logs.
+const debugBodyLimit = 64 << 10
+
+const redactedValue = "[REDACTED]"
+
+// DebugBodyLoggingMiddleware logs request and response bodies for
+// integration debugging. The request body is read and put back so the
+// handler still gets all of it, read errors included. JSON fields named
+// password, token or secret, or ending in _password, _token or _secret,
+// are redacted; other bodies are only logged by size. When disabled it
+// returns next unchanged.
+func DebugBodyLoggingMiddleware(enabled bool) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if !enabled {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				body, err := io.ReadAll(r.Body)
+				r.Body.Close()
+				var rest io.Reader = bytes.NewReader(body)
+				if err != nil {
+					// A body over MaxBodyBytes must still fail in the handler.
+					rest = io.MultiReader(rest, &errorReader{err: err})
+				}
+				r.Body = io.NopCloser(rest)
+				log.Printf("DEBUG_HTTP %s %s request: %s", r.Method, r.URL.Path, redactBody(body))
+			}
+
+			recorder := &bodyCapture{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			log.Printf("DEBUG_HTTP %s %s response %d: %s", r.Method, r.URL.Path, recorder.status, redactBody(recorder.body.Bytes()))
+		}
+	}
+}
+
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// bodyCapture passes the response through and keeps its first
+// debugBodyLimit bytes.
+type bodyCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bodyCapture) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyCapture) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if room := debugBodyLimit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(len(b), room)])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// redactBody renders body for the log with its secrets masked.
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return "(empty)"
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "(non-JSON or truncated body, " + strconv.Itoa(len(body)) + " bytes)"
+	}
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return "(unprintable body)"
+	}
+	return string(redacted)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSecretField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+func isSecretField(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range []string{"password", "token", "secret"} {
+		if key == name || strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
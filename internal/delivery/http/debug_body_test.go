@@ -0,0 +1,88 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the standard logger for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+func TestDebugBodyLoggingMiddleware_RedactsSecrets(t *testing.T) {
+	logs := captureLog(t)
+	body := `{"email":"test@example.com","password":"hunter2-secret"}`
+
+	var seen string
+	handler := DebugBodyLoggingMiddleware(true)(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		seen = string(raw)
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token":  "eyJ.header.sig",
+			"refresh_token": "eyJ.refresh.sig",
+			"user":          map[string]string{"email": "test@example.com"},
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body)))
+
+	if seen != body {
+		t.Errorf("Expected the handler to read the full body, got %q", seen)
+	}
+	if !strings.Contains(rec.Body.String(), "eyJ.header.sig") {
+		t.Errorf("Expected the response to reach the client untouched, got %s", rec.Body.String())
+	}
+
+	output := logs.String()
+	for _, secret := range []string{"hunter2-secret", "eyJ.header.sig", "eyJ.refresh.sig"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %q to be redacted, got %s", secret, output)
+		}
+	}
+	if !strings.Contains(output, `"email":"test@example.com"`) || !strings.Contains(output, redactedValue) {
+		t.Errorf("Expected the bodies to be logged with redactions, got %s", output)
+	}
+}
+
+func TestDebugBodyLoggingMiddleware_KeepsReadErrors(t *testing.T) {
+	captureLog(t)
+
+	var readErr error
+	handler := MaxBodyBytesMiddleware(8)(DebugBodyLoggingMiddleware(true)(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"too long"}`))
+	req.ContentLength = -1
+	handler(httptest.NewRecorder(), req)
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("Expected the handler to see *http.MaxBytesError, got %v", readErr)
+	}
+}
+
+func TestDebugBodyLoggingMiddleware_Disabled(t *testing.T) {
+	logs := captureLog(t)
+
+	handler := DebugBodyLoggingMiddleware(false)(okHandler)
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"password":"x"}`)))
+
+	if logs.Len() != 0 {
+		t.Errorf("Expected nothing logged when disabled, got %s", logs.String())
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/valentinfrappart/securerestapi/api"
+)
+
+// OpenAPISpec serves the embedded OpenAPI document.
+func (h *Handler) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(api.OpenAPISpec)
+}
+
+// swaggerUIVersion pins the Swagger UI assets loaded by /docs.
+const swaggerUIVersion = "5.17.14"
+
+// docsPage renders Swagger UI for the spec. The spec URL is relative so the
+// page keeps working under BASE_PATH.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>SecureRestApi - API documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: "openapi.yaml", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// Docs serves a Swagger UI page for the OpenAPI spec.
+func (h *Handler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(docsPage))
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/api"
+	"gopkg.in/yaml.v3"
+)
+
+type openAPIDocument struct {
+	OpenAPI    string                                 `yaml:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `yaml:"paths"`
+	Components struct {
+		SecuritySchemes map[string]struct {
+			Type   string `yaml:"type"`
+			Scheme string `yaml:"scheme"`
+		} `yaml:"securitySchemes"`
+	} `yaml:"components"`
+}
+
+type openAPIOperation struct {
+	Security  []map[string][]string `yaml:"security"`
+	Responses map[string]yaml.Node  `yaml:"responses"`
+}
+
+func TestOpenAPISpec_IsValidYAML(t *testing.T) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(api.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("Embedded spec is not valid YAML: %v", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	for path, method := range map[string]string{
+		"/health":            "get",
+		"/version":           "get",
+		"/api/auth/register": "post",
+		"/api/auth/login":    "post",
+		"/api/auth/me":       "get",
+	} {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("Expected the spec to document %s %s", strings.ToUpper(method), path)
+		}
+	}
+
+	bearer := doc.Components.SecuritySchemes["bearerAuth"]
+	if bearer.Type != "http" || bearer.Scheme != "bearer" {
+		t.Errorf("Expected an http bearer security scheme, got %+v", bearer)
+	}
+	if security := doc.Paths["/api/auth/me"]["get"].Security; len(security) != 1 || security[0]["bearerAuth"] == nil {
+		t.Errorf("Expected /api/auth/me to require bearerAuth, got %v", security)
+	}
+}
+
+func TestOpenAPISpec_RefsResolve(t *testing.T) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(api.OpenAPISpec, &root); err != nil {
+		t.Fatalf("Embedded spec is not valid YAML: %v", err)
+	}
+
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		for i := 0; i+1 < len(node.Content); i++ {
+			if node.Kind == yaml.MappingNode && node.Content[i].Value == "$ref" {
+				ref := node.Content[i+1].Value
+				if lookupRef(&root, ref) == nil {
+					t.Errorf("Unresolved $ref %s", ref)
+				}
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(&root)
+}
+
+// lookupRef follows a local "#/a/b" reference through mapping nodes.
+func lookupRef(root *yaml.Node, ref string) *yaml.Node {
+	node := root.Content[0]
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+func TestSetupRoutes_ServesOpenAPISpec(t *testing.T) {
+	handler := newTestRouter(RouterConfig{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/yaml" {
+		t.Errorf("Expected Content-Type application/yaml, got %q", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), api.OpenAPISpec) {
+		t.Error("Expected the embedded spec to be served unchanged")
+	}
+}
+
+func TestSetupRoutes_ServesDocs(t *testing.T) {
+	handler := newTestRouter(RouterConfig{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected an HTML page, got %q", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `url: "openapi.yaml"`) {
+		t.Error("Expected the page to load the spec from a relative URL")
+	}
+}
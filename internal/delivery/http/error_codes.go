@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// errorCodes gives each domain error the machine-readable code sent in the
+// "code" field of error responses. Clients branch on these, so once
+// published a code must not change even if the message does.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{domain.ErrUserNotFound, "user_not_found"},
+	{domain.ErrUserAlreadyExists, "user_exists"},
+	{domain.ErrUsernameTaken, "username_taken"},
+	{domain.ErrInvalidCredentials, "invalid_credentials"},
+	{domain.ErrInvalidToken, "invalid_token"},
+	{domain.ErrTokenExpired, "token_expired"},
+	{domain.ErrTokenNotYetValid, "token_not_yet_valid"},
+	{domain.ErrTokenMalformed, "token_malformed"},
+	{domain.ErrTokenInvalidIssuer, "token_invalid_issuer"},
+	{domain.ErrTokenInvalidAudience, "token_invalid_audience"},
+	{domain.ErrTokenRevoked, "token_revoked"},
+	{domain.ErrTokenMissingID, "token_missing_id"},
+	{domain.ErrEmailNotVerified, "email_not_verified"},
+	{domain.ErrEmailDomainNotAllowed, "email_domain_not_allowed"},
+	{domain.ErrEmailAlreadyVerified, "email_already_verified"},
+	{domain.ErrNoPendingEmail, "no_pending_email"},
+	{domain.ErrOneTimeTokenInvalid, "one_time_token_invalid"},
+	{domain.ErrOneTimeTokenExpired, "one_time_token_expired"},
+	{domain.ErrOneTimeTokenUsed, "one_time_token_used"},
+	{domain.ErrWeakPassword, "weak_password"},
+	{domain.ErrPasswordReused, "password_reused"},
+	{domain.ErrInvalidTOTPCode, "invalid_totp_code"},
+	{domain.ErrTOTPAlreadyEnabled, "totp_already_enabled"},
+	{domain.ErrTOTPNotEnrolled, "totp_not_enrolled"},
+	{domain.ErrSessionNotFound, "session_not_found"},
+	{domain.ErrCannotDeleteSelf, "cannot_delete_self"},
+	{domain.ErrReauthRequired, "reauth_required"},
+	{domain.ErrLoginThrottled, "login_throttled"},
+	{domain.ErrUnknownIdentityProvider, "oauth_provider_unknown"},
+	{domain.ErrOAuthStateInvalid, "oauth_state_invalid"},
+	{domain.ErrOAuthFailed, "oauth_failed"},
+	{domain.ErrTimeout, "request_timeout"},
+}
+
+// statusErrorCodes is the fallback for errors that are not domain errors,
+// such as a malformed body or a rate limit.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+const validationFailedCode = "validation_failed"
+
+// errorCode returns the code for err, or the generic code for status when
+// err is not a known domain error.
+func errorCode(err error, status int) string {
+	for _, entry := range errorCodes {
+		if errors.Is(err, entry.err) {
+			return entry.code
+		}
+	}
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	return "error"
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// These codes are part of the API contract: a failing case here means a
+// client-visible change, not a test to update.
+func TestErrorCode_DomainErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{domain.ErrUserNotFound, "user_not_found"},
+		{domain.ErrUserAlreadyExists, "user_exists"},
+		{domain.ErrUsernameTaken, "username_taken"},
+		{domain.ErrInvalidCredentials, "invalid_credentials"},
+		{domain.ErrInvalidToken, "invalid_token"},
+		{domain.ErrTokenExpired, "token_expired"},
+		{domain.ErrTokenNotYetValid, "token_not_yet_valid"},
+		{domain.ErrTokenMalformed, "token_malformed"},
+		{domain.ErrTokenInvalidIssuer, "token_invalid_issuer"},
+		{domain.ErrTokenInvalidAudience, "token_invalid_audience"},
+		{domain.ErrTokenRevoked, "token_revoked"},
+		{domain.ErrTokenMissingID, "token_missing_id"},
+		{domain.ErrEmailNotVerified, "email_not_verified"},
+		{domain.ErrEmailDomainNotAllowed, "email_domain_not_allowed"},
+		{domain.ErrEmailAlreadyVerified, "email_already_verified"},
+		{domain.ErrNoPendingEmail, "no_pending_email"},
+		{domain.ErrOneTimeTokenInvalid, "one_time_token_invalid"},
+		{domain.ErrOneTimeTokenExpired, "one_time_token_expired"},
+		{domain.ErrOneTimeTokenUsed, "one_time_token_used"},
+		{domain.ErrWeakPassword, "weak_password"},
+		{domain.ErrPasswordReused, "password_reused"},
+		{domain.ErrInvalidTOTPCode, "invalid_totp_code"},
+		{domain.ErrTOTPAlreadyEnabled, "totp_already_enabled"},
+		{domain.ErrTOTPNotEnrolled, "totp_not_enrolled"},
+		{domain.ErrSessionNotFound, "session_not_found"},
+		{domain.ErrCannotDeleteSelf, "cannot_delete_self"},
+		{domain.ErrReauthRequired, "reauth_required"},
+		{domain.ErrLoginThrottled, "login_throttled"},
+		{domain.ErrUnknownIdentityProvider, "oauth_provider_unknown"},
+		{domain.ErrOAuthStateInvalid, "oauth_state_invalid"},
+		{domain.ErrOAuthFailed, "oauth_failed"},
+		{domain.ErrTimeout, "request_timeout"},
+	}
+
+	if len(tests) != len(errorCodes) {
+		t.Errorf("Expected every mapped error to be covered: %d cases for %d codes", len(tests), len(errorCodes))
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err, http.StatusBadRequest); got != tt.code {
+			t.Errorf("%v: expected code %q, got %q", tt.err, tt.code, got)
+		}
+	}
+}
+
+func TestErrorCode_WrappedError(t *testing.T) {
+	err := fmt.Errorf("%w: token is expired", domain.ErrTokenExpired)
+	if got := errorCode(err, http.StatusUnauthorized); got != "token_expired" {
+		t.Errorf("Expected token_expired, got %q", got)
+	}
+}
+
+func TestErrorCode_FallsBackToStatus(t *testing.T) {
+	if got := errorCode(errors.New("boom"), http.StatusInternalServerError); got != "internal_error" {
+		t.Errorf("Expected internal_error, got %q", got)
+	}
+	if got := errorCode(nil, http.StatusTooManyRequests); got != "too_many_requests" {
+		t.Errorf("Expected too_many_requests, got %q", got)
+	}
+	if got := errorCode(nil, http.StatusTeapot); got != "error" {
+		t.Errorf("Expected error, got %q", got)
+	}
+}
+
+func TestLogin_InvalidCredentialsCode(t *testing.T) {
+	handler := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"nobody@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Code != "invalid_credentials" || body.Error != "Invalid email or password" {
+		t.Errorf("Expected invalid_credentials with the message kept, got %+v", body)
+	}
+}
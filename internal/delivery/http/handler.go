@@ -1,46 +1,207 @@
 package http
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
 	"net/http"
+	"path"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/valentinfrappart/securerestapi/internal/domain"
 	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
 	"github.com/valentinfrappart/securerestapi/internal/usecase"
+	"github.com/valentinfrappart/securerestapi/internal/version"
 )
 
 type Handler struct {
-	authUseCase *usecase.AuthUseCase
-	jwtService  *security.JWTService
+	authUseCase          *usecase.AuthUseCase
+	verificationUseCase  *usecase.VerificationUseCase
+	passwordResetUseCase *usecase.PasswordResetUseCase
+	auditUseCase         *usecase.AuditUseCase
+	jwtService           *security.JWTService
+	authCookie           CookieConfig
+	oauthCookieSecure    bool
+	db                   Pinger
+	health               *HealthChecker
+	startedAt            time.Time
+	version              string
+	passwordKDFInfo      bool
 }
 
-func NewHandler(authUseCase *usecase.AuthUseCase, jwtService *security.JWTService) *Handler {
-	return &Handler{
-		authUseCase: authUseCase,
-		jwtService:  jwtService,
+// Pinger is satisfied by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+const readinessTimeout = 2 * time.Second
+
+// CookieConfig describes the HttpOnly cookie carrying the access token for
+// browser clients. Cookie auth is disabled when Name is empty.
+type CookieConfig struct {
+	Name     string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+type HandlerOption func(*Handler)
+
+// WithAuthCookie makes Register and Login set the access token cookie, and
+// the router accept it when no Authorization header is sent.
+func WithAuthCookie(cookie CookieConfig) HandlerOption {
+	return func(h *Handler) {
+		h.authCookie = cookie
+	}
+}
+
+// WithOAuthCookieSecure marks the cookie holding an OAuth sign-in's state
+// Secure, so it is only sent over HTTPS.
+func WithOAuthCookieSecure(secure bool) HandlerOption {
+	return func(h *Handler) {
+		h.oauthCookieSecure = secure
+	}
+}
+
+// WithDatabase makes Ready report unavailable while db cannot be reached.
+func WithDatabase(db Pinger) HandlerOption {
+	return func(h *Handler) {
+		h.db = db
+	}
+}
+
+// WithHealthChecker sets the checks GET /health/detailed runs.
+func WithHealthChecker(health *HealthChecker) HandlerOption {
+	return func(h *Handler) {
+		h.health = health
+	}
+}
+
+// WithStartTime sets the instant Health measures uptime from; NewHandler
+// uses its own construction time by default.
+func WithStartTime(startedAt time.Time) HandlerOption {
+	return func(h *Handler) {
+		h.startedAt = startedAt
+	}
+}
+
+// WithPasswordKDFInfo makes the admin user endpoints report each account's
+// password hash algorithm and parameters.
+func WithPasswordKDFInfo(enabled bool) HandlerOption {
+	return func(h *Handler) {
+		h.passwordKDFInfo = enabled
+	}
+}
+
+// WithVersion sets the version Health reports instead of the build's.
+func WithVersion(version string) HandlerOption {
+	return func(h *Handler) {
+		h.version = version
+	}
+}
+
+// WithAuditLog enables the admin audit log endpoint.
+func WithAuditLog(auditUseCase *usecase.AuditUseCase) HandlerOption {
+	return func(h *Handler) {
+		h.auditUseCase = auditUseCase
+	}
+}
+
+func NewHandler(
+	authUseCase *usecase.AuthUseCase,
+	verificationUseCase *usecase.VerificationUseCase,
+	passwordResetUseCase *usecase.PasswordResetUseCase,
+	jwtService *security.JWTService,
+	opts ...HandlerOption,
+) *Handler {
+	h := &Handler{
+		authUseCase:          authUseCase,
+		verificationUseCase:  verificationUseCase,
+		passwordResetUseCase: passwordResetUseCase,
+		jwtService:           jwtService,
+		startedAt:            time.Now(),
+		version:              version.Version,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
+// ErrorResponse carries a human-readable message in Error and a stable
+// machine-readable Code for clients to branch on.
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
 }
 
-type UserResponse struct {
-	ID        int64  `json:"id"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at"`
+type ValidationErrorResponse struct {
+	Error  string            `json:"error"`
+	Code   string            `json:"code"`
+	Fields map[string]string `json:"fields"`
+}
+
+type AuditEventListResponse struct {
+	Events   []*domain.AuditEvent `json:"events"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
 }
 
 func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, ErrorResponse{Error: message})
+	respondWithJSON(w, code, ErrorResponse{Error: message, Code: errorCode(nil, code)})
+}
+
+// respondWithDomainError is respondWithError with the code taken from err.
+func respondWithDomainError(w http.ResponseWriter, code int, err error, message string) {
+	respondWithJSON(w, code, ErrorResponse{Error: message, Code: errorCode(err, code)})
+}
+
+// StatusClientClosedRequest is the non-standard status (from nginx) logged
+// when the client went away before the response was ready.
+const StatusClientClosedRequest = 499
+
+// respondWithServerError answers an error the handler has no specific case
+// for. A request whose context ended is not a server fault, so it is not
+// reported as a 500: a client that disconnected gets 499 and an expired
+// deadline 503.
+func respondWithServerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrTimeout) && errors.Is(err, context.Canceled):
+		respondWithDomainError(w, StatusClientClosedRequest, err, "Client closed request")
+	case errors.Is(err, domain.ErrTimeout):
+		respondWithDomainError(w, http.StatusServiceUnavailable, err, "Request timed out")
+	default:
+		respondWithError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func respondWithValidationError(w http.ResponseWriter, validationErr *domain.ValidationError) {
+	fields := make(map[string]string, len(validationErr.Fields))
+	for _, field := range validationErr.Fields {
+		fields[field.Field] = field.Err.Error()
+	}
+	respondWithJSON(w, http.StatusBadRequest, ValidationErrorResponse{
+		Error:  "Validation failed",
+		Code:   validationFailedCode,
+		Fields: fields,
+	})
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error":"Internal server error"}`))
+		w.Write([]byte(`{"error":"Internal server error","code":"internal_error"}`))
 		return
 	}
 
@@ -49,98 +210,1041 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
-func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
+// maxRequestBodyBytes bounds JSON request bodies; every payload this API
+// accepts is a handful of short strings.
+const maxRequestBodyBytes = 1 << 20
+
+// decodeJSONBody decodes the request body into dst, writing the error
+// response itself and returning false when the body is not acceptable.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+		return false
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return false
+		}
+		respondWithError(w, http.StatusBadRequest, decodeErrorMessage(err))
+		return false
+	}
+	return true
+}
+
+// decodeErrorMessage describes a JSON decoding failure without quoting the
+// body: encoding/json errors can carry fragments of the input (a numeric
+// password, for instance), so only the position or field name is reported.
+func decodeErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "Request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Invalid request payload: truncated JSON"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Invalid request payload: malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("Invalid request payload: field %q has the wrong type", typeErr.Field)
+	default:
+		return "Invalid request payload"
+	}
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var req usecase.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if validationErr := validateRequest(req); validationErr != nil {
+		respondWithValidationError(w, validationErr)
 		return
 	}
 
-	resp, err := h.authUseCase.Register(req)
+	resp, err := h.authUseCase.Register(r.Context(), req)
 	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithValidationError(w, validationErr)
+			return
+		}
+
 		switch err {
-		case domain.ErrUserAlreadyExists:
-			respondWithError(w, http.StatusConflict, err.Error())
-		case domain.ErrInvalidCredentials:
-			respondWithError(w, http.StatusBadRequest, err.Error())
+		case domain.ErrUserAlreadyExists, domain.ErrUsernameTaken:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		case domain.ErrEmailDomainNotAllowed:
+			respondWithDomainError(w, http.StatusForbidden, err, "Accounts cannot be created with this email domain")
 		default:
-			respondWithError(w, http.StatusInternalServerError, "Internal server error")
+			respondWithServerError(w, err)
 		}
 		return
 	}
 
+	h.setAuthCookie(w, resp)
 	respondWithJSON(w, http.StatusCreated, resp)
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	var req usecase.LoginRequest
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
-
-	var req usecase.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	// Which field failed is not reported, as with a wrong password.
+	if validateRequest(req) != nil {
+		respondWithDomainError(w, http.StatusUnauthorized, domain.ErrInvalidCredentials, "Invalid email or password")
 		return
 	}
 
-	resp, err := h.authUseCase.Login(req)
+	resp, err := h.authUseCase.Login(r.Context(), req)
 	if err != nil {
+		var throttled *domain.LoginThrottledError
+		if errors.As(err, &throttled) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			respondWithDomainError(w, http.StatusTooManyRequests, err, "Too many failed login attempts")
+			return
+		}
+
 		switch err {
 		case domain.ErrInvalidCredentials:
-			respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
+			respondWithDomainError(w, http.StatusUnauthorized, err, "Invalid email or password")
+		case domain.ErrEmailNotVerified:
+			respondWithDomainError(w, http.StatusForbidden, err, "Email address has not been verified")
 		default:
-			respondWithError(w, http.StatusInternalServerError, "Internal server error")
+			respondWithServerError(w, err)
 		}
 		return
 	}
 
+	if !resp.TOTPRequired {
+		h.setAuthCookie(w, resp)
+	}
 	respondWithJSON(w, http.StatusOK, resp)
 }
 
-func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+// oauthStateCookieName holds the state and PKCE verifier of a sign-in in
+// progress, joined by a dot, between the redirect to the provider and the
+// callback.
+const (
+	oauthStateCookieName = "oauth_state"
+	oauthStateMaxAge     = 10 * time.Minute
+)
+
+// OAuth serves /auth/oauth/{provider}, which sends the browser to the
+// provider's sign-in page, and /auth/oauth/{provider}/callback, where the
+// provider sends it back.
+func (h *Handler) OAuth(w http.ResponseWriter, r *http.Request) {
+	if path.Base(r.URL.Path) == "callback" {
+		h.oauthCallback(w, r, path.Base(path.Dir(r.URL.Path)))
+		return
+	}
+	h.oauthStart(w, r, path.Base(r.URL.Path))
+}
+
+func (h *Handler) oauthStart(w http.ResponseWriter, r *http.Request, provider string) {
+	redirect, err := h.authUseCase.StartOAuth(provider)
+	if err != nil {
+		switch err {
+		case domain.ErrUnknownIdentityProvider:
+			respondWithDomainError(w, http.StatusNotFound, err, "Unknown identity provider")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	h.setOAuthStateCookie(w, redirect.State+"."+redirect.CodeVerifier, int(oauthStateMaxAge.Seconds()))
+	http.Redirect(w, r, redirect.URL, http.StatusFound)
+}
+
+func (h *Handler) oauthCallback(w http.ResponseWriter, r *http.Request, provider string) {
+	var state, verifier string
+	if cookie, err := r.Cookie(oauthStateCookieName); err == nil {
+		state, verifier, _ = strings.Cut(cookie.Value, ".")
+	}
+	// The state is single use, whatever the outcome.
+	h.setOAuthStateCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if state == "" || verifier == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		respondWithDomainError(w, http.StatusBadRequest, domain.ErrOAuthStateInvalid, "Sign-in state is missing or does not match")
+		return
+	}
+	if query.Get("error") != "" {
+		respondWithDomainError(w, http.StatusUnauthorized, domain.ErrOAuthFailed, "Sign-in was refused by the identity provider")
+		return
+	}
+
+	resp, err := h.authUseCase.CompleteOAuth(r.Context(), provider, query.Get("code"), verifier)
+	if err != nil {
+		if errors.Is(err, domain.ErrOAuthFailed) {
+			log.Printf("OAuth sign-in with %s failed: %v", provider, err)
+			respondWithDomainError(w, http.StatusUnauthorized, err, "Sign-in with the identity provider failed")
+			return
+		}
+
+		switch err {
+		case domain.ErrUnknownIdentityProvider:
+			respondWithDomainError(w, http.StatusNotFound, err, "Unknown identity provider")
+		case domain.ErrEmailNotVerified:
+			respondWithDomainError(w, http.StatusForbidden, err, "The identity provider has not verified this email address")
+		case domain.ErrEmailDomainNotAllowed:
+			respondWithDomainError(w, http.StatusForbidden, err, "Accounts cannot be created with this email domain")
+		case domain.ErrUserAlreadyExists:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	if !resp.TOTPRequired {
+		h.setAuthCookie(w, resp)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// setOAuthStateCookie uses Lax, not Strict: the callback is a navigation
+// from the provider's site, which would not carry a Strict cookie.
+func (h *Handler) setOAuthStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.oauthCookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// TOTPSetup starts 2FA enrollment and returns the secret to load into an
+// authenticator app.
+func (h *Handler) TOTPSetup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	setup, err := h.authUseCase.SetupTOTP(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case domain.ErrTOTPAlreadyEnabled:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, setup)
+}
+
+func (h *Handler) TOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req usecase.TOTPConfirmRequest
+	if !decodeJSONBody(w, r, &req) {
 		return
 	}
 
-	userID, ok := r.Context().Value(contextKeyUserID).(int64)
+	err := h.authUseCase.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidTOTPCode:
+			respondWithDomainError(w, http.StatusBadRequest, err, err.Error())
+		case domain.ErrTOTPAlreadyEnabled, domain.ErrTOTPNotEnrolled:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "two-factor authentication enabled"})
+}
+
+// TOTPVerify is the second login step for accounts with 2FA enabled.
+func (h *Handler) TOTPVerify(w http.ResponseWriter, r *http.Request) {
+	var req usecase.TOTPVerifyRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	resp, err := h.authUseCase.VerifyTOTP(r.Context(), req)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			respondWithDomainError(w, http.StatusUnauthorized, err, "Invalid or expired pending token")
+		case domain.ErrInvalidTOTPCode:
+			respondWithDomainError(w, http.StatusUnauthorized, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	h.setAuthCookie(w, resp)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// setAuthCookie also sets the csrf_token cookie CSRFMiddleware checks. It
+// is readable by scripts so the front end can copy it into X-CSRF-Token.
+func (h *Handler) setAuthCookie(w http.ResponseWriter, resp *usecase.AuthResponse) {
+	if h.authCookie.Name == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.authCookie.Name,
+		Value:    resp.AccessToken,
+		Path:     "/",
+		MaxAge:   int(resp.ExpiresIn),
+		HttpOnly: true,
+		Secure:   h.authCookie.Secure,
+		SameSite: h.authCookie.SameSite,
+	})
+
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		// Without the cookie, state-changing requests are refused until
+		// the next sign-in; reads still work.
+		log.Printf("Failed to generate CSRF token: %v", err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		MaxAge:   int(resp.ExpiresIn),
+		Secure:   h.authCookie.Secure,
+		SameSite: h.authCookie.SameSite,
+	})
+}
+
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
 	if !ok {
 		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	user, err := h.authUseCase.GetUserByID(userID)
+	user, err := h.authUseCase.GetUserByID(r.Context(), userID)
 	if err != nil {
 		if err == domain.ErrUserNotFound {
 			respondWithError(w, http.StatusNotFound, "User not found")
 		} else {
-			respondWithError(w, http.StatusInternalServerError, "Internal server error")
+			respondWithServerError(w, err)
 		}
 		return
 	}
 
-	response := UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	respondWithJSON(w, http.StatusOK, usecase.NewUserDTO(user))
+}
+
+// UpdateMe applies a partial profile update: only the fields present in the
+// body change.
+func (h *Handler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req usecase.UpdateProfileRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	user, err := h.authUseCase.UpdateProfile(r.Context(), userID, req)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithValidationError(w, validationErr)
+			return
+		}
+
+		switch err {
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, usecase.NewUserDTO(user))
 }
 
-func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+// Logout revokes the presenting token so it is rejected until it expires.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	h.jwtService.Revoke(claims)
+	if claims.SessionID != 0 {
+		err := h.authUseCase.RevokeSession(r.Context(), claims.UserID, claims.SessionID)
+		if err != nil && err != domain.ErrSessionNotFound {
+			log.Printf("Failed to end session %d on logout: %v", claims.SessionID, err)
+		}
+	}
+	if h.authCookie.Name != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     h.authCookie.Name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   h.authCookie.Secure,
+			SameSite: h.authCookie.SameSite,
+		})
+		http.SetCookie(w, &http.Cookie{
+			Name:     csrfCookieName,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			Secure:   h.authCookie.Secure,
+			SameSite: h.authCookie.SameSite,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+// Refresh exchanges a refresh token for a new token pair.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req usecase.RefreshRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	resp, err := h.authUseCase.Refresh(r.Context(), req)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			respondWithDomainError(w, http.StatusUnauthorized, err, "Invalid or expired refresh token")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	h.setAuthCookie(w, resp)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+type SessionResponse struct {
+	*domain.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns one page of the caller's active sessions and flags
+// the one the presenting token belongs to.
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	params, err := pagination.Parse(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination: "+err.Error())
+		return
+	}
+
+	list, err := h.authUseCase.ListSessions(r.Context(), claims.UserID, params)
+	if err != nil {
+		respondWithServerError(w, err)
+		return
+	}
+
+	sessions := make([]SessionResponse, 0, len(list.Sessions))
+	for _, session := range list.Sessions {
+		sessions = append(sessions, SessionResponse{Session: session, Current: session.ID == claims.SessionID})
+	}
+	params.Page, params.PageSize = list.Page, list.PageSize
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPageResponse(sessions, params, list.Total))
+}
+
+// RevokeSession serves DELETE /api/auth/sessions/{id}.
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+	if err != nil || sessionID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid session id")
+		return
+	}
+
+	if err := h.authUseCase.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		switch err {
+		case domain.ErrSessionNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "Session not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "session revoked"})
+}
+
+// TokenClaims echoes the validated claims of the presenting token, so clients
+// don't have to decode the JWT themselves.
+func (h *Handler) TokenClaims(w http.ResponseWriter, r *http.Request) {
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, claims)
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token"`
+}
+
+// IntrospectionResponse follows RFC 7662: an inactive token carries no
+// other field.
+type IntrospectionResponse struct {
+	Active   bool     `json:"active"`
+	Subject  string   `json:"sub,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Issuer   string   `json:"iss,omitempty"`
+	Audience []string `json:"aud,omitempty"`
+	IssuedAt int64    `json:"iat,omitempty"`
+	Expiry   int64    `json:"exp,omitempty"`
+}
+
+// Introspect tells other services whether an access token is active. Any
+// token that fails, whatever the reason, gets {"active":false}.
+func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
+	var req IntrospectRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	claims, err := h.authUseCase.Introspect(r.Context(), req.Token)
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidToken:
+			respondWithJSON(w, http.StatusOK, IntrospectionResponse{Active: false})
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	resp := IntrospectionResponse{
+		Active:   true,
+		Subject:  strconv.FormatInt(claims.UserID, 10),
+		Email:    claims.Email,
+		Role:     claims.Role,
+		Issuer:   claims.Issuer,
+		Audience: claims.Audience,
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.ExpiresAt != nil {
+		resp.Expiry = claims.ExpiresAt.Unix()
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req usecase.ChangeEmailRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	token, err := h.authUseCase.ChangeEmail(r.Context(), userID, req.Email, req.CurrentPassword)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithValidationError(w, validationErr)
+			return
+		}
+
+		switch err {
+		case domain.ErrInvalidCredentials:
+			respondWithDomainError(w, http.StatusForbidden, err, "Current password is incorrect")
+		case domain.ErrUserAlreadyExists:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		case domain.ErrEmailDomainNotAllowed:
+			respondWithDomainError(w, http.StatusForbidden, err, "This email domain is not allowed")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	if token != "" {
+		respondWithJSON(w, http.StatusAccepted, map[string]string{"status": "confirmation email sent"})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "email updated"})
+}
+
+// ChangePassword replaces the caller's password and answers like Login with
+// fresh tokens: every token issued before, including the one on this
+// request, stops working.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req usecase.ChangePasswordRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	resp, err := h.authUseCase.ChangePassword(r.Context(), userID, req)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithValidationError(w, validationErr)
+			return
+		}
+
+		switch err {
+		case domain.ErrInvalidCredentials:
+			respondWithDomainError(w, http.StatusForbidden, err, "Current password is incorrect")
+		case domain.ErrPasswordReused:
+			respondWithDomainError(w, http.StatusBadRequest, err, "The new password was used recently")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	h.setAuthCookie(w, resp)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// ConfirmEmailChange completes a change started by ChangeEmail.
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	err := h.authUseCase.ConfirmEmailChange(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		switch err {
+		case domain.ErrOneTimeTokenInvalid, domain.ErrOneTimeTokenExpired, domain.ErrOneTimeTokenUsed, domain.ErrNoPendingEmail:
+			respondWithDomainError(w, http.StatusBadRequest, err, err.Error())
+		case domain.ErrUserAlreadyExists:
+			respondWithDomainError(w, http.StatusConflict, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "email changed"})
+}
+
+func (h *Handler) SendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	_, err := h.verificationUseCase.RequestEmailVerification(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case domain.ErrEmailAlreadyVerified:
+			respondWithDomainError(w, http.StatusConflict, err, "Email address is already verified")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"status": "verification email sent"})
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	err := h.verificationUseCase.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		switch err {
+		case domain.ErrOneTimeTokenInvalid, domain.ErrOneTimeTokenExpired, domain.ErrOneTimeTokenUsed:
+			respondWithDomainError(w, http.StatusBadRequest, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "email verified"})
+}
+
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req usecase.ForgotPasswordRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if _, err := h.passwordResetUseCase.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		respondWithServerError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"status": "if an account exists for this email, a reset link has been sent",
+	})
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req usecase.ResetPasswordRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	err := h.passwordResetUseCase.ResetPassword(r.Context(), req)
+	if err != nil {
+		switch err {
+		case domain.ErrOneTimeTokenInvalid, domain.ErrOneTimeTokenExpired, domain.ErrOneTimeTokenUsed, domain.ErrWeakPassword, domain.ErrPasswordReused:
+			respondWithDomainError(w, http.StatusBadRequest, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "password has been reset"})
+}
+
+// ValidateResetToken lets a frontend check a reset link before showing the
+// new password form. The token stays usable.
+func (h *Handler) ValidateResetToken(w http.ResponseWriter, r *http.Request) {
+	err := h.passwordResetUseCase.ValidateResetToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		switch err {
+		case domain.ErrOneTimeTokenInvalid, domain.ErrOneTimeTokenExpired, domain.ErrOneTimeTokenUsed:
+			respondWithDomainError(w, http.StatusBadRequest, err, err.Error())
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]bool{"valid": true})
+}
+
+func (h *Handler) AuthMethods(w http.ResponseWriter, r *http.Request) {
+	methods, err := h.authUseCase.AuthMethods(r.Context(), r.URL.Query().Get("email"))
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidCredentials:
+			respondWithError(w, http.StatusBadRequest, "Missing email query parameter")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string][]string{"methods": methods})
+}
+
+// userListOptions are the pagination rules of GET /api/users.
+var userListOptions = []pagination.Option{
+	pagination.WithSortFields(domain.UserSortID, domain.UserSortEmail, domain.UserSortCreatedAt),
+	pagination.WithCursor(domain.UserSortCreatedAt),
+}
+
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := pagination.Parse(r, userListOptions...)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination: "+err.Error())
+		return
+	}
+
+	list, err := h.authUseCase.ListUsers(r.Context(), params)
+	if err != nil {
+		respondWithServerError(w, err)
+		return
+	}
+
+	users := make([]*usecase.UserDTO, 0, len(list.Users))
+	for _, user := range list.Users {
+		users = append(users, h.adminUserDTO(user))
+	}
+	params.Page, params.PageSize = list.Page, list.PageSize
+
+	resp := pagination.NewPageResponse(users, params, list.Total)
+	if list.NextCursor != nil {
+		resp.Pagination.NextCursor = list.NextCursor.Encode()
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// ImportUsers creates a batch of accounts and reports each row's outcome.
+// A fail-mode batch that was rejected is answered with 422 and the same
+// per-row body.
+func (h *Handler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	var req usecase.ImportUsersRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	result, err := h.authUseCase.ImportUsers(r.Context(), req)
+	if err != nil {
+		var validationErr *domain.ValidationError
+		if errors.As(err, &validationErr) {
+			respondWithValidationError(w, validationErr)
+			return
+		}
+		respondWithServerError(w, err)
+		return
+	}
+
+	if result.Rejected() {
+		respondWithJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// DeleteUser removes the account whose ID ends the path and answers 204.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	userID, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+	if err != nil || userID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid user id")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
+	if err := h.authUseCase.DeleteUser(r.Context(), actorID, userID); err != nil {
+		switch err {
+		case domain.ErrCannotDeleteSelf:
+			respondWithDomainError(w, http.StatusBadRequest, err, "Admins cannot delete their own account")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser brings back a user deleted while USER_DELETE_MODE=soft.
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(path.Base(r.URL.Path), 10, 64)
+	if err != nil || userID <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	user, err := h.authUseCase.RestoreUser(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "No deleted user with this id")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h.adminUserDTO(user))
+}
+
+func (h *Handler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if h.auditUseCase == nil {
+		respondWithError(w, http.StatusNotFound, "Audit log is not enabled")
+		return
+	}
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid page parameter")
+		return
+	}
+
+	pageSize, err := queryInt(r, "page_size", 0)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid page_size parameter")
+		return
+	}
+
+	list, err := h.auditUseCase.ListEvents(r.Context(), page, pageSize)
+	if err != nil {
+		respondWithServerError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, AuditEventListResponse{
+		Events:   list.Events,
+		Total:    list.Total,
+		Page:     list.Page,
+		PageSize: list.PageSize,
+	})
+}
+
+// PasswordPolicyImpact reports how many users set their password under an
+// older policy than ?version= (the current policy by default).
+func (h *Handler) PasswordPolicyImpact(w http.ResponseWriter, r *http.Request) {
+	version, err := queryInt(r, "version", 0)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid version parameter")
+		return
+	}
+
+	impact, err := h.authUseCase.PasswordPolicyImpact(r.Context(), version)
+	if err != nil {
+		respondWithServerError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, impact)
+}
+
+func (h *Handler) GetUserByEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := h.authUseCase.GetUserByEmail(r.Context(), r.URL.Query().Get("email"))
+	if err != nil {
+		switch err {
+		case domain.ErrInvalidCredentials:
+			respondWithError(w, http.StatusBadRequest, "Missing email query parameter")
+		case domain.ErrUserNotFound:
+			respondWithDomainError(w, http.StatusNotFound, err, "User not found")
+		default:
+			respondWithServerError(w, err)
+		}
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, h.adminUserDTO(user))
+}
+
+// adminUserDTO is the user as the admin endpoints show it.
+func (h *Handler) adminUserDTO(user *domain.User) *usecase.UserDTO {
+	dto := usecase.NewUserDTO(user)
+	if h.passwordKDFInfo {
+		dto.WithPasswordKDF(user)
+	}
+	return dto
+}
+
+func (h *Handler) AdminPing(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "role": domain.RoleAdmin})
+}
+
+// HealthResponse keeps status first: liveness probes only look at it.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Uptime  string `json:"uptime"`
+	Version string `json:"version"`
+	Time    string `json:"time"`
+}
+
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	respondWithJSON(w, http.StatusOK, HealthResponse{
+		Status:  "healthy",
+		Uptime:  now.Sub(h.startedAt).Truncate(time.Second).String(),
+		Version: h.version,
+		Time:    now.UTC().Format(time.RFC3339),
+	})
+}
+
+type DetailedHealthResponse struct {
+	Status     string                       `json:"status"`
+	Checks     map[string]HealthCheckResult `json:"checks"`
+	GoVersion  string                       `json:"go_version"`
+	Goroutines int                          `json:"goroutines"`
+}
+
+// HealthDetailed runs the checks registered with WithHealthChecker and
+// reports them with runtime state for operators. Status is "healthy" when
+// every check passes, "degraded" when only non-critical ones fail, and
+// "unhealthy", with a 503, when a critical one fails. It can reveal
+// internal details, so the router only exposes it to allowlisted IPs.
+func (h *Handler) HealthDetailed(w http.ResponseWriter, r *http.Request) {
+	resp := DetailedHealthResponse{
+		Status:     "healthy",
+		Checks:     map[string]HealthCheckResult{},
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	code := http.StatusOK
+
+	if h.health != nil {
+		checks, healthy := h.health.Run(r.Context())
+		resp.Checks = checks
+		for _, check := range checks {
+			if !check.OK {
+				resp.Status = "degraded"
+			}
+		}
+		if !healthy {
+			resp.Status = "unhealthy"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	respondWithJSON(w, code, resp)
+}
+
+// Ready is the readiness probe: unlike Health it checks the database, so an
+// instance that lost its DB is taken out of rotation instead of restarted.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		if err := h.db.PingContext(ctx); err != nil {
+			log.Printf("Readiness check failed: %v", err)
+			respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable", "db": "down"})
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ready", "db": "up"})
 }
 
 func extractTokenFromHeader(r *http.Request) string {
@@ -150,3 +1254,11 @@ func extractTokenFromHeader(r *http.Request) string {
 	}
 	return ""
 }
+
+func queryInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,1055 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/oauth"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
+	"github.com/valentinfrappart/securerestapi/internal/usecase"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHandler(t *testing.T, opts ...HandlerOption) *Handler {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	passwordService, err := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to create password service: %v", err)
+	}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	authUseCase := usecase.NewAuthUseCase(repository.NewSQLiteUserRepository(db), passwordService, jwtService)
+
+	return NewHandler(authUseCase, nil, nil, jwtService, opts...)
+}
+
+func TestRegister_RejectsWrongContentType(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader("email=a%40b.c&password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.Register(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status %d, got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
+
+func TestLogin_RejectsOversizedBody(t *testing.T) {
+	handler := &Handler{}
+
+	body := `{"email":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestLogin_AcceptsJSONWithCharset(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected charset to be ignored and payload rejected with %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestDecodeJSONBody_NeverEchoesBody(t *testing.T) {
+	const secret = "987654321"
+
+	bodies := []string{
+		`{"password":"` + secret,
+		`{"password":` + secret + `x}`,
+		`{"password":"` + secret + `",}`,
+		`["` + secret + `"]`,
+		`"` + secret + `"`,
+		secret,
+	}
+	handlers := map[string]http.HandlerFunc{
+		"register":        (&Handler{}).Register,
+		"login":           (&Handler{}).Login,
+		"forgot":          (&Handler{}).ForgotPassword,
+		"reset":           (&Handler{}).ResetPassword,
+		"totp verify":     (&Handler{}).TOTPVerify,
+		"totp confirm":    (&Handler{}).TOTPConfirm,
+		"change email":    (&Handler{}).ChangeEmail,
+		"change password": (&Handler{}).ChangePassword,
+	}
+
+	for name, handler := range handlers {
+		for _, body := range bodies {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), userIDKey{}, int64(1)))
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s with %s: expected status %d, got %d", name, body, http.StatusBadRequest, rec.Code)
+			}
+			if strings.Contains(rec.Body.String(), secret) {
+				t.Errorf("%s with %s: response echoes the body: %s", name, body, rec.Body.String())
+			}
+		}
+	}
+
+	// encoding/json quotes numbers in type errors; only the field name may
+	// come back.
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"a@example.com","password":`+secret+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	(&Handler{}).Login(rec, req)
+
+	if strings.Contains(rec.Body.String(), secret) || !strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("Expected the field name without its value, got %s", rec.Body.String())
+	}
+}
+
+func doGetUserByEmail(handler *Handler, email string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/users/by-email", nil)
+	if email != "" {
+		q := req.URL.Query()
+		q.Set("email", email)
+		req.URL.RawQuery = q.Encode()
+	}
+	rec := httptest.NewRecorder()
+	handler.GetUserByEmail(rec, req)
+	return rec
+}
+
+func TestGetUserByEmail_NormalizesQuery(t *testing.T) {
+	handler := newTestHandler(t)
+	if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "User@Example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	rec := doGetUserByEmail(handler, "  USER@example.COM ")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var user usecase.UserDTO
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if user.Email != "user@example.com" {
+		t.Errorf("Expected normalized email, got %q", user.Email)
+	}
+}
+
+func TestGetUserByEmail_UnknownEmail(t *testing.T) {
+	rec := doGetUserByEmail(newTestHandler(t), "missing@example.com")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetUserByEmail_MissingQuery(t *testing.T) {
+	handler := newTestHandler(t)
+
+	if rec := doGetUserByEmail(handler, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for missing email, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := doGetUserByEmail(handler, "   "); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for blank email, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetUserByEmail_PasswordKDFInfo(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := repository.NewSQLiteUserRepository(db)
+
+	bcryptService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	argon2Params := security.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+	argon2Service, _ := security.NewArgon2PasswordService(argon2Params)
+	for email, hasher := range map[string]domain.PasswordHasher{"bcrypt@example.com": bcryptService, "argon2@example.com": argon2Service} {
+		hash, err := hasher.Hash("password123")
+		if err != nil {
+			t.Fatalf("Failed to hash password: %v", err)
+		}
+		if _, err := repo.Create(context.Background(), email, hash, 1); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	if _, err := repo.CreateExternal(context.Background(), "sso@example.com", "google"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	authUseCase := usecase.NewAuthUseCase(repo, bcryptService, jwtService)
+	getKDF := func(handler *Handler, email string) *usecase.PasswordKDFDTO {
+		t.Helper()
+		rec := doGetUserByEmail(handler, email)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "$2a$") || strings.Contains(rec.Body.String(), "$argon2id$") {
+			t.Errorf("Expected the hash itself to stay private, got %s", rec.Body.String())
+		}
+		var user usecase.UserDTO
+		if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return user.PasswordKDF
+	}
+
+	handler := NewHandler(authUseCase, nil, nil, jwtService, WithPasswordKDFInfo(true))
+	if kdf := getKDF(handler, "bcrypt@example.com"); kdf == nil || *kdf != (usecase.PasswordKDFDTO{Algorithm: security.AlgorithmBcrypt, Cost: bcrypt.MinCost}) {
+		t.Errorf("Expected bcrypt with cost %d, got %+v", bcrypt.MinCost, kdf)
+	}
+	want := usecase.PasswordKDFDTO{Algorithm: security.AlgorithmArgon2id, Memory: 64, Iterations: 1, Parallelism: 1}
+	if kdf := getKDF(handler, "argon2@example.com"); kdf == nil || *kdf != want {
+		t.Errorf("Expected %+v, got %+v", want, kdf)
+	}
+	if kdf := getKDF(handler, "sso@example.com"); kdf != nil {
+		t.Errorf("Expected no KDF for an account without a password, got %+v", kdf)
+	}
+
+	if kdf := getKDF(NewHandler(authUseCase, nil, nil, jwtService), "bcrypt@example.com"); kdf != nil {
+		t.Errorf("Expected no KDF without the option, got %+v", kdf)
+	}
+}
+
+func TestListUsers_PageResponse(t *testing.T) {
+	handler := newTestHandler(t)
+	for _, email := range []string{"bob@example.com", "carol@example.com", "alice@example.com"} {
+		if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: email, Password: "password123"}); err != nil {
+			t.Fatalf("Failed to register user: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ListUsers(rec, httptest.NewRequest(http.MethodGet, "/api/users?page_size=2&sort=email", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var body pagination.PageResponse[usecase.UserDTO]
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Data) != 2 || body.Data[0].Email != "alice@example.com" || body.Data[1].Email != "bob@example.com" {
+		t.Errorf("Expected alice and bob, got %+v", body.Data)
+	}
+	want := pagination.Meta{Page: 1, PageSize: 2, Total: 3, TotalPages: 2, Sort: "email", Order: "asc"}
+	if body.Pagination != want {
+		t.Errorf("Expected %+v, got %+v", want, body.Pagination)
+	}
+}
+
+func TestListUsers_Cursor(t *testing.T) {
+	handler := newTestHandler(t)
+	register := func(email string) {
+		t.Helper()
+		if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: email, Password: "password123"}); err != nil {
+			t.Fatalf("Failed to register user: %v", err)
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		register(fmt.Sprintf("user%d@example.com", i))
+	}
+
+	seen := map[string]bool{}
+	var order []string
+	query := "/api/users?page_size=2&sort=created_at"
+	for pages := 0; query != ""; pages++ {
+		if pages > 10 {
+			t.Fatal("Expected paging to end")
+		}
+		rec := httptest.NewRecorder()
+		handler.ListUsers(rec, httptest.NewRequest(http.MethodGet, query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		var body pagination.PageResponse[usecase.UserDTO]
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, user := range body.Data {
+			if seen[user.Email] {
+				t.Errorf("User %s returned twice", user.Email)
+			}
+			seen[user.Email] = true
+			order = append(order, user.Email)
+		}
+		if pages == 0 {
+			// A signup between two pages must not shift the next ones.
+			register("user6@example.com")
+		}
+
+		query = ""
+		if body.Pagination.NextCursor != "" {
+			query = "/api/users?page_size=2&cursor=" + body.Pagination.NextCursor
+		}
+	}
+
+	want := "user1@example.com user2@example.com user3@example.com user4@example.com user5@example.com user6@example.com"
+	if got := strings.Join(order, " "); got != want {
+		t.Errorf("Expected every user once in signup order, got %s", got)
+	}
+}
+
+func TestListUsers_InvalidCursor(t *testing.T) {
+	for _, query := range []string{"cursor=garbage", "cursor=" + (pagination.Cursor{ID: 1}).Encode() + "&page=2"} {
+		rec := httptest.NewRecorder()
+		newTestHandler(t).ListUsers(rec, httptest.NewRequest(http.MethodGet, "/api/users?"+query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestListUsers_InvalidSort(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestHandler(t).ListUsers(rec, httptest.NewRequest(http.MethodGet, "/api/users?sort=password_hash", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestLogin_SetsAuthCookie(t *testing.T) {
+	handler := newTestHandler(t, WithAuthCookie(CookieConfig{
+		Name:     "access_token",
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}))
+	if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp usecase.AuthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("Expected the auth and CSRF cookies, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != "access_token" || cookie.Value != resp.AccessToken {
+		t.Errorf("Expected access_token cookie carrying the token, got %s=%s", cookie.Name, cookie.Value)
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Expected HttpOnly, Secure, SameSite=Strict cookie, got %+v", cookie)
+	}
+	if cookie.MaxAge != int(time.Hour.Seconds()) {
+		t.Errorf("Expected MaxAge to match token lifetime, got %d", cookie.MaxAge)
+	}
+
+	// The front end must be able to read the CSRF token to echo it.
+	csrf := cookies[1]
+	if csrf.Name != csrfCookieName || len(csrf.Value) != 2*csrfTokenBytes || csrf.HttpOnly {
+		t.Errorf("Expected a script-readable csrf_token cookie, got %+v", csrf)
+	}
+}
+
+func TestLogin_NoCookieByDefault(t *testing.T) {
+	handler := newTestHandler(t)
+	if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("Expected no cookie without cookie auth, got %d", len(cookies))
+	}
+}
+
+func TestLogin_Throttled(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	authUseCase := usecase.NewAuthUseCase(repository.NewInMemoryUserRepository(), passwordService, jwtService,
+		usecase.WithLoginThrottle(usecase.LoginThrottleConfig{MaxPerAccount: 1, Window: time.Minute}))
+	handler := NewHandler(authUseCase, nil, nil, jwtService)
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"test@example.com","password":"password123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.Login(rec, req)
+		return rec
+	}
+
+	if rec := login(); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	rec := login()
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "login_throttled") {
+		t.Fatalf("Expected 429 login_throttled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Expected Retry-After within the window, got %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestHealth_UptimeAndVersion(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil, WithStartTime(time.Now().Add(-90*time.Minute)), WithVersion("v1.2.3"))
+
+	rec := httptest.NewRecorder()
+	handler.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var body HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Status != "healthy" || body.Version != "v1.2.3" {
+		t.Errorf("Expected healthy v1.2.3, got %+v", body)
+	}
+	uptime, err := time.ParseDuration(body.Uptime)
+	if err != nil || uptime < 90*time.Minute {
+		t.Errorf("Expected an uptime of at least 1h30m, got %q (%v)", body.Uptime, err)
+	}
+	if _, err := time.Parse(time.RFC3339, body.Time); err != nil {
+		t.Errorf("Expected an RFC 3339 time, got %q", body.Time)
+	}
+}
+
+func doReady(handler *Handler) (*httptest.ResponseRecorder, map[string]string) {
+	rec := httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+	var body map[string]string
+	json.NewDecoder(rec.Body).Decode(&body)
+	return rec, body
+}
+
+func TestReady_DatabaseUp(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	rec, body := doReady(NewHandler(nil, nil, nil, nil, WithDatabase(db)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if body["db"] != "up" {
+		t.Errorf("Expected db up, got %v", body)
+	}
+}
+
+func TestReady_DatabaseClosed(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.Close()
+
+	rec, body := doReady(NewHandler(nil, nil, nil, nil, WithDatabase(db)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if body["status"] != "unavailable" || body["db"] != "down" {
+		t.Errorf("Expected unavailable/down, got %v", body)
+	}
+}
+
+func TestMe_ContextEndedIsNotServerError(t *testing.T) {
+	handler := newTestHandler(t)
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+	}{
+		{"deadline exceeded", expired, http.StatusServiceUnavailable},
+		{"client canceled", canceled, StatusClientClosedRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req = req.WithContext(context.WithValue(tt.ctx, userIDKey{}, int64(1)))
+		rec := httptest.NewRecorder()
+		handler.Me(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), `"code":"request_timeout"`) {
+			t.Errorf("%s: expected code request_timeout, got %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+func TestImportUsers_MixedBatch(t *testing.T) {
+	handler := newTestHandler(t)
+	if _, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "existing@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	body := `{"users":[
+		{"email":"new@example.com","password":"password123"},
+		{"email":"existing@example.com","password":"password123"},
+		{"email":"not-an-email","password":"password123"}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ImportUsers(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var result usecase.ImportUsersResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Created != 1 || result.Skipped != 1 || result.Failed != 1 {
+		t.Errorf("Expected one created, one skipped and one failed row, got %+v", result)
+	}
+	if result.Results[1].Email != "existing@example.com" || result.Results[1].Error == "" {
+		t.Errorf("Expected the duplicate to be reported, got %+v", result.Results[1])
+	}
+}
+
+func TestImportUsers_FailModeRejected(t *testing.T) {
+	handler := newTestHandler(t)
+
+	body := `{"mode":"fail","users":[{"email":"new@example.com","password":"password123"},{"email":"not-an-email","password":"password123"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ImportUsers(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if _, err := handler.authUseCase.GetUserByEmail(context.Background(), "new@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected no account to be created, got %v", err)
+	}
+}
+
+func doUpdateMe(handler *Handler, userID int64, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/api/auth/me", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey{}, userID))
+	rec := httptest.NewRecorder()
+	handler.UpdateMe(rec, req)
+	return rec
+}
+
+func TestUpdateMe_PartialUpdate(t *testing.T) {
+	handler := newTestHandler(t)
+	resp, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "me@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	rec := doUpdateMe(handler, resp.User.ID, `{"display_name":"Alice Martin"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var user usecase.UserDTO
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if user.DisplayName == nil || *user.DisplayName != "Alice Martin" || user.Email != "me@example.com" {
+		t.Errorf("Expected the display name to be set, got %+v", user)
+	}
+
+	// An empty object changes nothing.
+	rec = doUpdateMe(handler, resp.User.ID, `{}`)
+	if !strings.Contains(rec.Body.String(), `"display_name":"Alice Martin"`) {
+		t.Errorf("Expected the display name to be kept, got %s", rec.Body.String())
+	}
+
+	rec = doUpdateMe(handler, resp.User.ID, `{"display_name":""}`)
+	if !strings.Contains(rec.Body.String(), `"display_name":null`) {
+		t.Errorf("Expected the display name to be cleared, got %s", rec.Body.String())
+	}
+}
+
+func TestUpdateMe_InvalidDisplayName(t *testing.T) {
+	handler := newTestHandler(t)
+
+	rec := doUpdateMe(handler, 1, `{"display_name":"`+strings.Repeat("a", domain.MaxDisplayNameLength+1)+`"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"fields":{"display_name":`) {
+		t.Errorf("Expected a display_name field error, got %s", rec.Body.String())
+	}
+}
+
+// The user in an auth response is built field by field, so nothing beyond
+// this list reaches clients even when domain.User grows.
+func TestRegisterAndLogin_UserShape(t *testing.T) {
+	handler := newTestHandler(t)
+	allowed := map[string]bool{
+		"id": true, "email": true, "username": true, "display_name": true, "role": true, "email_verified": true,
+		"totp_enabled": true, "created_at": true, "updated_at": true, "last_login_at": true,
+	}
+
+	body := `{"email":"shape@example.com","password":"password123"}`
+	for _, step := range []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/api/auth/register", handler.Register},
+		{"/api/auth/login", handler.Login},
+	} {
+		req := httptest.NewRequest(http.MethodPost, step.path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		step.handler(rec, req)
+
+		var resp struct {
+			User map[string]any `json:"user"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", step.path, err)
+		}
+		if len(resp.User) == 0 {
+			t.Fatalf("%s: expected a user in the response", step.path)
+		}
+		for field := range resp.User {
+			if !allowed[field] {
+				t.Errorf("%s: unexpected user field %q", step.path, field)
+			}
+		}
+		createdAt, _ := resp.User["created_at"].(string)
+		if _, err := time.Parse(time.RFC3339, createdAt); err != nil {
+			t.Errorf("%s: expected created_at in RFC 3339, got %q", step.path, createdAt)
+		}
+	}
+}
+
+func doDeleteUser(handler *Handler, actorID int64, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/"+target, nil)
+	req = req.WithContext(context.WithValue(req.Context(), userIDKey{}, actorID))
+	rec := httptest.NewRecorder()
+	handler.DeleteUser(rec, req)
+	return rec
+}
+
+func TestDeleteUser(t *testing.T) {
+	handler := newTestHandler(t)
+	resp, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "abuser@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	rec := doDeleteUser(handler, 99, strconv.FormatInt(resp.User.ID, 10))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %s", rec.Body.String())
+	}
+	if _, err := handler.authUseCase.GetUserByID(context.Background(), resp.User.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected the user to be deleted, got %v", err)
+	}
+}
+
+func TestDeleteUser_Errors(t *testing.T) {
+	handler := newTestHandler(t)
+	resp, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "admin@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	self := strconv.FormatInt(resp.User.ID, 10)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+	}{
+		{"self", self, http.StatusBadRequest, "cannot_delete_self"},
+		{"unknown id", "4242", http.StatusNotFound, "user_not_found"},
+		{"invalid id", "abc", http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		rec := doDeleteUser(handler, resp.User.ID, tt.target)
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `"code":"`+tt.code+`"`) {
+			t.Errorf("%s: expected code %s, got %s", tt.name, tt.code, rec.Body.String())
+		}
+	}
+
+	if _, err := handler.authUseCase.GetUserByID(context.Background(), resp.User.ID); err != nil {
+		t.Errorf("Expected the admin to be kept, got %v", err)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	authUseCase := usecase.NewAuthUseCase(repository.NewInMemoryUserRepository(repository.WithInMemorySoftDelete()), passwordService, jwtService)
+	handler := NewHandler(authUseCase, nil, nil, jwtService)
+
+	resp, err := authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "abuser@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	target := strconv.FormatInt(resp.User.ID, 10)
+
+	restore := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.RestoreUser(rec, httptest.NewRequest(http.MethodPost, "/api/admin/users/restore/"+target, nil))
+		return rec
+	}
+
+	if rec := restore(); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for a user that is not deleted, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if rec := doDeleteUser(handler, 99, target); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	rec := restore()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"email":"abuser@example.com"`) {
+		t.Errorf("Expected the restored user in the body, got %s", rec.Body.String())
+	}
+}
+
+func TestRegister_EmailDomainNotAllowed(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	authUseCase := usecase.NewAuthUseCase(repository.NewInMemoryUserRepository(), passwordService, jwtService,
+		usecase.WithEmailDomainPolicy(usecase.EmailDomainPolicy{Deny: []string{"mailinator.example"}}))
+	handler := NewHandler(authUseCase, nil, nil, jwtService)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(`{"email":"throwaway@mailinator.example","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.Register(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusForbidden, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"email_domain_not_allowed"`) {
+		t.Errorf("Expected code email_domain_not_allowed, got %s", rec.Body.String())
+	}
+}
+
+func TestRegister_UsernameTaken(t *testing.T) {
+	handler := newTestHandler(t)
+
+	for i, expected := range []int{http.StatusCreated, http.StatusConflict} {
+		body := fmt.Sprintf(`{"email":"user%d@example.com","username":"alice","password":"password123"}`, i)
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.Register(rec, req)
+
+		if rec.Code != expected {
+			t.Fatalf("Request %d: expected status %d, got %d: %s", i+1, expected, rec.Code, rec.Body.String())
+		}
+		if expected == http.StatusConflict && !strings.Contains(rec.Body.String(), `"code":"username_taken"`) {
+			t.Errorf("Expected code username_taken, got %s", rec.Body.String())
+		}
+	}
+}
+
+// newOAuthTestHandler signs in with a Google provider whose token endpoint
+// is a local fake: it redeems "good-code" only with the verifier matching
+// the challenge of the last authorization URL.
+func newOAuthTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.URL.Query().Get("code_challenge")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || oauth.CodeChallenge(r.Form.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"google-access-token","token_type":"Bearer"}`)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"sub":"42","email":"alice@gmail.example","email_verified":true}`)
+	})
+	google := httptest.NewServer(mux)
+	t.Cleanup(google.Close)
+
+	provider := oauth.NewGoogleProvider(oauth.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost/api/auth/oauth/google/callback",
+		AuthURL:      google.URL + "/auth",
+		TokenURL:     google.URL + "/token",
+		UserInfoURL:  google.URL + "/userinfo",
+	})
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	authUseCase := usecase.NewAuthUseCase(repository.NewInMemoryUserRepository(), passwordService, jwtService,
+		usecase.WithIdentityProviders(provider))
+	return NewHandler(authUseCase, nil, nil, jwtService, WithOAuthCookieSecure(true))
+}
+
+// startOAuth follows the redirect to the provider, as a browser would, and
+// returns the state it carried and the state cookie set.
+func startOAuth(t *testing.T, handler *Handler) (string, *http.Cookie) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.OAuth(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oauth/google", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusFound, rec.Code, rec.Body.String())
+	}
+
+	location := rec.Header().Get("Location")
+	resp, err := http.Get(location)
+	if err != nil {
+		t.Fatalf("Failed to follow the redirect: %v", err)
+	}
+	resp.Body.Close()
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookieName || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected a Secure, HttpOnly, Lax state cookie, got %+v", cookies)
+	}
+	return resp.Request.URL.Query().Get("state"), cookies[0]
+}
+
+func TestOAuth_Callback(t *testing.T) {
+	handler := newOAuthTestHandler(t)
+	state, cookie := startOAuth(t, handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/google/callback?code=good-code&state="+state, nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	handler.OAuth(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp usecase.AuthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.AccessToken == "" || resp.User.Email != "alice@gmail.example" || resp.User.Provider != "google" {
+		t.Errorf("Expected a token for the new google account, got %+v", resp)
+	}
+	if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].Name != oauthStateCookieName || cleared[0].MaxAge >= 0 {
+		t.Errorf("Expected the state cookie to be cleared, got %+v", cleared)
+	}
+}
+
+func TestOAuth_CallbackRefusals(t *testing.T) {
+	handler := newOAuthTestHandler(t)
+	state, cookie := startOAuth(t, handler)
+
+	tests := []struct {
+		name       string
+		query      string
+		cookie     *http.Cookie
+		wantStatus int
+		wantCode   string
+	}{
+		{"no state cookie", "code=good-code&state=" + state, nil, http.StatusBadRequest, "oauth_state_invalid"},
+		{"state mismatch", "code=good-code&state=forged", cookie, http.StatusBadRequest, "oauth_state_invalid"},
+		{"provider error", "error=access_denied&state=" + state, cookie, http.StatusUnauthorized, "oauth_failed"},
+		{"code refused", "code=bad-code&state=" + state, cookie, http.StatusUnauthorized, "oauth_failed"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oauth/google/callback?"+tt.query, nil)
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.OAuth(rec, req)
+
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), `"code":"`+tt.wantCode+`"`) {
+			t.Errorf("%s: expected %d %s, got %d: %s", tt.name, tt.wantStatus, tt.wantCode, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.OAuth(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oauth/github", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"code":"oauth_provider_unknown"`) {
+		t.Errorf("Expected 404 oauth_provider_unknown, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	handler := newTestHandler(t)
+	routes := NewRouter(handler, handler.jwtService, RouterConfig{IntrospectionAPIKey: "service-key"}).SetupRoutes()
+
+	registered, err := handler.authUseCase.Register(context.Background(), usecase.RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	expired, err := security.NewJWTService("test-secret", "test-issuer", -time.Minute).GenerateToken(registered.User.ID, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	introspect := func(key, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		body, _ := json.Marshal(IntrospectRequest{Token: token})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/introspect", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(APIKeyHeader, key)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+
+		var resp map[string]interface{}
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp
+	}
+
+	rec, resp := introspect("service-key", registered.AccessToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if resp["active"] != true || resp["sub"] != strconv.FormatInt(registered.User.ID, 10) || resp["email"] != "test@example.com" || resp["exp"] == nil {
+		t.Errorf("Expected an active token with its claims, got %v", resp)
+	}
+
+	// Neither response says why the token was refused.
+	for name, token := range map[string]string{"expired": expired, "garbage": "not-a-jwt"} {
+		rec, resp := introspect("service-key", token)
+		if rec.Code != http.StatusOK || len(resp) != 1 || resp["active"] != false {
+			t.Errorf("%s: expected 200 with only active=false, got %d %v", name, rec.Code, resp)
+		}
+	}
+
+	if rec, _ := introspect("wrong-key", registered.AccessToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong API key to get %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestIntrospect_DisabledWithoutKey(t *testing.T) {
+	handler := newTestHandler(t)
+	routes := NewRouter(handler, handler.jwtService, RouterConfig{}).SetupRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/introspect", strings.NewReader(`{"token":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestValidateResetToken(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	passwordService, err := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to create password service: %v", err)
+	}
+	userRepo := repository.NewSQLiteUserRepository(db)
+	tokenRepo := repository.NewSQLiteTokenRepository(db)
+	if _, err := userRepo.Create(context.Background(), "test@example.com", "hash", 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	resetUseCase := usecase.NewPasswordResetUseCase(userRepo, tokenRepo, passwordService, security.NewVerificationService(), time.Hour)
+	h := NewHandler(usecase.NewAuthUseCase(userRepo, passwordService, jwtService), nil, resetUseCase, jwtService)
+	handler := NewRouter(h, jwtService, RouterConfig{}).SetupRoutes()
+
+	validate := func(token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/reset-token/validate?token="+token, nil))
+		return rec
+	}
+
+	token, err := resetUseCase.RequestPasswordReset(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to request a reset: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if rec := validate(token); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"valid":true`) {
+			t.Fatalf("Expected the token to be valid, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/reset-password",
+		strings.NewReader(`{"token":"`+token+`","new_password":"newpassword123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the validated token to still reset the password, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := validate(token); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "one_time_token_used") {
+		t.Errorf("Expected a used token to be invalid, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	expiredUseCase := usecase.NewPasswordResetUseCase(userRepo, tokenRepo, passwordService, security.NewVerificationService(), -time.Minute)
+	expired, err := expiredUseCase.RequestPasswordReset(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to request a reset: %v", err)
+	}
+	if rec := validate(expired); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "one_time_token_expired") {
+		t.Errorf("Expected an expired token to be invalid, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := validate("unknown"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "one_time_token_invalid") {
+		t.Errorf("Expected an unknown token to be invalid, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
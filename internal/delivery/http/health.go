@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// HealthCheckFunc reports whether a component works, with a short detail
+// for operators. It should give up once ctx is done.
+type HealthCheckFunc func(ctx context.Context) (ok bool, detail string)
+
+// HealthCheckResult is one check's entry in GET /health/detailed.
+type HealthCheckResult struct {
+	OK       bool   `json:"ok"`
+	Critical bool   `json:"critical"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type healthCheck struct {
+	name     string
+	critical bool
+	check    HealthCheckFunc
+}
+
+// HealthChecker is the registry behind GET /health/detailed: components
+// register named checks, and Run executes them all concurrently. A failing
+// critical check makes the instance unhealthy; any other failure only
+// degrades it.
+type HealthChecker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks []healthCheck
+}
+
+// NewHealthChecker gives every run timeout to finish; a check still running
+// then is reported as failed.
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{timeout: timeout}
+}
+
+// Register adds a check under name. Registering the same name twice panics,
+// since the second would hide the first in the report.
+func (c *HealthChecker) Register(name string, critical bool, check HealthCheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.checks {
+		if existing.name == name {
+			panic("http: health check registered twice: " + name)
+		}
+	}
+	c.checks = append(c.checks, healthCheck{name: name, critical: critical, check: check})
+}
+
+// Run executes every check and reports whether all the critical ones
+// passed.
+func (c *HealthChecker) Run(ctx context.Context) (results map[string]HealthCheckResult, healthy bool) {
+	c.mu.Lock()
+	checks := append([]healthCheck(nil), c.checks...)
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	results = make(map[string]HealthCheckResult, len(checks))
+	healthy = true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+
+			result := HealthCheckResult{Critical: check.critical}
+			result.OK, result.Detail = runHealthCheck(ctx, check.check)
+			if !result.OK {
+				log.Printf("Health check %s failed: %s", check.name, result.Detail)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[check.name] = result
+			if !result.OK && check.critical {
+				healthy = false
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return results, healthy
+}
+
+// runHealthCheck stops waiting when ctx ends, so a check that ignores its
+// context cannot hold up the response.
+func runHealthCheck(ctx context.Context, check HealthCheckFunc) (bool, string) {
+	type outcome struct {
+		ok     bool
+		detail string
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		ok, detail := check(ctx)
+		done <- outcome{ok, detail}
+	}()
+
+	select {
+	case o := <-done:
+		return o.ok, o.detail
+	case <-ctx.Done():
+		return false, "timed out"
+	}
+}
+
+// PingCheck checks that the database answers a ping.
+func PingCheck(db Pinger) HealthCheckFunc {
+	return func(ctx context.Context) (bool, string) {
+		if err := db.PingContext(ctx); err != nil {
+			return false, err.Error()
+		}
+		return true, "up"
+	}
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) PingContext(ctx context.Context) error {
+	return f(ctx)
+}
+
+func passingCheck(ctx context.Context) (bool, string) {
+	return true, "up"
+}
+
+func doHealthDetailed(t *testing.T, health *HealthChecker) (*httptest.ResponseRecorder, DetailedHealthResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	NewHandler(nil, nil, nil, nil, WithHealthChecker(health)).HealthDetailed(rec, httptest.NewRequest(http.MethodGet, "/health/detailed", nil))
+
+	var body DetailedHealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return rec, body
+}
+
+func TestHealthDetailed_AllPass(t *testing.T) {
+	health := NewHealthChecker(time.Second)
+	health.Register("db", true, PingCheck(pingerFunc(func(context.Context) error { return nil })))
+	health.Register("mail", false, passingCheck)
+
+	rec, body := doHealthDetailed(t, health)
+	if rec.Code != http.StatusOK || body.Status != "healthy" {
+		t.Fatalf("Expected 200 healthy, got %d %q", rec.Code, body.Status)
+	}
+	if check := body.Checks["db"]; !check.OK || !check.Critical || check.Detail != "up" {
+		t.Errorf("Unexpected db check: %+v", check)
+	}
+	if len(body.Checks) != 2 {
+		t.Errorf("Expected 2 checks, got %v", body.Checks)
+	}
+}
+
+func TestHealthDetailed_NonCriticalFailureDegrades(t *testing.T) {
+	health := NewHealthChecker(time.Second)
+	health.Register("db", true, passingCheck)
+	health.Register("mail", false, func(context.Context) (bool, string) { return false, "smtp unreachable" })
+
+	rec, body := doHealthDetailed(t, health)
+	if rec.Code != http.StatusOK || body.Status != "degraded" {
+		t.Fatalf("Expected 200 degraded, got %d %q", rec.Code, body.Status)
+	}
+	if check := body.Checks["mail"]; check.OK || check.Detail != "smtp unreachable" {
+		t.Errorf("Unexpected mail check: %+v", check)
+	}
+}
+
+func TestHealthDetailed_CriticalFailure(t *testing.T) {
+	health := NewHealthChecker(time.Second)
+	health.Register("db", true, PingCheck(pingerFunc(func(context.Context) error { return errors.New("database is closed") })))
+	health.Register("mail", false, passingCheck)
+
+	rec, body := doHealthDetailed(t, health)
+	if rec.Code != http.StatusServiceUnavailable || body.Status != "unhealthy" {
+		t.Fatalf("Expected 503 unhealthy, got %d %q", rec.Code, body.Status)
+	}
+	if check := body.Checks["db"]; check.OK || check.Detail != "database is closed" {
+		t.Errorf("Unexpected db check: %+v", check)
+	}
+}
+
+func TestHealthChecker_RunsConcurrentlyWithTimeout(t *testing.T) {
+	health := NewHealthChecker(200 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	// Ignores its context, so only the checker's own timeout can end it.
+	health.Register("stuck", true, func(context.Context) (bool, string) {
+		<-release
+		return true, ""
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		health.Register(name, false, func(ctx context.Context) (bool, string) {
+			time.Sleep(20 * time.Millisecond)
+			return true, ""
+		})
+	}
+
+	start := time.Now()
+	results, healthy := health.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the run to end with its timeout, took %s", elapsed)
+	}
+	if healthy {
+		t.Error("Expected the stuck critical check to make the run unhealthy")
+	}
+	if check := results["stuck"]; check.OK || check.Detail != "timed out" {
+		t.Errorf("Unexpected stuck check: %+v", check)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if !results[name].OK {
+			t.Errorf("Expected %s to pass within the timeout, got %+v", name, results[name])
+		}
+	}
+}
+
+func TestHealthChecker_DuplicateNamePanics(t *testing.T) {
+	health := NewHealthChecker(time.Second)
+	health.Register("db", true, passingCheck)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering db twice to panic")
+		}
+	}()
+	health.Register("db", false, passingCheck)
+}
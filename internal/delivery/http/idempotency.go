@@ -0,0 +1,265 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyKeyReusedCode  = "idempotency_key_reused"
+)
+
+var errIdempotencyKeyReused = errors.New("idempotency key reused with a different request body")
+
+type idempotentResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyEntry tracks one key. done is closed once the first request
+// finishes; response is nil if it failed and the key was released.
+type idempotencyEntry struct {
+	bodyHash  [sha256.Size]byte
+	done      chan struct{}
+	response  *idempotentResponse
+	expiresAt time.Time
+}
+
+// IdempotencyStore remembers responses by Idempotency-Key for TTL so a
+// retried request is answered without being processed again. Expired
+// entries are swept lazily, as in RateLimiter.
+type IdempotencyStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &IdempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+// begin claims scope for a request whose body hashes to bodyHash. The
+// caller owns the entry when owner is true and must then call complete or
+// release; otherwise it waits on entry.done.
+func (s *IdempotencyStore) begin(scope string, bodyHash [sha256.Size]byte) (entry *idempotencyEntry, owner bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	// An expired entry the sweep has not reached yet is replaced: waiting on
+	// it would find done closed and nothing to replay, forever.
+	if existing, ok := s.entries[scope]; ok && !existing.expired(now) {
+		if existing.bodyHash != bodyHash {
+			return nil, false, errIdempotencyKeyReused
+		}
+		return existing, false, nil
+	}
+
+	entry = &idempotencyEntry{bodyHash: bodyHash, done: make(chan struct{})}
+	s.entries[scope] = entry
+	return entry, true, nil
+}
+
+func (s *IdempotencyStore) complete(entry *idempotencyEntry, response *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.response = response
+	entry.expiresAt = s.now().Add(s.ttl)
+	close(entry.done)
+}
+
+// release forgets a request that did not produce a response worth
+// replaying, so the next attempt with the key is processed normally.
+func (s *IdempotencyStore) release(scope string, entry *idempotencyEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.entries[scope] == entry {
+		delete(s.entries, scope)
+	}
+	close(entry.done)
+}
+
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for scope, entry := range s.entries {
+		if entry.expired(now) {
+			delete(s.entries, scope)
+		}
+	}
+	s.lastSweep = now
+}
+
+// lookup returns the entry for scope if it has not expired.
+func (s *IdempotencyStore) lookup(scope string) *idempotencyEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[scope]
+	if !ok || entry.expired(s.now()) {
+		return nil
+	}
+	return entry
+}
+
+// expired reports whether a completed entry outlived its TTL. Entries still
+// in flight have no expiry yet.
+func (e *idempotencyEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+// IdempotencyMiddleware replays the stored response when a request repeats
+// an Idempotency-Key on the same route. A concurrent duplicate waits for the
+// first request to finish. Reusing a key with a different body is answered
+// with 422. Server errors are not stored, so they can be retried. Requests
+// without the header are unaffected.
+func IdempotencyMiddleware(store *IdempotencyStore) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respondWithError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// The body hash guards against a key being replayed for someone
+			// else's request: a registration body includes the password.
+			bodyHash := sha256.Sum256(body)
+			scope := r.Method + " " + r.URL.Path + " " + key
+			for {
+				entry, owner, err := store.begin(scope, bodyHash)
+				if err != nil {
+					respondWithJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+						Error: "Idempotency-Key was already used with a different request",
+						Code:  idempotencyKeyReusedCode,
+					})
+					return
+				}
+				if owner {
+					serveIdempotent(store, scope, entry, next, w, r)
+					return
+				}
+
+				select {
+				case <-entry.done:
+				case <-r.Context().Done():
+					return
+				}
+				if entry.response != nil && store.lookup(scope) == entry {
+					replayResponse(w, entry.response)
+					return
+				}
+				// The first request failed or expired; try to claim the key.
+			}
+		}
+	}
+}
+
+func serveIdempotent(store *IdempotencyStore, scope string, entry *idempotencyEntry, next http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	recorder := &idempotencyRecorder{ResponseWriter: w, before: w.Header().Clone()}
+	finished := false
+	defer func() {
+		// A panicking handler must not leave waiters blocked.
+		if !finished {
+			store.release(scope, entry)
+		}
+	}()
+
+	next.ServeHTTP(recorder, r)
+
+	if recorder.status == 0 {
+		recorder.WriteHeader(http.StatusOK)
+	}
+	finished = true
+	if recorder.status >= http.StatusInternalServerError {
+		store.release(scope, entry)
+		return
+	}
+	store.complete(entry, &idempotentResponse{
+		status: recorder.status,
+		header: recorder.header,
+		body:   recorder.body.Bytes(),
+	})
+}
+
+func replayResponse(w http.ResponseWriter, response *idempotentResponse) {
+	for name, values := range response.header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(response.status)
+	w.Write(response.body)
+}
+
+// idempotencyRecorder passes the response through while keeping a copy of
+// its status, body and the headers the handler set. Headers set by outer
+// middleware (CORS, security headers) are left out: they are recomputed
+// for the replayed request.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = make(http.Header)
+		for name, values := range w.Header() {
+			if !slices.Equal(values, w.before[name]) {
+				w.header[name] = slices.Clone(values)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
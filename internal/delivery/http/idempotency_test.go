@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+)
+
+func doIdempotentRegister(handler http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddleware_ReplaysSuccess(t *testing.T) {
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour))(newTestHandler(t).Register)
+	body := `{"email":"retry@example.com","password":"password123"}`
+
+	first := doIdempotentRegister(handler, "key-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+
+	second := doIdempotentRegister(handler, "key-1", body)
+	if second.Code != http.StatusCreated {
+		t.Fatalf("Expected the retry to replay %d, got %d: %s", http.StatusCreated, second.Code, second.Body.String())
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the original body to be replayed")
+	}
+	if second.Header().Get(idempotencyReplayedHeader) != "true" || first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("Expected only the retry to be marked as replayed")
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected handler headers to be replayed, got %v", second.Header())
+	}
+
+	// Without the key the request is processed again.
+	if rec := doIdempotentRegister(handler, "", body); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d without a key, got %d", http.StatusConflict, rec.Code)
+	}
+}
+
+func TestIdempotencyMiddleware_ConflictingBody(t *testing.T) {
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour))(newTestHandler(t).Register)
+
+	doIdempotentRegister(handler, "key-1", `{"email":"first@example.com","password":"password123"}`)
+	rec := doIdempotentRegister(handler, "key-1", `{"email":"second@example.com","password":"password123"}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Code != idempotencyKeyReusedCode {
+		t.Errorf("Expected code %q, got %q", idempotencyKeyReusedCode, body.Code)
+	}
+}
+
+// Two identical registrations racing with one key must both see the 201:
+// the second waits for the first instead of failing with user_exists.
+func TestIdempotencyMiddleware_ConcurrentDuplicate(t *testing.T) {
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour))(newTestHandler(t).Register)
+	body := `{"email":"race@example.com","password":"password123"}`
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = doIdempotentRegister(handler, "key-1", body)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, rec := range results {
+		if rec.Code != http.StatusCreated {
+			t.Errorf("Request %d: expected status %d, got %d: %s", i+1, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+	if results[0].Body.String() != results[1].Body.String() {
+		t.Errorf("Expected both requests to get the same response")
+	}
+}
+
+func TestIdempotencyMiddleware_ServerErrorNotStored(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour))(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		respondWithError(w, http.StatusInternalServerError, "Internal server error")
+	})
+
+	doIdempotentRegister(handler, "key-1", `{}`)
+	doIdempotentRegister(handler, "key-1", `{}`)
+
+	if calls != 2 {
+		t.Errorf("Expected a failed request to be retried, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_Expires(t *testing.T) {
+	now := time.Now()
+	store := NewIdempotencyStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	calls := 0
+	handler := IdempotencyMiddleware(store)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		respondWithJSON(w, http.StatusCreated, map[string]int{"call": calls})
+	})
+
+	doIdempotentRegister(handler, "key-1", `{}`)
+	now = now.Add(2 * time.Minute)
+	if rec := doIdempotentRegister(handler, "key-1", `{"other":true}`); rec.Code != http.StatusCreated {
+		t.Errorf("Expected an expired key to be reusable, got %d", rec.Code)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the handler to run again after expiry, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_RetryAfterExpiryBeforeSweep(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	store := NewIdempotencyStore(time.Minute)
+	store.now = now.Now
+
+	calls := 0
+	handler := IdempotencyMiddleware(store)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		respondWithJSON(w, http.StatusCreated, map[string]int{"call": calls})
+	})
+
+	doIdempotentRegister(handler, "key-0", `{}`)
+	now.Advance(50 * time.Second)
+	doIdempotentRegister(handler, "key-1", `{}`)
+	// This sweep runs while key-1 is still fresh; the next one is a TTL away.
+	now.Advance(10 * time.Second)
+	doIdempotentRegister(handler, "key-2", `{}`)
+	now.Advance(55 * time.Second)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(`{}`))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	ctx, cancel := context.WithTimeout(req.Context(), 2*time.Second)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	handler(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusCreated || rec.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Errorf("Expected the expired key to be processed again, got %d", rec.Code)
+	}
+	if calls != 4 {
+		t.Errorf("Expected the handler to run for the retry, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_KeyTooLong(t *testing.T) {
+	handler := IdempotencyMiddleware(NewIdempotencyStore(time.Hour))(okHandler)
+
+	if rec := doIdempotentRegister(handler, strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
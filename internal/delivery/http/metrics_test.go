@@ -0,0 +1,49 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_CountsRequestsByRoute(t *testing.T) {
+	metrics := NewMetrics()
+	handler := newTestRouter(RouterConfig{Metrics: metrics, ExposeMetrics: true})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	}
+	doPreflight(handler, "/api/auth/login", "https://app.example.com")
+
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues("/health", http.MethodGet, "200")); got != 2 {
+		t.Errorf("Expected 2 requests for /health, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues("/api/auth/login", http.MethodOptions, "200")); got != 1 {
+		t.Errorf("Expected 1 request for /api/auth/login, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.inFlight.WithLabelValues("/health")); got != 0 {
+		t.Errorf("Expected no in-flight requests, got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `http_requests_total{method="GET",route="/health",status="200"} 2`) {
+		t.Errorf("Expected /metrics to expose the request counter, got:\n%s", body)
+	}
+}
+
+func TestMetrics_NotExposedWhenDisabled(t *testing.T) {
+	handler := newTestRouter(RouterConfig{Metrics: NewMetrics()})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected /metrics to be absent from the API mux, got %d", rec.Code)
+	}
+}
@@ -1,68 +1,464 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/valentinfrappart/securerestapi/internal/domain"
 	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
 )
 
-type ContextKey string
+func AuthMiddleware(jwtService *security.JWTService) func(http.HandlerFunc) http.HandlerFunc {
+	return NewAuthMiddleware(jwtService, AuthConfig{})
+}
 
+// CookieAuthMiddleware reads the token from the Authorization header and, only
+// when the header is absent, from the cookieName cookie. An empty cookieName
+// disables the cookie fallback.
+func CookieAuthMiddleware(jwtService *security.JWTService, cookieName string) func(http.HandlerFunc) http.HandlerFunc {
+	return NewAuthMiddleware(jwtService, AuthConfig{CookieName: cookieName})
+}
+
+type AuthConfig struct {
+	// CookieName enables the cookie fallback described on CookieAuthMiddleware.
+	CookieName string
+	// Realm, when set, adds an RFC 6750 WWW-Authenticate challenge to every
+	// 401 so clients can tell a missing token from an expired one.
+	Realm string
+	// TokenVersions, when set, rejects tokens issued before the user's
+	// last password change, and those of deleted users.
+	TokenVersions TokenVersionSource
+}
+
+// TokenVersionSource returns the token_version a user's tokens must carry;
+// usecase.AuthUseCase implements it.
+type TokenVersionSource interface {
+	TokenVersion(ctx context.Context, userID int64) (int, error)
+}
+
+// RFC 6750 section 3.1 error codes.
 const (
-	contextKeyUserID ContextKey = "userID"
-	contextKeyEmail  ContextKey = "email"
+	bearerErrorInvalidRequest = "invalid_request"
+	bearerErrorInvalidToken   = "invalid_token"
 )
 
-func AuthMiddleware(jwtService *security.JWTService) func(http.HandlerFunc) http.HandlerFunc {
+func NewAuthMiddleware(jwtService *security.JWTService, config AuthConfig) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				respondWithError(w, http.StatusUnauthorized, "Missing authorization header")
-				return
+			// err picks the response code; nil gives the generic one.
+			unauthorized := func(bearerError, description, message string, err error) {
+				if config.Realm != "" {
+					w.Header().Set("WWW-Authenticate", bearerChallenge(config.Realm, bearerError, description))
+				}
+				respondWithDomainError(w, http.StatusUnauthorized, err, message)
 			}
 
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				respondWithError(w, http.StatusUnauthorized, "Invalid authorization header format")
+			var token string
+			viaCookie := false
+			if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+				parts := strings.Split(authHeader, " ")
+				if len(parts) != 2 || parts[0] != "Bearer" {
+					unauthorized(bearerErrorInvalidRequest, "Malformed Authorization header", "Invalid authorization header format", nil)
+					return
+				}
+				token = parts[1]
+			} else if cookie, err := r.Cookie(config.CookieName); config.CookieName != "" && err == nil && cookie.Value != "" {
+				token = cookie.Value
+				viaCookie = true
+			} else {
+				// No credentials at all: RFC 6750 says to omit the error code.
+				unauthorized("", "", "Missing authorization header", nil)
 				return
 			}
 
-			token := parts[1]
-
 			claims, err := jwtService.ValidateToken(token)
 			if err != nil {
-				respondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+				description := "The access token is invalid"
+				switch {
+				case errors.Is(err, domain.ErrTokenExpired):
+					log.Printf("Rejected expired token from %s", r.RemoteAddr)
+					description = "The access token expired"
+				case errors.Is(err, domain.ErrTokenNotYetValid):
+					log.Printf("Rejected token used before its nbf from %s", r.RemoteAddr)
+					description = "The access token is not valid yet"
+				case errors.Is(err, domain.ErrTokenRevoked):
+					log.Printf("Rejected revoked token from %s", r.RemoteAddr)
+					description = "The access token has been revoked"
+				case errors.Is(err, domain.ErrTokenMissingID):
+					log.Printf("Rejected token without jti from %s", r.RemoteAddr)
+					description = "The access token predates revocation support; sign in again"
+				default:
+					log.Printf("Rejected invalid token from %s: %v", r.RemoteAddr, err)
+				}
+				unauthorized(bearerErrorInvalidToken, description, "Invalid or expired token", err)
+				return
+			}
+
+			if config.TokenVersions != nil {
+				version, err := config.TokenVersions.TokenVersion(r.Context(), claims.UserID)
+				if errors.Is(err, domain.ErrUserNotFound) {
+					log.Printf("Rejected token of deleted user %d from %s", claims.UserID, r.RemoteAddr)
+					unauthorized(bearerErrorInvalidToken, "The account no longer exists", "Invalid or expired token", domain.ErrInvalidToken)
+					return
+				}
+				if err != nil {
+					respondWithServerError(w, err)
+					return
+				}
+				if claims.TokenVersion != version {
+					log.Printf("Rejected token predating a password change from %s", r.RemoteAddr)
+					unauthorized(bearerErrorInvalidToken, "The access token was revoked by a password change", "Invalid or expired token", domain.ErrTokenRevoked)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(contextWithAuth(r.Context(), claims, viaCookie)))
+		}
+	}
+}
+
+// bearerChallenge formats a WWW-Authenticate value; bearerError and
+// description are omitted when empty.
+func bearerChallenge(realm, bearerError, description string) string {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	challenge := fmt.Sprintf(`Bearer realm="%s"`, quote.Replace(realm))
+	if bearerError != "" {
+		challenge += fmt.Sprintf(`, error="%s"`, bearerError)
+	}
+	if description != "" {
+		challenge += fmt.Sprintf(`, error_description="%s"`, quote.Replace(description))
+	}
+	return challenge
+}
+
+func RequireRole(role string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userRole, ok := roleFromContext(r.Context())
+			if !ok || userRole != role {
+				respondWithError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// RequireRecentAuth guards sensitive actions: a token whose auth_time is
+// older than maxAge gets 401 reauth_required even though it is still valid,
+// so the user has to sign in again. Age is measured by jwtService's clock
+// and leeway, like token expiry. Tokens without auth_time count as stale.
+// It must run after the auth middleware.
+func RequireRecentAuth(jwtService *security.JWTService, maxAge time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := claimsFromContext(r.Context())
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !jwtService.AuthTimeWithin(claims, maxAge) {
+				respondWithDomainError(w, http.StatusUnauthorized, domain.ErrReauthRequired, "Please sign in again to continue")
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), contextKeyUserID, claims.UserID)
-			ctx = context.WithValue(ctx, contextKeyEmail, claims.Email)
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies. It only applies to
+	// allowlisted origins, never to "*".
+	AllowCredentials bool
+}
 
-			next.ServeHTTP(w, r.WithContext(ctx))
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", idempotencyKeyHeader, csrfHeader},
+	}
+}
+
+// NewCORSMiddleware applies config to a route. With a "*" origin any site
+// is allowed; otherwise only listed origins are echoed back.
+func NewCORSMiddleware(config CORSConfig) func(http.HandlerFunc) http.HandlerFunc {
+	defaults := DefaultCORSConfig()
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = defaults.AllowedMethods
+	}
+	if len(config.AllowedHeaders) == 0 {
+		config.AllowedHeaders = defaults.AllowedHeaders
+	}
+
+	wildcard := false
+	allowed := make(map[string]bool, len(config.AllowedOrigins))
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			wildcard = true
+		}
+		allowed[origin] = true
+	}
+
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			switch {
+			case wildcard:
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && allowed[origin]:
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+				if config.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
 		}
 	}
 }
 
 func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+	return NewCORSMiddleware(DefaultCORSConfig())(next)
+}
+
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is sent as Strict-Transport-Security on TLS requests only;
+	// zero disables the header.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// HSTSPreload opts into browser preload lists, which require
+	// includeSubDomains and a max-age of at least a year; both are enforced.
+	HSTSPreload bool
+	// ContentSecurityPolicy is omitted when empty.
+	ContentSecurityPolicy string
+}
+
+const hstsPreloadMinMaxAge = 365 * 24 * time.Hour
+
+func SecurityHeadersMiddleware(config SecurityHeadersConfig) func(http.HandlerFunc) http.HandlerFunc {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		maxAge := config.HSTSMaxAge
+		if config.HSTSPreload && maxAge < hstsPreloadMinMaxAge {
+			maxAge = hstsPreloadMinMaxAge
+		}
+		hsts = fmt.Sprintf("max-age=%d", int64(maxAge.Seconds()))
+		if config.HSTSIncludeSubdomains || config.HSTSPreload {
+			hsts += "; includeSubDomains"
 		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("X-Frame-Options", "DENY")
+			w.Header().Set("Referrer-Policy", "no-referrer")
+			if config.ContentSecurityPolicy != "" {
+				w.Header().Set("Content-Security-Policy", config.ContentSecurityPolicy)
+			}
+			// Browsers ignore HSTS received over plain HTTP.
+			if hsts != "" && r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// TimeoutMiddleware cancels the request context after d and answers 503 if
+// the handler has not finished by then. The handler's own response is
+// buffered and discarded once the deadline passes. A zero d disables it.
+func TimeoutMiddleware(d time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if d <= 0 {
+			return next
+		}
+
+		timeout := http.TimeoutHandler(next, d, `{"error":"request timeout","code":"request_timeout"}`)
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Only reaches the client on timeout; a completed handler's
+			// headers replace it.
+			w.Header().Set("Content-Type", "application/json")
+			timeout.ServeHTTP(w, r)
+		}
+	}
+}
+
+// DefaultMaxBodyBytes is the request body limit applied to every route
+// unless configured otherwise.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// MaxBodyBytesMiddleware caps request bodies at n bytes on every route,
+// including unknown ones, so no path can be used to stream an unbounded
+// payload. A declared Content-Length over n is refused with 413 before the
+// handler runs; a body that grows past n fails to read with
+// *http.MaxBytesError, which decodeJSONBody reports as 413 too. A zero n
+// disables it.
+func MaxBodyBytesMiddleware(n int64) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if n <= 0 {
+			return next
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
 
-		next.ServeHTTP(w, r)
+func (w *statusRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
 	}
+	w.ResponseWriter.WriteHeader(code)
 }
 
-func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r)
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+const bodySignatureHeader = "X-Body-Signature"
+
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// BodySignatureMiddleware buffers the response and sets X-Body-Signature to
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of the body under key.
+func BodySignatureMiddleware(key []byte) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			buffered := &bufferedResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(buffered, r)
+
+			if buffered.status == 0 {
+				buffered.status = http.StatusOK
+			}
+
+			w.Header().Set(bodySignatureHeader, "sha256="+signBody(key, buffered.body.Bytes()))
+			w.WriteHeader(buffered.status)
+			w.Write(buffered.body.Bytes())
+		}
 	}
 }
+
+func signBody(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// methodGuard rejects methods the route does not serve with 405 and an Allow
+// header. HEAD is served by the GET handler with the body discarded, and an
+// OPTIONS request without an Origin is answered with the Allow list; CORS
+// requests are passed on so the CORS middleware can answer them.
+func methodGuard(allowed ...string) func(http.HandlerFunc) http.HandlerFunc {
+	methods := make(map[string]bool, len(allowed)+2)
+	for _, method := range allowed {
+		methods[method] = true
+	}
+	list := append([]string(nil), allowed...)
+	headViaGet := methods[http.MethodGet] && !methods[http.MethodHead]
+	if headViaGet {
+		methods[http.MethodHead] = true
+		list = append(list, http.MethodHead)
+	}
+	list = append(list, http.MethodOptions)
+	allow := strings.Join(list, ", ")
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodOptions:
+				w.Header().Set("Allow", allow)
+				if r.Header.Get("Origin") == "" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+			case !methods[r.Method]:
+				w.Header().Set("Allow", allow)
+				respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			case r.Method == http.MethodHead && headViaGet:
+				head := r.Clone(r.Context())
+				head.Method = http.MethodGet
+				next.ServeHTTP(headResponseWriter{w}, head)
+			default:
+				next.ServeHTTP(w, r)
+			}
+		}
+	}
+}
+
+// headResponseWriter keeps the headers and status of a GET response but
+// drops its body.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
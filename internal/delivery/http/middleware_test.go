@@ -0,0 +1,408 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func TestBodySignatureMiddleware_SignsBody(t *testing.T) {
+	key := []byte("shared-signing-key")
+	handler := BodySignatureMiddleware(key)(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected Content-Type to be preserved, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(rec.Body.Bytes())
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := rec.Header().Get("X-Body-Signature"); got != expected {
+		t.Errorf("Expected signature %s, got %s", expected, got)
+	}
+}
+
+func TestBodySignatureMiddleware_DifferentKeyDoesNotMatch(t *testing.T) {
+	handler := BodySignatureMiddleware([]byte("server-key"))(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Header().Get("X-Body-Signature") == "sha256="+signBody([]byte("other-key"), rec.Body.Bytes()) {
+		t.Error("Expected signature computed with another key not to match")
+	}
+}
+
+func doRoleRequest(t *testing.T, jwtService *security.JWTService, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	token, err := jwtService.GenerateToken(1, "test@example.com", role)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	handler := applyMiddlewares(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}, AuthMiddleware(jwtService), RequireRole(domain.RoleAdmin))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/ping", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestRequireRole_Allowed(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	rec := doRoleRequest(t, jwtService, domain.RoleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestRequireRole_Forbidden(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	rec := doRoleRequest(t, jwtService, domain.RoleUser)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func doRecentAuthRequest(t *testing.T, jwtService *security.JWTService, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	handler := applyMiddlewares(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, AuthMiddleware(jwtService), RequireRecentAuth(jwtService, 15*time.Minute))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour, security.WithClock(now), security.WithLeeway(30*time.Second))
+
+	token, err := jwtService.GenerateToken(1, "admin@example.com", domain.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if rec := doRecentAuthRequest(t, jwtService, token); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected a fresh sign-in to pass, got %d", rec.Code)
+	}
+
+	// Past maxAge but within the leeway still passes.
+	now.Advance(15*time.Minute + 20*time.Second)
+	if rec := doRecentAuthRequest(t, jwtService, token); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected a sign-in within the leeway to pass, got %d", rec.Code)
+	}
+
+	// The token itself is valid for another 44 minutes, but the sign-in it
+	// carries is 16 minutes old.
+	now.Advance(40 * time.Second)
+	rec := doRecentAuthRequest(t, jwtService, token)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `"code":"reauth_required"`) {
+		t.Errorf("Expected code reauth_required, got %s", rec.Body.String())
+	}
+}
+
+func TestRequireRecentAuth_MissingAuthTime(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	// Tokens issued before auth_time was added cannot prove a recent sign-in.
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, security.Claims{
+		UserID: 1,
+		Role:   domain.RoleAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "test-issuer",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	if rec := doRecentAuthRequest(t, jwtService, token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func doCookieAuthRequest(jwtService *security.JWTService, cookieName, header, cookie string) *httptest.ResponseRecorder {
+	handler := CookieAuthMiddleware(jwtService, cookieName)(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: cookie})
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestCookieAuthMiddleware_Transports(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	token, err := jwtService.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		cookieName string
+		header     string
+		cookie     string
+		expected   int
+	}{
+		{"header", "access_token", "Bearer " + token, "", http.StatusOK},
+		{"cookie", "access_token", "", token, http.StatusOK},
+		{"header takes precedence over cookie", "access_token", "Bearer invalid", token, http.StatusUnauthorized},
+		{"cookie ignored when disabled", "", "", token, http.StatusUnauthorized},
+		{"neither", "access_token", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doCookieAuthRequest(jwtService, tt.cookieName, tt.header, tt.cookie)
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersMiddleware_SetsHeaders(t *testing.T) {
+	handler := SecurityHeadersMiddleware(SecurityHeadersConfig{
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'none'",
+	})(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "https://api.example.com/health", nil))
+
+	expected := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+		"Content-Security-Policy":   "default-src 'none'",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+	}
+	for header, value := range expected {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestSecurityHeadersMiddleware_OptionalHeaders(t *testing.T) {
+	handler := SecurityHeadersMiddleware(SecurityHeadersConfig{HSTSMaxAge: time.Hour})(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "http://api.example.com/health", nil))
+
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected no HSTS over plain HTTP, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Expected no CSP when not configured, got %q", got)
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("Expected nosniff on every response, got %q", got)
+	}
+}
+
+func TestTimeoutMiddleware_SlowHandler(t *testing.T) {
+	ctxErr := make(chan error, 1)
+	handler := TimeoutMiddleware(20 * time.Millisecond)(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			ctxErr <- r.Context().Err()
+		case <-time.After(time.Second):
+			ctxErr <- nil
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "too late"})
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/auth/me", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Body.String(); got != `{"error":"request timeout","code":"request_timeout"}` {
+		t.Errorf("Expected timeout body, got %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", got)
+	}
+
+	if err := <-ctxErr; err != context.DeadlineExceeded {
+		t.Errorf("Expected handler context to be cancelled with DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTimeoutMiddleware_FastHandler(t *testing.T) {
+	handler := TimeoutMiddleware(time.Second)(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/auth/me", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+}
+
+func TestMaxBodyBytesMiddleware_OversizedRegister(t *testing.T) {
+	handler := newTestRouter(RouterConfig{MaxBodyBytes: 64})
+	body := `{"email":"user@example.com","password":"` + strings.Repeat("a", 100) + `"}`
+
+	for _, declaredLength := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if !declaredLength {
+			// A chunked upload is only caught while the handler reads it.
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("Content-Length declared %v: expected status %d, got %d", declaredLength, http.StatusRequestEntityTooLarge, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), `"code":"payload_too_large"`) {
+			t.Errorf("Content-Length declared %v: expected payload_too_large, got %s", declaredLength, rec.Body.String())
+		}
+	}
+}
+
+func TestMaxBodyBytesMiddleware_UnknownRoute(t *testing.T) {
+	handler := newTestRouter(RouterConfig{MaxBodyBytes: 64})
+
+	req := httptest.NewRequest(http.MethodPost, "/no-such-route", strings.NewReader(strings.Repeat("a", 100)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestSecurityHeadersMiddleware_HSTSPreload(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		expected string
+	}{
+		{"long max-age", 2 * 365 * 24 * time.Hour, "max-age=63072000; includeSubDomains; preload"},
+		{"short max-age raised to preload minimum", time.Hour, "max-age=31536000; includeSubDomains; preload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := SecurityHeadersMiddleware(SecurityHeadersConfig{HSTSMaxAge: tt.maxAge, HSTSPreload: true})(okHandler)
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "https://api.example.com/health", nil))
+
+			if got := rec.Header().Get("Strict-Transport-Security"); got != tt.expected {
+				t.Errorf("Expected HSTS %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewAuthMiddleware_WWWAuthenticate(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	valid, _ := jwtService.GenerateToken(1, "test@example.com", domain.RoleUser)
+	expired, _ := security.NewJWTService("test-secret", "test-issuer", -time.Minute).GenerateToken(1, "test@example.com", domain.RoleUser)
+	scheduled, _ := jwtService.GenerateToken(1, "test@example.com", domain.RoleUser, security.NotBefore(time.Now().Add(time.Hour)))
+
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"missing token", "", `Bearer realm="api"`},
+		{"malformed header", "Token abc", `Bearer realm="api", error="invalid_request", error_description="Malformed Authorization header"`},
+		{"expired token", "Bearer " + expired, `Bearer realm="api", error="invalid_token", error_description="The access token expired"`},
+		{"token not valid yet", "Bearer " + scheduled, `Bearer realm="api", error="invalid_token", error_description="The access token is not valid yet"`},
+		{"invalid token", "Bearer not-a-jwt", `Bearer realm="api", error="invalid_token", error_description="The access token is invalid"`},
+		{"valid token", "Bearer " + valid, ""},
+	}
+
+	handler := NewAuthMiddleware(jwtService, AuthConfig{Realm: "api"})(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.expected {
+				t.Errorf("Expected WWW-Authenticate %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewAuthMiddleware_NoChallengeWithoutRealm(t *testing.T) {
+	rec := doCookieAuthRequest(security.NewJWTService("test-secret", "test-issuer", time.Hour), "", "", "")
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("Expected no challenge without a realm, got %q", got)
+	}
+}
+
+func TestBearerChallenge_EscapesQuotes(t *testing.T) {
+	got := bearerChallenge(`my "api"`, "", "")
+	if got != `Bearer realm="my \"api\""` {
+		t.Errorf("Expected quotes to be escaped, got %s", got)
+	}
+}
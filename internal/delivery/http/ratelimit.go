@@ -0,0 +1,109 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+)
+
+type RateLimitConfig struct {
+	Rate    float64
+	Burst   int
+	IdleTTL time.Duration
+	// TrustedProxies may set X-Forwarded-For; nil keys buckets by the
+	// connection's address.
+	TrustedProxies *IPAllowlist
+	// Clock defaults to the system clock.
+	Clock clock.Clock
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// RateLimiter is a per-key token bucket. Buckets idle for longer than
+// IdleTTL are swept lazily so memory stays bounded by active clients.
+type RateLimiter struct {
+	config    RateLimitConfig
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	clock     clock.Clock
+}
+
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.IdleTTL <= 0 {
+		config.IdleTTL = 10 * time.Minute
+	}
+	return &RateLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+		clock:   clock.OrReal(config.Clock),
+	}
+}
+
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(l.config.Burst), lastSeen: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	bucket.tokens = math.Min(float64(l.config.Burst), bucket.tokens+elapsed*l.config.Rate)
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.config.Rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.config.IdleTTL {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= l.config.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	return clientIP(r, l.config.TrustedProxies)
+}
+
+func RateLimitMiddleware(limiter *RateLimiter) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter := limiter.Allow(limiter.clientIP(r))
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				respondWithError(w, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
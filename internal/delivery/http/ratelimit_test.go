@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+)
+
+func newTestRateLimiter(config RateLimitConfig, now *clock.Fake) *RateLimiter {
+	config.Clock = now
+	return NewRateLimiter(config)
+}
+
+func doRateLimitedRequest(handler http.HandlerFunc, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func TestRateLimitMiddleware_ExhaustsBucket(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	limiter := newTestRateLimiter(RateLimitConfig{Rate: 1, Burst: 3}, now)
+	handler := RateLimitMiddleware(limiter)(okHandler)
+
+	for i := 0; i < 3; i++ {
+		if rec := doRateLimitedRequest(handler, "10.0.0.1:1234", ""); rec.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to pass, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := doRateLimitedRequest(handler, "10.0.0.1:1234", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", rec.Header().Get("Retry-After"))
+	}
+
+	if rec := doRateLimitedRequest(handler, "10.0.0.2:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected another IP to have its own bucket, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddleware_Refills(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	limiter := newTestRateLimiter(RateLimitConfig{Rate: 2, Burst: 1}, now)
+	handler := RateLimitMiddleware(limiter)(okHandler)
+
+	doRateLimitedRequest(handler, "10.0.0.1:1234", "")
+	if rec := doRateLimitedRequest(handler, "10.0.0.1:1234", ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected bucket to be empty, got %d", rec.Code)
+	}
+
+	now.Advance(500 * time.Millisecond)
+
+	if rec := doRateLimitedRequest(handler, "10.0.0.1:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected bucket to refill after 500ms, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddleware_ForwardedFor(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	proxies, _ := ParseIPAllowlist([]string{"10.0.0.0/8"})
+	limiter := newTestRateLimiter(RateLimitConfig{Rate: 1, Burst: 1, TrustedProxies: proxies}, now)
+	handler := RateLimitMiddleware(limiter)(okHandler)
+
+	doRateLimitedRequest(handler, "10.0.0.1:1234", "203.0.113.7, 10.0.0.1")
+	if rec := doRateLimitedRequest(handler, "10.0.0.1:1234", "203.0.113.8"); rec.Code != http.StatusOK {
+		t.Errorf("Expected distinct forwarded clients to be limited separately, got %d", rec.Code)
+	}
+	if rec := doRateLimitedRequest(handler, "10.0.0.9:1234", "203.0.113.7"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected forwarded client to be limited, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_ExpiresIdleBuckets(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	limiter := newTestRateLimiter(RateLimitConfig{Rate: 1, Burst: 1, IdleTTL: time.Minute}, now)
+
+	limiter.Allow("10.0.0.1")
+	limiter.Allow("10.0.0.2")
+
+	now.Advance(2 * time.Minute)
+	limiter.Allow("10.0.0.3")
+
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, got %d buckets", len(limiter.buckets))
+	}
+}
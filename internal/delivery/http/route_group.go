@@ -0,0 +1,101 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Middleware wraps a handler; the first one passed to a group runs first.
+type Middleware = func(http.HandlerFunc) http.HandlerFunc
+
+// RouteGroup registers routes that share a path prefix and a middleware
+// chain. A subgroup runs its parent's middlewares before its own, so
+// protected routes only have to name what they add.
+type RouteGroup struct {
+	prefix      string
+	middlewares []Middleware
+	routes      *routeTable
+}
+
+// routeTable is shared by a group and all its subgroups, so methods
+// registered on the same path from different groups end up behind one
+// methodGuard.
+type routeTable struct {
+	paths  []string
+	byPath map[string]*route
+}
+
+type route struct {
+	methods  []string
+	handlers map[string]http.HandlerFunc
+}
+
+func NewRouteGroup(middlewares ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		middlewares: middlewares,
+		routes:      &routeTable{byPath: make(map[string]*route)},
+	}
+}
+
+// Group returns a subgroup under prefix whose routes run middlewares after
+// the ones of g.
+func (g *RouteGroup) Group(prefix string, middlewares ...Middleware) *RouteGroup {
+	chain := make([]Middleware, 0, len(g.middlewares)+len(middlewares))
+	chain = append(chain, g.middlewares...)
+	chain = append(chain, middlewares...)
+	return &RouteGroup{
+		prefix:      g.prefix + prefix,
+		middlewares: chain,
+		routes:      g.routes,
+	}
+}
+
+// Handle serves method on the group's prefix plus path. Registering the
+// same method and path twice panics, as http.ServeMux does.
+func (g *RouteGroup) Handle(method, path string, handler http.HandlerFunc) {
+	path = g.prefix + path
+	r, ok := g.routes.byPath[path]
+	if !ok {
+		r = &route{handlers: make(map[string]http.HandlerFunc)}
+		g.routes.byPath[path] = r
+		g.routes.paths = append(g.routes.paths, path)
+	}
+	if _, ok := r.handlers[method]; ok {
+		panic(fmt.Sprintf("http: multiple registrations for %s %s", method, path))
+	}
+	r.methods = append(r.methods, method)
+	r.handlers[method] = applyMiddlewares(handler, g.middlewares...)
+}
+
+// Register adds every route of the group's tree to mux. Methods not
+// registered on a path are answered by methodGuard.
+func (g *RouteGroup) Register(mux *http.ServeMux) {
+	for _, path := range g.routes.paths {
+		mux.HandleFunc(path, g.routes.byPath[path].handler())
+	}
+}
+
+// Routes lists every route of the group's tree as "METHOD path", in the
+// order they were registered.
+func (g *RouteGroup) Routes() []string {
+	var routes []string
+	for _, path := range g.routes.paths {
+		for _, method := range g.routes.byPath[path].methods {
+			routes = append(routes, method+" "+path)
+		}
+	}
+	return routes
+}
+
+func (r *route) handler() http.HandlerFunc {
+	dispatch := func(w http.ResponseWriter, req *http.Request) {
+		handler, ok := r.handlers[req.Method]
+		if !ok {
+			// A CORS preflight: the first method's chain holds the CORS
+			// middleware that answers it.
+			handler = r.handlers[r.methods[0]]
+		}
+		handler(w, req)
+	}
+	return methodGuard(r.methods...)(dispatch)
+}
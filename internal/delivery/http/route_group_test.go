@@ -0,0 +1,111 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tagMiddleware records name in calls each time a request passes through.
+func tagMiddleware(calls *[]string, name string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+func TestRouteGroup_MiddlewareRunsForAllRoutes(t *testing.T) {
+	var calls []string
+	routes := NewRouteGroup(tagMiddleware(&calls, "root"))
+	api := routes.Group("/api", tagMiddleware(&calls, "api"))
+	api.Handle(http.MethodGet, "/a", okHandler)
+	api.Handle(http.MethodPost, "/b", okHandler)
+	api.Group("/admin", tagMiddleware(&calls, "admin")).Handle(http.MethodGet, "/c", okHandler)
+	routes.Handle(http.MethodGet, "/health", okHandler)
+
+	mux := http.NewServeMux()
+	routes.Register(mux)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{http.MethodGet, "/api/a", "root,api"},
+		{http.MethodPost, "/api/b", "root,api"},
+		{http.MethodGet, "/api/admin/c", "root,api,admin"},
+		{http.MethodGet, "/health", "root"},
+	}
+
+	for _, tt := range tests {
+		calls = nil
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, http.StatusOK, rec.Code)
+		}
+		if got := strings.Join(calls, ","); got != tt.expected {
+			t.Errorf("%s %s: expected middlewares %q, got %q", tt.method, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestRouteGroup_MethodsShareAPath(t *testing.T) {
+	var calls []string
+	routes := NewRouteGroup()
+	routes.Group("", tagMiddleware(&calls, "read")).Handle(http.MethodGet, "/me", okHandler)
+	routes.Group("", tagMiddleware(&calls, "write")).Handle(http.MethodPatch, "/me", okHandler)
+
+	mux := http.NewServeMux()
+	routes.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/me", nil))
+	if rec.Code != http.StatusOK || strings.Join(calls, ",") != "write" {
+		t.Errorf("Expected PATCH to run only its own chain, got %d and %v", rec.Code, calls)
+	}
+
+	// Unregistered methods never reach a chain.
+	calls = nil
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/me", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "GET, PATCH, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow to list both methods, got %q", got)
+	}
+	if len(calls) != 0 {
+		t.Errorf("Expected no middleware to run, got %v", calls)
+	}
+}
+
+func TestRouteGroup_DuplicatePanics(t *testing.T) {
+	routes := NewRouteGroup()
+	routes.Handle(http.MethodGet, "/a", okHandler)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a duplicate registration to panic")
+		}
+	}()
+	routes.Group("").Handle(http.MethodGet, "/a", okHandler)
+}
+
+func TestRouteGroup_Routes(t *testing.T) {
+	routes := NewRouteGroup()
+	routes.Handle(http.MethodGet, "/health", okHandler)
+	api := routes.Group("/api")
+	api.Handle(http.MethodGet, "/auth/me", okHandler)
+	api.Group("/admin").Handle(http.MethodDelete, "/users/", okHandler)
+	api.Handle(http.MethodPatch, "/auth/me", okHandler)
+
+	expected := "GET /health,GET /api/auth/me,PATCH /api/auth/me,DELETE /api/admin/users/"
+	if got := strings.Join(routes.Routes(), ","); got != expected {
+		t.Errorf("Expected routes %q, got %q", expected, got)
+	}
+}
@@ -1,36 +1,243 @@
 package http
 
 import (
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/valentinfrappart/securerestapi/internal/domain"
 	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
 )
 
+type RouterConfig struct {
+	ResponseSigningKey []byte
+	RateLimiter        *RateLimiter
+	AuthCORS           CORSConfig
+	BasePath           string
+	Metrics            *Metrics
+	ExposeMetrics      bool
+	SecurityHeaders    SecurityHeadersConfig
+	RequestTimeout     time.Duration
+	AuthRealm          string
+	// TrustedProxies are allowed to set X-Forwarded-For and X-Real-IP;
+	// nil uses the connection's address for every client.
+	TrustedProxies    *IPAllowlist
+	InternalAllowlist *IPAllowlist
+	// CompressionMinSize is the smallest response gzipped for clients that
+	// accept it; zero disables compression.
+	CompressionMinSize int
+	// MaxBodyBytes caps request bodies on every route; zero disables the
+	// global limit, leaving only the per-handler one.
+	MaxBodyBytes int64
+	// Idempotency replays responses to retried registrations that carry an
+	// Idempotency-Key; nil disables it.
+	Idempotency *IdempotencyStore
+	// ReauthMaxAge is how recent a sign-in sensitive actions require; zero
+	// disables the check.
+	ReauthMaxAge time.Duration
+	// DebugBodies logs request and response bodies, with secrets redacted.
+	DebugBodies bool
+	// SlowRequestThreshold logs requests slower than it to
+	// SlowRequestLogger; zero disables the log.
+	SlowRequestThreshold time.Duration
+	SlowRequestLogger    *slog.Logger
+	// IntrospectionAPIKey is the key services present to introspect
+	// tokens; empty leaves the endpoint unregistered.
+	IntrospectionAPIKey string
+}
+
 type Router struct {
 	handler    *Handler
 	jwtService *security.JWTService
+	config     RouterConfig
+	routes     []string
 }
 
-func NewRouter(handler *Handler, jwtService *security.JWTService) *Router {
+func NewRouter(handler *Handler, jwtService *security.JWTService, config RouterConfig) *Router {
 	return &Router{
 		handler:    handler,
 		jwtService: jwtService,
+		config:     config,
 	}
 }
 
-func (rt *Router) SetupRoutes() *http.ServeMux {
+func (rt *Router) SetupRoutes() http.Handler {
+	routes := NewRouteGroup()
+
+	public := routes.Group("", CORSMiddleware, rt.timeout, rt.rateLimit)
+	public.Handle(http.MethodGet, "/health", rt.handler.Health)
+	public.Handle(http.MethodGet, "/ready", rt.handler.Ready)
+	public.Handle(http.MethodGet, "/version", rt.handler.Version)
+	public.Handle(http.MethodGet, "/openapi.yaml", rt.handler.OpenAPISpec)
+	public.Handle(http.MethodGet, "/docs", rt.handler.Docs)
+
+	internal := routes.Group("", rt.internalOnly, rt.timeout)
+	internal.Handle(http.MethodGet, "/health/detailed", rt.handler.HealthDetailed)
+
+	api := routes.Group("/api", NewCORSMiddleware(rt.config.AuthCORS), rt.timeout, rt.rateLimit, rt.signResponses)
+	api.Group("", rt.idempotent).Handle(http.MethodPost, "/auth/register", rt.handler.Register)
+	api.Handle(http.MethodPost, "/auth/login", rt.handler.Login)
+	api.Handle(http.MethodPost, "/auth/forgot-password", rt.handler.ForgotPassword)
+	api.Handle(http.MethodPost, "/auth/reset-password", rt.handler.ResetPassword)
+	api.Handle(http.MethodGet, "/auth/reset-token/validate", rt.handler.ValidateResetToken)
+	api.Handle(http.MethodGet, "/auth/verify", rt.handler.VerifyEmail)
+	api.Handle(http.MethodGet, "/auth/confirm-email", rt.handler.ConfirmEmailChange)
+	api.Handle(http.MethodGet, "/auth/methods", rt.handler.AuthMethods)
+	api.Handle(http.MethodPost, "/auth/refresh", rt.handler.Refresh)
+	api.Handle(http.MethodPost, "/auth/2fa/verify", rt.handler.TOTPVerify)
+	api.Handle(http.MethodGet, "/auth/oauth/", rt.handler.OAuth)
+	if rt.config.IntrospectionAPIKey != "" {
+		api.Group("", APIKeyMiddleware(rt.config.IntrospectionAPIKey)).Handle(http.MethodPost, "/auth/introspect", rt.handler.Introspect)
+	}
+
+	protected := api.Group("", rt.authenticate, rt.csrf)
+	protected.Handle(http.MethodGet, "/auth/me", rt.handler.Me)
+	protected.Handle(http.MethodPatch, "/auth/me", rt.handler.UpdateMe)
+	protected.Handle(http.MethodPost, "/auth/logout", rt.handler.Logout)
+	protected.Handle(http.MethodGet, "/auth/token", rt.handler.TokenClaims)
+	protected.Handle(http.MethodPut, "/auth/me/email", rt.handler.ChangeEmail)
+	protected.Group("", rt.recentAuth).Handle(http.MethodPut, "/auth/me/password", rt.handler.ChangePassword)
+	protected.Handle(http.MethodPost, "/auth/2fa/setup", rt.handler.TOTPSetup)
+	protected.Handle(http.MethodPost, "/auth/2fa/confirm", rt.handler.TOTPConfirm)
+	protected.Handle(http.MethodGet, "/auth/sessions", rt.handler.ListSessions)
+	protected.Handle(http.MethodDelete, "/auth/sessions/", rt.handler.RevokeSession)
+	protected.Handle(http.MethodPost, "/auth/send-verification", rt.handler.SendVerification)
+
+	admin := protected.Group("", RequireRole(domain.RoleAdmin))
+	admin.Handle(http.MethodGet, "/users", rt.handler.ListUsers)
+	admin.Handle(http.MethodGet, "/users/by-email", rt.handler.GetUserByEmail)
+	admin.Handle(http.MethodPost, "/admin/users/import", rt.handler.ImportUsers)
+	admin.Group("", rt.recentAuth).Handle(http.MethodDelete, "/admin/users/", rt.handler.DeleteUser)
+	admin.Group("", rt.recentAuth).Handle(http.MethodPost, "/admin/users/restore/", rt.handler.RestoreUser)
+	admin.Handle(http.MethodGet, "/admin/audit", rt.handler.ListAuditEvents)
+	admin.Handle(http.MethodGet, "/admin/password-policy/impact", rt.handler.PasswordPolicyImpact)
+	admin.Handle(http.MethodGet, "/admin/ping", rt.handler.AdminPing)
+
+	rt.routes = routes.Routes()
 	mux := http.NewServeMux()
+	routes.Register(mux)
+	// The most general pattern: ServeMux only falls back to it when no
+	// route matches.
+	mux.HandleFunc("/", notFound)
+
+	var handler http.Handler = mux
+	if rt.config.Metrics != nil {
+		if rt.config.ExposeMetrics {
+			mux.HandleFunc("/metrics", rt.internalOnly(rt.config.Metrics.Handler().ServeHTTP))
+		}
+		handler = rt.config.Metrics.Instrument(mux)
+	}
+	// Like the metrics, the slow request log sits right around the mux and
+	// reports the pattern it matched, without any base path.
+	handler = SlowRequestMiddleware(mux, rt.config.SlowRequestThreshold, rt.config.SlowRequestLogger)(handler.ServeHTTP)
+	handler = withRequestMetadata(rt.config.TrustedProxies, handler)
+	handler = withBasePath(rt.config.BasePath, handler)
+	logged := DebugBodyLoggingMiddleware(rt.config.DebugBodies)(handler.ServeHTTP)
+	limited := MaxBodyBytesMiddleware(rt.config.MaxBodyBytes)(logged)
+	compressed := CompressionMiddleware(rt.config.CompressionMinSize)(limited)
+	return SecurityHeadersMiddleware(rt.config.SecurityHeaders)(compressed)
+}
+
+// Routes lists the routes registered by the last SetupRoutes call as
+// "METHOD path", without the base path.
+func (rt *Router) Routes() []string {
+	return rt.routes
+}
+
+// withBasePath serves handler under prefix (e.g. "/auth-service") so the API can
+// sit behind a reverse proxy that forwards the full path.
+func withBasePath(prefix string, handler http.Handler) http.Handler {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		return handler
+	}
+
+	root := http.NewServeMux()
+	root.Handle(prefix+"/", http.StripPrefix(prefix, handler))
+	root.HandleFunc("/", notFound)
+	return root
+}
+
+// notFound answers paths no route matches in JSON, like every other error,
+// instead of ServeMux's plain-text 404. Wrong methods on a known path are
+// answered by methodGuard with 405.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, http.StatusNotFound, "Not found")
+}
+
+// withRequestMetadata stores the caller's address and User-Agent in the
+// request context so use cases can attach them to audit events and sessions.
+func withRequestMetadata(trustedProxies *IPAllowlist, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := domain.ContextWithClientIP(r.Context(), clientIP(r, trustedProxies))
+		ctx = domain.ContextWithUserAgent(ctx, r.UserAgent())
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-	mux.HandleFunc("/health", applyMiddlewares(rt.handler.Health, CORSMiddleware, LoggingMiddleware))
-	mux.HandleFunc("/api/auth/register", applyMiddlewares(rt.handler.Register, CORSMiddleware, LoggingMiddleware))
-	mux.HandleFunc("/api/auth/login", applyMiddlewares(rt.handler.Login, CORSMiddleware, LoggingMiddleware))
+func (rt *Router) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	config := AuthConfig{
+		CookieName: rt.handler.authCookie.Name,
+		Realm:      rt.config.AuthRealm,
+	}
+	// A nil *AuthUseCase would make a non-nil interface.
+	if rt.handler.authUseCase != nil {
+		config.TokenVersions = rt.handler.authUseCase
+	}
+	return NewAuthMiddleware(rt.jwtService, config)(next)
+}
 
-	mux.HandleFunc("/api/auth/me", applyMiddlewares(rt.handler.Me, CORSMiddleware, LoggingMiddleware, AuthMiddleware(rt.jwtService)))
+// csrf only matters when tokens can arrive in a cookie.
+func (rt *Router) csrf(next http.HandlerFunc) http.HandlerFunc {
+	if rt.handler.authCookie.Name == "" {
+		return next
+	}
+	return CSRFMiddleware(next)
+}
 
-	return mux
+// internalOnly restricts a route to InternalAllowlist; without one the route
+// stays open, which suits local development.
+func (rt *Router) internalOnly(next http.HandlerFunc) http.HandlerFunc {
+	if rt.config.InternalAllowlist == nil {
+		return next
+	}
+	return IPAllowlistMiddleware(rt.config.InternalAllowlist, rt.config.TrustedProxies)(next)
+}
+
+func (rt *Router) recentAuth(next http.HandlerFunc) http.HandlerFunc {
+	if rt.config.ReauthMaxAge <= 0 {
+		return next
+	}
+	return RequireRecentAuth(rt.jwtService, rt.config.ReauthMaxAge)(next)
+}
+
+func (rt *Router) timeout(next http.HandlerFunc) http.HandlerFunc {
+	return TimeoutMiddleware(rt.config.RequestTimeout)(next)
+}
+
+func (rt *Router) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	if rt.config.RateLimiter == nil {
+		return next
+	}
+	return RateLimitMiddleware(rt.config.RateLimiter)(next)
+}
+
+func (rt *Router) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	if rt.config.Idempotency == nil {
+		return next
+	}
+	return IdempotencyMiddleware(rt.config.Idempotency)(next)
+}
+
+func (rt *Router) signResponses(next http.HandlerFunc) http.HandlerFunc {
+	if len(rt.config.ResponseSigningKey) == 0 {
+		return next
+	}
+	return BodySignatureMiddleware(rt.config.ResponseSigningKey)(next)
 }
 
-func applyMiddlewares(handler http.HandlerFunc, middlewares ...func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
+func applyMiddlewares(handler http.HandlerFunc, middlewares ...Middleware) http.HandlerFunc {
 	for i := len(middlewares) - 1; i >= 0; i-- {
 		handler = middlewares[i](handler)
 	}
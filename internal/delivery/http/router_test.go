@@ -0,0 +1,363 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/usecase"
+)
+
+func newTestRouter(config RouterConfig) http.Handler {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	return NewRouter(&Handler{jwtService: jwtService}, jwtService, config).SetupRoutes()
+}
+
+func doPreflight(handler http.Handler, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", origin)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSetupRoutes_PerRouteCORS(t *testing.T) {
+	handler := newTestRouter(RouterConfig{
+		AuthCORS: CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+	})
+
+	rec := doPreflight(handler, "/health", "https://anything.example.org")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected public route to allow any origin, got %q", got)
+	}
+
+	rec = doPreflight(handler, "/api/auth/login", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected allowlisted origin to be echoed, got %q", got)
+	}
+
+	rec = doPreflight(handler, "/api/auth/login", "https://evil.example.org")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected non-allowlisted origin to be refused, got %q", got)
+	}
+}
+
+func TestSetupRoutes_BasePath(t *testing.T) {
+	handler := newTestRouter(RouterConfig{BasePath: "/auth-service/"})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth-service/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d under the base path, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = doPreflight(handler, "/auth-service/api/auth/login", "https://app.example.com")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected auth route to match under the base path, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d outside the base path, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestSetupRoutes_CORSAllowCredentials(t *testing.T) {
+	handler := newTestRouter(RouterConfig{
+		AuthCORS: CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+	})
+
+	rec := doPreflight(handler, "/api/auth/login", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed for allowlisted origin, got %q", got)
+	}
+
+	rec = doPreflight(handler, "/api/auth/login", "https://evil.example.org")
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Expected no credentials header for other origins, got %q", got)
+	}
+}
+
+func TestSetupRoutes_SecurityHeadersOnAllRoutes(t *testing.T) {
+	handler := newTestRouter(RouterConfig{BasePath: "/auth-service"})
+
+	for _, path := range []string{"/auth-service/health", "/auth-service/api/auth/me", "/unknown"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if got := rec.Header().Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("Expected X-Frame-Options on %s, got %q", path, got)
+		}
+	}
+}
+
+func TestSetupRoutes_DeleteUserRequiresRecentAuth(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour, security.WithClock(now))
+	handler := NewRouter(&Handler{jwtService: jwtService}, jwtService, RouterConfig{ReauthMaxAge: 15 * time.Minute}).SetupRoutes()
+
+	token, err := jwtService.GenerateSessionToken(&domain.User{ID: 1, Email: "admin@example.com", Role: domain.RoleAdmin}, 1, now.Now())
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	now.Advance(30 * time.Minute)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/users/2", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "reauth_required") {
+		t.Errorf("Expected 401 reauth_required, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSetupRoutes_PasswordChangeRevokesOldToken(t *testing.T) {
+	h := newTestHandler(t)
+	handler := NewRouter(h, h.jwtService, RouterConfig{}).SetupRoutes()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var registered usecase.AuthResponse
+	rec := do(http.MethodPost, "/api/auth/register", "", `{"email":"test@example.com","password":"password123"}`)
+	if err := json.NewDecoder(rec.Body).Decode(&registered); err != nil || registered.AccessToken == "" {
+		t.Fatalf("Failed to register: %d %v", rec.Code, err)
+	}
+
+	rec = do(http.MethodPut, "/api/auth/me/password", registered.AccessToken, `{"current_password":"password123","new_password":"newpassword456"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var changed usecase.AuthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&changed); err != nil || changed.AccessToken == "" {
+		t.Fatalf("Expected a fresh token, got %v", err)
+	}
+
+	rec = do(http.MethodGet, "/api/auth/me", registered.AccessToken, "")
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "token_revoked") {
+		t.Errorf("Expected the old token to get 401 token_revoked, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/auth/me", changed.AccessToken, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected the new token to work, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSetupRoutes_CookieAuthRequiresCSRF(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	handler := NewRouter(&Handler{jwtService: jwtService, authCookie: CookieConfig{Name: "access_token"}}, jwtService, RouterConfig{}).SetupRoutes()
+
+	token, err := jwtService.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), csrfTokenInvalidCode) {
+		t.Errorf("Expected 403 %s, got %d: %s", csrfTokenInvalidCode, rec.Code, rec.Body.String())
+	}
+}
+
+func TestSetupRoutes_TokenClaims(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour, security.WithAudience("test-audience"))
+	handler := NewRouter(&Handler{jwtService: jwtService}, jwtService, RouterConfig{}).SetupRoutes()
+
+	token, err := jwtService.GenerateToken(42, "test@example.com", domain.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	expected, err := jwtService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/token", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var body struct {
+		UserID   int64    `json:"user_id"`
+		Email    string   `json:"email"`
+		Role     string   `json:"role"`
+		Issuer   string   `json:"iss"`
+		Audience []string `json:"aud"`
+		IssuedAt int64    `json:"iat"`
+		Expiry   int64    `json:"exp"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if body.UserID != 42 || body.Email != "test@example.com" || body.Role != domain.RoleAdmin {
+		t.Errorf("Expected identity claims to match the token, got %+v", body)
+	}
+	if body.Issuer != "test-issuer" || len(body.Audience) != 1 || body.Audience[0] != "test-audience" {
+		t.Errorf("Expected iss and aud to match the token, got %+v", body)
+	}
+	if body.IssuedAt != expected.IssuedAt.Unix() || body.Expiry != expected.ExpiresAt.Unix() {
+		t.Errorf("Expected iat %d and exp %d, got %d and %d", expected.IssuedAt.Unix(), expected.ExpiresAt.Unix(), body.IssuedAt, body.Expiry)
+	}
+}
+
+func TestSetupRoutes_TokenClaimsRequiresAuth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(RouterConfig{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/token", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestSetupRoutes_MethodNotAllowed(t *testing.T) {
+	handler := newTestRouter(RouterConfig{})
+
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{http.MethodGet, "/api/auth/login", "POST, OPTIONS"},
+		{http.MethodPost, "/health", "GET, HEAD, OPTIONS"},
+		{http.MethodDelete, "/api/users", "GET, HEAD, OPTIONS"},
+		{http.MethodPost, "/api/auth/me/email", "PUT, OPTIONS"},
+		{http.MethodGet, "/api/admin/users/2", "DELETE, OPTIONS"},
+		{http.MethodDelete, "/api/admin/users/import", "POST, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, http.StatusMethodNotAllowed, rec.Code)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.expected {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestSetupRoutes_NotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RouterConfig
+		path   string
+	}{
+		{"unknown path", RouterConfig{}, "/does-not-exist"},
+		{"unknown API path", RouterConfig{}, "/api/auth/unknown"},
+		{"outside the base path", RouterConfig{BasePath: "/auth-service"}, "/health"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		newTestRouter(tt.config).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusNotFound, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected a JSON response, got %q", tt.name, ct)
+		}
+		var body ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "not_found" {
+			t.Errorf("%s: expected code not_found, got %+v (%v)", tt.name, body, err)
+		}
+	}
+
+	// Registered routes still take precedence over the catch-all.
+	rec := httptest.NewRecorder()
+	newTestRouter(RouterConfig{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected /health to answer %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestSetupRoutes_Head(t *testing.T) {
+	handler := newTestRouter(RouterConfig{})
+
+	get := httptest.NewRecorder()
+	handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/health", nil))
+	head := httptest.NewRecorder()
+	handler.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/health", nil))
+
+	if head.Code != get.Code {
+		t.Errorf("Expected status %d, got %d", get.Code, head.Code)
+	}
+	if head.Header().Get("Content-Type") != get.Header().Get("Content-Type") {
+		t.Errorf("Expected the GET headers, got %v", head.Header())
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q", head.Body.String())
+	}
+}
+
+func TestSetupRoutes_Options(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(RouterConfig{}).ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/api/auth/register", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST, OPTIONS" {
+		t.Errorf("Expected Allow %q, got %q", "POST, OPTIONS", got)
+	}
+}
+
+func TestWithRequestMetadata(t *testing.T) {
+	var got, userAgent string
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = domain.ClientIPFromContext(r.Context())
+		userAgent = domain.UserAgentFromContext(r.Context())
+	})
+
+	proxies, _ := ParseIPAllowlist([]string{"192.0.2.1", "10.0.0.0/8"})
+	tests := []struct {
+		name     string
+		proxies  *IPAllowlist
+		expected string
+	}{
+		{"remote address", nil, "192.0.2.1"},
+		{"forwarded for trusted proxy", proxies, "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			req.Header.Set("User-Agent", "test-agent/1.0")
+			withRequestMetadata(tt.proxies, inner).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.expected {
+				t.Errorf("Expected client IP %s, got %s", tt.expected, got)
+			}
+			if userAgent != "test-agent/1.0" {
+				t.Errorf("Expected user agent to be stored, got %q", userAgent)
+			}
+		})
+	}
+}
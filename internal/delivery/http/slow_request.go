@@ -0,0 +1,43 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SlowRequestMiddleware logs, as a warning, each request that takes longer
+// than threshold to answer, with its method, route, status and latency.
+// Faster requests are not logged at all, so it catches latency
+// regressions without a full access log. The route is the mux pattern the
+// request matched, as in the metrics labels, so IDs in paths don't leak
+// into the log. A zero threshold returns next unchanged; a nil logger uses
+// slog.Default.
+func SlowRequestMiddleware(mux *http.ServeMux, threshold time.Duration, logger *slog.Logger) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if threshold <= 0 {
+			return next
+		}
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			latency := time.Since(start)
+			if latency <= threshold {
+				return
+			}
+			logger.WarnContext(r.Context(), "slow request",
+				slog.String("method", r.Method),
+				slog.String("route", matchedRoute(mux, r)),
+				slog.Int("status", recorder.status),
+				slog.Float64("latency_ms", float64(latency)/float64(time.Millisecond)),
+				slog.Float64("threshold_ms", float64(threshold)/float64(time.Millisecond)),
+			)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", okHandler)
+	mux.HandleFunc("/api/auth/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handler := SlowRequestMiddleware(mux, 20*time.Millisecond, slog.New(slog.NewJSONHandler(&logs, nil)))(mux.ServeHTTP)
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if logs.Len() != 0 {
+		t.Fatalf("Expected a fast request not to be logged, got %s", logs.String())
+	}
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/auth/sessions/42", nil))
+
+	var entry struct {
+		Level     string  `json:"level"`
+		Message   string  `json:"msg"`
+		Method    string  `json:"method"`
+		Route     string  `json:"route"`
+		Status    int     `json:"status"`
+		LatencyMS float64 `json:"latency_ms"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log line, got %q: %v", logs.String(), err)
+	}
+	if entry.Level != "WARN" || entry.Message != "slow request" || entry.Method != http.MethodDelete ||
+		entry.Route != "/api/auth/sessions/" || entry.Status != http.StatusAccepted || entry.LatencyMS < 30 {
+		t.Errorf("Unexpected log entry: %+v", entry)
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// requestValidator checks the `validate` tags on request structs. It is
+// safe for concurrent use and caches struct metadata, hence one instance.
+var requestValidator = newRequestValidator()
+
+func newRequestValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields under their JSON names, as the use cases do.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// The use cases trim and lowercase addresses before storing them, so the
+	// built-in rule would reject input they accept. Use the same parser.
+	v.RegisterValidation("email", func(fl validator.FieldLevel) bool {
+		email := strings.ToLower(strings.TrimSpace(fl.Field().String()))
+		addr, err := mail.ParseAddress(email)
+		return err == nil && addr.Address == email
+	})
+
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return domain.ValidUsername(strings.ToLower(strings.TrimSpace(fl.Field().String())))
+	})
+
+	return v
+}
+
+// validationErrors maps a failed rule, optionally qualified by field, to the
+// error the use cases return for the same problem so both layers produce
+// identical messages.
+var validationErrors = map[string]error{
+	"required":         domain.ErrRequiredField,
+	"required_without": domain.ErrRequiredField,
+	"email":            domain.ErrInvalidEmail,
+	"email.max":        domain.ErrEmailTooLong,
+	"username":         domain.ErrUsernameInvalid,
+	"password.min":     domain.ErrWeakPassword,
+	"password.max":     domain.ErrWeakPassword,
+}
+
+// validateRequest runs the struct's `validate` tags and returns the failures
+// as a *domain.ValidationError, or nil when the request is valid.
+func validateRequest(req interface{}) *domain.ValidationError {
+	err := requestValidator.Struct(req)
+	if err == nil {
+		return nil
+	}
+
+	validation := &domain.ValidationError{}
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		validation.Add("request", err)
+		return validation
+	}
+
+	for _, fieldErr := range fieldErrs {
+		validation.Add(fieldErr.Field(), validationError(fieldErr))
+	}
+	return validation
+}
+
+func validationError(fieldErr validator.FieldError) error {
+	if err, ok := validationErrors[fieldErr.Field()+"."+fieldErr.Tag()]; ok {
+		return err
+	}
+	if err, ok := validationErrors[fieldErr.Tag()]; ok {
+		return err
+	}
+	return fmt.Errorf("failed the %s rule", fieldErr.Tag())
+}
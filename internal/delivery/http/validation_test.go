@@ -0,0 +1,135 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/usecase"
+)
+
+func TestValidateRequest_RegisterTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   usecase.RegisterRequest
+		field string
+		want  error
+	}{
+		{"email required", usecase.RegisterRequest{Password: "password123"}, "email", domain.ErrRequiredField},
+		{"email format", usecase.RegisterRequest{Email: "not-an-email", Password: "password123"}, "email", domain.ErrInvalidEmail},
+		{"email max", usecase.RegisterRequest{Email: strings.Repeat("a", 243) + "@example.com", Password: "password123"}, "email", domain.ErrEmailTooLong},
+		{"password required", usecase.RegisterRequest{Email: "user@example.com"}, "password", domain.ErrRequiredField},
+		{"password min", usecase.RegisterRequest{Email: "user@example.com", Password: "short"}, "password", domain.ErrWeakPassword},
+		{"password max", usecase.RegisterRequest{Email: "user@example.com", Password: strings.Repeat("p", 73)}, "password", domain.ErrWeakPassword},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := validateRequest(tt.req)
+			if validation == nil {
+				t.Fatal("Expected a validation error")
+			}
+			if len(validation.Fields) != 1 {
+				t.Fatalf("Expected exactly one field error, got %v", validation)
+			}
+			if got := validation.Fields[0]; got.Field != tt.field || !errors.Is(got.Err, tt.want) {
+				t.Errorf("Expected %s: %v, got %v", tt.field, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateRequest_ValidRegister(t *testing.T) {
+	// Addresses are normalized by the use case, so padding and case pass.
+	req := usecase.RegisterRequest{Email: "  User@Example.com ", Password: "password123"}
+	if validation := validateRequest(req); validation != nil {
+		t.Errorf("Expected no validation error, got %v", validation)
+	}
+}
+
+func TestValidateRequest_LoginTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   usecase.LoginRequest
+		field string
+		want  error
+	}{
+		{"identifier required", usecase.LoginRequest{Password: "password123"}, "identifier", domain.ErrRequiredField},
+		{"email format", usecase.LoginRequest{Email: "not-an-email", Password: "password123"}, "email", domain.ErrInvalidEmail},
+		{"password required", usecase.LoginRequest{Email: "user@example.com"}, "password", domain.ErrRequiredField},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation := validateRequest(tt.req)
+			if validation == nil || len(validation.Fields) != 1 {
+				t.Fatalf("Expected exactly one field error, got %v", validation)
+			}
+			if got := validation.Fields[0]; got.Field != tt.field || !errors.Is(got.Err, tt.want) {
+				t.Errorf("Expected %s: %v, got %v", tt.field, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateRequest_LoginByUsername(t *testing.T) {
+	if validation := validateRequest(usecase.LoginRequest{Identifier: "alice", Password: "password123"}); validation != nil {
+		t.Errorf("Expected no validation error, got %v", validation)
+	}
+}
+
+func TestValidateRequest_RegisterUsername(t *testing.T) {
+	valid := usecase.RegisterRequest{Email: "user@example.com", Username: " Alice.Martin ", Password: "password123"}
+	if validation := validateRequest(valid); validation != nil {
+		t.Errorf("Expected no validation error, got %v", validation)
+	}
+
+	invalid := usecase.RegisterRequest{Email: "user@example.com", Username: "a@b", Password: "password123"}
+	validation := validateRequest(invalid)
+	if validation == nil || len(validation.Fields) != 1 || validation.Fields[0].Field != "username" || !errors.Is(validation.Fields[0].Err, domain.ErrUsernameInvalid) {
+		t.Errorf("Expected username: %v, got %v", domain.ErrUsernameInvalid, validation)
+	}
+}
+
+func TestRegister_ReportsTagFailuresByField(t *testing.T) {
+	handler := &Handler{}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(`{"email":"nope","password":"short"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.Register(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var body ValidationErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Fields["email"] != domain.ErrInvalidEmail.Error() || body.Fields["password"] != domain.ErrWeakPassword.Error() {
+		t.Errorf("Unexpected field errors: %v", body.Fields)
+	}
+}
+
+func TestLogin_ValidationFailureIsInvalidCredentials(t *testing.T) {
+	handler := &Handler{}
+
+	for _, payload := range []string{`{"email":"","password":"password123"}`, `{"email":"nope","password":"password123"}`, `{"email":"user@example.com"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.Login(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status %d, got %d", payload, http.StatusUnauthorized, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "fields") {
+			t.Errorf("%s: expected no field details, got %s", payload, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/valentinfrappart/securerestapi/internal/version"
+)
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+}
+
+// Version reports which build is running.
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, VersionResponse{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildTime: version.BuildTime,
+	})
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/version"
+)
+
+func TestVersion_ReportsBuildInfo(t *testing.T) {
+	saved := []string{version.Version, version.Commit, version.BuildTime}
+	t.Cleanup(func() { version.Version, version.Commit, version.BuildTime = saved[0], saved[1], saved[2] })
+	version.Version, version.Commit, version.BuildTime = "v1.2.3", "abc1234", "2024-01-15T10:30:00Z"
+
+	rec := httptest.NewRecorder()
+	newTestRouter(RouterConfig{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var body VersionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := VersionResponse{Version: "v1.2.3", Commit: "abc1234", BuildTime: "2024-01-15T10:30:00Z"}
+	if body != want {
+		t.Errorf("Expected %+v, got %+v", want, body)
+	}
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditActionRegister       = "register"
+	AuditActionLogin          = "login"
+	AuditActionLoginFailed    = "login-failed"
+	AuditActionPasswordChange = "password-change"
+	AuditActionUserImport     = "user-import"
+	AuditActionUserDelete     = "user-delete"
+	AuditActionUserRestore    = "user-restore"
+)
+
+// AuditEvent records a security-sensitive action. UserID is zero when the
+// account is unknown, e.g. a failed login for an unregistered email.
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	UserID    int64     `json:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditLogger is best effort: a failure to record must never fail the
+// action being audited, so Record reports nothing back.
+type AuditLogger interface {
+	Record(event AuditEvent)
+}
+
+type AuditRepository interface {
+	AuditLogger
+	// List returns events newest first.
+	List(ctx context.Context, limit, offset int) ([]*AuditEvent, error)
+	Count(ctx context.Context) (int64, error)
+}
+
+type clientIPKey struct{}
+
+// ContextWithClientIP lets the delivery layer pass the caller's address down
+// to use cases for auditing.
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -1,13 +1,99 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrUserNotFound = errors.New("user not found")
 
 	ErrUserAlreadyExists = errors.New("user already exists")
 
+	ErrUsernameTaken = errors.New("username already taken")
+
 	ErrInvalidCredentials = errors.New("invalid credentials")
 
 	ErrInvalidToken = errors.New("invalid token")
+
+	ErrTokenExpired = errors.New("token has expired")
+
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+
+	ErrTokenMalformed = errors.New("token is malformed")
+
+	ErrTokenInvalidIssuer = errors.New("token was issued by an untrusted issuer")
+
+	ErrTokenInvalidAudience = errors.New("token is not intended for this audience")
+
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	ErrTokenMissingID = errors.New("token has no jti and cannot be checked for revocation")
+
+	ErrEmailNotVerified = errors.New("email not verified")
+
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+
+	ErrNoPendingEmail = errors.New("no email change is pending")
+
+	ErrOneTimeTokenInvalid = errors.New("invalid or unknown token")
+
+	ErrOneTimeTokenExpired = errors.New("token has expired")
+
+	ErrOneTimeTokenUsed = errors.New("token has already been used")
+
+	ErrWeakPassword = errors.New("password does not meet the policy requirements")
+
+	// ErrPasswordReused rejects a new password matching one of the user's
+	// recent passwords.
+	ErrPasswordReused = errors.New("password was used recently")
+
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication has not been set up")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrCannotDeleteSelf = errors.New("admins cannot delete their own account")
+
+	// ErrReauthRequired rejects a valid token whose sign-in is too old for
+	// a sensitive action.
+	ErrReauthRequired = errors.New("recent authentication required")
+
+	// ErrLoginThrottled refuses a login after too many failures from the
+	// same IP or against the same account. Use cases return it as a
+	// *LoginThrottledError.
+	ErrLoginThrottled = errors.New("too many failed login attempts")
+
+	ErrUnknownIdentityProvider = errors.New("identity provider is not configured")
+
+	// ErrOAuthStateInvalid rejects a provider callback whose state does
+	// not match the one issued when the sign-in started.
+	ErrOAuthStateInvalid = errors.New("sign-in state is missing or does not match")
+
+	// ErrOAuthFailed means the identity provider did not confirm the
+	// sign-in, for instance because the code was invalid or already used.
+	ErrOAuthFailed = errors.New("sign-in with the identity provider failed")
+
+	// ErrTimeout wraps context.Canceled or context.DeadlineExceeded when a
+	// request's context ends before the operation completes.
+	ErrTimeout = errors.New("operation canceled or timed out")
 )
+
+// LoginThrottledError is ErrLoginThrottled with the time left until the
+// throttle lifts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrLoginThrottled.Error()
+}
+
+func (e *LoginThrottledError) Is(target error) bool {
+	return target == ErrLoginThrottled
+}
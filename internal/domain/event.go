@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+const EventUserRegistered = "user.registered"
+
+// OutboxEvent is a domain event stored in the same transaction as the change
+// that produced it and delivered to webhook subscribers afterwards.
+type OutboxEvent struct {
+	ID             int64
+	Type           string
+	Payload        []byte
+	IdempotencyKey string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+}
+
+type UserRegisteredEvent struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OutboxRepository interface {
+	FetchPending(now time.Time, maxAttempts, limit int) ([]*OutboxEvent, error)
+	MarkDelivered(id int64, deliveredAt time.Time) error
+	MarkFailed(id int64, lastError string, nextAttemptAt time.Time) error
+}
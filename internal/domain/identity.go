@@ -0,0 +1,24 @@
+package domain
+
+import "context"
+
+// ExternalIdentity is what an identity provider asserts about the user who
+// signed in with it.
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// IdentityProvider signs users in through the OAuth2 authorization code
+// flow with PKCE.
+type IdentityProvider interface {
+	Name() string
+	// AuthCodeURL is the provider page the user is sent to. The provider
+	// sends state back to the callback unchanged, and will only accept the
+	// code with the verifier codeChallenge (S256) was derived from.
+	AuthCodeURL(state, codeChallenge string) string
+	// Exchange redeems the code the callback received and returns who
+	// signed in.
+	Exchange(ctx context.Context, code, codeVerifier string) (*ExternalIdentity, error)
+}
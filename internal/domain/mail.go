@@ -0,0 +1,7 @@
+package domain
+
+// Mailer delivers plain-text email. Implementations must reject recipients
+// and subjects containing line breaks, which could inject headers.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "context"
+
+// PasswordPolicyVersion identifies the password rules new passwords are
+// checked against. Bump it whenever the rules get stricter so users whose
+// password predates the change can be counted; 0 means "before tracking".
+const PasswordPolicyVersion = 1
+
+// PasswordHasher hashes and verifies user passwords so use cases don't depend
+// on a specific KDF.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hashedPassword, password string) error
+	// NeedsRehash reports whether a stored hash is weaker than the
+	// currently configured parameters.
+	NeedsRehash(hashedPassword string) bool
+	// VerifyDummy spends the same work as Verify for accounts that don't
+	// exist. It always returns an error.
+	VerifyDummy(password string) error
+}
+
+// PasswordHistoryRepository keeps the hashes of the passwords users have
+// replaced, so they cannot switch back to them.
+type PasswordHistoryRepository interface {
+	// Add records passwordHash as the user's most recently replaced
+	// password and drops all but the newest keep entries.
+	Add(ctx context.Context, userID int64, passwordHash string, keep int) error
+	// Recent returns up to limit of the user's replaced password hashes,
+	// newest first.
+	Recent(ctx context.Context, userID int64, limit int) ([]string, error)
+}
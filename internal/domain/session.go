@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Session is one login on one device. It holds the jti of the refresh token
+// currently valid for it, which changes every time the token is rotated.
+type Session struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"-"`
+	RefreshTokenID string    `json:"-"`
+	UserAgent      string    `json:"user_agent"`
+	IP             string    `json:"ip"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type SessionStore interface {
+	// Create fills in session.ID.
+	Create(ctx context.Context, session *Session) error
+	FindByID(ctx context.Context, id int64) (*Session, error)
+	// ListByUser returns a page of the user's unexpired sessions, most
+	// recently used first.
+	ListByUser(ctx context.Context, userID int64, limit, offset int) ([]*Session, error)
+	// CountByUser counts the sessions ListByUser pages through.
+	CountByUser(ctx context.Context, userID int64) (int64, error)
+	// Rotate replaces the session's refresh token ID, but only while it is
+	// still currentID. It returns ErrSessionNotFound when the session is gone
+	// or another rotation got there first.
+	Rotate(ctx context.Context, id int64, currentID, refreshTokenID string, expiresAt, usedAt time.Time) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type userAgentKey struct{}
+
+// ContextWithUserAgent carries the client's User-Agent down to use cases so
+// sessions can show which device they belong to.
+func ContextWithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentKey{}, userAgent)
+}
+
+func UserAgentFromContext(ctx context.Context) string {
+	userAgent, _ := ctx.Value(userAgentKey{}).(string)
+	return userAgent
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+type OneTimeToken struct {
+	ID        int64
+	UserID    int64
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevocationStore remembers revoked access tokens by jti until they would
+// have expired anyway.
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time)
+	IsRevoked(jti string) bool
+}
+
+type TokenRepository interface {
+	Create(ctx context.Context, token *OneTimeToken) error
+	FindByHash(ctx context.Context, purpose, tokenHash string) (*OneTimeToken, error)
+	MarkUsed(ctx context.Context, id int64, usedAt time.Time) error
+	// InvalidateUnused marks every unused token of the user for purpose as
+	// used, so only a token issued afterwards can be redeemed.
+	InvalidateUnused(ctx context.Context, userID int64, purpose string, usedAt time.Time) error
+}
@@ -1,17 +1,153 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
+}
 
 type User struct {
-	ID           int64     `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                    int64      `json:"id"`
+	Email                 string     `json:"email"`
+	Username              string     `json:"username,omitempty"`
+	PendingEmail          string     `json:"pending_email,omitempty"`
+	DisplayName           string     `json:"display_name,omitempty"`
+	PasswordHash          string     `json:"-"`
+	Provider              string     `json:"provider,omitempty"`
+	Role                  string     `json:"role"`
+	EmailVerified         bool       `json:"email_verified"`
+	TOTPSecret            string     `json:"-"`
+	TOTPEnabled           bool       `json:"totp_enabled"`
+	FailedAttempts        int        `json:"-"`
+	PasswordPolicyVersion int        `json:"-"`
+	TokenVersion          int        `json:"-"`
+	LastLoginAt           *time.Time `json:"last_login_at,omitempty"`
+	DeletedAt             *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+// LogValue keeps secrets out of logs: a *User passed to slog is rendered as
+// its id, email and role only.
+func (u *User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int64("id", u.ID),
+		slog.String("email", u.Email),
+		slog.String("role", u.Role),
+	)
+}
+
+const (
+	AuthMethodPassword = "password"
+	AuthMethodTOTP     = "totp"
+	AuthMethodSSO      = "sso"
+)
+
+func (u *User) AuthMethods() []string {
+	methods := []string{}
+	if u.PasswordHash != "" {
+		methods = append(methods, AuthMethodPassword)
+	}
+	if u.TOTPEnabled {
+		methods = append(methods, AuthMethodTOTP)
+	}
+	if u.Provider != "" {
+		methods = append(methods, AuthMethodSSO)
+	}
+	return methods
+}
+
+// Fields UserRepository.List can sort by.
+const (
+	UserSortID        = "id"
+	UserSortEmail     = "email"
+	UserSortCreatedAt = "created_at"
+)
+
+// UserListOptions selects a page of users. SortBy is one of the UserSort*
+// fields and defaults to id; ties are broken by id. With After, the page
+// starts after that user in (created_at, id) order instead of at Offset,
+// and SortBy must be UserSortCreatedAt.
+type UserListOptions struct {
+	Limit      int
+	Offset     int
+	SortBy     string
+	Descending bool
+	After      *UserCursor
+}
+
+// UserCursor is a user's position in (created_at, id) order.
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        int64
 }
 
 type UserRepository interface {
-	Create(email, passwordHash string) (*User, error)
-	FindByEmail(email string) (*User, error)
-	FindByID(id int64) (*User, error)
+	Create(ctx context.Context, email, passwordHash string, policyVersion int) (*User, error)
+	// CreateWithUsername is Create for a user who also picked a username.
+	// It returns ErrUsernameTaken when another user already has it.
+	CreateWithUsername(ctx context.Context, email, username, passwordHash string, policyVersion int) (*User, error)
+	// CreateExternal creates a user who signs in through an identity
+	// provider: the password hash is left empty, so no password matches
+	// it, and the email counts as verified since the provider vouched for
+	// it.
+	CreateExternal(ctx context.Context, email, provider string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByUsername(ctx context.Context, username string) (*User, error)
+	FindByID(ctx context.Context, id int64) (*User, error)
+	// FindByIDIncludingDeleted is FindByID for admin tooling: it also
+	// returns a soft-deleted user, with DeletedAt set.
+	FindByIDIncludingDeleted(ctx context.Context, id int64) (*User, error)
+	List(ctx context.Context, opts UserListOptions) ([]*User, error)
+	Count(ctx context.Context) (int64, error)
+	SetEmailVerified(ctx context.Context, id int64, verified bool) error
+	SetRole(ctx context.Context, id int64, role string) error
+	// UpdateProfile sets the user-editable profile fields. An empty
+	// display name clears it.
+	UpdateProfile(ctx context.Context, id int64, displayName string) error
+	UpdatePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) error
+	// ChangePassword is UpdatePassword for a password the user replaced:
+	// it also bumps token_version, revoking every token issued before,
+	// and returns the new version.
+	ChangePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) (int, error)
+	// Delete removes the user along with their one-time tokens and
+	// sessions. In soft-delete mode the user row is kept with deleted_at
+	// set instead: the lookups, List and every update treat it as gone
+	// (ErrUserNotFound), and its email and username stay taken
+	// (ErrUserAlreadyExists) so Restore cannot collide.
+	Delete(ctx context.Context, id int64) error
+	// Restore clears deleted_at on a soft-deleted user. It returns
+	// ErrUserNotFound when id is not a soft-deleted user.
+	Restore(ctx context.Context, id int64) error
+	// UpdateEmail also clears email_verified, since the new address has
+	// not been proven yet.
+	UpdateEmail(ctx context.Context, id int64, email string) error
+	// SetPendingEmail records an address the user is switching to without
+	// touching the one they log in with. An empty email cancels the change.
+	SetPendingEmail(ctx context.Context, id int64, email string) error
+	// ConfirmPendingEmail makes the pending address the login email and
+	// marks it verified in one step, so the account never has two active
+	// addresses or none.
+	ConfirmPendingEmail(ctx context.Context, id int64) error
+	// SetTOTPSecret stores an encrypted TOTP secret and leaves 2FA disabled
+	// until EnableTOTP confirms the user can produce codes.
+	SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error
+	EnableTOTP(ctx context.Context, id int64) error
+	// IncrementFailedAttempts atomically bumps the failed login counter and
+	// returns its new value, so concurrent failures are all counted.
+	IncrementFailedAttempts(ctx context.Context, id int64) (int, error)
+	ResetFailedAttempts(ctx context.Context, id int64) error
+	// CountByPasswordPolicyVersion returns the number of users per
+	// password policy version.
+	CountByPasswordPolicyVersion(ctx context.Context) (map[int]int64, error)
+	UpdateLastLogin(ctx context.Context, id int64, t time.Time) error
 }
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestUser_LogValueRedactsSecrets(t *testing.T) {
+	user := &User{
+		ID:           7,
+		Email:        "user@example.com",
+		PasswordHash: "$2a$10$secrethashvalue",
+		Role:         RoleAdmin,
+		TOTPSecret:   "encrypted-totp-secret",
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("login", "user", user)
+	out := buf.String()
+
+	for _, secret := range []string{user.PasswordHash, user.TOTPSecret} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted, got %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"user":{"id":7,"email":"user@example.com","role":"admin"}`) {
+		t.Errorf("Expected id, email and role to be logged, got %s", out)
+	}
+}
+
+func TestValidUsername(t *testing.T) {
+	for _, username := range []string{"bob", "alice.martin", "a_b-c", "007", strings.Repeat("a", MaxUsernameLength)} {
+		if !ValidUsername(username) {
+			t.Errorf("Expected %q to be accepted", username)
+		}
+	}
+	for _, username := range []string{"", "ab", strings.Repeat("a", MaxUsernameLength+1), "Bob", "bob@example.com", "bob smith", ".bob", "_bob", "bøb"} {
+		if ValidUsername(username) {
+			t.Errorf("Expected %q to be rejected", username)
+		}
+	}
+}
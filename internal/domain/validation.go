@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrRequiredField = errors.New("is required")
+	ErrInvalidEmail  = errors.New("is not a valid email address")
+	ErrEmailTooLong  = errors.New("must be at most 254 characters")
+	ErrInvalidRole   = errors.New("must be user or admin")
+
+	ErrDisplayNameTooLong = errors.New("must be at most 100 characters")
+	ErrDisplayNameInvalid = errors.New("must not contain control characters")
+
+	ErrUsernameInvalid = errors.New("must be 3 to 30 characters: letters, digits, '.', '_' or '-', starting with a letter or digit")
+)
+
+// MaxEmailLength is the longest address RFC 5321 allows in a forward path.
+// The users table enforces the same bound.
+const MaxEmailLength = 254
+
+// MaxDisplayNameLength is counted in characters, not bytes.
+const MaxDisplayNameLength = 100
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 30
+)
+
+// ValidUsername reports whether a username, already lowercased, has an
+// accepted length and charset. '@' is not allowed, so a login identifier is
+// never ambiguous between an email and a username.
+func ValidUsername(username string) bool {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return false
+	}
+	if strings.IndexByte(".-_", username[0]) >= 0 {
+		return false
+	}
+	for _, c := range username {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError aggregates independent field failures so callers can
+// report all of them at once. It unwraps to every field error, so
+// errors.Is matches any underlying sentinel.
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Add(field string, err error) {
+	e.Fields = append(e.Fields, &FieldError{Field: field, Err: err})
+}
+
+// Err returns nil when no field failed, so it can be returned directly.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return errors.Join(e.Unwrap()...).Error()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, field := range e.Fields {
+		errs[i] = field
+	}
+	return errs
+}
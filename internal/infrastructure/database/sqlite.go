@@ -3,16 +3,65 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func NewSQLiteDB(dbPath string) (*sql.DB, error) {
+// PoolConfig sizes the connection pool. Zero MaxOpenConns or
+// ConnMaxLifetime means no limit, as in database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func (p PoolConfig) String() string {
+	lifetime := "unlimited"
+	if p.ConnMaxLifetime > 0 {
+		lifetime = p.ConnMaxLifetime.String()
+	}
+	maxOpen := "unlimited"
+	if p.MaxOpenConns > 0 {
+		maxOpen = fmt.Sprint(p.MaxOpenConns)
+	}
+	return fmt.Sprintf("max open %s, max idle %d, max lifetime %s", maxOpen, p.MaxIdleConns, lifetime)
+}
+
+// DefaultSQLitePool keeps a single connection: SQLite allows one writer at
+// a time, and concurrent writers on separate connections fail with
+// "database is locked" instead of queueing.
+var DefaultSQLitePool = PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1}
+
+type Option func(*PoolConfig)
+
+// WithPool replaces DefaultSQLitePool.
+func WithPool(pool PoolConfig) Option {
+	return func(p *PoolConfig) {
+		*p = pool
+	}
+}
+
+// NewSQLiteDB opens and migrates the database. An in-memory database always
+// keeps a single connection open, since each connection to :memory: is a
+// separate empty database.
+func NewSQLiteDB(dbPath string, opts ...Option) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	pool := DefaultSQLitePool
+	for _, opt := range opts {
+		opt(&pool)
+	}
+	if dbPath == ":memory:" {
+		pool = DefaultSQLitePool
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -21,6 +70,10 @@ func NewSQLiteDB(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
+	if err := migrateUsersTable(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate users table: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -28,15 +81,151 @@ func createTables(db *sql.DB) error {
 	query := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT UNIQUE NOT NULL,
+		email VARCHAR(254) UNIQUE NOT NULL CHECK (length(email) <= 254),
+		username TEXT,
+		pending_email TEXT NOT NULL DEFAULT '',
+		display_name TEXT,
 		password_hash TEXT NOT NULL,
+		provider TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL DEFAULT 'user',
+		email_verified INTEGER NOT NULL DEFAULT 0,
+		totp_secret TEXT NOT NULL DEFAULT '',
+		totp_enabled INTEGER NOT NULL DEFAULT 0,
+		failed_attempts INTEGER NOT NULL DEFAULT 0,
+		password_policy_version INTEGER NOT NULL DEFAULT 0,
+		token_version INTEGER NOT NULL DEFAULT 0,
+		last_login_at DATETIME,
+		deleted_at DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+	CREATE TABLE IF NOT EXISTS one_time_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		purpose TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_one_time_tokens_user ON one_time_tokens(user_id, purpose);
+
+	CREATE TABLE IF NOT EXISTS outbox_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		idempotency_key TEXT UNIQUE NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		delivered_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(delivered_at, next_attempt_at);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		action TEXT NOT NULL,
+		user_id INTEGER,
+		email TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		refresh_token_id TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id, expires_at);
+
+	CREATE TABLE IF NOT EXISTS password_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_history_user ON password_history(user_id, id);
 	`
 
 	_, err := db.Exec(query)
 	return err
 }
+
+var userColumns = []struct {
+	name       string
+	definition string
+}{
+	{"role", "TEXT NOT NULL DEFAULT 'user'"},
+	{"email_verified", "INTEGER NOT NULL DEFAULT 0"},
+	{"totp_secret", "TEXT NOT NULL DEFAULT ''"},
+	{"totp_enabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"failed_attempts", "INTEGER NOT NULL DEFAULT 0"},
+	{"password_policy_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"last_login_at", "DATETIME"},
+	{"pending_email", "TEXT NOT NULL DEFAULT ''"},
+	{"display_name", "TEXT"},
+	{"username", "TEXT"},
+	{"token_version", "INTEGER NOT NULL DEFAULT 0"},
+	{"deleted_at", "DATETIME"},
+	{"provider", "TEXT NOT NULL DEFAULT ''"},
+}
+
+func migrateUsersTable(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(users)`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range userColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE users ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", column.name, err)
+		}
+	}
+
+	// Created here rather than in createTables, where an existing database
+	// would not have the column yet. NULLs do not collide, so users without
+	// a username are unaffected.
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username)`); err != nil {
+		return fmt.Errorf("failed to create username index: %w", err)
+	}
+
+	return nil
+}
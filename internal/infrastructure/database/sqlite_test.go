@@ -0,0 +1,47 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSQLiteDB_PoolSettings(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "app.db"), WithPool(PoolConfig{
+		MaxOpenConns:    4,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != 4 {
+		t.Errorf("Expected 4 max open connections, got %d", got)
+	}
+}
+
+func TestNewSQLiteDB_DefaultPool(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != DefaultSQLitePool.MaxOpenConns {
+		t.Errorf("Expected the single-writer default, got %d", got)
+	}
+}
+
+func TestNewSQLiteDB_MemoryKeepsOneConnection(t *testing.T) {
+	db, err := NewSQLiteDB(":memory:", WithPool(PoolConfig{MaxOpenConns: 8}))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != 1 {
+		t.Errorf("Expected :memory: to keep a single connection, got %d", got)
+	}
+}
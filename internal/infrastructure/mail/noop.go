@@ -0,0 +1,19 @@
+package mail
+
+import "log"
+
+// NoopMailer logs messages instead of sending them, for development. Links
+// in the body are logged too, so never use it in production.
+type NoopMailer struct{}
+
+func NewNoopMailer() *NoopMailer {
+	return &NoopMailer{}
+}
+
+func (m *NoopMailer) Send(to, subject, body string) error {
+	if err := validateHeaders(to, subject); err != nil {
+		return err
+	}
+	log.Printf("📧 Email to %s (not sent, no SMTP server configured): %s\n%s", to, subject, body)
+	return nil
+}
@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// fakeSMTPServer accepts one session without STARTTLS or AUTH and returns
+// the envelope and DATA it received.
+func fakeSMTPServer(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		var lines []string
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				break
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, _ := text.ReadDotLines()
+				lines = append(lines, data...)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				received <- lines
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+		received <- lines
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	mailer := NewSMTPMailer(Config{Host: host, Port: port, From: "no-reply@example.com", Timeout: 5 * time.Second})
+	if err := mailer.Send("user@example.com", "Verify your email address", "Open this link:\n\nhttps://example.com/verify?token=abc\n"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message")
+	}
+	session := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<user@example.com>",
+		"From: no-reply@example.com",
+		"To: user@example.com",
+		"Subject: Verify your email address",
+		"Content-Type: text/plain; charset=utf-8",
+		"https://example.com/verify?token=abc",
+	} {
+		if !strings.Contains(session, want) {
+			t.Errorf("Expected session to contain %q, got:\n%s", want, session)
+		}
+	}
+}
+
+func TestSMTPMailer_BuildMessageUsesCRLF(t *testing.T) {
+	mailer := NewSMTPMailer(Config{Host: "localhost", From: "no-reply@example.com"})
+	msg := string(mailer.buildMessage("user@example.com", "Réinitialisation", "line one\nline two\n"))
+
+	if strings.Contains(strings.ReplaceAll(msg, "\r\n", ""), "\n") {
+		t.Errorf("Expected only CRLF line endings, got %q", msg)
+	}
+	if !strings.Contains(msg, "Subject: =?utf-8?q?") {
+		t.Errorf("Expected a non-ASCII subject to be encoded, got %q", msg)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(msg)))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("Failed to parse headers: %v", err)
+	}
+	if header.Get("To") != "user@example.com" || header.Get("Date") == "" {
+		t.Errorf("Unexpected headers: %v", header)
+	}
+}
+
+func TestMailers_RejectHeaderInjection(t *testing.T) {
+	for name, mailer := range map[string]domain.Mailer{
+		"smtp": NewSMTPMailer(Config{Host: "localhost"}),
+		"noop": NewNoopMailer(),
+	} {
+		if err := mailer.Send("user@example.com\r\nBcc: victim@example.com", "Hi", "body"); !errors.Is(err, errHeaderInjection) {
+			t.Errorf("%s: expected errHeaderInjection for the recipient, got %v", name, err)
+		}
+		if err := mailer.Send("user@example.com", "Hi\nBcc: victim@example.com", "body"); !errors.Is(err, errHeaderInjection) {
+			t.Errorf("%s: expected errHeaderInjection for the subject, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// newFakeGoogle serves the token and userinfo endpoints, accepting only
+// code "good-code" redeemed with verifier.
+func newFakeGoogle(t *testing.T, verifier string) *GoogleProvider {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") != verifier ||
+			r.Form.Get("client_secret") != "client-secret" || r.Form.Get("grant_type") != "authorization_code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "google-access-token", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer google-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"sub": "1234", "email": "alice@gmail.example", "email_verified": true})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewGoogleProvider(Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://api.example.com/api/auth/oauth/google/callback",
+		TokenURL:     server.URL + "/token",
+		UserInfoURL:  server.URL + "/userinfo",
+	})
+}
+
+func TestGoogleProvider_AuthCodeURL(t *testing.T) {
+	provider := NewGoogleProvider(Config{ClientID: "client-id", RedirectURL: "https://api.example.com/callback"})
+
+	authURL, err := url.Parse(provider.AuthCodeURL("the-state", "the-challenge"))
+	if err != nil {
+		t.Fatalf("Expected a valid URL, got %v", err)
+	}
+	if authURL.Scheme+"://"+authURL.Host+authURL.Path != GoogleAuthURL {
+		t.Errorf("Expected Google's authorization endpoint, got %s", authURL)
+	}
+
+	query := authURL.Query()
+	expected := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client-id",
+		"redirect_uri":          "https://api.example.com/callback",
+		"scope":                 "openid email",
+		"state":                 "the-state",
+		"code_challenge":        "the-challenge",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range expected {
+		if query.Get(name) != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, query.Get(name))
+		}
+	}
+}
+
+func TestGoogleProvider_Exchange(t *testing.T) {
+	provider := newFakeGoogle(t, "the-verifier")
+
+	identity, err := provider.Exchange(context.Background(), "good-code", "the-verifier")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if identity.Subject != "1234" || identity.Email != "alice@gmail.example" || !identity.EmailVerified {
+		t.Errorf("Unexpected identity %+v", identity)
+	}
+}
+
+func TestGoogleProvider_Exchange_Rejected(t *testing.T) {
+	provider := newFakeGoogle(t, "the-verifier")
+
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+	}{
+		{"unknown code", "bad-code", "the-verifier"},
+		{"wrong verifier", "good-code", "another-verifier"},
+	}
+	for _, tt := range tests {
+		if _, err := provider.Exchange(context.Background(), tt.code, tt.verifier); !errors.Is(err, domain.ErrOAuthFailed) {
+			t.Errorf("%s: expected ErrOAuthFailed, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B.
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Unexpected challenge %s", got)
+	}
+}
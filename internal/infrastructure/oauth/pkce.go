@@ -0,0 +1,37 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// randomBytes is enough entropy for both the state and the code verifier;
+// encoded, it gives the 43 characters RFC 7636 requires at minimum.
+const randomBytes = 32
+
+// NewState returns a random value binding a provider callback to the
+// browser that started the sign-in.
+func NewState() (string, error) {
+	return randomString()
+}
+
+// NewCodeVerifier returns a random PKCE code verifier.
+func NewCodeVerifier() (string, error) {
+	return randomString()
+}
+
+// CodeChallenge derives the S256 challenge sent to the provider from a code
+// verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, randomBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,466 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// InMemoryUserRepository keeps users in process memory. It follows the same
+// contract as SQLiteUserRepository and is meant for tests and throwaway
+// demos: nothing survives a restart.
+type InMemoryUserRepository struct {
+	mu         sync.RWMutex
+	users      map[int64]*domain.User
+	byEmail    map[string]int64
+	byUsername map[string]int64
+	nextID     int64
+	softDelete bool
+}
+
+type InMemoryUserRepositoryOption func(*InMemoryUserRepository)
+
+// WithInMemorySoftDelete is WithSoftDelete for the in-memory repository.
+func WithInMemorySoftDelete() InMemoryUserRepositoryOption {
+	return func(r *InMemoryUserRepository) {
+		r.softDelete = true
+	}
+}
+
+func NewInMemoryUserRepository(opts ...InMemoryUserRepositoryOption) *InMemoryUserRepository {
+	r := &InMemoryUserRepository{
+		users:      make(map[int64]*domain.User),
+		byEmail:    make(map[string]int64),
+		byUsername: make(map[string]int64),
+		nextID:     1,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *InMemoryUserRepository) Create(ctx context.Context, email, passwordHash string, policyVersion int) (*domain.User, error) {
+	return r.CreateWithUsername(ctx, email, "", passwordHash, policyVersion)
+}
+
+func (r *InMemoryUserRepository) CreateWithUsername(ctx context.Context, email, username, passwordHash string, policyVersion int) (*domain.User, error) {
+	return r.insert(ctx, &domain.User{
+		Email:                 email,
+		Username:              username,
+		PasswordHash:          passwordHash,
+		PasswordPolicyVersion: policyVersion,
+	})
+}
+
+func (r *InMemoryUserRepository) CreateExternal(ctx context.Context, email, provider string) (*domain.User, error) {
+	return r.insert(ctx, &domain.User{
+		Email:         email,
+		Provider:      provider,
+		EmailVerified: true,
+	})
+}
+
+func (r *InMemoryUserRepository) insert(ctx context.Context, user *domain.User) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Mirrors the CHECK constraint on users.email.
+	if len(user.Email) > domain.MaxEmailLength {
+		return nil, domain.ErrEmailTooLong
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.byEmail[user.Email]; exists {
+		return nil, domain.ErrUserAlreadyExists
+	}
+	if _, exists := r.byUsername[user.Username]; exists && user.Username != "" {
+		return nil, domain.ErrUsernameTaken
+	}
+
+	now := time.Now()
+	user.ID = r.nextID
+	user.Role = domain.RoleUser
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	r.nextID++
+	r.users[user.ID] = user
+	r.byEmail[user.Email] = user.ID
+	if user.Username != "" {
+		r.byUsername[user.Username] = user.ID
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *InMemoryUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, exists := r.byEmail[email]
+	if !exists || r.users[id].DeletedAt != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(r.users[id]), nil
+}
+
+func (r *InMemoryUserRepository) FindByUsername(ctx context.Context, username string) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, exists := r.byUsername[username]
+	if !exists || r.users[id].DeletedAt != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(r.users[id]), nil
+}
+
+func (r *InMemoryUserRepository) FindByID(ctx context.Context, id int64) (*domain.User, error) {
+	return r.findByID(ctx, id, false)
+}
+
+func (r *InMemoryUserRepository) FindByIDIncludingDeleted(ctx context.Context, id int64) (*domain.User, error) {
+	return r.findByID(ctx, id, true)
+}
+
+func (r *InMemoryUserRepository) findByID(ctx context.Context, id int64, includeDeleted bool) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[id]
+	if !exists || (user.DeletedAt != nil && !includeDeleted) {
+		return nil, domain.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (r *InMemoryUserRepository) List(ctx context.Context, opts domain.UserListOptions) ([]*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	column, err := userSortColumn(opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	if opts.After != nil && column != "created_at" {
+		return nil, fmt.Errorf("a user cursor requires sorting by %s", domain.UserSortCreatedAt)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*domain.User, 0, len(r.users))
+	for _, user := range r.users {
+		if user.DeletedAt == nil && (opts.After == nil || afterCursor(user, opts.After, opts.Descending)) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if opts.Descending {
+			a, b = b, a
+		}
+		switch opts.SortBy {
+		case domain.UserSortEmail:
+			if a.Email != b.Email {
+				return a.Email < b.Email
+			}
+		case domain.UserSortCreatedAt:
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+		}
+		return a.ID < b.ID
+	})
+
+	start := opts.Offset
+	if start < 0 || opts.After != nil {
+		start = 0
+	}
+	page := []*domain.User{}
+	for i := start; i < len(users) && len(page) < opts.Limit; i++ {
+		page = append(page, copyUser(users[i]))
+	}
+	return page, nil
+}
+
+// afterCursor reports whether user comes after cursor in (created_at, id)
+// order, or before it when descending.
+func afterCursor(user *domain.User, cursor *domain.UserCursor, descending bool) bool {
+	if descending {
+		return user.CreatedAt.Before(cursor.CreatedAt) || (user.CreatedAt.Equal(cursor.CreatedAt) && user.ID < cursor.ID)
+	}
+	return user.CreatedAt.After(cursor.CreatedAt) || (user.CreatedAt.Equal(cursor.CreatedAt) && user.ID > cursor.ID)
+}
+
+func (r *InMemoryUserRepository) Count(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, user := range r.users {
+		if user.DeletedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists || (user.DeletedAt != nil && r.softDelete) {
+		return domain.ErrUserNotFound
+	}
+	if r.softDelete {
+		now := time.Now()
+		user.DeletedAt = &now
+		user.UpdatedAt = now
+		return nil
+	}
+	delete(r.byEmail, user.Email)
+	if user.Username != "" {
+		delete(r.byUsername, user.Username)
+	}
+	delete(r.users, id)
+	return nil
+}
+
+func (r *InMemoryUserRepository) Restore(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists || user.DeletedAt == nil {
+		return domain.ErrUserNotFound
+	}
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *InMemoryUserRepository) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.EmailVerified = verified
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) SetRole(ctx context.Context, id int64, role string) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.Role = role
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdateProfile(ctx context.Context, id int64, displayName string) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.DisplayName = displayName
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.PasswordHash = passwordHash
+		user.PasswordPolicyVersion = policyVersion
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) ChangePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) (int, error) {
+	var version int
+	err := r.update(ctx, id, func(user *domain.User) error {
+		user.PasswordHash = passwordHash
+		user.PasswordPolicyVersion = policyVersion
+		user.TokenVersion++
+		version = user.TokenVersion
+		return nil
+	})
+	return version, err
+}
+
+func (r *InMemoryUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		if len(email) > domain.MaxEmailLength {
+			return domain.ErrEmailTooLong
+		}
+		if otherID, exists := r.byEmail[email]; exists && otherID != id {
+			return domain.ErrUserAlreadyExists
+		}
+		delete(r.byEmail, user.Email)
+		r.byEmail[email] = id
+		user.Email = email
+		user.EmailVerified = false
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) SetPendingEmail(ctx context.Context, id int64, email string) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		if len(email) > domain.MaxEmailLength {
+			return domain.ErrEmailTooLong
+		}
+		if _, exists := r.byEmail[email]; exists && email != "" {
+			return domain.ErrUserAlreadyExists
+		}
+		user.PendingEmail = email
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) ConfirmPendingEmail(ctx context.Context, id int64) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		if user.PendingEmail == "" {
+			return domain.ErrNoPendingEmail
+		}
+		if _, exists := r.byEmail[user.PendingEmail]; exists {
+			return domain.ErrUserAlreadyExists
+		}
+		delete(r.byEmail, user.Email)
+		r.byEmail[user.PendingEmail] = id
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.EmailVerified = true
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.TOTPSecret = encryptedSecret
+		user.TOTPEnabled = false
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) EnableTOTP(ctx context.Context, id int64) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		// Same outcome as the SQL WHERE totp_secret != '' matching no row.
+		if user.TOTPSecret == "" {
+			return domain.ErrUserNotFound
+		}
+		user.TOTPEnabled = true
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) IncrementFailedAttempts(ctx context.Context, id int64) (int, error) {
+	var attempts int
+	err := r.update(ctx, id, func(user *domain.User) error {
+		user.FailedAttempts++
+		attempts = user.FailedAttempts
+		return nil
+	})
+	return attempts, err
+}
+
+func (r *InMemoryUserRepository) ResetFailedAttempts(ctx context.Context, id int64) error {
+	return r.update(ctx, id, func(user *domain.User) error {
+		user.FailedAttempts = 0
+		return nil
+	})
+}
+
+func (r *InMemoryUserRepository) UpdateLastLogin(ctx context.Context, id int64, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists || user.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	// A login is not a profile change, so updated_at is left alone.
+	lastLoginAt := t.UTC()
+	user.LastLoginAt = &lastLoginAt
+	return nil
+}
+
+func (r *InMemoryUserRepository) CountByPasswordPolicyVersion(ctx context.Context) (map[int]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[int]int64)
+	for _, user := range r.users {
+		if user.DeletedAt == nil {
+			counts[user.PasswordPolicyVersion]++
+		}
+	}
+	return counts, nil
+}
+
+// update applies change to the stored user under the write lock and bumps
+// UpdatedAt when it succeeds.
+func (r *InMemoryUserRepository) update(ctx context.Context, id int64, change func(*domain.User) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists || user.DeletedAt != nil {
+		return domain.ErrUserNotFound
+	}
+	if err := change(user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// copyUser keeps callers from mutating stored users behind the lock, the way
+// a database hands out fresh rows.
+func copyUser(user *domain.User) *domain.User {
+	copied := *user
+	if user.LastLoginAt != nil {
+		lastLoginAt := *user.LastLoginAt
+		copied.LastLoginAt = &lastLoginAt
+	}
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		copied.DeletedAt = &deletedAt
+	}
+	return &copied
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// RetryConfig sets how transient database errors are retried: up to
+// MaxAttempts tries in all, waiting BaseDelay after the first failure and
+// doubling the wait after each further one, up to MaxDelay.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryConfig rides out the short "database is locked" windows
+// SQLite's single writer causes under load.
+var DefaultRetryConfig = RetryConfig{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 200 * time.Millisecond}
+
+// RetryingUserRepository retries the writes of another UserRepository when
+// they fail with a transient error, such as SQLITE_BUSY. Only writes that
+// can be replayed with the same outcome are retried: the Create methods,
+// ChangePassword, IncrementFailedAttempts, ConfirmPendingEmail, Delete and
+// Restore may have taken effect before the error surfaced, so they and
+// every read pass straight through.
+type RetryingUserRepository struct {
+	domain.UserRepository
+	config RetryConfig
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+func NewRetryingUserRepository(repo domain.UserRepository, config RetryConfig) *RetryingUserRepository {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return &RetryingUserRepository{
+		UserRepository: repo,
+		config:         config,
+		sleep:          sleepContext,
+	}
+}
+
+// IsTransientDBError reports whether err is a lock conflict that may clear
+// on its own, as opposed to a failure a retry would only repeat.
+func IsTransientDBError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+func (r *RetryingUserRepository) retry(ctx context.Context, op func() error) error {
+	delay := r.config.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= r.config.MaxAttempts || !IsTransientDBError(err) {
+			return err
+		}
+		// A request that ends while waiting reports why it ended, so it
+		// is answered as a timeout rather than a database failure.
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			return fmt.Errorf("%w (after %v)", sleepErr, err)
+		}
+		delay *= 2
+		if r.config.MaxDelay > 0 && delay > r.config.MaxDelay {
+			delay = r.config.MaxDelay
+		}
+	}
+}
+
+func (r *RetryingUserRepository) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	return r.retry(ctx, func() error { return r.UserRepository.SetEmailVerified(ctx, id, verified) })
+}
+
+func (r *RetryingUserRepository) SetRole(ctx context.Context, id int64, role string) error {
+	return r.retry(ctx, func() error { return r.UserRepository.SetRole(ctx, id, role) })
+}
+
+func (r *RetryingUserRepository) UpdateProfile(ctx context.Context, id int64, displayName string) error {
+	return r.retry(ctx, func() error { return r.UserRepository.UpdateProfile(ctx, id, displayName) })
+}
+
+func (r *RetryingUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) error {
+	return r.retry(ctx, func() error { return r.UserRepository.UpdatePassword(ctx, id, passwordHash, policyVersion) })
+}
+
+func (r *RetryingUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	return r.retry(ctx, func() error { return r.UserRepository.UpdateEmail(ctx, id, email) })
+}
+
+func (r *RetryingUserRepository) SetPendingEmail(ctx context.Context, id int64, email string) error {
+	return r.retry(ctx, func() error { return r.UserRepository.SetPendingEmail(ctx, id, email) })
+}
+
+func (r *RetryingUserRepository) SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error {
+	return r.retry(ctx, func() error { return r.UserRepository.SetTOTPSecret(ctx, id, encryptedSecret) })
+}
+
+func (r *RetryingUserRepository) EnableTOTP(ctx context.Context, id int64) error {
+	return r.retry(ctx, func() error { return r.UserRepository.EnableTOTP(ctx, id) })
+}
+
+func (r *RetryingUserRepository) ResetFailedAttempts(ctx context.Context, id int64) error {
+	return r.retry(ctx, func() error { return r.UserRepository.ResetFailedAttempts(ctx, id) })
+}
+
+func (r *RetryingUserRepository) UpdateLastLogin(ctx context.Context, id int64, t time.Time) error {
+	return r.retry(ctx, func() error { return r.UserRepository.UpdateLastLogin(ctx, id, t) })
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
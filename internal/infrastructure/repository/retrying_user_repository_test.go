@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// flakyUserRepository fails its writes with SQLITE_BUSY until failures runs
+// out, then behaves like the in-memory repository.
+type flakyUserRepository struct {
+	*InMemoryUserRepository
+	failures int
+	calls    int
+}
+
+func (r *flakyUserRepository) flake() error {
+	r.calls++
+	if r.failures > 0 {
+		r.failures--
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	}
+	return nil
+}
+
+func (r *flakyUserRepository) SetRole(ctx context.Context, id int64, role string) error {
+	if err := r.flake(); err != nil {
+		return err
+	}
+	return r.InMemoryUserRepository.SetRole(ctx, id, role)
+}
+
+func (r *flakyUserRepository) IncrementFailedAttempts(ctx context.Context, id int64) (int, error) {
+	if err := r.flake(); err != nil {
+		return 0, err
+	}
+	return r.InMemoryUserRepository.IncrementFailedAttempts(ctx, id)
+}
+
+func newFlakyRepository(t *testing.T, failures int) (*RetryingUserRepository, *flakyUserRepository, *[]time.Duration) {
+	t.Helper()
+
+	flaky := &flakyUserRepository{InMemoryUserRepository: NewInMemoryUserRepository(), failures: failures}
+	seedUsers(t, flaky, 1)
+
+	repo := NewRetryingUserRepository(flaky, RetryConfig{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second})
+	var delays []time.Duration
+	repo.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return repo, flaky, &delays
+}
+
+func TestRetryingUserRepository_RetriesTransientErrors(t *testing.T) {
+	repo, flaky, delays := newFlakyRepository(t, 2)
+
+	if err := repo.SetRole(context.Background(), 1, domain.RoleAdmin); err != nil {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", flaky.calls)
+	}
+	if len(*delays) != 2 || (*delays)[0] != 10*time.Millisecond || (*delays)[1] != 20*time.Millisecond {
+		t.Errorf("Expected waits of 10ms then 20ms, got %v", *delays)
+	}
+	if user, _ := repo.FindByID(context.Background(), 1); user.Role != domain.RoleAdmin {
+		t.Errorf("Expected the role to be saved, got %q", user.Role)
+	}
+}
+
+func TestRetryingUserRepository_GivesUp(t *testing.T) {
+	repo, flaky, _ := newFlakyRepository(t, 5)
+
+	err := repo.SetRole(context.Background(), 1, domain.RoleAdmin)
+	if !IsTransientDBError(err) || flaky.calls != 3 {
+		t.Errorf("Expected the busy error after 3 attempts, got %v after %d", err, flaky.calls)
+	}
+}
+
+func TestRetryingUserRepository_SkipsUnsafeAndPermanentErrors(t *testing.T) {
+	repo, flaky, _ := newFlakyRepository(t, 1)
+
+	// Replaying an increment could count one failure twice.
+	if _, err := repo.IncrementFailedAttempts(context.Background(), 1); !IsTransientDBError(err) || flaky.calls != 1 {
+		t.Errorf("Expected no retry, got %v after %d attempts", err, flaky.calls)
+	}
+
+	flaky.calls = 0
+	if err := repo.SetRole(context.Background(), 42, domain.RoleAdmin); !errors.Is(err, domain.ErrUserNotFound) || flaky.calls != 1 {
+		t.Errorf("Expected ErrUserNotFound without a retry, got %v after %d attempts", err, flaky.calls)
+	}
+}
+
+func TestRetryingUserRepository_StopsWithContext(t *testing.T) {
+	repo, flaky, _ := newFlakyRepository(t, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := repo.SetRole(ctx, 1, domain.RoleAdmin)
+	if !errors.Is(err, context.Canceled) || flaky.calls != 1 {
+		t.Errorf("Expected the wait to end with the context, got %v after %d attempts", err, flaky.calls)
+	}
+}
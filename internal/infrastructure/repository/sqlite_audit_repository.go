@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type SQLiteAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuditRepository(db *sql.DB) *SQLiteAuditRepository {
+	return &SQLiteAuditRepository{
+		db: db,
+	}
+}
+
+// Record does not take the request context: an audit entry should still be
+// written when the client disconnects right after the action.
+func (r *SQLiteAuditRepository) Record(event domain.AuditEvent) {
+	query := `
+		INSERT INTO audit_log (action, user_id, email, ip, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	var userID sql.NullInt64
+	if event.UserID != 0 {
+		userID = sql.NullInt64{Int64: event.UserID, Valid: true}
+	}
+
+	if _, err := r.db.Exec(query, event.Action, userID, event.Email, event.IP, event.CreatedAt.UTC()); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+func (r *SQLiteAuditRepository) List(ctx context.Context, limit, offset int) ([]*domain.AuditEvent, error) {
+	query := `
+		SELECT id, action, user_id, email, ip, created_at
+		FROM audit_log
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.AuditEvent{}
+	for rows.Next() {
+		event := &domain.AuditEvent{}
+		var userID sql.NullInt64
+		if err := rows.Scan(
+			&event.ID,
+			&event.Action,
+			&userID,
+			&event.Email,
+			&event.IP,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		event.UserID = userID.Int64
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *SQLiteAuditRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
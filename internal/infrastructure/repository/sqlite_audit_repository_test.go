@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+)
+
+func TestSQLiteAuditRepository_RecordAndList(t *testing.T) {
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSQLiteAuditRepository(db)
+	repo.Record(domain.AuditEvent{Action: domain.AuditActionRegister, UserID: 1, Email: "test@example.com", IP: "203.0.113.7"})
+	repo.Record(domain.AuditEvent{Action: domain.AuditActionLoginFailed, Email: "unknown@example.com"})
+	repo.Record(domain.AuditEvent{Action: domain.AuditActionLogin, UserID: 1})
+
+	count, err := repo.Count(context.Background())
+	if err != nil || count != 3 {
+		t.Fatalf("Expected 3 events, got %d (%v)", count, err)
+	}
+
+	events, err := repo.List(context.Background(), 2, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(events) != 2 || events[0].Action != domain.AuditActionLogin || events[1].Action != domain.AuditActionLoginFailed {
+		t.Fatalf("Expected newest events first, got %+v", events)
+	}
+	if events[1].UserID != 0 || events[1].Email != "unknown@example.com" {
+		t.Errorf("Expected unknown user to round-trip as zero ID, got %+v", events[1])
+	}
+	if events[0].CreatedAt.IsZero() {
+		t.Error("Expected CreatedAt to default to now")
+	}
+
+	oldest, _ := repo.List(context.Background(), 2, 2)
+	if len(oldest) != 1 || oldest[0].IP != "203.0.113.7" || oldest[0].UserID != 1 {
+		t.Errorf("Unexpected oldest event: %+v", oldest)
+	}
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type SQLiteOutboxRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteOutboxRepository(db *sql.DB) *SQLiteOutboxRepository {
+	return &SQLiteOutboxRepository{
+		db: db,
+	}
+}
+
+// insertOutboxEvent runs inside the caller's transaction so the event is
+// committed or rolled back together with the change it describes.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, eventType string, payload interface{}, now time.Time) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox_events (event_type, payload, idempotency_key, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	// Times are stored in UTC so next_attempt_at compares correctly as text.
+	_, err = tx.ExecContext(ctx, query, eventType, string(body), hex.EncodeToString(key), now.UTC(), now.UTC())
+	return err
+}
+
+func (r *SQLiteOutboxRepository) FetchPending(now time.Time, maxAttempts, limit int) ([]*domain.OutboxEvent, error) {
+	query := `
+		SELECT id, event_type, payload, idempotency_key, attempts, next_attempt_at, last_error, created_at
+		FROM outbox_events
+		WHERE delivered_at IS NULL AND attempts < ? AND next_attempt_at <= ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, maxAttempts, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*domain.OutboxEvent
+	for rows.Next() {
+		event := &domain.OutboxEvent{}
+		var payload string
+		if err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&payload,
+			&event.IdempotencyKey,
+			&event.Attempts,
+			&event.NextAttemptAt,
+			&event.LastError,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		event.Payload = []byte(payload)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *SQLiteOutboxRepository) MarkDelivered(id int64, deliveredAt time.Time) error {
+	query := `
+		UPDATE outbox_events
+		SET delivered_at = ?, attempts = attempts + 1, last_error = ''
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, deliveredAt.UTC(), id)
+	return err
+}
+
+func (r *SQLiteOutboxRepository) MarkFailed(id int64, lastError string, nextAttemptAt time.Time) error {
+	query := `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE id = ? AND delivered_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, lastError, nextAttemptAt.UTC(), id)
+	return err
+}
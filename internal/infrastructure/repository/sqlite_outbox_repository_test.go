@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestSQLiteUserRepository_CreateRecordsOutboxEvent(t *testing.T) {
+	repo := NewSQLiteUserRepository(newTestRepository(t).db, WithOutboxEvents())
+	outbox := NewSQLiteOutboxRepository(repo.db)
+
+	user, err := repo.Create(context.Background(), "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := repo.Create(context.Background(), "test@example.com", "hash", 1); err != domain.ErrUserAlreadyExists {
+		t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+	}
+
+	events, err := outbox.FetchPending(time.Now(), 5, 10)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected exactly one event for the committed insert, got %d", len(events))
+	}
+
+	event := events[0]
+	if event.Type != domain.EventUserRegistered || event.IdempotencyKey == "" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	var payload domain.UserRegisteredEvent
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.UserID != user.ID || payload.Email != user.Email {
+		t.Errorf("Expected payload for user %d, got %+v", user.ID, payload)
+	}
+}
+
+func TestSQLiteOutboxRepository_FailedEventWaitsForRetry(t *testing.T) {
+	repo := NewSQLiteUserRepository(newTestRepository(t).db, WithOutboxEvents())
+	outbox := NewSQLiteOutboxRepository(repo.db)
+
+	if _, err := repo.Create(context.Background(), "test@example.com", "hash", 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	now := time.Now()
+	events, _ := outbox.FetchPending(now, 2, 10)
+	if err := outbox.MarkFailed(events[0].ID, "boom", now.Add(time.Minute)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if events, _ := outbox.FetchPending(now, 2, 10); len(events) != 0 {
+		t.Errorf("Expected event to wait for its retry time, got %d events", len(events))
+	}
+
+	events, _ = outbox.FetchPending(now.Add(2*time.Minute), 2, 10)
+	if len(events) != 1 || events[0].Attempts != 1 || events[0].LastError != "boom" {
+		t.Fatalf("Expected event to be due again with one attempt, got %+v", events)
+	}
+
+	outbox.MarkFailed(events[0].ID, "boom", now)
+	if events, _ := outbox.FetchPending(now.Add(time.Hour), 2, 10); len(events) != 0 {
+		t.Errorf("Expected event to be abandoned after max attempts, got %d events", len(events))
+	}
+}
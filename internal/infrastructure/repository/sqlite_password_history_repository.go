@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SQLitePasswordHistoryRepository struct {
+	db *sql.DB
+}
+
+func NewSQLitePasswordHistoryRepository(db *sql.DB) *SQLitePasswordHistoryRepository {
+	return &SQLitePasswordHistoryRepository{
+		db: db,
+	}
+}
+
+// Add inserts the hash and trims the user's older entries in one
+// transaction, so the history never holds more than keep hashes.
+func (r *SQLitePasswordHistoryRepository) Add(ctx context.Context, userID int64, passwordHash string, keep int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO password_history (user_id, password_hash, created_at)
+		VALUES (?, ?, ?)
+	`, userID, passwordHash, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM password_history
+		WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, userID, userID, keep); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLitePasswordHistoryRepository) Recent(ctx context.Context, userID int64, limit int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
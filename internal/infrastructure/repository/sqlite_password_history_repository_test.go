@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSQLitePasswordHistoryRepository_AddTrimsPerUser(t *testing.T) {
+	userRepo := newTestRepository(t)
+	historyRepo := NewSQLitePasswordHistoryRepository(userRepo.db)
+	ctx := context.Background()
+
+	user, err := userRepo.Create(ctx, "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	other, err := userRepo.Create(ctx, "other@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := historyRepo.Add(ctx, other.ID, "other-1", 3); err != nil {
+		t.Fatalf("Failed to add history: %v", err)
+	}
+	for _, hash := range []string{"hash-1", "hash-2", "hash-3", "hash-4"} {
+		if err := historyRepo.Add(ctx, user.ID, hash, 3); err != nil {
+			t.Fatalf("Failed to add history: %v", err)
+		}
+	}
+
+	hashes, err := historyRepo.Recent(ctx, user.ID, 10)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if expected := []string{"hash-4", "hash-3", "hash-2"}; !reflect.DeepEqual(hashes, expected) {
+		t.Errorf("Expected the newest 3 hashes %v, got %v", expected, hashes)
+	}
+	if hashes, _ := historyRepo.Recent(ctx, user.ID, 1); !reflect.DeepEqual(hashes, []string{"hash-4"}) {
+		t.Errorf("Expected the limit to apply, got %v", hashes)
+	}
+	if hashes, _ := historyRepo.Recent(ctx, other.ID, 10); !reflect.DeepEqual(hashes, []string{"other-1"}) {
+		t.Errorf("Expected the other user's history to be untouched, got %v", hashes)
+	}
+
+	if err := userRepo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if hashes, _ := historyRepo.Recent(ctx, user.ID, 10); len(hashes) != 0 {
+		t.Errorf("Expected the history to go with the user, got %v", hashes)
+	}
+}
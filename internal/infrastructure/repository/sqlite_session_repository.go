@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type SQLiteSessionRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteSessionRepository(db *sql.DB) *SQLiteSessionRepository {
+	return &SQLiteSessionRepository{
+		db: db,
+	}
+}
+
+const sessionSelectColumns = "id, user_id, refresh_token_id, user_agent, ip, created_at, last_used_at, expires_at"
+
+func scanSession(row rowScanner) (*domain.Session, error) {
+	session := &domain.Session{}
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	)
+	return session, err
+}
+
+func (r *SQLiteSessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	query := `
+		INSERT INTO sessions (user_id, refresh_token_id, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	now := time.Now().UTC()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	if session.LastUsedAt.IsZero() {
+		session.LastUsedAt = session.CreatedAt
+	}
+
+	result, err := r.db.ExecContext(ctx, query,
+		session.UserID,
+		session.RefreshTokenID,
+		session.UserAgent,
+		session.IP,
+		session.CreatedAt.UTC(),
+		session.LastUsedAt.UTC(),
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	session.ID = id
+	return nil
+}
+
+func (r *SQLiteSessionRepository) FindByID(ctx context.Context, id int64) (*domain.Session, error) {
+	query := `
+		SELECT ` + sessionSelectColumns + `
+		FROM sessions
+		WHERE id = ?
+	`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *SQLiteSessionRepository) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]*domain.Session, error) {
+	query := `
+		SELECT ` + sessionSelectColumns + `
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_used_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, time.Now().UTC(), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *SQLiteSessionRepository) CountByUser(ctx context.Context, userID int64) (int64, error) {
+	query := `SELECT COUNT(*) FROM sessions WHERE user_id = ? AND expires_at > ?`
+
+	var count int64
+	if err := r.db.QueryRowContext(ctx, query, userID, time.Now().UTC()).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLiteSessionRepository) Rotate(ctx context.Context, id int64, currentID, refreshTokenID string, expiresAt, usedAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET refresh_token_id = ?, expires_at = ?, last_used_at = ?
+		WHERE id = ? AND refresh_token_id = ?
+	`
+
+	result, err := r.db.ExecContext(ctx, query, refreshTokenID, expiresAt.UTC(), usedAt.UTC(), id, currentID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteSessionRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrSessionNotFound
+	}
+
+	return nil
+}
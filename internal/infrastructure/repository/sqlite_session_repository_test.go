@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestSQLiteSessionRepository_Lifecycle(t *testing.T) {
+	userRepo := newTestRepository(t)
+	sessionRepo := NewSQLiteSessionRepository(userRepo.db)
+	ctx := context.Background()
+
+	user, err := userRepo.Create(ctx, "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	now := time.Now().UTC()
+	older := &domain.Session{
+		UserID:         user.ID,
+		RefreshTokenID: "jti-1",
+		UserAgent:      "curl/8.0",
+		IP:             "203.0.113.7",
+		CreatedAt:      now.Add(-time.Hour),
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	newer := &domain.Session{
+		UserID:         user.ID,
+		RefreshTokenID: "jti-2",
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(time.Hour),
+	}
+	expired := &domain.Session{
+		UserID:         user.ID,
+		RefreshTokenID: "jti-3",
+		CreatedAt:      now.Add(-2 * time.Hour),
+		ExpiresAt:      now.Add(-time.Minute),
+	}
+	for _, session := range []*domain.Session{older, newer, expired} {
+		if err := sessionRepo.Create(ctx, session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		if session.ID == 0 {
+			t.Fatal("Expected Create to set the session ID")
+		}
+	}
+
+	sessions, err := sessionRepo.ListByUser(ctx, user.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != newer.ID || sessions[1].ID != older.ID {
+		t.Fatalf("Expected unexpired sessions most recent first, got %+v", sessions)
+	}
+	if count, err := sessionRepo.CountByUser(ctx, user.ID); err != nil || count != 2 {
+		t.Errorf("Expected 2 unexpired sessions counted, got %d, %v", count, err)
+	}
+	if page, err := sessionRepo.ListByUser(ctx, user.ID, 1, 1); err != nil || len(page) != 1 || page[0].ID != older.ID {
+		t.Errorf("Expected the second page to hold the older session, got %+v, %v", page, err)
+	}
+	if sessions[1].UserAgent != "curl/8.0" || sessions[1].IP != "203.0.113.7" {
+		t.Errorf("Expected metadata to round-trip, got %+v", sessions[1])
+	}
+
+	usedAt := now.Add(time.Minute)
+	if err := sessionRepo.Rotate(ctx, older.ID, "jti-1", "jti-4", now.Add(2*time.Hour), usedAt); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	found, err := sessionRepo.FindByID(ctx, older.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if found.RefreshTokenID != "jti-4" || !found.LastUsedAt.Equal(usedAt) {
+		t.Errorf("Expected rotated session, got %+v", found)
+	}
+	// jti-1 was already replaced, so a second rotation from it must lose.
+	if err := sessionRepo.Rotate(ctx, older.ID, "jti-1", "jti-6", now.Add(2*time.Hour), usedAt); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound rotating from a stale token, got %v", err)
+	}
+
+	if err := sessionRepo.Delete(ctx, older.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := sessionRepo.FindByID(ctx, older.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound after delete, got %v", err)
+	}
+	if err := sessionRepo.Delete(ctx, older.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound deleting twice, got %v", err)
+	}
+	if err := sessionRepo.Rotate(ctx, older.ID, "jti-4", "jti-5", now, now); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound rotating a deleted session, got %v", err)
+	}
+}
+
+func TestSQLiteUserRepository_DeleteRemovesSessions(t *testing.T) {
+	userRepo := newTestRepository(t)
+	sessionRepo := NewSQLiteSessionRepository(userRepo.db)
+	ctx := context.Background()
+
+	user, err := userRepo.Create(ctx, "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	now := time.Now().UTC()
+	session := &domain.Session{UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(-time.Minute)}
+	if err := sessionRepo.Create(ctx, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if err := userRepo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := sessionRepo.FindByID(ctx, session.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected the session to be deleted with the user, got %v", err)
+	}
+}
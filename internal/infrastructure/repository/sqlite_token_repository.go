@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type SQLiteTokenRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteTokenRepository(db *sql.DB) *SQLiteTokenRepository {
+	return &SQLiteTokenRepository{
+		db: db,
+	}
+}
+
+func (r *SQLiteTokenRepository) Create(ctx context.Context, token *domain.OneTimeToken) error {
+	query := `
+		INSERT INTO one_time_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	token.ID = id
+	token.CreatedAt = now
+	return nil
+}
+
+func (r *SQLiteTokenRepository) FindByHash(ctx context.Context, purpose, tokenHash string) (*domain.OneTimeToken, error) {
+	query := `
+		SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at
+		FROM one_time_tokens
+		WHERE purpose = ? AND token_hash = ?
+	`
+
+	token := &domain.OneTimeToken{}
+	var usedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, purpose, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&usedAt,
+		&token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrOneTimeTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+
+	return token, nil
+}
+
+func (r *SQLiteTokenRepository) MarkUsed(ctx context.Context, id int64, usedAt time.Time) error {
+	query := `
+		UPDATE one_time_tokens
+		SET used_at = ?
+		WHERE id = ? AND used_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, usedAt, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrOneTimeTokenUsed
+	}
+
+	return nil
+}
+
+func (r *SQLiteTokenRepository) InvalidateUnused(ctx context.Context, userID int64, purpose string, usedAt time.Time) error {
+	query := `
+		UPDATE one_time_tokens
+		SET used_at = ?
+		WHERE user_id = ? AND purpose = ? AND used_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, usedAt, userID, purpose)
+	return err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestSQLiteTokenRepository_MarkUsedOnce(t *testing.T) {
+	userRepo := newTestRepository(t)
+	tokenRepo := NewSQLiteTokenRepository(userRepo.db)
+
+	user, err := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	token := &domain.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposeEmailVerification,
+		TokenHash: "token-hash",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := tokenRepo.Create(context.Background(), token); err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+
+	found, err := tokenRepo.FindByHash(context.Background(), domain.TokenPurposeEmailVerification, "token-hash")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if found.UserID != user.ID || found.UsedAt != nil {
+		t.Fatalf("Expected unused token for user %d, got %+v", user.ID, found)
+	}
+
+	if err := tokenRepo.MarkUsed(context.Background(), found.ID, time.Now()); err != nil {
+		t.Fatalf("Expected first MarkUsed to succeed, got %v", err)
+	}
+
+	if err := tokenRepo.MarkUsed(context.Background(), found.ID, time.Now()); !errors.Is(err, domain.ErrOneTimeTokenUsed) {
+		t.Errorf("Expected ErrOneTimeTokenUsed, got %v", err)
+	}
+
+	if _, err := tokenRepo.FindByHash(context.Background(), "other_purpose", "token-hash"); !errors.Is(err, domain.ErrOneTimeTokenInvalid) {
+		t.Errorf("Expected token lookup to be scoped by purpose, got %v", err)
+	}
+}
+
+func TestSQLiteTokenRepository_InvalidateUnused(t *testing.T) {
+	userRepo := newTestRepository(t)
+	tokenRepo := NewSQLiteTokenRepository(userRepo.db)
+	ctx := context.Background()
+
+	user, err := userRepo.Create(ctx, "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	for _, token := range []*domain.OneTimeToken{
+		{UserID: user.ID, Purpose: domain.TokenPurposeEmailChange, TokenHash: "change", ExpiresAt: time.Now().Add(time.Hour)},
+		{UserID: user.ID, Purpose: domain.TokenPurposePasswordReset, TokenHash: "reset", ExpiresAt: time.Now().Add(time.Hour)},
+	} {
+		if err := tokenRepo.Create(ctx, token); err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+	}
+
+	if err := tokenRepo.InvalidateUnused(ctx, user.ID, domain.TokenPurposeEmailChange, time.Now()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	change, _ := tokenRepo.FindByHash(ctx, domain.TokenPurposeEmailChange, "change")
+	if change.UsedAt == nil {
+		t.Error("Expected email change token to be invalidated")
+	}
+	reset, _ := tokenRepo.FindByHash(ctx, domain.TokenPurposePasswordReset, "reset")
+	if reset.UsedAt != nil {
+		t.Error("Expected tokens for other purposes to stay usable")
+	}
+}
@@ -1,69 +1,208 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/valentinfrappart/securerestapi/internal/domain"
 )
 
 type SQLiteUserRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	outboxEvents bool
+	softDelete   bool
 }
 
-func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
-	return &SQLiteUserRepository{
+type UserRepositoryOption func(*SQLiteUserRepository)
+
+// WithOutboxEvents makes Create record a user.registered event in the
+// outbox within the same transaction as the insert.
+func WithOutboxEvents() UserRepositoryOption {
+	return func(r *SQLiteUserRepository) {
+		r.outboxEvents = true
+	}
+}
+
+// WithSoftDelete makes Delete set deleted_at instead of removing the user
+// row, so the account can be restored and its audit history still points at
+// a user.
+func WithSoftDelete() UserRepositoryOption {
+	return func(r *SQLiteUserRepository) {
+		r.softDelete = true
+	}
+}
+
+func NewSQLiteUserRepository(db *sql.DB, opts ...UserRepositoryOption) *SQLiteUserRepository {
+	r := &SQLiteUserRepository{
 		db: db,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// userSelectColumns lists the users columns in the order scanUser reads them.
+const userSelectColumns = "id, email, username, pending_email, display_name, password_hash, provider, role, email_verified, totp_secret, totp_enabled, failed_attempts, password_policy_version, token_version, last_login_at, deleted_at, created_at, updated_at"
+
+// userSortColumns maps the domain.UserSort* fields to their columns.
+var userSortColumns = map[string]string{
+	"":                       "id",
+	domain.UserSortID:        "id",
+	domain.UserSortEmail:     "email",
+	domain.UserSortCreatedAt: "created_at",
+}
+
+func userSortColumn(sortBy string) (string, error) {
+	column, ok := userSortColumns[sortBy]
+	if !ok {
+		return "", fmt.Errorf("unsupported user sort field %q", sortBy)
+	}
+	return column, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	user := &domain.User{}
+	var username, displayName sql.NullString
+	var lastLoginAt, deletedAt sql.NullTime
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&username,
+		&user.PendingEmail,
+		&displayName,
+		&user.PasswordHash,
+		&user.Provider,
+		&user.Role,
+		&user.EmailVerified,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.FailedAttempts,
+		&user.PasswordPolicyVersion,
+		&user.TokenVersion,
+		&lastLoginAt,
+		&deletedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	user.Username = username.String
+	user.DisplayName = displayName.String
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+	if deletedAt.Valid {
+		user.DeletedAt = &deletedAt.Time
+	}
+	return user, err
+}
+
+func (r *SQLiteUserRepository) Create(ctx context.Context, email, passwordHash string, policyVersion int) (*domain.User, error) {
+	return r.CreateWithUsername(ctx, email, "", passwordHash, policyVersion)
+}
+
+// CreateWithUsername stores an empty username as NULL.
+func (r *SQLiteUserRepository) CreateWithUsername(ctx context.Context, email, username, passwordHash string, policyVersion int) (*domain.User, error) {
+	return r.insert(ctx, &domain.User{
+		Email:                 email,
+		Username:              username,
+		PasswordHash:          passwordHash,
+		PasswordPolicyVersion: policyVersion,
+	})
+}
+
+func (r *SQLiteUserRepository) CreateExternal(ctx context.Context, email, provider string) (*domain.User, error) {
+	return r.insert(ctx, &domain.User{
+		Email:         email,
+		Provider:      provider,
+		EmailVerified: true,
+	})
 }
 
-func (r *SQLiteUserRepository) Create(email, passwordHash string) (*domain.User, error) {
+// insert stores a new user with the user role and fills in its id and
+// timestamps.
+func (r *SQLiteUserRepository) insert(ctx context.Context, user *domain.User) (*domain.User, error) {
 	query := `
-		INSERT INTO users (email, password_hash, created_at, updated_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO users (email, username, password_hash, provider, email_verified, password_policy_version, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	now := time.Now()
-	result, err := r.db.Exec(query, email, passwordHash, now, now)
+	user.Role = domain.RoleUser
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	result, err := tx.ExecContext(ctx, query, user.Email, sql.NullString{String: user.Username, Valid: user.Username != ""}, user.PasswordHash, user.Provider, user.EmailVerified, user.PasswordPolicyVersion, user.Role, now, now)
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: users.email" {
+		switch err.Error() {
+		case "UNIQUE constraint failed: users.email":
 			return nil, domain.ErrUserAlreadyExists
+		case "UNIQUE constraint failed: users.username":
+			return nil, domain.ErrUsernameTaken
 		}
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	user.ID, err = result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
 
-	user := &domain.User{
-		ID:           id,
-		Email:        email,
-		PasswordHash: passwordHash,
-		CreatedAt:    now,
-		UpdatedAt:    now,
+	if r.outboxEvents {
+		event := domain.UserRegisteredEvent{
+			UserID:    user.ID,
+			Email:     user.Email,
+			Role:      user.Role,
+			CreatedAt: user.CreatedAt,
+		}
+		if err := insertOutboxEvent(ctx, tx, domain.EventUserRegistered, event, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
 	return user, nil
 }
 
-func (r *SQLiteUserRepository) FindByEmail(email string) (*domain.User, error) {
+func (r *SQLiteUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT ` + userSelectColumns + `
 		FROM users
-		WHERE email = ?
+		WHERE email = ? AND deleted_at IS NULL
 	`
 
-	user := &domain.User{}
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, email))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	return user, nil
+}
+
+func (r *SQLiteUserRepository) FindByUsername(ctx context.Context, username string) (*domain.User, error) {
+	query := `
+		SELECT ` + userSelectColumns + `
+		FROM users
+		WHERE username = ? AND deleted_at IS NULL
+	`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrUserNotFound
 	}
@@ -74,22 +213,25 @@ func (r *SQLiteUserRepository) FindByEmail(email string) (*domain.User, error) {
 	return user, nil
 }
 
-func (r *SQLiteUserRepository) FindByID(id int64) (*domain.User, error) {
+func (r *SQLiteUserRepository) FindByID(ctx context.Context, id int64) (*domain.User, error) {
+	return r.findByID(ctx, id, false)
+}
+
+func (r *SQLiteUserRepository) FindByIDIncludingDeleted(ctx context.Context, id int64) (*domain.User, error) {
+	return r.findByID(ctx, id, true)
+}
+
+func (r *SQLiteUserRepository) findByID(ctx context.Context, id int64, includeDeleted bool) (*domain.User, error) {
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT ` + userSelectColumns + `
 		FROM users
 		WHERE id = ?
 	`
+	if !includeDeleted {
+		query += ` AND deleted_at IS NULL`
+	}
 
-	user := &domain.User{}
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrUserNotFound
 	}
@@ -99,3 +241,488 @@ func (r *SQLiteUserRepository) FindByID(id int64) (*domain.User, error) {
 
 	return user, nil
 }
+
+func (r *SQLiteUserRepository) List(ctx context.Context, opts domain.UserListOptions) ([]*domain.User, error) {
+	column, err := userSortColumn(opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	where := `deleted_at IS NULL`
+	var args []interface{}
+	if opts.After != nil {
+		if column != "created_at" {
+			return nil, fmt.Errorf("a user cursor requires sorting by %s", domain.UserSortCreatedAt)
+		}
+		where += ` AND (created_at ` + comparison + ` ? OR (created_at = ? AND id ` + comparison + ` ?))`
+		args = append(args, opts.After.CreatedAt, opts.After.CreatedAt, opts.After.ID)
+		opts.Offset = 0
+	}
+
+	// column comes from a fixed set, never from the caller's string.
+	query := `
+		SELECT ` + userSelectColumns + `
+		FROM users
+		WHERE ` + where + `
+		ORDER BY ` + column + ` ` + direction + `, id ` + direction + `
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, opts.Limit, opts.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*domain.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *SQLiteUserRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLiteUserRepository) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	query := `
+		UPDATE users
+		SET email_verified = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, verified, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) SetRole(ctx context.Context, id int64, role string) error {
+	query := `
+		UPDATE users
+		SET role = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, role, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateProfile stores an empty display name as NULL.
+func (r *SQLiteUserRepository) UpdateProfile(ctx context.Context, id int64, displayName string) error {
+	query := `
+		UPDATE users
+		SET display_name = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, sql.NullString{String: displayName, Valid: displayName != ""}, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) error {
+	query := `
+		UPDATE users
+		SET password_hash = ?, password_policy_version = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, passwordHash, policyVersion, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) ChangePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) (int, error) {
+	query := `
+		UPDATE users
+		SET password_hash = ?, password_policy_version = ?, token_version = token_version + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING token_version
+	`
+
+	var version int
+	err := r.db.QueryRowContext(ctx, query, passwordHash, policyVersion, time.Now(), id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Delete also removes the user's rows in one_time_tokens and sessions, in
+// the same transaction: their ON DELETE CASCADE only applies when SQLite's
+// foreign_keys pragma is on, and a soft delete does not trigger it at all.
+// A hard delete removes password_history too; a soft delete keeps it, so a
+// restored user still cannot reuse a recent password. Audit entries are
+// kept.
+func (r *SQLiteUserRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	children := []string{
+		`DELETE FROM one_time_tokens WHERE user_id = ?`,
+		`DELETE FROM sessions WHERE user_id = ?`,
+	}
+	if !r.softDelete {
+		children = append(children, `DELETE FROM password_history WHERE user_id = ?`)
+	}
+	for _, query := range children {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	query := `DELETE FROM users WHERE id = ?`
+	args := []interface{}{id}
+	if r.softDelete {
+		now := time.Now()
+		query = `UPDATE users SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`
+		args = []interface{}{now, now, id}
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLiteUserRepository) Restore(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET deleted_at = NULL, updated_at = ?
+		WHERE id = ? AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	query := `
+		UPDATE users
+		SET email = ?, email_verified = 0, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, email, time.Now(), id)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: users.email" {
+			return domain.ErrUserAlreadyExists
+		}
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) SetPendingEmail(ctx context.Context, id int64, email string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if email != "" {
+		var taken bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email = ?)`, email).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return domain.ErrUserAlreadyExists
+		}
+	}
+
+	query := `
+		UPDATE users
+		SET pending_email = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := tx.ExecContext(ctx, query, email, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLiteUserRepository) ConfirmPendingEmail(ctx context.Context, id int64) error {
+	// A single UPDATE swaps the addresses, so a failure (such as someone
+	// registering the address meanwhile) leaves the old email in place.
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = '', email_verified = 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND pending_email != ''
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		if err.Error() == "UNIQUE constraint failed: users.email" {
+			return domain.ErrUserAlreadyExists
+		}
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		if _, err := r.FindByID(ctx, id); err != nil {
+			return err
+		}
+		return domain.ErrNoPendingEmail
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error {
+	query := `
+		UPDATE users
+		SET totp_secret = ?, totp_enabled = 0, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, encryptedSecret, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) EnableTOTP(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET totp_enabled = 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND totp_secret != ''
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) IncrementFailedAttempts(ctx context.Context, id int64) (int, error) {
+	query := `
+		UPDATE users
+		SET failed_attempts = failed_attempts + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING failed_attempts
+	`
+
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, time.Now(), id).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (r *SQLiteUserRepository) ResetFailedAttempts(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users
+		SET failed_attempts = 0, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) UpdateLastLogin(ctx context.Context, id int64, t time.Time) error {
+	query := `
+		UPDATE users
+		SET last_login_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, t.UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *SQLiteUserRepository) CountByPasswordPolicyVersion(ctx context.Context) (map[int]int64, error) {
+	query := `
+		SELECT password_policy_version, COUNT(*)
+		FROM users
+		WHERE deleted_at IS NULL
+		GROUP BY password_policy_version
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var count int64
+		if err := rows.Scan(&version, &count); err != nil {
+			return nil, err
+		}
+		counts[version] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
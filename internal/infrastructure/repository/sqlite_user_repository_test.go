@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+)
+
+func newTestRepository(t *testing.T) *SQLiteUserRepository {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewSQLiteUserRepository(db)
+}
+
+func seedUsers(t *testing.T, repo domain.UserRepository, n int) {
+	t.Helper()
+
+	for i := 1; i <= n; i++ {
+		if _, err := repo.Create(context.Background(), fmt.Sprintf("user%d@example.com", i), "hash", 1); err != nil {
+			t.Fatalf("Failed to create user %d: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,759 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// forEachUserRepository runs test against every domain.UserRepository
+// implementation, so they are held to the same contract.
+func forEachUserRepository(t *testing.T, test func(t *testing.T, repo domain.UserRepository)) {
+	implementations := []struct {
+		name    string
+		newRepo func(t *testing.T) domain.UserRepository
+	}{
+		{"sqlite", func(t *testing.T) domain.UserRepository { return newTestRepository(t) }},
+		{"memory", func(t *testing.T) domain.UserRepository { return NewInMemoryUserRepository() }},
+	}
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			test(t, impl.newRepo(t))
+		})
+	}
+}
+
+func TestUserRepository_List_LimitOffset(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 5)
+
+		users, err := repo.List(context.Background(), domain.UserListOptions{Limit: 2, Offset: 1})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(users) != 2 {
+			t.Fatalf("Expected 2 users, got %d", len(users))
+		}
+		if users[0].Email != "user2@example.com" || users[1].Email != "user3@example.com" {
+			t.Errorf("Expected users 2 and 3, got %s and %s", users[0].Email, users[1].Email)
+		}
+	})
+}
+
+func TestUserRepository_List_LastPartialPage(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 5)
+
+		users, err := repo.List(context.Background(), domain.UserListOptions{Limit: 3, Offset: 3})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(users) != 2 {
+			t.Errorf("Expected 2 users on the last page, got %d", len(users))
+		}
+	})
+}
+
+func TestUserRepository_List_OffsetBeyondEnd(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 2)
+
+		users, err := repo.List(context.Background(), domain.UserListOptions{Limit: 10, Offset: 5})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if users == nil || len(users) != 0 {
+			t.Errorf("Expected an empty non-nil slice, got %v", users)
+		}
+	})
+}
+
+func TestUserRepository_List_SortByEmailDescending(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		for _, email := range []string{"bob@example.com", "carol@example.com", "alice@example.com"} {
+			if _, err := repo.Create(context.Background(), email, "hash", 1); err != nil {
+				t.Fatalf("Failed to create user: %v", err)
+			}
+		}
+
+		users, err := repo.List(context.Background(), domain.UserListOptions{Limit: 2, SortBy: domain.UserSortEmail, Descending: true})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(users) != 2 || users[0].Email != "carol@example.com" || users[1].Email != "bob@example.com" {
+			t.Errorf("Expected carol then bob, got %v", users)
+		}
+	})
+}
+
+func TestUserRepository_List_UnsupportedSort(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		if _, err := repo.List(context.Background(), domain.UserListOptions{Limit: 10, SortBy: "password_hash"}); err == nil {
+			t.Error("Expected an error for an unsupported sort field")
+		}
+	})
+}
+
+func TestUserRepository_SetRole(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		user, err := repo.Create(context.Background(), "ops@example.com", "hash", 1)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		if err := repo.SetRole(context.Background(), user.ID, domain.RoleAdmin); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		found, err := repo.FindByID(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("Failed to find user: %v", err)
+		}
+		if found.Role != domain.RoleAdmin {
+			t.Errorf("Expected role %q, got %q", domain.RoleAdmin, found.Role)
+		}
+
+		if err := repo.SetRole(context.Background(), 999, domain.RoleAdmin); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_UpdateProfile(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+		seedUsers(t, repo, 1)
+
+		if err := repo.UpdateProfile(ctx, 1, "Alice Martin"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		user, err := repo.FindByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.DisplayName != "Alice Martin" {
+			t.Errorf("Expected display name %q, got %q", "Alice Martin", user.DisplayName)
+		}
+
+		if err := repo.UpdateProfile(ctx, 1, ""); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		user, err = repo.FindByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.DisplayName != "" {
+			t.Errorf("Expected display name to be cleared, got %q", user.DisplayName)
+		}
+
+		if err := repo.UpdateProfile(ctx, 99, "Ghost"); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_Delete(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+		seedUsers(t, repo, 2)
+
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := repo.FindByID(ctx, 1); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+		if _, err := repo.FindByID(ctx, 2); err != nil {
+			t.Errorf("Expected the other user to be kept, got %v", err)
+		}
+
+		// The address is free again.
+		if _, err := repo.Create(ctx, "user1@example.com", "hash", 1); err != nil {
+			t.Errorf("Expected the email to be reusable, got %v", err)
+		}
+
+		if err := repo.Delete(ctx, 1); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_Username(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+
+		created, err := repo.CreateWithUsername(ctx, "alice@example.com", "alice", "hash", 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		found, err := repo.FindByUsername(ctx, "alice")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if found.ID != created.ID || found.Username != "alice" {
+			t.Errorf("Expected to find the user by username, got %+v", found)
+		}
+
+		if _, err := repo.CreateWithUsername(ctx, "other@example.com", "alice", "hash", 1); err != domain.ErrUsernameTaken {
+			t.Errorf("Expected ErrUsernameTaken, got %v", err)
+		}
+		if _, err := repo.FindByEmail(ctx, "other@example.com"); err != domain.ErrUserNotFound {
+			t.Errorf("Expected the conflicting user not to be created, got %v", err)
+		}
+
+		// Users without a username do not collide.
+		seedUsers(t, repo, 2)
+		if _, err := repo.FindByUsername(ctx, ""); err != domain.ErrUserNotFound {
+			t.Errorf("Expected an empty username to match no one, got %v", err)
+		}
+
+		if err := repo.Delete(ctx, created.ID); err != nil {
+			t.Fatalf("Failed to delete user: %v", err)
+		}
+		if _, err := repo.CreateWithUsername(ctx, "new@example.com", "alice", "hash", 1); err != nil {
+			t.Errorf("Expected a deleted user's username to be reusable, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_CreateExternal(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+
+		created, err := repo.CreateExternal(ctx, "sso@example.com", "google")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		user, err := repo.FindByEmail(ctx, "sso@example.com")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.ID != created.ID || user.Provider != "google" || user.PasswordHash != "" || !user.EmailVerified || user.Role != domain.RoleUser {
+			t.Errorf("Expected a verified google user without a password, got %+v", user)
+		}
+
+		if _, err := repo.CreateExternal(ctx, "sso@example.com", "google"); !errors.Is(err, domain.ErrUserAlreadyExists) {
+			t.Errorf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_Count(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 3)
+
+		count, err := repo.Count(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if count != 3 {
+			t.Errorf("Expected count 3, got %d", count)
+		}
+	})
+}
+
+func TestUserRepository_UpdateEmail(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 2)
+
+		if err := repo.SetEmailVerified(context.Background(), 1, true); err != nil {
+			t.Fatalf("Failed to verify user: %v", err)
+		}
+
+		if err := repo.UpdateEmail(context.Background(), 1, "user2@example.com"); err != domain.ErrUserAlreadyExists {
+			t.Errorf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+
+		if err := repo.UpdateEmail(context.Background(), 1, "renamed@example.com"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		user, err := repo.FindByID(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.Email != "renamed@example.com" || user.EmailVerified {
+			t.Errorf("Expected renamed unverified user, got %+v", user)
+		}
+
+		if err := repo.UpdateEmail(context.Background(), 99, "ghost@example.com"); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_PendingEmail(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+		seedUsers(t, repo, 2)
+
+		if err := repo.SetPendingEmail(ctx, 1, "user2@example.com"); err != domain.ErrUserAlreadyExists {
+			t.Errorf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+		if err := repo.ConfirmPendingEmail(ctx, 1); err != domain.ErrNoPendingEmail {
+			t.Errorf("Expected ErrNoPendingEmail, got %v", err)
+		}
+
+		if err := repo.SetPendingEmail(ctx, 1, "renamed@example.com"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := repo.FindByEmail(ctx, "user1@example.com"); err != nil {
+			t.Errorf("Expected old email to still identify the user, got %v", err)
+		}
+		if _, err := repo.FindByEmail(ctx, "renamed@example.com"); err != domain.ErrUserNotFound {
+			t.Errorf("Expected pending email not to identify the user, got %v", err)
+		}
+
+		if err := repo.ConfirmPendingEmail(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		user, err := repo.FindByEmail(ctx, "renamed@example.com")
+		if err != nil {
+			t.Fatalf("Expected new email to identify the user, got %v", err)
+		}
+		if user.ID != 1 || user.PendingEmail != "" || !user.EmailVerified {
+			t.Errorf("Expected confirmed verified address, got %+v", user)
+		}
+		if _, err := repo.FindByEmail(ctx, "user1@example.com"); err != domain.ErrUserNotFound {
+			t.Errorf("Expected old email to be released, got %v", err)
+		}
+
+		if err := repo.ConfirmPendingEmail(ctx, 99); err != domain.ErrUserNotFound {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_ConfirmPendingEmail_Collision(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+		seedUsers(t, repo, 1)
+
+		if err := repo.SetPendingEmail(ctx, 1, "late@example.com"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := repo.Create(ctx, "late@example.com", "hash", 1); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+
+		if err := repo.ConfirmPendingEmail(ctx, 1); err != domain.ErrUserAlreadyExists {
+			t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+		}
+		user, err := repo.FindByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.Email != "user1@example.com" || user.PendingEmail != "late@example.com" {
+			t.Errorf("Expected the account to keep its old email, got %+v", user)
+		}
+	})
+}
+
+func TestUserRepository_RejectsOverlongEmail(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		atLimit := strings.Repeat("a", domain.MaxEmailLength-len("@example.com")) + "@example.com"
+		user, err := repo.Create(context.Background(), atLimit, "hash", 1)
+		if err != nil {
+			t.Fatalf("Expected email at the limit to be stored, got %v", err)
+		}
+		found, err := repo.FindByID(context.Background(), user.ID)
+		if err != nil || found.Email != atLimit {
+			t.Fatalf("Expected email to round-trip untruncated, got %v", err)
+		}
+
+		// Overlong addresses must fail outright rather than be truncated into a
+		// collision with atLimit.
+		if _, err := repo.Create(context.Background(), "b"+atLimit, "hash", 1); err == nil {
+			t.Error("Expected overlong email to be rejected")
+		}
+		if count, _ := repo.Count(context.Background()); count != 1 {
+			t.Errorf("Expected only one user to be stored, got %d", count)
+		}
+	})
+}
+
+func TestUserRepository_CancelledContext(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := repo.FindByEmail(ctx, "user1@example.com"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled from FindByEmail, got %v", err)
+		}
+		if _, err := repo.Create(ctx, "new@example.com", "hash", 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled from Create, got %v", err)
+		}
+
+		count, err := repo.Count(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected the cancelled insert to be aborted, got %d users", count)
+		}
+	})
+}
+
+func TestUserRepository_TOTP(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+		ctx := context.Background()
+
+		if err := repo.EnableTOTP(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected enabling without a secret to fail, got %v", err)
+		}
+
+		if err := repo.SetTOTPSecret(ctx, 1, "encrypted"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		user, _ := repo.FindByID(ctx, 1)
+		if user.TOTPSecret != "encrypted" || user.TOTPEnabled {
+			t.Errorf("Expected stored secret with TOTP disabled, got %q enabled=%v", user.TOTPSecret, user.TOTPEnabled)
+		}
+
+		if err := repo.EnableTOTP(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		user, _ = repo.FindByEmail(ctx, "user1@example.com")
+		if !user.TOTPEnabled {
+			t.Error("Expected TOTP to be enabled")
+		}
+	})
+}
+
+func TestUserRepository_IncrementFailedAttempts_Concurrent(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+		ctx := context.Background()
+
+		const workers, perWorker = 10, 10
+		var wg sync.WaitGroup
+		errs := make(chan error, workers*perWorker)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < perWorker; j++ {
+					if _, err := repo.IncrementFailedAttempts(ctx, 1); err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		user, err := repo.FindByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if user.FailedAttempts != workers*perWorker {
+			t.Errorf("Expected %d failed attempts, got %d", workers*perWorker, user.FailedAttempts)
+		}
+
+		attempts, err := repo.IncrementFailedAttempts(ctx, 1)
+		if err != nil || attempts != workers*perWorker+1 {
+			t.Errorf("Expected increment to return %d, got %d (%v)", workers*perWorker+1, attempts, err)
+		}
+	})
+}
+
+func TestUserRepository_ResetFailedAttempts(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+		ctx := context.Background()
+
+		repo.IncrementFailedAttempts(ctx, 1)
+		repo.IncrementFailedAttempts(ctx, 1)
+		if err := repo.ResetFailedAttempts(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if attempts, _ := repo.IncrementFailedAttempts(ctx, 1); attempts != 1 {
+			t.Errorf("Expected counter to restart at 1, got %d", attempts)
+		}
+		if _, err := repo.IncrementFailedAttempts(ctx, 99); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+		if err := repo.ResetFailedAttempts(ctx, 99); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_CountByPasswordPolicyVersion(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+
+		repo.Create(ctx, "old@example.com", "hash", 0)
+		repo.Create(ctx, "a@example.com", "hash", 1)
+		user, _ := repo.Create(ctx, "b@example.com", "hash", 1)
+		if err := repo.UpdatePassword(ctx, user.ID, "newhash", 2); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		counts, err := repo.CountByPasswordPolicyVersion(ctx)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(counts) != 3 || counts[0] != 1 || counts[1] != 1 || counts[2] != 1 {
+			t.Errorf("Expected one user per version, got %v", counts)
+		}
+
+		updated, _ := repo.FindByID(ctx, user.ID)
+		if updated.PasswordPolicyVersion != 2 {
+			t.Errorf("Expected UpdatePassword to record version 2, got %d", updated.PasswordPolicyVersion)
+		}
+	})
+}
+
+func TestUserRepository_ChangePassword(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		ctx := context.Background()
+		user, _ := repo.Create(ctx, "test@example.com", "hash", 1)
+
+		for want := 1; want <= 2; want++ {
+			version, err := repo.ChangePassword(ctx, user.ID, "newhash", 2)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if version != want {
+				t.Errorf("Expected token_version %d, got %d", want, version)
+			}
+		}
+
+		updated, _ := repo.FindByID(ctx, user.ID)
+		if updated.PasswordHash != "newhash" || updated.PasswordPolicyVersion != 2 || updated.TokenVersion != 2 {
+			t.Errorf("Expected the new hash, policy version 2 and token_version 2, got %+v", updated)
+		}
+
+		// Rehashing keeps existing tokens valid.
+		if err := repo.UpdatePassword(ctx, user.ID, "rehashed", 2); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if updated, _ := repo.FindByID(ctx, user.ID); updated.TokenVersion != 2 {
+			t.Errorf("Expected UpdatePassword to keep token_version, got %d", updated.TokenVersion)
+		}
+
+		if _, err := repo.ChangePassword(ctx, 99, "hash", 1); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_UpdateLastLogin(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+		ctx := context.Background()
+
+		user, _ := repo.FindByID(ctx, 1)
+		if user.LastLoginAt != nil {
+			t.Fatalf("Expected no last login for a new user, got %v", user.LastLoginAt)
+		}
+
+		loginAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+		if err := repo.UpdateLastLogin(ctx, 1, loginAt); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		user, _ = repo.FindByID(ctx, 1)
+		if user.LastLoginAt == nil || !user.LastLoginAt.Equal(loginAt) {
+			t.Errorf("Expected last login %v, got %v", loginAt, user.LastLoginAt)
+		}
+		if err := repo.UpdateLastLogin(ctx, 99, loginAt); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserRepository_SoftDelete(t *testing.T) {
+	implementations := []struct {
+		name    string
+		newRepo func(t *testing.T) domain.UserRepository
+	}{
+		{"sqlite", func(t *testing.T) domain.UserRepository {
+			return NewSQLiteUserRepository(newTestRepository(t).db, WithSoftDelete())
+		}},
+		{"memory", func(t *testing.T) domain.UserRepository { return NewInMemoryUserRepository(WithInMemorySoftDelete()) }},
+	}
+
+	for _, impl := range implementations {
+		t.Run(impl.name, func(t *testing.T) {
+			repo := impl.newRepo(t)
+			ctx := context.Background()
+			seedUsers(t, repo, 2)
+
+			if err := repo.Delete(ctx, 1); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if _, err := repo.FindByID(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("Expected FindByID to skip the deleted user, got %v", err)
+			}
+			if _, err := repo.FindByEmail(ctx, "user1@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("Expected FindByEmail to skip the deleted user, got %v", err)
+			}
+			users, _ := repo.List(ctx, domain.UserListOptions{Limit: 10})
+			if count, _ := repo.Count(ctx); len(users) != 1 || count != 1 {
+				t.Errorf("Expected List and Count to skip the deleted user, got %d users and a count of %d", len(users), count)
+			}
+
+			deleted, err := repo.FindByIDIncludingDeleted(ctx, 1)
+			if err != nil || deleted.DeletedAt == nil {
+				t.Fatalf("Expected the deleted user with deleted_at set, got %+v (%v)", deleted, err)
+			}
+			if _, err := repo.Create(ctx, "user1@example.com", "hash", 1); !errors.Is(err, domain.ErrUserAlreadyExists) {
+				t.Errorf("Expected the email to stay taken, got %v", err)
+			}
+			if _, err := repo.CreateExternal(ctx, "user1@example.com", "google"); !errors.Is(err, domain.ErrUserAlreadyExists) {
+				t.Errorf("Expected the email to stay taken for a provider account, got %v", err)
+			}
+
+			// Writes must not reach the deleted row either.
+			updates := map[string]func() error{
+				"SetEmailVerified": func() error { return repo.SetEmailVerified(ctx, 1, true) },
+				"SetRole":          func() error { return repo.SetRole(ctx, 1, domain.RoleAdmin) },
+				"UpdateProfile":    func() error { return repo.UpdateProfile(ctx, 1, "Ghost") },
+				"UpdatePassword":   func() error { return repo.UpdatePassword(ctx, 1, "new-hash", 1) },
+				"ChangePassword": func() error {
+					_, err := repo.ChangePassword(ctx, 1, "new-hash", 1)
+					return err
+				},
+				"UpdateEmail":         func() error { return repo.UpdateEmail(ctx, 1, "ghost@example.com") },
+				"SetPendingEmail":     func() error { return repo.SetPendingEmail(ctx, 1, "ghost@example.com") },
+				"ConfirmPendingEmail": func() error { return repo.ConfirmPendingEmail(ctx, 1) },
+				"SetTOTPSecret":       func() error { return repo.SetTOTPSecret(ctx, 1, "secret") },
+				"EnableTOTP":          func() error { return repo.EnableTOTP(ctx, 1) },
+				"IncrementFailedAttempts": func() error {
+					_, err := repo.IncrementFailedAttempts(ctx, 1)
+					return err
+				},
+				"ResetFailedAttempts": func() error { return repo.ResetFailedAttempts(ctx, 1) },
+				"UpdateLastLogin":     func() error { return repo.UpdateLastLogin(ctx, 1, time.Now()) },
+			}
+			for name, update := range updates {
+				if err := update(); !errors.Is(err, domain.ErrUserNotFound) {
+					t.Errorf("%s: expected ErrUserNotFound for a deleted user, got %v", name, err)
+				}
+			}
+			if after, _ := repo.FindByIDIncludingDeleted(ctx, 1); after.PasswordHash != deleted.PasswordHash || after.Role != deleted.Role || !after.UpdatedAt.Equal(deleted.UpdatedAt) {
+				t.Errorf("Expected the deleted row to be untouched, got %+v", after)
+			}
+			if err := repo.Delete(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("Expected a second delete to fail, got %v", err)
+			}
+
+			if err := repo.Restore(ctx, 1); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			restored, err := repo.FindByEmail(ctx, "user1@example.com")
+			if err != nil || restored.DeletedAt != nil {
+				t.Errorf("Expected the user to be back, got %+v (%v)", restored, err)
+			}
+			if err := repo.Restore(ctx, 2); !errors.Is(err, domain.ErrUserNotFound) {
+				t.Errorf("Expected restoring a live user to fail, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUserRepository_RestoreAfterHardDelete(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 1)
+		ctx := context.Background()
+
+		if err := repo.Delete(ctx, 1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := repo.FindByIDIncludingDeleted(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected the row to be gone, got %v", err)
+		}
+		if err := repo.Restore(ctx, 1); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+// pageByCursor walks the whole list pageSize users at a time, resuming each
+// page after the last user of the previous one, and returns the IDs seen.
+func pageByCursor(t *testing.T, repo domain.UserRepository, pageSize int, descending bool) []int64 {
+	t.Helper()
+
+	var ids []int64
+	var after *domain.UserCursor
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("Expected paging to end")
+		}
+		users, err := repo.List(context.Background(), domain.UserListOptions{
+			Limit:      pageSize,
+			SortBy:     domain.UserSortCreatedAt,
+			Descending: descending,
+			After:      after,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		if len(users) < pageSize {
+			return ids
+		}
+		last := users[len(users)-1]
+		after = &domain.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func TestUserRepository_List_Cursor(t *testing.T) {
+	forEachUserRepository(t, func(t *testing.T, repo domain.UserRepository) {
+		seedUsers(t, repo, 7)
+
+		want := []int64{1, 2, 3, 4, 5, 6, 7}
+		if got := pageByCursor(t, repo, 3, false); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected every user once in order, got %v", got)
+		}
+		want = []int64{7, 6, 5, 4, 3, 2, 1}
+		if got := pageByCursor(t, repo, 3, true); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected every user once in reverse order, got %v", got)
+		}
+
+		// A cursor is keyed on created_at, so another sort is refused.
+		_, err := repo.List(context.Background(), domain.UserListOptions{Limit: 3, SortBy: domain.UserSortEmail, After: &domain.UserCursor{ID: 1}})
+		if err == nil {
+			t.Error("Expected an error for a cursor on another sort")
+		}
+	})
+}
+
+func TestSQLiteUserRepository_List_CursorTies(t *testing.T) {
+	repo := newTestRepository(t)
+	seedUsers(t, repo, 6)
+
+	// Users 2 to 5 share a created_at, so only the id orders them.
+	tie := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	if _, err := repo.db.Exec(`UPDATE users SET created_at = ? WHERE id BETWEEN 2 AND 5`, tie); err != nil {
+		t.Fatalf("Failed to update created_at: %v", err)
+	}
+
+	want := []int64{2, 3, 4, 5, 1, 6}
+	if got := pageByCursor(t, repo, 2, false); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,192 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms, as selected by PASSWORD_HASH_ALGORITHM.
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// HashAlgorithm tells which algorithm produced a stored hash from its
+// prefix. It returns "" for an unknown format.
+func HashAlgorithm(hashedPassword string) string {
+	switch {
+	case strings.HasPrefix(hashedPassword, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(hashedPassword, "$2a$"), strings.HasPrefix(hashedPassword, "$2b$"), strings.HasPrefix(hashedPassword, "$2y$"):
+		return AlgorithmBcrypt
+	default:
+		return ""
+	}
+}
+
+// HashInfo describes how a stored hash was made, without the hash itself:
+// the algorithm and its cost parameters. Only the fields of Algorithm are
+// set.
+type HashInfo struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// DescribeHash reads the algorithm and parameters from the prefix of a
+// stored hash, so accounts due for a rehash can be found. It returns an
+// error for a format it does not know.
+func DescribeHash(hashedPassword string) (HashInfo, error) {
+	info := HashInfo{Algorithm: HashAlgorithm(hashedPassword)}
+	var err error
+	switch info.Algorithm {
+	case AlgorithmBcrypt:
+		info.BcryptCost, err = bcrypt.Cost([]byte(hashedPassword))
+	case AlgorithmArgon2id:
+		info.Argon2, _, _, err = decodeArgon2Hash(hashedPassword)
+	default:
+		err = errors.New("unknown password hash format")
+	}
+	if err != nil {
+		return HashInfo{}, err
+	}
+	return info, nil
+}
+
+// Argon2Params are the Argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params follow the second recommended option of RFC 9106.
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errArgon2Mismatch = errors.New("argon2: hashedPassword is not the hash of the given password")
+
+// Argon2PasswordService hashes with Argon2id and still verifies bcrypt
+// hashes, which NeedsRehash always reports, so accounts move to Argon2id
+// as their users log in.
+type Argon2PasswordService struct {
+	params Argon2Params
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewArgon2PasswordService(params Argon2Params) (*Argon2PasswordService, error) {
+	if params.Iterations < 1 {
+		return nil, fmt.Errorf("argon2 iterations must be at least 1, got %d", params.Iterations)
+	}
+	if params.Parallelism < 1 {
+		return nil, fmt.Errorf("argon2 parallelism must be at least 1, got %d", params.Parallelism)
+	}
+	if params.Memory < 8*uint32(params.Parallelism) {
+		return nil, fmt.Errorf("argon2 memory must be at least 8 KiB per thread, got %d KiB for %d threads", params.Memory, params.Parallelism)
+	}
+	return &Argon2PasswordService{params: params}, nil
+}
+
+// Hash returns the PHC string format used by the reference implementation:
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func (s *Argon2PasswordService) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, s.params.Iterations, s.params.Memory, s.params.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, s.params.Memory, s.params.Iterations, s.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (s *Argon2PasswordService) Verify(hashedPassword, password string) error {
+	if HashAlgorithm(hashedPassword) == AlgorithmBcrypt {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	}
+	return verifyArgon2(hashedPassword, password)
+}
+
+// NeedsRehash reports bcrypt hashes and Argon2id hashes made with lower
+// parameters than the configured ones.
+func (s *Argon2PasswordService) NeedsRehash(hashedPassword string) bool {
+	if HashAlgorithm(hashedPassword) != AlgorithmArgon2id {
+		return true
+	}
+	params, _, _, err := decodeArgon2Hash(hashedPassword)
+	if err != nil {
+		return false
+	}
+	return params.Memory < s.params.Memory || params.Iterations < s.params.Iterations
+}
+
+// VerifyDummy spends the same work as Verify against an Argon2id hash with
+// the configured parameters. It always returns an error.
+func (s *Argon2PasswordService) VerifyDummy(password string) error {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.Hash("dummy-password")
+	})
+	if err := verifyArgon2(s.dummyHash, password); err != nil {
+		return err
+	}
+	return errArgon2Mismatch
+}
+
+func verifyArgon2(hashedPassword, password string) error {
+	params, salt, key, err := decodeArgon2Hash(hashedPassword)
+	if err != nil {
+		return err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return errArgon2Mismatch
+	}
+	return nil
+}
+
+func decodeArgon2Hash(hashedPassword string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	invalid := errors.New("argon2: malformed hash")
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, invalid
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, invalid
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("argon2: unsupported version %d", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, invalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, invalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, invalid
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,119 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keeps the tests fast; production uses DefaultArgon2Params.
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestArgon2PasswordService_HashAndVerify(t *testing.T) {
+	service, err := NewArgon2PasswordService(testArgon2Params)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	hash, err := service.Hash("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || HashAlgorithm(hash) != AlgorithmArgon2id {
+		t.Errorf("Expected a PHC-formatted argon2id hash, got %q", hash)
+	}
+
+	if err := service.Verify(hash, "password123"); err != nil {
+		t.Errorf("Expected the password to verify, got %v", err)
+	}
+	if err := service.Verify(hash, "wrongpassword"); err == nil {
+		t.Error("Expected a wrong password to fail")
+	}
+	if err := service.Verify("$argon2id$v=19$garbage", "password123"); err == nil {
+		t.Error("Expected a malformed hash to fail")
+	}
+	if err := service.VerifyDummy("password123"); err == nil {
+		t.Error("Expected dummy verification to fail")
+	}
+}
+
+func TestPasswordServices_VerifyAcrossAlgorithms(t *testing.T) {
+	bcryptService, _ := NewPasswordServiceWithCost(bcrypt.MinCost)
+	argon2Service, _ := NewArgon2PasswordService(testArgon2Params)
+
+	bcryptHash, _ := bcryptService.Hash("password123")
+	argon2Hash, _ := argon2Service.Hash("password123")
+
+	if err := argon2Service.Verify(bcryptHash, "password123"); err != nil {
+		t.Errorf("Expected argon2 service to verify a bcrypt hash, got %v", err)
+	}
+	if err := bcryptService.Verify(argon2Hash, "password123"); err != nil {
+		t.Errorf("Expected bcrypt service to verify an argon2 hash, got %v", err)
+	}
+	if err := argon2Service.Verify(bcryptHash, "wrongpassword"); err == nil {
+		t.Error("Expected a wrong password to fail against a bcrypt hash")
+	}
+	if err := bcryptService.Verify(argon2Hash, "wrongpassword"); err == nil {
+		t.Error("Expected a wrong password to fail against an argon2 hash")
+	}
+}
+
+func TestArgon2PasswordService_NeedsRehash(t *testing.T) {
+	weak, _ := NewArgon2PasswordService(testArgon2Params)
+	strong, _ := NewArgon2PasswordService(Argon2Params{Memory: 128, Iterations: 2, Parallelism: 1})
+	bcryptService, _ := NewPasswordServiceWithCost(bcrypt.MinCost)
+
+	hash, _ := weak.Hash("password123")
+	bcryptHash, _ := bcryptService.Hash("password123")
+
+	if weak.NeedsRehash(hash) {
+		t.Error("Expected a hash with the configured parameters not to need rehash")
+	}
+	if !strong.NeedsRehash(hash) {
+		t.Error("Expected a hash with lower parameters to need rehash")
+	}
+	if !weak.NeedsRehash(bcryptHash) {
+		t.Error("Expected a bcrypt hash to need rehash")
+	}
+	if bcryptService.NeedsRehash(hash) {
+		t.Error("Expected the bcrypt service not to downgrade argon2 hashes")
+	}
+}
+
+func TestNewArgon2PasswordService_Validation(t *testing.T) {
+	invalid := []Argon2Params{
+		{Memory: 64, Iterations: 0, Parallelism: 1},
+		{Memory: 64, Iterations: 1, Parallelism: 0},
+		{Memory: 8, Iterations: 1, Parallelism: 2},
+	}
+	for _, params := range invalid {
+		if _, err := NewArgon2PasswordService(params); err == nil {
+			t.Errorf("Expected %+v to be refused", params)
+		}
+	}
+}
+
+func TestDescribeHash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	info, err := DescribeHash(string(bcryptHash))
+	if err != nil || info != (HashInfo{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}) {
+		t.Errorf("Expected bcrypt with cost %d, got %+v (%v)", bcrypt.MinCost, info, err)
+	}
+
+	service, _ := NewArgon2PasswordService(testArgon2Params)
+	argon2Hash, _ := service.Hash("password123")
+	info, err = DescribeHash(argon2Hash)
+	if err != nil || info != (HashInfo{Algorithm: AlgorithmArgon2id, Argon2: testArgon2Params}) {
+		t.Errorf("Expected argon2id with %+v, got %+v (%v)", testArgon2Params, info, err)
+	}
+
+	for _, hash := range []string{"", "plaintext", "$argon2id$v=19$garbage"} {
+		if _, err := DescribeHash(hash); err == nil {
+			t.Errorf("Expected an error for %q", hash)
+		}
+	}
+}
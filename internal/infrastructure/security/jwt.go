@@ -1,63 +1,379 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
 )
 
 type JWTService struct {
-	secretKey []byte
+	// keys holds every key tokens may be verified with, by kid. New tokens
+	// are always signed with keys[activeKID].
+	keys      map[string][]byte
+	activeKID string
 	issuer    string
+	audience  string
 	duration  time.Duration
+	refresh   time.Duration
+	leeway    time.Duration
+	revoked   domain.RevocationStore
+	// allowMissingJTI accepts tokens minted before jti was added even though
+	// they cannot be checked against the revocation store.
+	allowMissingJTI bool
+	clock           clock.Clock
+}
+
+type JWTOption func(*JWTService)
+
+// WithLeeway tolerates clock drift between nodes when checking exp/iat.
+func WithLeeway(leeway time.Duration) JWTOption {
+	return func(s *JWTService) {
+		s.leeway = leeway
+	}
+}
+
+// WithClock sets the time tokens are issued at and checked against;
+// clock.Real is the default.
+func WithClock(c clock.Clock) JWTOption {
+	return func(s *JWTService) {
+		s.clock = clock.OrReal(c)
+	}
 }
 
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	TokenUse  string `json:"token_use,omitempty"`
+	SessionID int64  `json:"sid,omitempty"`
+	// AuthTime is when the user last proved their credentials. Refreshing
+	// keeps it, so sensitive actions can ask for a recent sign-in.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// TokenVersion is the user's token_version when the token was issued.
+	// Changing the password bumps it, so older tokens stop matching.
+	TokenVersion int `json:"token_version,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewJWTService(secretKey, issuer string, duration time.Duration) *JWTService {
-	return &JWTService{
-		secretKey: []byte(secretKey),
-		issuer:    issuer,
-		duration:  duration,
+// TokenUseTOTPPending marks a token that only proves the password step of a
+// two-factor login. Access checks reject it.
+const TokenUseTOTPPending = "totp_pending"
+
+// TokenUseRefresh marks a refresh token, which can only be exchanged for a
+// new access token.
+const TokenUseRefresh = "refresh"
+
+// PendingTokenDuration bounds how long a user has to enter their TOTP code.
+const PendingTokenDuration = 5 * time.Minute
+
+const DefaultRefreshTokenDuration = 30 * 24 * time.Hour
+
+// WithRefreshDuration sets the lifetime of refresh tokens. Zero keeps
+// DefaultRefreshTokenDuration.
+func WithRefreshDuration(duration time.Duration) JWTOption {
+	return func(s *JWTService) {
+		if duration > 0 {
+			s.refresh = duration
+		}
+	}
+}
+
+// WithAudience sets the aud claim on generated tokens and requires it on
+// validation.
+func WithAudience(audience string) JWTOption {
+	return func(s *JWTService) {
+		s.audience = audience
 	}
 }
 
-func (s *JWTService) GenerateToken(userID int64, email string) (string, error) {
-	now := time.Now()
+// WithRevocationStore rejects tokens whose jti has been revoked.
+func WithRevocationStore(store domain.RevocationStore) JWTOption {
+	return func(s *JWTService) {
+		s.revoked = store
+	}
+}
+
+// WithMissingJTIGrace lets tokens without a jti through when a revocation
+// store is set. By default they are rejected, since revoking them is
+// impossible; enable this only while such tokens are still in circulation.
+func WithMissingJTIGrace(allow bool) JWTOption {
+	return func(s *JWTService) {
+		s.allowMissingJTI = allow
+	}
+}
+
+// NewJWTService signs and verifies with a single key. Its tokens carry no
+// kid header.
+func NewJWTService(secretKey, issuer string, duration time.Duration, opts ...JWTOption) *JWTService {
+	s := &JWTService{
+		keys:     map[string][]byte{"": []byte(secretKey)},
+		issuer:   issuer,
+		duration: duration,
+		refresh:  DefaultRefreshTokenDuration,
+		clock:    clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewJWTServiceWithKeys supports key rotation: tokens are signed with
+// keys[activeKID] and tagged with its kid, and tokens signed by any key in
+// keys still validate. The empty kid matches tokens without a kid header,
+// such as those from NewJWTService. Drop a retired key once every token
+// signed with it has expired.
+func NewJWTServiceWithKeys(keys map[string][]byte, activeKID, issuer string, duration time.Duration, opts ...JWTOption) (*JWTService, error) {
+	if len(keys[activeKID]) == 0 {
+		return nil, fmt.Errorf("no signing key for active kid %q", activeKID)
+	}
+
+	copied := make(map[string][]byte, len(keys))
+	for kid, key := range keys {
+		copied[kid] = key
+	}
+
+	s := NewJWTService("", issuer, duration, opts...)
+	s.keys = copied
+	s.activeKID = activeKID
+	return s, nil
+}
+
+func (s *JWTService) Duration() time.Duration {
+	return s.duration
+}
+
+func (s *JWTService) RefreshDuration() time.Duration {
+	return s.refresh
+}
+
+// TokenOption adjusts a single token when it is generated.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	notBefore time.Time
+}
+
+// NotBefore sets the nbf claim, so the token is rejected with
+// ErrTokenNotYetValid until t. Its lifetime then counts from t rather than
+// from issuance. A t in the past is ignored.
+func NotBefore(t time.Time) TokenOption {
+	return func(o *tokenOptions) {
+		o.notBefore = t
+	}
+}
+
+// GenerateToken issues an access token for a user who has just
+// authenticated.
+func (s *JWTService) GenerateToken(userID int64, email, role string, opts ...TokenOption) (string, error) {
+	return s.GenerateSessionToken(&domain.User{ID: userID, Email: email, Role: role}, 0, s.clock.Now(), opts...)
+}
+
+// GenerateSessionToken issues an access token tied to a session, so the
+// session list can tell which one is making the request. authTime is when
+// the session was opened. A zero sessionID issues a session-less token.
+func (s *JWTService) GenerateSessionToken(user *domain.User, sessionID int64, authTime time.Time, opts ...TokenOption) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    s.issuer,
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.duration)),
-		},
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         user.Role,
+		SessionID:    sessionID,
+		AuthTime:     jwt.NewNumericDate(authTime),
+		TokenVersion: user.TokenVersion,
 	}
+	token, _, err := s.generate(claims, s.duration, opts...)
+	return token, err
+}
+
+// GeneratePendingToken issues the short-lived token returned by a password
+// login when the account still has to pass TOTP verification.
+func (s *JWTService) GeneratePendingToken(userID int64, email, role string) (string, error) {
+	token, _, err := s.generate(Claims{UserID: userID, Email: email, Role: role, TokenUse: TokenUseTOTPPending}, PendingTokenDuration)
+	return token, err
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+// GenerateRefreshToken also returns the claims so the caller can record the
+// jti and expiry on the session.
+func (s *JWTService) GenerateRefreshToken(user *domain.User, sessionID int64) (string, *Claims, error) {
+	claims := Claims{
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         user.Role,
+		TokenUse:     TokenUseRefresh,
+		SessionID:    sessionID,
+		TokenVersion: user.TokenVersion,
+	}
+	return s.generate(claims, s.refresh)
+}
+
+func (s *JWTService) generate(claims Claims, duration time.Duration, opts ...TokenOption) (string, *Claims, error) {
+	var options tokenOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := s.clock.Now()
+	start := now
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:       jti,
+		Issuer:   s.issuer,
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+	if options.notBefore.After(now) {
+		start = options.notBefore
+		claims.NotBefore = jwt.NewNumericDate(start)
+	}
+	claims.ExpiresAt = jwt.NewNumericDate(start.Add(duration))
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if s.activeKID != "" {
+		unsigned.Header["kid"] = s.activeKID
+	}
+	token, err := unsigned.SignedString(s.keys[s.activeKID])
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &claims, nil
 }
 
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != "" {
+		return nil, domain.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ValidatePendingToken accepts only tokens from GeneratePendingToken.
+func (s *JWTService) ValidatePendingToken(tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != TokenUseTOTPPending {
+		return nil, domain.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ValidateRefreshToken accepts only tokens from GenerateRefreshToken.
+func (s *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != TokenUseRefresh || claims.SessionID == 0 {
+		return nil, domain.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *JWTService) parse(tokenString string) (*Claims, error) {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithLeeway(s.leeway),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithTimeFunc(s.clock.Now),
+	}
+	if s.audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(s.audience))
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return s.secretKey, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, parserOptions...)
 
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, fmt.Errorf("%w: %v", domain.ErrTokenExpired, err)
+		case errors.Is(err, jwt.ErrTokenNotValidYet):
+			return nil, fmt.Errorf("%w: %v", domain.ErrTokenNotYetValid, err)
+		case errors.Is(err, jwt.ErrTokenMalformed):
+			return nil, fmt.Errorf("%w: %v", domain.ErrTokenMalformed, err)
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+			return nil, fmt.Errorf("%w: %v", domain.ErrTokenInvalidIssuer, err)
+		case errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, fmt.Errorf("%w: %v", domain.ErrTokenInvalidAudience, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidToken, err)
+		}
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, domain.ErrInvalidToken
+	}
+
+	if s.revoked != nil {
+		if claims.ID == "" {
+			if !s.allowMissingJTI {
+				return nil, domain.ErrTokenMissingID
+			}
+		} else if s.revoked.IsRevoked(claims.ID) {
+			return nil, domain.ErrTokenRevoked
+		}
 	}
 
-	return nil, errors.New("invalid token")
+	return claims, nil
+}
+
+// AuthTimeWithin reports whether claims carry a sign-in at most maxAge old
+// by the service's clock, with the same leeway as exp and iat. Tokens
+// without auth_time never do.
+func (s *JWTService) AuthTimeWithin(claims *Claims, maxAge time.Duration) bool {
+	if claims.AuthTime == nil {
+		return false
+	}
+	return s.clock.Now().Sub(claims.AuthTime.Time) <= maxAge+s.leeway
+}
+
+// Revoke invalidates claims' token until its natural expiry. It is a no-op
+// without a revocation store or for tokens issued before jti was added.
+func (s *JWTService) Revoke(claims *Claims) {
+	if claims.ExpiresAt == nil {
+		return
+	}
+	s.RevokeID(claims.ID, claims.ExpiresAt.Time)
+}
+
+// RevokeID invalidates the token with jti when only its stored ID and
+// expiry are at hand, as for a session's refresh token.
+func (s *JWTService) RevokeID(jti string, expiresAt time.Time) {
+	if s.revoked == nil || jti == "" {
+		return
+	}
+	s.revoked.Revoke(jti, expiresAt)
+}
+
+func newTokenID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
 }
@@ -0,0 +1,96 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func BenchmarkValidateToken(b *testing.B) {
+	store := NewMemoryRevocationStore()
+	service := NewJWTService("bench-secret", "bench-issuer", time.Hour, WithRevocationStore(store))
+
+	token, err := service.GenerateToken(42, "bench@example.com", domain.RoleUser)
+	if err != nil {
+		b.Fatalf("Failed to generate token: %v", err)
+	}
+	// Some revoked entries, so the revocation lookup is not trivially empty.
+	for i := 0; i < 100; i++ {
+		revoked, _ := newTokenID()
+		store.Revoke(revoked, time.Now().Add(time.Hour))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		claims, err := service.ValidateToken(token)
+		if err != nil {
+			b.Fatalf("ValidateToken failed: %v", err)
+		}
+		if claims.UserID != 42 {
+			b.Fatalf("Unexpected claims: %+v", claims)
+		}
+	}
+}
+
+// BenchmarkValidateSigningMethod compares HMAC and RSA verification of the
+// same claims, to weigh a move to asymmetric keys.
+func BenchmarkValidateSigningMethod(b *testing.B) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		b.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	hmacKey := []byte("bench-secret")
+
+	now := time.Now()
+	claims := Claims{
+		UserID: 42,
+		Email:  "bench@example.com",
+		Role:   domain.RoleUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "bench-jti",
+			Issuer:    "bench-issuer",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+
+	methods := []struct {
+		name      string
+		method    jwt.SigningMethod
+		signKey   interface{}
+		verifyKey interface{}
+	}{
+		{"HS256", jwt.SigningMethodHS256, hmacKey, hmacKey},
+		{"RS256", jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey},
+	}
+
+	for _, m := range methods {
+		b.Run(m.name, func(b *testing.B) {
+			token, err := jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+			if err != nil {
+				b.Fatalf("Failed to sign token: %v", err)
+			}
+			keyFunc := func(token *jwt.Token) (interface{}, error) {
+				return m.verifyKey, nil
+			}
+			parser := jwt.NewParser(jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithIssuer("bench-issuer"))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				parsed, err := parser.ParseWithClaims(token, &Claims{}, keyFunc)
+				if err != nil || !parsed.Valid {
+					b.Fatalf("Validation failed: %v", err)
+				}
+				if parsed.Claims.(*Claims).UserID != 42 {
+					b.Fatalf("Unexpected claims: %+v", parsed.Claims)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package security
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestJWTService_ValidateToken_Success(t *testing.T) {
+	service := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	token, err := service.GenerateToken(42, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if claims.UserID != 42 || claims.Email != "test@example.com" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestJWTService_ValidateToken_Expired(t *testing.T) {
+	service := NewJWTService("test-secret", "test-issuer", -time.Minute)
+
+	token, err := service.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	_, err = service.ValidateToken(token)
+	if !errors.Is(err, domain.ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestJWTService_ValidateToken_ExpiresWithClock(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	service := NewJWTService("test-secret", "test-issuer", time.Hour, WithClock(now), WithLeeway(30*time.Second))
+
+	token, err := service.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected a fresh token to be valid, got %v", err)
+	}
+	if !claims.IssuedAt.Time.Equal(now.Now()) || !claims.AuthTime.Time.Equal(now.Now()) {
+		t.Errorf("Expected iat and auth_time from the clock, got %v and %v", claims.IssuedAt, claims.AuthTime)
+	}
+
+	now.Advance(time.Hour + 29*time.Second)
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Errorf("Expected the token to be valid within the leeway, got %v", err)
+	}
+
+	now.Advance(time.Second)
+	if _, err := service.ValidateToken(token); !errors.Is(err, domain.ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+func TestJWTService_ValidateToken_NotBefore(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))
+	service := NewJWTService("test-secret", "test-issuer", time.Hour, WithClock(now))
+	notBefore := now.Now().Add(2 * time.Hour)
+
+	token, err := service.GenerateToken(1, "test@example.com", domain.RoleUser, NotBefore(notBefore))
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := service.ValidateToken(token); !errors.Is(err, domain.ErrTokenNotYetValid) {
+		t.Fatalf("Expected ErrTokenNotYetValid, got %v", err)
+	}
+
+	now.Advance(2 * time.Hour)
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected the token to be valid from its nbf, got %v", err)
+	}
+	if !claims.NotBefore.Time.Equal(notBefore) || !claims.ExpiresAt.Time.Equal(notBefore.Add(time.Hour)) {
+		t.Errorf("Expected nbf %v and the lifetime to count from it, got nbf %v and exp %v", notBefore, claims.NotBefore, claims.ExpiresAt)
+	}
+
+	// A past nbf adds nothing.
+	token, _ = service.GenerateToken(1, "test@example.com", domain.RoleUser, NotBefore(now.Now().Add(-time.Minute)))
+	if claims, err := service.ValidateToken(token); err != nil || claims.NotBefore != nil {
+		t.Errorf("Expected a plain token, got %+v (%v)", claims, err)
+	}
+}
+
+func TestJWTService_ValidateToken_Malformed(t *testing.T) {
+	service := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	_, err := service.ValidateToken("not-a-jwt")
+	if !errors.Is(err, domain.ErrTokenMalformed) {
+		t.Errorf("Expected ErrTokenMalformed, got %v", err)
+	}
+}
+
+func TestJWTService_ValidateToken_WithinLeeway(t *testing.T) {
+	service := NewJWTService("test-secret", "test-issuer", -10*time.Second, WithLeeway(30*time.Second))
+
+	token, err := service.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Errorf("Expected token within leeway to be accepted, got %v", err)
+	}
+}
+
+func TestJWTService_ValidateToken_WrongIssuer(t *testing.T) {
+	minter := NewJWTService("test-secret", "other-issuer", time.Hour)
+	validator := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	token, err := minter.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	_, err = validator.ValidateToken(token)
+	if !errors.Is(err, domain.ErrTokenInvalidIssuer) {
+		t.Errorf("Expected ErrTokenInvalidIssuer, got %v", err)
+	}
+}
+
+func TestJWTService_ValidateToken_Audience(t *testing.T) {
+	validator := NewJWTService("test-secret", "test-issuer", time.Hour, WithAudience("api"))
+
+	token, err := validator.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if _, err := validator.ValidateToken(token); err != nil {
+		t.Fatalf("Expected token for the configured audience to be accepted, got %v", err)
+	}
+
+	minter := NewJWTService("test-secret", "test-issuer", time.Hour, WithAudience("other-service"))
+	token, err = minter.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	_, err = validator.ValidateToken(token)
+	if !errors.Is(err, domain.ErrTokenInvalidAudience) {
+		t.Errorf("Expected ErrTokenInvalidAudience, got %v", err)
+	}
+}
+
+func TestJWTService_RevokedToken(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	jwtService := NewJWTService("test-secret", "test-issuer", time.Hour, WithRevocationStore(store))
+
+	token, err := jwtService.GenerateToken(1, "test@example.com", "user")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	other, _ := jwtService.GenerateToken(1, "test@example.com", "user")
+
+	claims, err := jwtService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if claims.ID == "" {
+		t.Fatal("Expected token to carry a jti")
+	}
+
+	jwtService.Revoke(claims)
+
+	if _, err := jwtService.ValidateToken(token); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := jwtService.ValidateToken(other); err != nil {
+		t.Errorf("Expected other tokens of the same user to stay valid, got %v", err)
+	}
+}
+
+// signLegacyToken mints a token the way the service did before jti existed.
+func signLegacyToken(t *testing.T) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := Claims{
+		UserID: 1,
+		Email:  "test@example.com",
+		Role:   domain.RoleUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "test-issuer",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTService_AuthTime(t *testing.T) {
+	jwtService := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	token, err := jwtService.GenerateToken(1, "test@example.com", "user")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := jwtService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Expected valid token, got %v", err)
+	}
+	if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) > time.Minute {
+		t.Errorf("Expected auth_time to be now, got %v", claims.AuthTime)
+	}
+
+	signedIn := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	token, err = jwtService.GenerateSessionToken(&domain.User{ID: 1, Email: "test@example.com", Role: "user"}, 7, signedIn)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	if claims, err = jwtService.ValidateToken(token); err != nil || !claims.AuthTime.Time.Equal(signedIn) {
+		t.Errorf("Expected auth_time %s, got %v (%v)", signedIn, claims, err)
+	}
+}
+
+func TestJWTService_MissingJTI_Strict(t *testing.T) {
+	jwtService := NewJWTService("test-secret", "test-issuer", time.Hour, WithRevocationStore(NewMemoryRevocationStore()))
+
+	if _, err := jwtService.ValidateToken(signLegacyToken(t)); !errors.Is(err, domain.ErrTokenMissingID) {
+		t.Errorf("Expected ErrTokenMissingID, got %v", err)
+	}
+}
+
+func TestJWTService_MissingJTI_Grace(t *testing.T) {
+	jwtService := NewJWTService("test-secret", "test-issuer", time.Hour,
+		WithRevocationStore(NewMemoryRevocationStore()),
+		WithMissingJTIGrace(true),
+	)
+
+	claims, err := jwtService.ValidateToken(signLegacyToken(t))
+	if err != nil {
+		t.Fatalf("Expected jti-less token to be accepted during grace, got %v", err)
+	}
+	if claims.UserID != 1 {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestJWTService_MissingJTI_WithoutRevocation(t *testing.T) {
+	jwtService := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	if _, err := jwtService.ValidateToken(signLegacyToken(t)); err != nil {
+		t.Errorf("Expected jti-less token to be accepted without a revocation store, got %v", err)
+	}
+}
+
+func TestJWTService_PendingTokenIsNotAnAccessToken(t *testing.T) {
+	service := NewJWTService("test-secret", "test-issuer", time.Hour)
+
+	pending, err := service.GeneratePendingToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	access, _ := service.GenerateToken(1, "test@example.com", domain.RoleUser)
+
+	if _, err := service.ValidateToken(pending); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected pending token to be rejected as access token, got %v", err)
+	}
+	if _, err := service.ValidatePendingToken(access); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected access token to be rejected as pending token, got %v", err)
+	}
+
+	claims, err := service.ValidatePendingToken(pending)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != PendingTokenDuration {
+		t.Errorf("Expected pending token to live %v, got %v", PendingTokenDuration, lifetime)
+	}
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	before, err := NewJWTServiceWithKeys(map[string][]byte{"k1": []byte("first-secret")}, "k1", "test-issuer", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	oldToken, err := before.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	after, err := NewJWTServiceWithKeys(map[string][]byte{
+		"k1": []byte("first-secret"),
+		"k2": []byte("second-secret"),
+	}, "k2", "test-issuer", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	newToken, err := after.GenerateToken(2, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil {
+		t.Fatalf("Failed to parse token: %v", err)
+	}
+	if parsed.Header["kid"] != "k2" {
+		t.Errorf("Expected new tokens to be signed with k2, got kid %v", parsed.Header["kid"])
+	}
+
+	if _, err := after.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected token signed with the previous key to validate, got %v", err)
+	}
+	if _, err := after.ValidateToken(newToken); err != nil {
+		t.Errorf("Expected token signed with the active key to validate, got %v", err)
+	}
+	if _, err := before.ValidateToken(newToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected unknown kid to be rejected, got %v", err)
+	}
+
+	retired, _ := NewJWTServiceWithKeys(map[string][]byte{"k2": []byte("second-secret")}, "k2", "test-issuer", time.Hour)
+	if _, err := retired.ValidateToken(oldToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected token signed with a dropped key to be rejected, got %v", err)
+	}
+}
+
+func TestJWTService_KeyRotationFromSingleKey(t *testing.T) {
+	legacy := NewJWTService("first-secret", "test-issuer", time.Hour)
+	oldToken, err := legacy.GenerateToken(1, "test@example.com", domain.RoleUser)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	rotated, err := NewJWTServiceWithKeys(map[string][]byte{
+		"":   []byte("first-secret"),
+		"k2": []byte("second-secret"),
+	}, "k2", "test-issuer", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if _, err := rotated.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected token without kid to validate with the empty kid key, got %v", err)
+	}
+}
+
+func TestNewJWTServiceWithKeys_MissingActiveKey(t *testing.T) {
+	if _, err := NewJWTServiceWithKeys(map[string][]byte{"k1": []byte("secret")}, "k2", "test-issuer", time.Hour); err == nil {
+		t.Error("Expected an error when the active kid has no key")
+	}
+}
@@ -1,11 +1,17 @@
 package security
 
 import (
+	"fmt"
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
 type PasswordService struct {
 	cost int
+
+	dummyOnce sync.Once
+	dummyHash []byte
 }
 
 func NewPasswordService() *PasswordService {
@@ -14,6 +20,15 @@ func NewPasswordService() *PasswordService {
 	}
 }
 
+func NewPasswordServiceWithCost(cost int) (*PasswordService, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+	}
+	return &PasswordService{
+		cost: cost,
+	}, nil
+}
+
 func (s *PasswordService) Hash(password string) (string, error) {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
 	if err != nil {
@@ -22,6 +37,34 @@ func (s *PasswordService) Hash(password string) (string, error) {
 	return string(hashedBytes), nil
 }
 
+// Verify also accepts Argon2id hashes, so switching back from
+// Argon2PasswordService does not lock out migrated accounts.
 func (s *PasswordService) Verify(hashedPassword, password string) error {
+	if HashAlgorithm(hashedPassword) == AlgorithmArgon2id {
+		return verifyArgon2(hashedPassword, password)
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+// NeedsRehash reports whether hashedPassword was produced with a lower cost
+// than the one currently configured.
+func (s *PasswordService) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	if err != nil {
+		return false
+	}
+	return cost < s.cost
+}
+
+// VerifyDummy spends the same work as Verify against a hash of the configured
+// cost, so a login for an unknown account takes as long as a wrong password.
+// It always returns an error.
+func (s *PasswordService) VerifyDummy(password string) error {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), s.cost)
+	})
+	if err := bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password)); err != nil {
+		return err
+	}
+	return bcrypt.ErrMismatchedHashAndPassword
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewPasswordServiceWithCost_Validation(t *testing.T) {
+	if _, err := NewPasswordServiceWithCost(bcrypt.MinCost - 1); err == nil {
+		t.Error("Expected error for cost below bcrypt.MinCost")
+	}
+
+	if _, err := NewPasswordServiceWithCost(bcrypt.MaxCost + 1); err == nil {
+		t.Error("Expected error for cost above bcrypt.MaxCost")
+	}
+
+	if _, err := NewPasswordServiceWithCost(bcrypt.MinCost); err != nil {
+		t.Errorf("Expected bcrypt.MinCost to be accepted, got %v", err)
+	}
+}
+
+func TestPasswordService_NeedsRehash(t *testing.T) {
+	weak, _ := NewPasswordServiceWithCost(bcrypt.MinCost)
+	strong, _ := NewPasswordServiceWithCost(bcrypt.MinCost + 1)
+
+	hash, err := weak.Hash("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	if weak.NeedsRehash(hash) {
+		t.Error("Expected hash at the configured cost not to need rehash")
+	}
+
+	if !strong.NeedsRehash(hash) {
+		t.Error("Expected hash below the configured cost to need rehash")
+	}
+}
+
+func TestPasswordService_VerifyDummyUsesConfiguredCost(t *testing.T) {
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 2} {
+		service, _ := NewPasswordServiceWithCost(cost)
+
+		if err := service.VerifyDummy("password123"); err == nil {
+			t.Errorf("Expected dummy verification to fail")
+		}
+
+		got, err := bcrypt.Cost(service.dummyHash)
+		if err != nil {
+			t.Fatalf("Expected a bcrypt dummy hash, got %v", err)
+		}
+		if got != cost {
+			t.Errorf("Expected dummy hash cost %d, got %d", cost, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package security
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRevocationStore keeps revoked jtis in memory. Entries are only needed
+// until the token expires, so Run periodically drops them to bound memory.
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, revoked := s.revoked[jti]
+	return revoked
+}
+
+// Cleanup drops entries whose token has expired and returns how many were
+// removed.
+func (s *MemoryRevocationStore) Cleanup() int {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for jti, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, jti)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *MemoryRevocationStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.revoked)
+}
+
+// Run calls Cleanup every interval (default one minute) until ctx is
+// cancelled.
+func (s *MemoryRevocationStore) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Cleanup()
+		}
+	}
+}
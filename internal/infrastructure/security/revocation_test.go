@@ -0,0 +1,86 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationStore_CleanupAfterExpiry(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRevocationStore()
+	store.now = func() time.Time { return now }
+
+	store.Revoke("short", now.Add(time.Minute))
+	store.Revoke("long", now.Add(time.Hour))
+
+	if removed := store.Cleanup(); removed != 0 {
+		t.Errorf("Expected nothing to be removed before expiry, got %d", removed)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if removed := store.Cleanup(); removed != 1 {
+		t.Errorf("Expected one expired entry to be removed, got %d", removed)
+	}
+	if store.IsRevoked("short") {
+		t.Error("Expected expired entry to be dropped")
+	}
+	if !store.IsRevoked("long") {
+		t.Error("Expected unexpired entry to remain revoked")
+	}
+}
+
+func TestMemoryRevocationStore_RunStopsWithContext(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	store.Revoke("expired", time.Now().Add(-time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		store.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for store.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if store.Len() != 0 {
+		t.Error("Expected janitor to remove the expired entry")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected janitor to stop after cancellation")
+	}
+}
+
+func TestMemoryRevocationStore_ConcurrentAccess(t *testing.T) {
+	store := NewMemoryRevocationStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				jti := fmt.Sprintf("%d-%d", worker, j)
+				store.Revoke(jti, time.Now().Add(time.Duration(j%2)*time.Hour))
+				store.IsRevoked(jti)
+				if j%50 == 0 {
+					store.Cleanup()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	store.Cleanup()
+	if got := store.Len(); got != 8*100 {
+		t.Errorf("Expected only unexpired entries to remain, got %d", got)
+	}
+}
@@ -0,0 +1,178 @@
+package security
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestTOTPService(now *time.Time) *TOTPService {
+	s := NewTOTPService("SecureRestApi", "test-encryption-key")
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestTOTPService_GenerateCode_RFC6238Vectors(t *testing.T) {
+	s := NewTOTPService("SecureRestApi", "test-encryption-key")
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := s.GenerateCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("At %d: expected %s, got %s", tt.unix, tt.code, code)
+		}
+	}
+}
+
+func TestTOTPService_Verify(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	s := newTestTOTPService(&now)
+
+	if s.Verify(rfc6238Secret, "000000") {
+		t.Error("Expected wrong code to be rejected")
+	}
+	if s.Verify(rfc6238Secret, "81804") {
+		t.Error("Expected short code to be rejected")
+	}
+	if s.Verify("not base32!", "081804") {
+		t.Error("Expected malformed secret to be rejected")
+	}
+	if !s.Verify(rfc6238Secret, "081804") {
+		t.Error("Expected current code to be accepted")
+	}
+}
+
+func TestTOTPService_Verify_SkewWindow(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	s := newTestTOTPService(&now)
+
+	previous, _ := s.GenerateCode(rfc6238Secret, now.Add(-totpPeriod))
+	next, _ := s.GenerateCode(rfc6238Secret, now.Add(totpPeriod))
+	stale, _ := s.GenerateCode(rfc6238Secret, now.Add(-2*totpPeriod))
+
+	if !s.Verify(rfc6238Secret, previous) {
+		t.Error("Expected code from the previous period to be accepted")
+	}
+	if !s.Verify(rfc6238Secret, next) {
+		t.Error("Expected code from the next period to be accepted")
+	}
+	if s.Verify(rfc6238Secret, stale) {
+		t.Error("Expected code from two periods ago to be rejected")
+	}
+}
+
+func TestTOTPService_Verify_RejectsReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	s := newTestTOTPService(&now)
+
+	code, _ := s.GenerateCode(rfc6238Secret, now)
+	if !s.Verify(rfc6238Secret, code) {
+		t.Fatal("Expected first use to be accepted")
+	}
+	if s.Verify(rfc6238Secret, code) {
+		t.Error("Expected replayed code to be rejected")
+	}
+
+	// Still inside the skew window, so the code remains valid but used.
+	now = now.Add(totpPeriod)
+	if s.Verify(rfc6238Secret, code) {
+		t.Error("Expected replayed code to be rejected in the next period")
+	}
+
+	// Another user's secret is tracked separately.
+	other, _, err := s.GenerateSecret("other@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	otherCode, _ := s.GenerateCode(other, now)
+	if !s.Verify(other, otherCode) {
+		t.Error("Expected another secret's code to be accepted")
+	}
+}
+
+func TestTOTPService_Verify_ForgetsExpiredCodes(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	s := newTestTOTPService(&now)
+
+	code, _ := s.GenerateCode(rfc6238Secret, now)
+	s.Verify(rfc6238Secret, code)
+
+	now = now.Add(5 * totpPeriod)
+	fresh, _ := s.GenerateCode(rfc6238Secret, now)
+	if !s.Verify(rfc6238Secret, fresh) {
+		t.Fatal("Expected fresh code to be accepted")
+	}
+	if len(s.used) != 1 {
+		t.Errorf("Expected expired entries to be dropped, got %d entries", len(s.used))
+	}
+}
+
+func TestTOTPService_GenerateSecret(t *testing.T) {
+	s := NewTOTPService("SecureRestApi", "test-encryption-key")
+
+	secret, rawURL, err := s.GenerateSecret("user@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := decodeTOTPSecret(secret); err != nil {
+		t.Errorf("Expected a base32 secret, got %q", secret)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Expected a valid URL, got %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("Expected otpauth://totp URL, got %s", rawURL)
+	}
+	if !strings.HasSuffix(u.Path, "SecureRestApi:user@example.com") {
+		t.Errorf("Expected issuer and email in label, got %s", u.Path)
+	}
+	if u.Query().Get("secret") != secret || u.Query().Get("issuer") != "SecureRestApi" {
+		t.Errorf("Expected secret and issuer parameters, got %s", u.RawQuery)
+	}
+}
+
+func TestTOTPService_EncryptSecret(t *testing.T) {
+	s := NewTOTPService("SecureRestApi", "test-encryption-key")
+
+	encrypted, err := s.EncryptSecret(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(encrypted, rfc6238Secret) {
+		t.Error("Expected secret not to appear in ciphertext")
+	}
+
+	decrypted, err := s.DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if decrypted != rfc6238Secret {
+		t.Errorf("Expected %s, got %s", rfc6238Secret, decrypted)
+	}
+
+	other := NewTOTPService("SecureRestApi", "another-key")
+	if _, err := other.DecryptSecret(encrypted); err == nil {
+		t.Error("Expected decryption with a different key to fail")
+	}
+	if _, err := s.DecryptSecret("short"); err == nil {
+		t.Error("Expected malformed ciphertext to fail")
+	}
+}
@@ -0,0 +1,33 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const verificationTokenBytes = 32
+
+type VerificationService struct{}
+
+func NewVerificationService() *VerificationService {
+	return &VerificationService{}
+}
+
+// GenerateToken returns a random URL-safe token and the hash to persist.
+// Only the hash is stored so a database leak doesn't expose usable tokens.
+func (s *VerificationService) GenerateToken() (string, string, error) {
+	raw := make([]byte, verificationTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, s.HashToken(token), nil
+}
+
+func (s *VerificationService) HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type Config struct {
+	URL          string
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration
+}
+
+// Dispatcher polls the outbox and POSTs due events to the configured URL.
+// Delivery is at-least-once: subscribers should dedup on Idempotency-Key.
+type Dispatcher struct {
+	repo   domain.OutboxRepository
+	config Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewDispatcher(repo domain.OutboxRepository, config Config) *Dispatcher {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 30 * time.Second
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 5 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 50
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &Dispatcher{
+		repo:   repo,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		now:    time.Now,
+	}
+}
+
+// Run dispatches pending events every PollInterval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DispatchPending(ctx); err != nil {
+			log.Printf("Webhook dispatch failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchPending delivers one batch of due events. A failed delivery is
+// rescheduled with exponential backoff; only repository errors are returned.
+func (d *Dispatcher) DispatchPending(ctx context.Context) error {
+	events, err := d.repo.FetchPending(d.now(), d.config.MaxAttempts, d.config.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err := d.deliver(ctx, event); err != nil {
+			attempt := event.Attempts + 1
+			log.Printf("Webhook delivery of event %d failed (attempt %d/%d): %v", event.ID, attempt, d.config.MaxAttempts, err)
+			if err := d.repo.MarkFailed(event.ID, err.Error(), d.now().Add(d.backoff(attempt))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.repo.MarkDelivered(event.ID, d.now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, event *domain.OutboxEvent) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.config.URL, bytes.NewReader(event.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.IdempotencyKey)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	shift := attempt - 1
+	if shift > 10 {
+		shift = 10
+	}
+	return d.config.RetryBackoff << shift
+}
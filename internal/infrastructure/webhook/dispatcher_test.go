@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+)
+
+type subscriber struct {
+	mu       sync.Mutex
+	keys     []string
+	failures int
+}
+
+func (s *subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestDispatcher(t *testing.T, sub *subscriber, now *time.Time) *Dispatcher {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	server := httptest.NewServer(sub)
+	t.Cleanup(server.Close)
+
+	userRepo := repository.NewSQLiteUserRepository(db, repository.WithOutboxEvents())
+	if _, err := userRepo.Create(context.Background(), "test@example.com", "hash", 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	dispatcher := NewDispatcher(repository.NewSQLiteOutboxRepository(db), Config{
+		URL:          server.URL,
+		MaxAttempts:  3,
+		RetryBackoff: time.Minute,
+	})
+	dispatcher.now = func() time.Time { return *now }
+	return dispatcher
+}
+
+func TestDispatcher_DeliversOnce(t *testing.T) {
+	now := time.Now().Add(time.Second)
+	sub := &subscriber{}
+	dispatcher := newTestDispatcher(t, sub, &now)
+
+	if err := dispatcher.DispatchPending(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := dispatcher.DispatchPending(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(sub.keys) != 1 {
+		t.Fatalf("Expected exactly one delivery, got %d", len(sub.keys))
+	}
+	if sub.keys[0] == "" {
+		t.Error("Expected Idempotency-Key header to be set")
+	}
+}
+
+func TestDispatcher_RetriesWithSameIdempotencyKey(t *testing.T) {
+	now := time.Now().Add(time.Second)
+	sub := &subscriber{failures: 1}
+	dispatcher := newTestDispatcher(t, sub, &now)
+
+	dispatcher.DispatchPending(context.Background())
+	dispatcher.DispatchPending(context.Background())
+	if len(sub.keys) != 1 {
+		t.Fatalf("Expected retry to wait for backoff, got %d deliveries", len(sub.keys))
+	}
+
+	now = now.Add(2 * time.Minute)
+	dispatcher.DispatchPending(context.Background())
+	now = now.Add(time.Hour)
+	dispatcher.DispatchPending(context.Background())
+
+	if len(sub.keys) != 2 {
+		t.Fatalf("Expected one failed and one successful delivery, got %d", len(sub.keys))
+	}
+	if sub.keys[0] != sub.keys[1] {
+		t.Errorf("Expected retries to reuse the idempotency key, got %q and %q", sub.keys[0], sub.keys[1])
+	}
+}
@@ -0,0 +1,52 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("cursor is malformed")
+
+// Cursor is the position of an item in a list kept in (CreatedAt, ID)
+// order. Keyset pagination resumes after it, so rows inserted meanwhile
+// neither shift nor repeat the following pages the way offsets do.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+type cursorPayload struct {
+	CreatedAt string `json:"t"`
+	ID        int64  `json:"id"`
+}
+
+// Encode returns the opaque form sent to clients as next_cursor. The time
+// keeps its offset, so the database compares it with the stored value as
+// written.
+func (c Cursor) Encode() string {
+	payload, _ := json.Marshal(cursorPayload{CreatedAt: c.CreatedAt.Format(time.RFC3339Nano), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor parses a cursor from Encode. Anything else, including a
+// cursor that decodes but lacks a field, is ErrInvalidCursor. The cursor is
+// not signed: a forged one only moves the page start.
+func DecodeCursor(value string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.ID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, payload.CreatedAt)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{CreatedAt: createdAt, ID: payload.ID}, nil
+}
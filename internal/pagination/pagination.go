@@ -0,0 +1,236 @@
+// Package pagination parses the page, page_size, sort, order and cursor
+// query parameters shared by list endpoints and shapes their responses.
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+var (
+	ErrInvalidPage     = errors.New("page must be a positive integer")
+	ErrInvalidPageSize = errors.New("page_size must be an integer")
+	ErrInvalidSort     = errors.New("unsupported sort field")
+	ErrInvalidOrder    = errors.New("order must be asc or desc")
+)
+
+// Params is a validated page request. Sort is empty when the endpoint
+// declares no sort fields. After is set when the client resumes from a
+// cursor; Page is then zero.
+type Params struct {
+	Page     int
+	PageSize int
+	Sort     string
+	Order    string
+	After    *Cursor
+}
+
+// Offset is the number of items before the first one on the page.
+func (p Params) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Descending reports whether items should be sorted in descending order.
+func (p Params) Descending() bool {
+	return p.Order == OrderDesc
+}
+
+type config struct {
+	defaultPageSize int
+	maxPageSize     int
+	sortFields      []string
+	defaultOrder    string
+	cursorField     string
+}
+
+type Option func(*config)
+
+// WithPageSize overrides the page size used when none is requested and the
+// cap applied to larger requests.
+func WithPageSize(defaultSize, maxSize int) Option {
+	return func(c *config) {
+		if defaultSize > 0 {
+			c.defaultPageSize = defaultSize
+		}
+		if maxSize > 0 {
+			c.maxPageSize = maxSize
+		}
+	}
+}
+
+// WithSortFields lists the fields the endpoint can sort by. The first one is
+// the default; any other value of ?sort= is rejected.
+func WithSortFields(fields ...string) Option {
+	return func(c *config) {
+		c.sortFields = fields
+	}
+}
+
+// WithDefaultOrder sets the order used when ?order= is absent.
+func WithDefaultOrder(order string) Option {
+	return func(c *config) {
+		if order == OrderAsc || order == OrderDesc {
+			c.defaultOrder = order
+		}
+	}
+}
+
+// WithCursor accepts ?cursor= on lists sorted by field, which must also be
+// one of the sort fields. A cursor implies that sort and excludes ?page=.
+func WithCursor(field string) Option {
+	return func(c *config) {
+		c.cursorField = field
+	}
+}
+
+// Parse reads the pagination query parameters of r. Malformed values are
+// errors; out-of-range ones are clamped: page to at least 1 and page_size to
+// the default below 1 and to the maximum above it.
+func Parse(r *http.Request, opts ...Option) (Params, error) {
+	cfg := config{
+		defaultPageSize: DefaultPageSize,
+		maxPageSize:     MaxPageSize,
+		defaultOrder:    OrderAsc,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.defaultPageSize > cfg.maxPageSize {
+		cfg.defaultPageSize = cfg.maxPageSize
+	}
+
+	query := r.URL.Query()
+	params := Params{Page: 1, PageSize: cfg.defaultPageSize, Order: cfg.defaultOrder}
+
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		// Past this the offset would overflow.
+		if err != nil || page > math.MaxInt32 {
+			return Params{}, ErrInvalidPage
+		}
+		if page > 1 {
+			params.Page = page
+		}
+	}
+
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.Atoi(value)
+		if err != nil {
+			return Params{}, ErrInvalidPageSize
+		}
+		switch {
+		case pageSize > cfg.maxPageSize:
+			params.PageSize = cfg.maxPageSize
+		case pageSize > 0:
+			params.PageSize = pageSize
+		}
+	}
+
+	if len(cfg.sortFields) > 0 {
+		params.Sort = cfg.sortFields[0]
+	}
+	if value := query.Get("sort"); value != "" {
+		if !contains(cfg.sortFields, value) {
+			if len(cfg.sortFields) == 0 {
+				return Params{}, fmt.Errorf("%w: this list cannot be sorted", ErrInvalidSort)
+			}
+			return Params{}, fmt.Errorf("%w: use one of %s", ErrInvalidSort, strings.Join(cfg.sortFields, ", "))
+		}
+		params.Sort = value
+	}
+
+	if value := query.Get("order"); value != "" {
+		order := strings.ToLower(value)
+		if order != OrderAsc && order != OrderDesc {
+			return Params{}, ErrInvalidOrder
+		}
+		params.Order = order
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		switch {
+		case cfg.cursorField == "":
+			return Params{}, fmt.Errorf("%w: this list does not support cursors", ErrInvalidCursor)
+		case query.Get("page") != "":
+			return Params{}, fmt.Errorf("%w: cursor and page cannot be combined", ErrInvalidCursor)
+		case query.Get("sort") != "" && query.Get("sort") != cfg.cursorField:
+			return Params{}, fmt.Errorf("%w: a cursor requires sort=%s", ErrInvalidCursor, cfg.cursorField)
+		}
+		cursor, err := DecodeCursor(value)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = &cursor
+		params.Page = 0
+		params.Sort = cfg.cursorField
+	}
+
+	return params, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Meta describes the page returned alongside the items. Page is omitted
+// for a page fetched by cursor. NextCursor, when set, fetches the page
+// after this one.
+type Meta struct {
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	Total      int64  `json:"total"`
+	TotalPages int64  `json:"total_pages"`
+	Sort       string `json:"sort,omitempty"`
+	Order      string `json:"order,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PageResponse is the body of a list endpoint:
+// {"data":[...],"pagination":{...}}.
+type PageResponse[T any] struct {
+	Data       []T  `json:"data"`
+	Pagination Meta `json:"pagination"`
+}
+
+// NewPageResponse wraps one page of items out of total. A nil data slice is
+// encoded as an empty array.
+func NewPageResponse[T any](data []T, params Params, total int64) PageResponse[T] {
+	if data == nil {
+		data = []T{}
+	}
+
+	var totalPages int64
+	if params.PageSize > 0 {
+		totalPages = (total + int64(params.PageSize) - 1) / int64(params.PageSize)
+	}
+
+	meta := Meta{
+		Page:       params.Page,
+		PageSize:   params.PageSize,
+		Total:      total,
+		TotalPages: totalPages,
+		Sort:       params.Sort,
+	}
+	if params.Sort != "" {
+		meta.Order = params.Order
+	}
+
+	return PageResponse[T]{Data: data, Pagination: meta}
+}
@@ -0,0 +1,156 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func parseQuery(t *testing.T, query string, opts ...Option) (Params, error) {
+	t.Helper()
+	return Parse(httptest.NewRequest("GET", "/items?"+query, nil), opts...)
+}
+
+func TestParse_Defaults(t *testing.T) {
+	params, err := parseQuery(t, "", WithSortFields("id", "email"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := Params{Page: 1, PageSize: DefaultPageSize, Sort: "id", Order: OrderAsc}
+	if params != want {
+		t.Errorf("Expected %+v, got %+v", want, params)
+	}
+	if params.Offset() != 0 {
+		t.Errorf("Expected offset 0, got %d", params.Offset())
+	}
+}
+
+func TestParse_ReadsParams(t *testing.T) {
+	params, err := parseQuery(t, "page=3&page_size=10&sort=email&order=DESC", WithSortFields("id", "email"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := Params{Page: 3, PageSize: 10, Sort: "email", Order: OrderDesc}
+	if params != want {
+		t.Errorf("Expected %+v, got %+v", want, params)
+	}
+	if params.Offset() != 20 || !params.Descending() {
+		t.Errorf("Expected offset 20 descending, got %d and %v", params.Offset(), params.Descending())
+	}
+}
+
+func TestParse_Clamps(t *testing.T) {
+	tests := []struct {
+		query    string
+		page     int
+		pageSize int
+	}{
+		{"page=0", 1, DefaultPageSize},
+		{"page=-4", 1, DefaultPageSize},
+		{"page_size=0", 1, DefaultPageSize},
+		{"page_size=-1", 1, DefaultPageSize},
+		{"page_size=1000", 1, MaxPageSize},
+	}
+
+	for _, tt := range tests {
+		params, err := parseQuery(t, tt.query)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.query, err)
+			continue
+		}
+		if params.Page != tt.page || params.PageSize != tt.pageSize {
+			t.Errorf("%s: expected page %d size %d, got %d and %d", tt.query, tt.page, tt.pageSize, params.Page, params.PageSize)
+		}
+	}
+}
+
+func TestParse_WithPageSize(t *testing.T) {
+	params, err := parseQuery(t, "page_size=80", WithPageSize(10, 50))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if params.PageSize != 50 {
+		t.Errorf("Expected page size capped at 50, got %d", params.PageSize)
+	}
+
+	params, _ = parseQuery(t, "", WithPageSize(10, 50))
+	if params.PageSize != 10 {
+		t.Errorf("Expected default page size 10, got %d", params.PageSize)
+	}
+}
+
+var (
+	cursorOpts  = []Option{WithSortFields("id", "created_at"), WithCursor("created_at")}
+	validCursor = Cursor{CreatedAt: time.Date(2024, 1, 15, 10, 30, 0, 5, time.FixedZone("", 2*3600)), ID: 3}.Encode()
+)
+
+func TestParse_Cursor(t *testing.T) {
+	params, err := parseQuery(t, "cursor="+validCursor+"&page_size=5&order=desc", cursorOpts...)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if params.After == nil || params.After.ID != 3 || params.After.CreatedAt.Format(time.RFC3339Nano) != "2024-01-15T10:30:00.000000005+02:00" {
+		t.Fatalf("Expected the cursor to round-trip with its offset, got %+v", params.After)
+	}
+	if params.Page != 0 || params.PageSize != 5 || params.Sort != "created_at" || !params.Descending() {
+		t.Errorf("Expected a cursor page sorted by created_at desc, got %+v", params)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		query string
+		opts  []Option
+		want  error
+	}{
+		{"page=abc", nil, ErrInvalidPage},
+		{"page=99999999999", nil, ErrInvalidPage},
+		{"page_size=ten", nil, ErrInvalidPageSize},
+		{"sort=password_hash", []Option{WithSortFields("id", "email")}, ErrInvalidSort},
+		{"sort=id", nil, ErrInvalidSort},
+		{"order=sideways", nil, ErrInvalidOrder},
+		{"cursor=" + validCursor, nil, ErrInvalidCursor},
+		{"cursor=not-base64!", cursorOpts, ErrInvalidCursor},
+		{"cursor=" + base64.RawURLEncoding.EncodeToString([]byte(`{"t":"yesterday","id":3}`)), cursorOpts, ErrInvalidCursor},
+		{"cursor=" + base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-01-15T10:30:00Z"}`)), cursorOpts, ErrInvalidCursor},
+		{"cursor=" + validCursor + "&page=2", cursorOpts, ErrInvalidCursor},
+		{"cursor=" + validCursor + "&sort=id", cursorOpts, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		if _, err := parseQuery(t, tt.query, tt.opts...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, err)
+		}
+	}
+}
+
+func TestNewPageResponse(t *testing.T) {
+	params := Params{Page: 2, PageSize: 2, Sort: "email", Order: OrderDesc}
+	body, err := json.Marshal(NewPageResponse([]string{"c", "d"}, params, 5))
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+
+	want := `{"data":["c","d"],"pagination":{"page":2,"page_size":2,"total":5,"total_pages":3,"sort":"email","order":"desc"}}`
+	if string(body) != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+}
+
+func TestNewPageResponse_EmptyPage(t *testing.T) {
+	body, err := json.Marshal(NewPageResponse[int](nil, Params{Page: 1, PageSize: 20, Order: OrderAsc}, 0))
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+
+	want := `{"data":[],"pagination":{"page":1,"page_size":20,"total":0,"total_pages":0}}`
+	if string(body) != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+}
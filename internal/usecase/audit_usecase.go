@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type AuditUseCase struct {
+	auditRepo domain.AuditRepository
+}
+
+type AuditEventList struct {
+	Events   []*domain.AuditEvent
+	Total    int64
+	Page     int
+	PageSize int
+}
+
+func NewAuditUseCase(auditRepo domain.AuditRepository) *AuditUseCase {
+	return &AuditUseCase{
+		auditRepo: auditRepo,
+	}
+}
+
+// ListEvents pages through the audit log, most recent first.
+func (uc *AuditUseCase) ListEvents(ctx context.Context, page, pageSize int) (*AuditEventList, error) {
+	page, pageSize = normalizePage(page, pageSize)
+
+	events, err := uc.auditRepo.List(ctx, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	total, err := uc.auditRepo.Count(ctx)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	return &AuditEventList{
+		Events:   events,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
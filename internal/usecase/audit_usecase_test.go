@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// MockAuditRepository keeps recorded events in memory, oldest first.
+type MockAuditRepository struct {
+	events []domain.AuditEvent
+}
+
+func (m *MockAuditRepository) Record(event domain.AuditEvent) {
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, event)
+}
+
+func (m *MockAuditRepository) List(ctx context.Context, limit, offset int) ([]*domain.AuditEvent, error) {
+	events := []*domain.AuditEvent{}
+	for i := len(m.events) - 1 - offset; i >= 0 && len(events) < limit; i-- {
+		event := m.events[i]
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func (m *MockAuditRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.events)), nil
+}
+
+func (m *MockAuditRepository) actions() []string {
+	actions := make([]string, 0, len(m.events))
+	for _, event := range m.events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+func newAuditedAuthUseCase(audit *MockAuditRepository) *AuthUseCase {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	return NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithAuditLogger(audit))
+}
+
+func TestAuthUseCase_Login_RecordsFailedAttempts(t *testing.T) {
+	audit := &MockAuditRepository{}
+	useCase := newAuditedAuthUseCase(audit)
+	ctx := domain.ContextWithClientIP(context.Background(), "203.0.113.7")
+
+	resp, err := useCase.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+	useCase.Login(ctx, LoginRequest{Email: "unknown@example.com", Password: "password123"})
+
+	expected := []string{domain.AuditActionRegister, domain.AuditActionLoginFailed, domain.AuditActionLoginFailed}
+	if got := audit.actions(); len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] {
+		t.Fatalf("Expected actions %v, got %v", expected, got)
+	}
+
+	wrongPassword := audit.events[1]
+	if wrongPassword.UserID != resp.User.ID || wrongPassword.Email != "test@example.com" || wrongPassword.IP != "203.0.113.7" {
+		t.Errorf("Unexpected failed login event: %+v", wrongPassword)
+	}
+
+	unknown := audit.events[2]
+	if unknown.UserID != 0 || unknown.Email != "unknown@example.com" {
+		t.Errorf("Expected unknown account to be recorded by email only, got %+v", unknown)
+	}
+}
+
+func TestAuthUseCase_Login_RecordsSuccess(t *testing.T) {
+	audit := &MockAuditRepository{}
+	useCase := newAuditedAuthUseCase(audit)
+
+	useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := audit.actions(); len(got) != 2 || got[1] != domain.AuditActionLogin {
+		t.Errorf("Expected a login event after registration, got %v", got)
+	}
+}
+
+func TestAuditUseCase_ListEvents_Paginates(t *testing.T) {
+	audit := &MockAuditRepository{}
+	for i := 0; i < 5; i++ {
+		audit.Record(domain.AuditEvent{Action: domain.AuditActionLogin})
+	}
+
+	list, err := NewAuditUseCase(audit).ListEvents(context.Background(), 2, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if list.Total != 5 || list.Page != 2 || list.PageSize != 2 {
+		t.Errorf("Unexpected pagination: %+v", list)
+	}
+	if len(list.Events) != 2 || list.Events[0].ID != 3 || list.Events[1].ID != 2 {
+		t.Errorf("Expected events 3 and 2, got %+v", list.Events)
+	}
+}
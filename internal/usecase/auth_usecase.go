@@ -1,46 +1,247 @@
 package usecase
 
 import (
+	"context"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
 	"github.com/valentinfrappart/securerestapi/internal/domain"
 	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
+	"golang.org/x/sync/singleflight"
 )
 
 type AuthUseCase struct {
-	userRepo        domain.UserRepository
-	passwordService *security.PasswordService
-	jwtService      *security.JWTService
+	userRepo              domain.UserRepository
+	passwordService       domain.PasswordHasher
+	jwtService            *security.JWTService
+	enumerationProtection bool
+	requireVerifiedEmail  bool
+	legacyTokenField      bool
+	totp                  *security.TOTPService
+	audit                 domain.AuditLogger
+	registerHooks         []RegisterHook
+	registerHooksFatal    bool
+	sessions              domain.SessionStore
+	emailChangeTokens     domain.TokenRepository
+	verificationService   *security.VerificationService
+	emailChangeTTL        time.Duration
+	mailer                domain.Mailer
+	linkBaseURL           string
+	loginBackoff          *loginBackoff
+	loginThrottle         *loginThrottle
+	emailDomainPolicy     EmailDomainPolicy
+	identityProviders     map[string]domain.IdentityProvider
+	loginLookups          singleflight.Group
+	passwordHistory       passwordHistory
+	totpAttempts          *attemptTracker
+	clock                 clock.Clock
+}
+
+type AuthOption func(*AuthUseCase)
+
+// WithEnumerationProtection controls whether endpoints that take an email
+// may reveal if an account exists. Protection is enabled by default.
+func WithEnumerationProtection(enabled bool) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.enumerationProtection = enabled
+	}
+}
+
+// WithRequireVerifiedEmail rejects logins from accounts whose email has not
+// been verified yet.
+func WithRequireVerifiedEmail(required bool) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.requireVerifiedEmail = required
+	}
+}
+
+// WithLegacyTokenField keeps the deprecated "token" field alongside
+// "access_token" in auth responses for clients that have not migrated.
+func WithLegacyTokenField(enabled bool) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.legacyTokenField = enabled
+	}
+}
+
+// WithTOTP enables TOTP enrollment. Logins for accounts with TOTP enabled
+// always require the second step, whether or not this option is set.
+func WithTOTP(totp *security.TOTPService) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.totp = totp
+	}
+}
+
+// WithAuditLogger records registrations and login attempts.
+func WithAuditLogger(audit domain.AuditLogger) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.audit = audit
+	}
+}
+
+// WithMailer emails email-change confirmation links, built on baseURL, to
+// the new address.
+func WithMailer(mailer domain.Mailer, baseURL string) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.mailer = mailer
+		uc.linkBaseURL = baseURL
+	}
+}
+
+// WithClock sets the time source for login and session timestamps and
+// token expiries; clock.Real is the default.
+func WithClock(c clock.Clock) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.clock = clock.OrReal(c)
+	}
 }
 
 func NewAuthUseCase(
 	userRepo domain.UserRepository,
-	passwordService *security.PasswordService,
+	passwordService domain.PasswordHasher,
 	jwtService *security.JWTService,
+	opts ...AuthOption,
 ) *AuthUseCase {
-	return &AuthUseCase{
-		userRepo:        userRepo,
-		passwordService: passwordService,
-		jwtService:      jwtService,
+	uc := &AuthUseCase{
+		userRepo:              userRepo,
+		passwordService:       passwordService,
+		jwtService:            jwtService,
+		enumerationProtection: true,
+		legacyTokenField:      true,
+		clock:                 clock.Real{},
+	}
+	for _, opt := range opts {
+		opt(uc)
 	}
+	uc.totpAttempts = newAttemptTracker(security.PendingTokenDuration, uc.clock)
+	return uc
 }
 
+// RegisterRequest and LoginRequest carry `validate` tags that the HTTP
+// layer checks before calling the use case; the use case still validates on
+// its own for other callers.
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Username string `json:"username,omitempty" validate:"omitempty,username"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
 }
 
+// LoginRequest identifies the user by Identifier, an email or a username,
+// or by Email as before usernames existed. Identifier wins when both are set.
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Identifier string `json:"identifier,omitempty" validate:"required_without=Email,max=254"`
+	Email      string `json:"email,omitempty" validate:"omitempty,email"`
+	Password   string `json:"password" validate:"required"`
+}
+
+// Validate checks the request as Register would store it, trimmed and
+// lowercased, and returns every failing field in a *domain.ValidationError.
+// It does not apply the email domain policy, which depends on configuration.
+func (req RegisterRequest) Validate() error {
+	validation := &domain.ValidationError{}
+	if err := validateEmail(normalizeEmail(req.Email)); err != nil {
+		validation.Add("email", err)
+	}
+	if username := normalizeUsername(req.Username); username != "" && !domain.ValidUsername(username) {
+		validation.Add("username", domain.ErrUsernameInvalid)
+	}
+	if req.Password == "" {
+		validation.Add("password", domain.ErrRequiredField)
+	} else if err := validatePassword(req.Password); err != nil {
+		validation.Add("password", err)
+	}
+	return validation.Err()
+}
+
+// identifier is the email or username the request signs in with.
+func (req LoginRequest) identifier() string {
+	if req.Identifier != "" {
+		return normalizeUsername(req.Identifier)
+	}
+	return normalizeUsername(req.Email)
+}
+
+// Validate only checks that the request names a user and carries a
+// password; Login reports its failures as ErrInvalidCredentials so callers
+// cannot tell them from a wrong password.
+func (req LoginRequest) Validate() error {
+	validation := &domain.ValidationError{}
+	if req.identifier() == "" {
+		validation.Add("identifier", domain.ErrRequiredField)
+	}
+	if req.Password == "" {
+		validation.Add("password", domain.ErrRequiredField)
+	}
+	return validation.Err()
 }
 
+type ChangeEmailRequest struct {
+	Email           string `json:"email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+// UserList is one page of users. Page is zero for a page fetched by cursor.
+type UserList struct {
+	Users      []*domain.User
+	Total      int64
+	Page       int
+	PageSize   int
+	NextCursor *pagination.Cursor
+}
+
+const TokenTypeBearer = "Bearer"
+
+// AuthResponse is returned by successful logins. When TOTPRequired is set,
+// only PendingToken and ExpiresIn are filled in and the client must finish
+// with VerifyTOTP.
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *domain.User `json:"user"`
+	AccessToken  string   `json:"access_token,omitempty"`
+	TokenType    string   `json:"token_type,omitempty"`
+	ExpiresIn    int64    `json:"expires_in"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	User         *UserDTO `json:"user,omitempty"`
+	Token        string   `json:"token,omitempty"`
+	TOTPRequired bool     `json:"totp_required,omitempty"`
+	PendingToken string   `json:"pending_token,omitempty"`
 }
 
-func (uc *AuthUseCase) Register(req RegisterRequest) (*AuthResponse, error) {
-	if req.Email == "" || req.Password == "" {
-		return nil, domain.ErrInvalidCredentials
+// normalizeEmail is applied before every store or lookup so addresses
+// differing only by case or surrounding whitespace map to one account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeUsername makes usernames case-insensitive, like emails.
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// validateEmail expects an address that has already been normalized.
+func validateEmail(email string) error {
+	if email == "" {
+		return domain.ErrRequiredField
+	}
+	if len(email) > domain.MaxEmailLength {
+		return domain.ErrEmailTooLong
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return domain.ErrInvalidEmail
+	}
+	return nil
+}
+
+func (uc *AuthUseCase) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+	req.Email = normalizeEmail(req.Email)
+	req.Username = normalizeUsername(req.Username)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if !uc.emailDomainPolicy.Allows(req.Email) {
+		return nil, domain.ErrEmailDomainNotAllowed
 	}
 
 	hashedPassword, err := uc.passwordService.Hash(req.Password)
@@ -48,50 +249,359 @@ func (uc *AuthUseCase) Register(req RegisterRequest) (*AuthResponse, error) {
 		return nil, err
 	}
 
-	user, err := uc.userRepo.Create(req.Email, hashedPassword)
+	user, err := uc.userRepo.CreateWithUsername(ctx, req.Email, req.Username, hashedPassword, domain.PasswordPolicyVersion)
 	if err != nil {
+		return nil, contextError(err)
+	}
+	uc.recordAudit(ctx, domain.AuditActionRegister, user.ID, user.Email)
+
+	if err := uc.runRegisterHooks(user); err != nil {
 		return nil, err
 	}
 
-	token, err := uc.jwtService.GenerateToken(user.ID, user.Email)
+	return uc.issueTokens(ctx, user)
+}
+
+func (uc *AuthUseCase) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+	identifier := req.identifier()
+
+	user, err := uc.findByLoginIdentifier(ctx, identifier)
+	if err != nil && err != domain.ErrUserNotFound {
+		return nil, err
+	}
+	account := loginAccountKey(user, identifier)
+	if err := uc.checkLoginThrottle(ctx, account); err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		uc.passwordService.VerifyDummy(req.Password)
+		uc.recordAudit(ctx, domain.AuditActionLoginFailed, 0, identifier)
+		return nil, uc.failLogin(ctx, account, nil)
+	}
+
+	// An account created through an identity provider has no password; the
+	// dummy check keeps its refusal as slow as a wrong password's.
+	if user.PasswordHash == "" {
+		uc.passwordService.VerifyDummy(req.Password)
+		uc.recordAudit(ctx, domain.AuditActionLoginFailed, user.ID, user.Email)
+		return nil, uc.failLogin(ctx, account, user)
+	}
+	if err := uc.passwordService.Verify(user.PasswordHash, req.Password); err != nil {
+		uc.recordAudit(ctx, domain.AuditActionLoginFailed, user.ID, user.Email)
+		return nil, uc.failLogin(ctx, account, user)
+	}
+	// With 2FA the password alone is not enough to clear the failures, or
+	// a stolen password would allow endless code guessing; VerifyTOTP
+	// clears them once the code is right.
+	if !user.TOTPEnabled {
+		uc.clearLoginFailures(ctx, account, user)
+	}
+
+	if uc.requireVerifiedEmail && !user.EmailVerified {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	if uc.passwordService.NeedsRehash(user.PasswordHash) {
+		uc.rehashPassword(ctx, user, req.Password)
+	}
+
+	if user.TOTPEnabled {
+		return uc.totpChallenge(user)
+	}
+
+	return uc.completeLogin(ctx, user)
+}
+
+// loginAccountKey is the key the login throttle and backoff count an
+// account's failures under. A known user is keyed by ID, so signing in by
+// email and by username share one budget; an unknown identifier is keyed
+// by itself.
+func loginAccountKey(user *domain.User, identifier string) string {
+	if user == nil {
+		return identifier
+	}
+	return "user:" + strconv.FormatInt(user.ID, 10)
+}
+
+// findByLoginIdentifier treats an identifier containing '@' as an email and
+// anything else as a username; usernames cannot contain '@'.
+func (uc *AuthUseCase) findByLoginIdentifier(ctx context.Context, identifier string) (*domain.User, error) {
+	if strings.Contains(identifier, "@") {
+		return uc.findByEmailShared(ctx, identifier)
+	}
+	return uc.userRepo.FindByUsername(ctx, identifier)
+}
+
+// findByEmailShared lets concurrent logins to the same account share one
+// FindByEmail query. Nothing is cached: the next login after the query
+// returns, even after an error, queries again. The query runs without the
+// caller's cancellation, so one client disconnecting does not fail the
+// others; each caller still stops waiting when its own context ends.
+func (uc *AuthUseCase) findByEmailShared(ctx context.Context, email string) (*domain.User, error) {
+	result := uc.loginLookups.DoChan(email, func() (interface{}, error) {
+		return uc.userRepo.FindByEmail(context.WithoutCancel(ctx), email)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, contextError(ctx.Err())
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// Each login gets its own copy, since Login updates the user.
+		user := *res.Val.(*domain.User)
+		return &user, nil
+	}
+}
+
+// completeLogin issues the access token once every login step has passed.
+func (uc *AuthUseCase) completeLogin(ctx context.Context, user *domain.User) (*AuthResponse, error) {
+	// Like the rehash, a failed write must not fail a login that succeeded.
+	now := uc.clock.Now().UTC()
+	if err := uc.userRepo.UpdateLastLogin(ctx, user.ID, now); err == nil {
+		user.LastLoginAt = &now
+	}
+
+	resp, err := uc.issueTokens(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	uc.recordAudit(ctx, domain.AuditActionLogin, user.ID, user.Email)
 
-	return &AuthResponse{
-		Token: token,
-		User:  user,
-	}, nil
+	return resp, nil
 }
 
-func (uc *AuthUseCase) Login(req LoginRequest) (*AuthResponse, error) {
-	if req.Email == "" || req.Password == "" {
-		return nil, domain.ErrInvalidCredentials
+func (uc *AuthUseCase) recordAudit(ctx context.Context, action string, userID int64, email string) {
+	if uc.audit == nil {
+		return
 	}
+	uc.audit.Record(domain.AuditEvent{
+		Action: action,
+		UserID: userID,
+		Email:  email,
+		IP:     domain.ClientIPFromContext(ctx),
+	})
+}
 
-	user, err := uc.userRepo.FindByEmail(req.Email)
+// rehashPassword upgrades a stored hash to the configured cost. Failures are
+// ignored: the login already succeeded and the next one will retry.
+func (uc *AuthUseCase) rehashPassword(ctx context.Context, user *domain.User, password string) {
+	hashedPassword, err := uc.passwordService.Hash(password)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
-			return nil, domain.ErrInvalidCredentials
+		return
+	}
+	// Only the hash parameters change, not the password, so the policy
+	// version stays as it was.
+	if err := uc.userRepo.UpdatePassword(ctx, user.ID, hashedPassword, user.PasswordPolicyVersion); err != nil {
+		return
+	}
+	user.PasswordHash = hashedPassword
+}
+
+func (uc *AuthUseCase) newAuthResponse(user *domain.User, accessToken string) *AuthResponse {
+	resp := &AuthResponse{
+		AccessToken: accessToken,
+		TokenType:   TokenTypeBearer,
+		ExpiresIn:   int64(uc.jwtService.Duration().Seconds()),
+		User:        NewUserDTO(user),
+	}
+	if uc.legacyTokenField {
+		resp.Token = accessToken
+	}
+	return resp
+}
+
+func (uc *AuthUseCase) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	user, err := uc.userRepo.FindByID(ctx, id)
+	return user, contextError(err)
+}
+
+// ChangeEmail re-authenticates with the current password before switching
+// the account to newEmail. The new address starts out unverified. With
+// WithEmailChangeConfirmation the switch is only staged and the returned
+// token must be passed to ConfirmEmailChange; otherwise the token is empty.
+func (uc *AuthUseCase) ChangeEmail(ctx context.Context, userID int64, newEmail, currentPassword string) (string, error) {
+	newEmail = normalizeEmail(newEmail)
+
+	validation := &domain.ValidationError{}
+	if err := validateEmail(newEmail); err != nil {
+		validation.Add("email", err)
+	}
+	if currentPassword == "" {
+		validation.Add("current_password", domain.ErrRequiredField)
+	}
+	if err := validation.Err(); err != nil {
+		return "", err
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return "", contextError(err)
+	}
+
+	if err := uc.passwordService.Verify(user.PasswordHash, currentPassword); err != nil {
+		return "", domain.ErrInvalidCredentials
+	}
+	// Keeping the current address is always allowed, even if the policy
+	// changed since it was registered.
+	if newEmail != user.Email && !uc.emailDomainPolicy.Allows(newEmail) {
+		return "", domain.ErrEmailDomainNotAllowed
+	}
+
+	if uc.emailChangeTokens != nil {
+		// Asking for the current address again cancels a pending change.
+		if newEmail == user.Email {
+			newEmail = ""
+			if user.PendingEmail == "" {
+				return "", nil
+			}
 		}
-		return nil, err
+		return uc.stageEmailChange(ctx, user, newEmail)
 	}
 
-	if err := uc.passwordService.Verify(user.PasswordHash, req.Password); err != nil {
+	if newEmail == user.Email {
+		return "", nil
+	}
+
+	return "", uc.userRepo.UpdateEmail(ctx, user.ID, newEmail)
+}
+
+func (uc *AuthUseCase) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	email = normalizeEmail(email)
+	if email == "" {
 		return nil, domain.ErrInvalidCredentials
 	}
 
-	token, err := uc.jwtService.GenerateToken(user.ID, user.Email)
+	user, err := uc.userRepo.FindByEmail(ctx, email)
+	return user, contextError(err)
+}
+
+var genericAuthMethods = []string{domain.AuthMethodPassword}
+
+func (uc *AuthUseCase) AuthMethods(ctx context.Context, email string) ([]string, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if uc.enumerationProtection {
+		return genericAuthMethods, nil
+	}
+
+	user, err := uc.userRepo.FindByEmail(ctx, email)
 	if err != nil {
-		return nil, err
+		return nil, contextError(err)
+	}
+
+	return user.AuthMethods(), nil
+}
+
+const (
+	defaultPageSize = pagination.DefaultPageSize
+	maxPageSize     = pagination.MaxPageSize
+)
+
+// ListUsers returns one page of users in the order params asks for, by
+// offset or after params.After when set. Lists sorted by created_at also
+// get a NextCursor while users remain, so a client can switch to keyset
+// pagination from any page.
+func (uc *AuthUseCase) ListUsers(ctx context.Context, params pagination.Params) (*UserList, error) {
+	page, pageSize := normalizePage(params.Page, params.PageSize)
+
+	opts := domain.UserListOptions{
+		Limit:      pageSize,
+		Offset:     (page - 1) * pageSize,
+		SortBy:     params.Sort,
+		Descending: params.Descending(),
+	}
+	if params.After != nil {
+		opts.After = &domain.UserCursor{CreatedAt: params.After.CreatedAt, ID: params.After.ID}
+		page = 0
+	}
+	keyset := params.Sort == domain.UserSortCreatedAt
+	if keyset {
+		// One extra row tells whether there is a next page.
+		opts.Limit++
+	}
+
+	users, err := uc.userRepo.List(ctx, opts)
+	if err != nil {
+		return nil, contextError(err)
 	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  user,
+	var next *pagination.Cursor
+	if keyset && len(users) > pageSize {
+		users = users[:pageSize]
+		last := users[len(users)-1]
+		next = &pagination.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	total, err := uc.userRepo.Count(ctx)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	return &UserList{
+		Users:      users,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		NextCursor: next,
 	}, nil
 }
 
-func (uc *AuthUseCase) GetUserByID(id int64) (*domain.User, error) {
-	return uc.userRepo.FindByID(id)
+// normalizePage defaults a missing page or page size and caps the size at
+// maxPageSize.
+func normalizePage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+type PasswordPolicyImpact struct {
+	CurrentVersion int           `json:"current_version"`
+	TargetVersion  int           `json:"target_version"`
+	TotalUsers     int64         `json:"total_users"`
+	OutdatedUsers  int64         `json:"outdated_users"`
+	ByVersion      map[int]int64 `json:"by_version"`
+}
+
+// PasswordPolicyImpact counts users whose password was last set under a
+// policy older than targetVersion, i.e. who would not be known to comply if
+// that version became mandatory. A targetVersion below 1 means the current
+// policy.
+func (uc *AuthUseCase) PasswordPolicyImpact(ctx context.Context, targetVersion int) (*PasswordPolicyImpact, error) {
+	if targetVersion < 1 {
+		targetVersion = domain.PasswordPolicyVersion
+	}
+
+	counts, err := uc.userRepo.CountByPasswordPolicyVersion(ctx)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	impact := &PasswordPolicyImpact{
+		CurrentVersion: domain.PasswordPolicyVersion,
+		TargetVersion:  targetVersion,
+		ByVersion:      counts,
+	}
+	for version, count := range counts {
+		impact.TotalUsers += count
+		if version < targetVersion {
+			impact.OutdatedUsers += count
+		}
+	}
+	return impact, nil
 }
@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// countingHasher wraps the real hasher so benchmarks can check that every
+// iteration reached the KDF instead of failing early.
+type countingHasher struct {
+	domain.PasswordHasher
+	hashes   int
+	verifies int
+}
+
+func (h *countingHasher) Hash(password string) (string, error) {
+	h.hashes++
+	return h.PasswordHasher.Hash(password)
+}
+
+func (h *countingHasher) Verify(hashedPassword, password string) error {
+	h.verifies++
+	return h.PasswordHasher.Verify(hashedPassword, password)
+}
+
+func newBenchmarkAuthUseCase(b *testing.B) (*AuthUseCase, *countingHasher) {
+	b.Helper()
+
+	passwordService, err := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	if err != nil {
+		b.Fatalf("Failed to create password service: %v", err)
+	}
+	hasher := &countingHasher{PasswordHasher: passwordService}
+	jwtService := security.NewJWTService("bench-secret", "bench-issuer", time.Hour,
+		security.WithRevocationStore(security.NewMemoryRevocationStore()))
+
+	return NewAuthUseCase(repository.NewInMemoryUserRepository(), hasher, jwtService), hasher
+}
+
+func BenchmarkRegister(b *testing.B) {
+	useCase, hasher := newBenchmarkAuthUseCase(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := useCase.Register(ctx, RegisterRequest{Email: fmt.Sprintf("user%d@example.com", i), Password: "password123"})
+		if err != nil {
+			b.Fatalf("Register failed: %v", err)
+		}
+		if resp.AccessToken == "" {
+			b.Fatal("Expected an access token")
+		}
+	}
+	b.StopTimer()
+
+	if hasher.hashes != b.N {
+		b.Fatalf("Expected %d password hashes, got %d", b.N, hasher.hashes)
+	}
+}
+
+func BenchmarkLogin(b *testing.B) {
+	useCase, hasher := newBenchmarkAuthUseCase(b)
+	ctx := context.Background()
+
+	if _, err := useCase.Register(ctx, RegisterRequest{Email: "bench@example.com", Password: "password123"}); err != nil {
+		b.Fatalf("Failed to register user: %v", err)
+	}
+	req := LoginRequest{Email: "bench@example.com", Password: "password123"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := useCase.Login(ctx, req)
+		if err != nil {
+			b.Fatalf("Login failed: %v", err)
+		}
+		if resp.AccessToken == "" {
+			b.Fatal("Expected an access token")
+		}
+	}
+	b.StopTimer()
+
+	if hasher.verifies != b.N {
+		b.Fatalf("Expected %d password verifications, got %d", b.N, hasher.verifies)
+	}
+}
@@ -1,11 +1,20 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/valentinfrappart/securerestapi/internal/clock"
 	"github.com/valentinfrappart/securerestapi/internal/domain"
 	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type MockUserRepository struct {
@@ -22,7 +31,11 @@ func NewMockUserRepository() *MockUserRepository {
 	}
 }
 
-func (m *MockUserRepository) Create(email, passwordHash string) (*domain.User, error) {
+func (m *MockUserRepository) Create(ctx context.Context, email, passwordHash string, policyVersion int) (*domain.User, error) {
+	return m.CreateWithUsername(ctx, email, "", passwordHash, policyVersion)
+}
+
+func (m *MockUserRepository) CreateWithUsername(ctx context.Context, email, username, passwordHash string, policyVersion int) (*domain.User, error) {
 	if m.createError != nil {
 		return nil, m.createError
 	}
@@ -30,11 +43,17 @@ func (m *MockUserRepository) Create(email, passwordHash string) (*domain.User, e
 	if _, exists := m.users[email]; exists {
 		return nil, domain.ErrUserAlreadyExists
 	}
+	if _, err := m.FindByUsername(ctx, username); err == nil {
+		return nil, domain.ErrUsernameTaken
+	}
 
 	user := &domain.User{
-		ID:           m.nextID,
-		Email:        email,
-		PasswordHash: passwordHash,
+		ID:                    m.nextID,
+		Email:                 email,
+		Username:              username,
+		PasswordHash:          passwordHash,
+		Role:                  domain.RoleUser,
+		PasswordPolicyVersion: policyVersion,
 	}
 	m.nextID++
 	m.users[email] = user
@@ -42,7 +61,17 @@ func (m *MockUserRepository) Create(email, passwordHash string) (*domain.User, e
 	return user, nil
 }
 
-func (m *MockUserRepository) FindByEmail(email string) (*domain.User, error) {
+func (m *MockUserRepository) CreateExternal(ctx context.Context, email, provider string) (*domain.User, error) {
+	user, err := m.CreateWithUsername(ctx, email, "", "", 0)
+	if err != nil {
+		return nil, err
+	}
+	user.Provider = provider
+	user.EmailVerified = true
+	return user, nil
+}
+
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	user, exists := m.users[email]
 	if !exists {
 		return nil, domain.ErrUserNotFound
@@ -50,7 +79,16 @@ func (m *MockUserRepository) FindByEmail(email string) (*domain.User, error) {
 	return user, nil
 }
 
-func (m *MockUserRepository) FindByID(id int64) (*domain.User, error) {
+func (m *MockUserRepository) FindByUsername(ctx context.Context, username string) (*domain.User, error) {
+	for _, user := range m.users {
+		if username != "" && user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) FindByID(ctx context.Context, id int64) (*domain.User, error) {
 	if m.findByIDError != nil {
 		return nil, m.findByIDError
 	}
@@ -63,6 +101,263 @@ func (m *MockUserRepository) FindByID(id int64) (*domain.User, error) {
 	return nil, domain.ErrUserNotFound
 }
 
+func (m *MockUserRepository) List(ctx context.Context, opts domain.UserListOptions) ([]*domain.User, error) {
+	users := make([]*domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if opts.SortBy == domain.UserSortEmail {
+			return (users[i].Email < users[j].Email) != opts.Descending
+		}
+		return (users[i].ID < users[j].ID) != opts.Descending
+	})
+
+	if opts.Offset >= len(users) {
+		return []*domain.User{}, nil
+	}
+	users = users[opts.Offset:]
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+	}
+	return users, nil
+}
+
+func (m *MockUserRepository) Count(ctx context.Context) (int64, error) {
+	return int64(len(m.users)), nil
+}
+
+func (m *MockUserRepository) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.EmailVerified = verified
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) SetRole(ctx context.Context, id int64, role string) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.Role = role
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) Delete(ctx context.Context, id int64) error {
+	for email, user := range m.users {
+		if user.ID == id {
+			delete(m.users, email)
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) FindByIDIncludingDeleted(ctx context.Context, id int64) (*domain.User, error) {
+	return m.FindByID(ctx, id)
+}
+
+// Restore always fails: the mock only hard-deletes.
+func (m *MockUserRepository) Restore(ctx context.Context, id int64) error {
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) UpdateProfile(ctx context.Context, id int64, displayName string) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.DisplayName = displayName
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.PasswordHash = passwordHash
+			user.PasswordPolicyVersion = policyVersion
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) ChangePassword(ctx context.Context, id int64, passwordHash string, policyVersion int) (int, error) {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.PasswordHash = passwordHash
+			user.PasswordPolicyVersion = policyVersion
+			user.TokenVersion++
+			return user.TokenVersion, nil
+		}
+	}
+	return 0, domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	if _, exists := m.users[email]; exists {
+		return domain.ErrUserAlreadyExists
+	}
+	for oldEmail, user := range m.users {
+		if user.ID == id {
+			delete(m.users, oldEmail)
+			user.Email = email
+			user.EmailVerified = false
+			m.users[email] = user
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) SetPendingEmail(ctx context.Context, id int64, email string) error {
+	if _, exists := m.users[email]; exists {
+		return domain.ErrUserAlreadyExists
+	}
+	for _, user := range m.users {
+		if user.ID == id {
+			user.PendingEmail = email
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) ConfirmPendingEmail(ctx context.Context, id int64) error {
+	for oldEmail, user := range m.users {
+		if user.ID == id {
+			if user.PendingEmail == "" {
+				return domain.ErrNoPendingEmail
+			}
+			if _, exists := m.users[user.PendingEmail]; exists {
+				return domain.ErrUserAlreadyExists
+			}
+			delete(m.users, oldEmail)
+			user.Email = user.PendingEmail
+			user.PendingEmail = ""
+			user.EmailVerified = true
+			m.users[user.Email] = user
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) SetTOTPSecret(ctx context.Context, id int64, encryptedSecret string) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.TOTPSecret = encryptedSecret
+			user.TOTPEnabled = false
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) EnableTOTP(ctx context.Context, id int64) error {
+	for _, user := range m.users {
+		if user.ID == id && user.TOTPSecret != "" {
+			user.TOTPEnabled = true
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) IncrementFailedAttempts(ctx context.Context, id int64) (int, error) {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.FailedAttempts++
+			return user.FailedAttempts, nil
+		}
+	}
+	return 0, domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) ResetFailedAttempts(ctx context.Context, id int64) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.FailedAttempts = 0
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) UpdateLastLogin(ctx context.Context, id int64, t time.Time) error {
+	for _, user := range m.users {
+		if user.ID == id {
+			user.LastLoginAt = &t
+			return nil
+		}
+	}
+	return domain.ErrUserNotFound
+}
+
+func (m *MockUserRepository) CountByPasswordPolicyVersion(ctx context.Context) (map[int]int64, error) {
+	counts := make(map[int]int64)
+	for _, user := range m.users {
+		counts[user.PasswordPolicyVersion]++
+	}
+	return counts, nil
+}
+
+// MockPasswordHasher stores passwords in clear text and can be told to fail.
+type MockPasswordHasher struct {
+	hashError error
+}
+
+func (m *MockPasswordHasher) Hash(password string) (string, error) {
+	if m.hashError != nil {
+		return "", m.hashError
+	}
+	return "hashed:" + password, nil
+}
+
+func (m *MockPasswordHasher) Verify(hashedPassword, password string) error {
+	if hashedPassword != "hashed:"+password {
+		return errors.New("mismatch")
+	}
+	return nil
+}
+
+func (m *MockPasswordHasher) NeedsRehash(hashedPassword string) bool {
+	return false
+}
+
+func (m *MockPasswordHasher) VerifyDummy(password string) error {
+	return errors.New("mismatch")
+}
+
+func assertFieldErrors(t *testing.T, err error, expected map[string]error) {
+	t.Helper()
+
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	if len(validationErr.Fields) != len(expected) {
+		t.Errorf("Expected %d field errors, got %d: %v", len(expected), len(validationErr.Fields), err)
+	}
+
+	for _, field := range validationErr.Fields {
+		want, ok := expected[field.Field]
+		if !ok {
+			t.Errorf("Unexpected field error for %s: %v", field.Field, field.Err)
+			continue
+		}
+		if !errors.Is(field, want) {
+			t.Errorf("Expected %s error %v, got %v", field.Field, want, field.Err)
+		}
+	}
+}
+
 func TestAuthUseCase_Register_Success(t *testing.T) {
 	mockRepo := NewMockUserRepository()
 	passwordService := security.NewPasswordService()
@@ -75,7 +370,7 @@ func TestAuthUseCase_Register_Success(t *testing.T) {
 		Password: "password123",
 	}
 
-	resp, err := useCase.Register(req)
+	resp, err := useCase.Register(context.Background(), req)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -109,12 +404,12 @@ func TestAuthUseCase_Register_DuplicateEmail(t *testing.T) {
 		Password: "password123",
 	}
 
-	_, err := useCase.Register(req)
+	_, err := useCase.Register(context.Background(), req)
 	if err != nil {
 		t.Fatalf("Expected no error on first registration, got %v", err)
 	}
 
-	_, err = useCase.Register(req)
+	_, err = useCase.Register(context.Background(), req)
 	if !errors.Is(err, domain.ErrUserAlreadyExists) {
 		t.Errorf("Expected ErrUserAlreadyExists, got %v", err)
 	}
@@ -132,10 +427,8 @@ func TestAuthUseCase_Register_EmptyEmail(t *testing.T) {
 		Password: "password123",
 	}
 
-	_, err := useCase.Register(req)
-	if !errors.Is(err, domain.ErrInvalidCredentials) {
-		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
-	}
+	_, err := useCase.Register(context.Background(), req)
+	assertFieldErrors(t, err, map[string]error{"email": domain.ErrRequiredField})
 }
 
 func TestAuthUseCase_Register_EmptyPassword(t *testing.T) {
@@ -150,10 +443,28 @@ func TestAuthUseCase_Register_EmptyPassword(t *testing.T) {
 		Password: "",
 	}
 
-	_, err := useCase.Register(req)
-	if !errors.Is(err, domain.ErrInvalidCredentials) {
-		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
-	}
+	_, err := useCase.Register(context.Background(), req)
+	assertFieldErrors(t, err, map[string]error{"password": domain.ErrRequiredField})
+}
+
+func TestAuthUseCase_Register_MultipleValidationErrors(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "", Password: ""})
+	assertFieldErrors(t, err, map[string]error{
+		"email":    domain.ErrRequiredField,
+		"password": domain.ErrRequiredField,
+	})
+
+	_, err = useCase.Register(context.Background(), RegisterRequest{Email: "", Password: "short"})
+	assertFieldErrors(t, err, map[string]error{
+		"email":    domain.ErrRequiredField,
+		"password": domain.ErrWeakPassword,
+	})
 }
 
 func TestAuthUseCase_Login_Success(t *testing.T) {
@@ -167,7 +478,7 @@ func TestAuthUseCase_Login_Success(t *testing.T) {
 		Email:    "test@example.com",
 		Password: "password123",
 	}
-	_, err := useCase.Register(registerReq)
+	_, err := useCase.Register(context.Background(), registerReq)
 	if err != nil {
 		t.Fatalf("Failed to register user: %v", err)
 	}
@@ -177,7 +488,7 @@ func TestAuthUseCase_Login_Success(t *testing.T) {
 		Password: "password123",
 	}
 
-	resp, err := useCase.Login(loginReq)
+	resp, err := useCase.Login(context.Background(), loginReq)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -206,7 +517,7 @@ func TestAuthUseCase_Login_WrongPassword(t *testing.T) {
 		Email:    "test@example.com",
 		Password: "password123",
 	}
-	_, err := useCase.Register(registerReq)
+	_, err := useCase.Register(context.Background(), registerReq)
 	if err != nil {
 		t.Fatalf("Failed to register user: %v", err)
 	}
@@ -216,12 +527,38 @@ func TestAuthUseCase_Login_WrongPassword(t *testing.T) {
 		Password: "wrongpassword",
 	}
 
-	_, err = useCase.Login(loginReq)
+	_, err = useCase.Login(context.Background(), loginReq)
 	if !errors.Is(err, domain.ErrInvalidCredentials) {
 		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
 	}
 }
 
+func TestAuthUseCase_Login_RecordsLastLogin(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+	now := clock.NewFake(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService, WithClock(now))
+
+	registered, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	if registered.User.LastLoginAt != nil {
+		t.Fatal("Expected registration not to count as a login")
+	}
+
+	now.Advance(time.Hour)
+	resp, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	if stored.LastLoginAt == nil || !stored.LastLoginAt.Equal(now.Now()) {
+		t.Errorf("Expected last login to be recorded at %v, got %v", now.Now(), stored.LastLoginAt)
+	}
+}
+
 func TestAuthUseCase_Login_UserNotFound(t *testing.T) {
 	mockRepo := NewMockUserRepository()
 	passwordService := security.NewPasswordService()
@@ -234,7 +571,7 @@ func TestAuthUseCase_Login_UserNotFound(t *testing.T) {
 		Password: "password123",
 	}
 
-	_, err := useCase.Login(loginReq)
+	_, err := useCase.Login(context.Background(), loginReq)
 	if !errors.Is(err, domain.ErrInvalidCredentials) {
 		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
 	}
@@ -252,7 +589,7 @@ func TestAuthUseCase_Login_EmptyEmail(t *testing.T) {
 		Password: "password123",
 	}
 
-	_, err := useCase.Login(loginReq)
+	_, err := useCase.Login(context.Background(), loginReq)
 	if !errors.Is(err, domain.ErrInvalidCredentials) {
 		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
 	}
@@ -269,12 +606,12 @@ func TestAuthUseCase_GetUserByID_Success(t *testing.T) {
 		Email:    "test@example.com",
 		Password: "password123",
 	}
-	resp, err := useCase.Register(registerReq)
+	resp, err := useCase.Register(context.Background(), registerReq)
 	if err != nil {
 		t.Fatalf("Failed to register user: %v", err)
 	}
 
-	user, err := useCase.GetUserByID(resp.User.ID)
+	user, err := useCase.GetUserByID(context.Background(), resp.User.ID)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -291,8 +628,582 @@ func TestAuthUseCase_GetUserByID_NotFound(t *testing.T) {
 
 	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
 
-	_, err := useCase.GetUserByID(999)
+	_, err := useCase.GetUserByID(context.Background(), 999)
 	if !errors.Is(err, domain.ErrUserNotFound) {
 		t.Errorf("Expected ErrUserNotFound, got %v", err)
 	}
 }
+
+func TestAuthUseCase_GetUserByID_DeadlineExceeded(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	mockRepo.findByIDError = fmt.Errorf("query user: %w", context.DeadlineExceeded)
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	_, err := useCase.GetUserByID(context.Background(), 1)
+	if !errors.Is(err, domain.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context error to be kept, got %v", err)
+	}
+}
+
+func TestAuthUseCase_AuthMethods_ProtectedModeIsGeneric(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	existing, err := useCase.AuthMethods(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	unknown, err := useCase.AuthMethods(context.Background(), "nonexistent@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error for unknown email, got %v", err)
+	}
+
+	if len(existing) != len(unknown) || existing[0] != unknown[0] {
+		t.Errorf("Expected identical methods for existing and unknown accounts, got %v and %v", existing, unknown)
+	}
+}
+
+func TestAuthUseCase_AuthMethods_PermissiveMode(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService, WithEnumerationProtection(false))
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	methods, err := useCase.AuthMethods(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(methods) != 1 || methods[0] != domain.AuthMethodPassword {
+		t.Errorf("Expected [%s], got %v", domain.AuthMethodPassword, methods)
+	}
+
+	_, err = useCase.AuthMethods(context.Background(), "nonexistent@example.com")
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ListUsers_ClampsPageSize(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	for i := 0; i < 3; i++ {
+		mockRepo.Create(context.Background(), fmt.Sprintf("user%d@example.com", i), "hash", 1)
+	}
+
+	list, err := useCase.ListUsers(context.Background(), pagination.Params{Page: 0, PageSize: 1000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if list.Page != 1 {
+		t.Errorf("Expected page 1, got %d", list.Page)
+	}
+	if list.PageSize != maxPageSize {
+		t.Errorf("Expected page size %d, got %d", maxPageSize, list.PageSize)
+	}
+	if list.Total != 3 || len(list.Users) != 3 {
+		t.Errorf("Expected 3 users, got total %d and %d users", list.Total, len(list.Users))
+	}
+}
+
+func TestAuthUseCase_ListUsers_Sorted(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, security.NewJWTService("test-secret", "test-issuer", time.Hour))
+
+	for _, email := range []string{"bob@example.com", "carol@example.com", "alice@example.com"} {
+		mockRepo.Create(context.Background(), email, "hash", 1)
+	}
+
+	list, err := useCase.ListUsers(context.Background(), pagination.Params{Page: 2, PageSize: 2, Sort: domain.UserSortEmail, Order: pagination.OrderDesc})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(list.Users) != 1 || list.Users[0].Email != "alice@example.com" {
+		t.Errorf("Expected alice alone on the second page, got %v", list.Users)
+	}
+}
+
+func TestAuthUseCase_Login_UnverifiedEmailBlocked(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService, WithRequireVerifiedEmail(true))
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	loginReq := LoginRequest{Email: "test@example.com", Password: "password123"}
+
+	_, err = useCase.Login(context.Background(), loginReq)
+	if !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Fatalf("Expected ErrEmailNotVerified, got %v", err)
+	}
+
+	mockRepo.SetEmailVerified(context.Background(), resp.User.ID, true)
+
+	if _, err := useCase.Login(context.Background(), loginReq); err != nil {
+		t.Errorf("Expected verified user to log in, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_RehashesLowerCost(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	weakService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	hash, err := weakService.Hash("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	mockRepo.Create(context.Background(), "test@example.com", hash, 1)
+
+	strongService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost + 1)
+	useCase := NewAuthUseCase(mockRepo, strongService, jwtService)
+
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	user, _ := mockRepo.FindByEmail(context.Background(), "test@example.com")
+	cost, err := bcrypt.Cost([]byte(user.PasswordHash))
+	if err != nil {
+		t.Fatalf("Failed to read stored cost: %v", err)
+	}
+	if cost != bcrypt.MinCost+1 {
+		t.Errorf("Expected stored cost %d after login, got %d", bcrypt.MinCost+1, cost)
+	}
+
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Errorf("Expected login with the rehashed password to succeed, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_MigratesBcryptToArgon2(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	bcryptService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	hash, err := bcryptService.Hash("password123")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	mockRepo.Create(context.Background(), "test@example.com", hash, 1)
+
+	argon2Service, _ := security.NewArgon2PasswordService(security.Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1})
+	useCase := NewAuthUseCase(mockRepo, argon2Service, jwtService)
+
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Expected the bcrypt hash to verify, got %v", err)
+	}
+
+	user, _ := mockRepo.FindByEmail(context.Background(), "test@example.com")
+	if got := security.HashAlgorithm(user.PasswordHash); got != security.AlgorithmArgon2id {
+		t.Fatalf("Expected the hash to be migrated to argon2id, got %q", got)
+	}
+	if user.PasswordPolicyVersion != 1 {
+		t.Errorf("Expected the policy version to be kept, got %d", user.PasswordPolicyVersion)
+	}
+
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Errorf("Expected login with the migrated hash to succeed, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_ResponseEnvelope(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 15*time.Minute)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService, WithLegacyTokenField(false))
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	resp, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.AccessToken == "" {
+		t.Error("Expected access_token to be set")
+	}
+	if resp.TokenType != "Bearer" {
+		t.Errorf("Expected token_type Bearer, got %q", resp.TokenType)
+	}
+	if resp.ExpiresIn != 900 {
+		t.Errorf("Expected expires_in 900, got %d", resp.ExpiresIn)
+	}
+	if resp.User == nil || resp.User.Email != "test@example.com" {
+		t.Errorf("Expected user in response, got %+v", resp.User)
+	}
+	if resp.Token != "" {
+		t.Error("Expected legacy token field to be omitted")
+	}
+}
+
+func TestAuthUseCase_Login_LegacyTokenField(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 15*time.Minute)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	if resp.Token != resp.AccessToken {
+		t.Errorf("Expected legacy token to mirror access_token, got %q and %q", resp.Token, resp.AccessToken)
+	}
+}
+
+func TestAuthUseCase_Login_UnknownUserTakesComparableTime(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	// Warm up the lazily generated dummy hash.
+	useCase.Login(context.Background(), LoginRequest{Email: "missing@example.com", Password: "password123"})
+
+	start := time.Now()
+	useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "wrong-password"})
+	wrongPassword := time.Since(start)
+
+	start = time.Now()
+	_, err := useCase.Login(context.Background(), LoginRequest{Email: "missing@example.com", Password: "password123"})
+	unknownUser := time.Since(start)
+
+	if err != domain.ErrInvalidCredentials {
+		t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	// Generous bound: without the dummy compare the unknown-user path is
+	// orders of magnitude faster than a bcrypt comparison.
+	if unknownUser < wrongPassword/4 {
+		t.Errorf("Expected unknown user login (%s) to take comparable time to a wrong password (%s)", unknownUser, wrongPassword)
+	}
+}
+
+func newChangeEmailUseCase(t *testing.T) (*AuthUseCase, *MockUserRepository, *domain.User) {
+	t.Helper()
+
+	mockRepo := NewMockUserRepository()
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "old@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	mockRepo.SetEmailVerified(context.Background(), resp.User.ID, true)
+
+	user, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	return useCase, mockRepo, user
+}
+
+func TestAuthUseCase_ChangeEmail_Success(t *testing.T) {
+	useCase, mockRepo, user := newChangeEmailUseCase(t)
+
+	if _, err := useCase.ChangeEmail(context.Background(), user.ID, "  New@Example.com ", "password123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	updated, err := mockRepo.FindByEmail(context.Background(), "new@example.com")
+	if err != nil {
+		t.Fatalf("Expected user under normalized new email, got %v", err)
+	}
+	if updated.EmailVerified {
+		t.Error("Expected verified flag to be reset")
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_WrongPassword(t *testing.T) {
+	useCase, mockRepo, user := newChangeEmailUseCase(t)
+
+	_, err := useCase.ChangeEmail(context.Background(), user.ID, "new@example.com", "wrong-password")
+	if err != domain.ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := mockRepo.FindByEmail(context.Background(), "old@example.com"); err != nil {
+		t.Error("Expected email to be unchanged")
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_Collision(t *testing.T) {
+	useCase, _, user := newChangeEmailUseCase(t)
+
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "taken@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register second user: %v", err)
+	}
+
+	_, err := useCase.ChangeEmail(context.Background(), user.ID, "taken@example.com", "password123")
+	if err != domain.ErrUserAlreadyExists {
+		t.Errorf("Expected ErrUserAlreadyExists, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_Validation(t *testing.T) {
+	useCase, _, user := newChangeEmailUseCase(t)
+
+	_, err := useCase.ChangeEmail(context.Background(), user.ID, "not-an-email", "")
+	assertFieldErrors(t, err, map[string]error{
+		"email":            domain.ErrInvalidEmail,
+		"current_password": domain.ErrRequiredField,
+	})
+}
+
+func TestAuthUseCase_Register_HashFailure(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	hashErr := errors.New("kdf unavailable")
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{hashError: hashErr}, jwtService)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if !errors.Is(err, hashErr) {
+		t.Fatalf("Expected hashing error to be returned, got %v", err)
+	}
+
+	var validationErr *domain.ValidationError
+	if errors.As(err, &validationErr) || errors.Is(err, domain.ErrUserAlreadyExists) {
+		t.Errorf("Expected an internal error, got client error %v", err)
+	}
+	if count, _ := mockRepo.Count(context.Background()); count != 0 {
+		t.Errorf("Expected no user to be created, got %d", count)
+	}
+}
+
+func TestAuthUseCase_Login_WithMockHasher(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService)
+
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Errorf("Expected login through the injected hasher to succeed, got %v", err)
+	}
+}
+
+// emailOfLength builds a syntactically valid address of exactly n bytes.
+func emailOfLength(n int) string {
+	const suffix = "@example.com"
+	local := ""
+	for len(local)+len(suffix) < n {
+		local += "a"
+	}
+	return local + suffix
+}
+
+func TestAuthUseCase_Register_EmailLengthBoundary(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	passwordService, _ := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+
+	atLimit := emailOfLength(domain.MaxEmailLength)
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: atLimit, Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected %d-character email to be accepted, got %v", len(atLimit), err)
+	}
+	if resp.User.Email != atLimit {
+		t.Errorf("Expected email to be stored unchanged, got %d characters", len(resp.User.Email))
+	}
+
+	overLimit := emailOfLength(domain.MaxEmailLength + 1)
+	_, err = useCase.Register(context.Background(), RegisterRequest{Email: overLimit, Password: "password123"})
+	assertFieldErrors(t, err, map[string]error{"email": domain.ErrEmailTooLong})
+}
+
+func TestAuthUseCase_PasswordPolicyImpact(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService)
+
+	// Two accounts predate version tracking, one was set under version 1.
+	mockRepo.Create(context.Background(), "legacy1@example.com", "hash", 0)
+	mockRepo.Create(context.Background(), "legacy2@example.com", "hash", 0)
+	mockRepo.Create(context.Background(), "v1@example.com", "hash", 1)
+	mockRepo.Create(context.Background(), "v2@example.com", "hash", 2)
+
+	tests := []struct {
+		name     string
+		target   int
+		expected int64
+	}{
+		{"current policy", 0, 2},
+		{"stricter policy", 2, 3},
+		{"future policy", 3, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impact, err := useCase.PasswordPolicyImpact(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if impact.TotalUsers != 4 {
+				t.Errorf("Expected 4 users, got %d", impact.TotalUsers)
+			}
+			if impact.OutdatedUsers != tt.expected {
+				t.Errorf("Expected %d outdated users, got %d", tt.expected, impact.OutdatedUsers)
+			}
+		})
+	}
+}
+
+func TestAuthUseCase_Register_RecordsPasswordPolicyVersion(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService)
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stored, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	if stored.PasswordPolicyVersion != domain.PasswordPolicyVersion {
+		t.Errorf("Expected policy version %d, got %d", domain.PasswordPolicyVersion, stored.PasswordPolicyVersion)
+	}
+}
+
+// blockingLookupRepository holds FindByEmail until release is closed, so a
+// test can pile up concurrent lookups on one query.
+type blockingLookupRepository struct {
+	*MockUserRepository
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (r *blockingLookupRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if r.calls.Add(1) == 1 {
+		close(r.entered)
+	}
+	<-r.release
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.MockUserRepository.FindByEmail(ctx, email)
+}
+
+// concurrentLookups runs n logins' email lookups at once and releases the
+// repository once they are all waiting on the first one.
+func concurrentLookups(useCase *AuthUseCase, repo *blockingLookupRepository, n int) ([]*domain.User, []error) {
+	users := make([]*domain.User, n)
+	errs := make([]error, n)
+	var started, done sync.WaitGroup
+	started.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer done.Done()
+			started.Done()
+			users[i], errs[i] = useCase.findByLoginIdentifier(context.Background(), "test@example.com")
+		}(i)
+	}
+
+	<-repo.entered
+	started.Wait()
+	// Started is not quite waiting: leave the stragglers time to join.
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	done.Wait()
+	return users, errs
+}
+
+func newBlockingLookupUseCase(t *testing.T) (*AuthUseCase, *blockingLookupRepository) {
+	t.Helper()
+
+	repo := &blockingLookupRepository{
+		MockUserRepository: NewMockUserRepository(),
+		entered:            make(chan struct{}),
+		release:            make(chan struct{}),
+	}
+	if _, err := repo.Create(context.Background(), "test@example.com", "hashed:password123", domain.PasswordPolicyVersion); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	return NewAuthUseCase(repo, &MockPasswordHasher{}, jwtService), repo
+}
+
+func TestAuthUseCase_ConcurrentLoginsShareLookup(t *testing.T) {
+	useCase, repo := newBlockingLookupUseCase(t)
+
+	users, errs := concurrentLookups(useCase, repo, 50)
+
+	if calls := repo.calls.Load(); calls != 1 {
+		t.Errorf("Expected one FindByEmail for the burst, got %d", calls)
+	}
+	for i := range users {
+		if errs[i] != nil || users[i] == nil || users[i].Email != "test@example.com" {
+			t.Fatalf("Lookup %d: expected the user, got %v, %v", i, users[i], errs[i])
+		}
+	}
+	if users[0] == users[1] {
+		t.Error("Expected each login to get its own copy of the user")
+	}
+}
+
+func TestAuthUseCase_ConcurrentLoginsShareLookupError(t *testing.T) {
+	useCase, repo := newBlockingLookupUseCase(t)
+	errDB := errors.New("database unavailable")
+	repo.err = errDB
+
+	_, errs := concurrentLookups(useCase, repo, 10)
+	for i, err := range errs {
+		if !errors.Is(err, errDB) {
+			t.Errorf("Lookup %d: expected the shared error, got %v", i, err)
+		}
+	}
+
+	// The failure is not remembered: the next login queries again.
+	repo.err = nil
+	user, err := useCase.findByLoginIdentifier(context.Background(), "test@example.com")
+	if err != nil || user == nil {
+		t.Errorf("Expected the lookup to succeed after the error, got %v", err)
+	}
+	if calls := repo.calls.Load(); calls != 2 {
+		t.Errorf("Expected a second FindByEmail, got %d calls", calls)
+	}
+}
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// WithEmailChangeConfirmation makes ChangeEmail stage the new address and
+// return a single-use token for it. The old address stays the login email
+// until ConfirmEmailChange redeems that token.
+func WithEmailChangeConfirmation(tokens domain.TokenRepository, verificationService *security.VerificationService, ttl time.Duration) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.emailChangeTokens = tokens
+		uc.verificationService = verificationService
+		uc.emailChangeTTL = ttl
+	}
+}
+
+// stageEmailChange records email as the user's pending address and issues
+// a token proving ownership of it. Tokens issued for an earlier pending
+// address stop working. An empty email cancels the change.
+func (uc *AuthUseCase) stageEmailChange(ctx context.Context, user *domain.User, email string) (string, error) {
+	if err := uc.userRepo.SetPendingEmail(ctx, user.ID, email); err != nil {
+		return "", contextError(err)
+	}
+
+	now := uc.clock.Now()
+	if err := uc.emailChangeTokens.InvalidateUnused(ctx, user.ID, domain.TokenPurposeEmailChange, now); err != nil {
+		return "", contextError(err)
+	}
+	if email == "" {
+		return "", nil
+	}
+
+	token, tokenHash, err := uc.verificationService.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.emailChangeTokens.Create(ctx, &domain.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposeEmailChange,
+		TokenHash: tokenHash,
+		ExpiresAt: now.Add(uc.emailChangeTTL),
+	})
+	if err != nil {
+		return "", contextError(err)
+	}
+
+	if uc.mailer != nil {
+		subject, body := emailChangeEmail(emailLink(uc.linkBaseURL, confirmEmailChangePath, token), uc.emailChangeTTL)
+		if err := uc.mailer.Send(email, subject, body); err != nil {
+			return "", err
+		}
+	}
+
+	return token, nil
+}
+
+// ConfirmEmailChange redeems a token from ChangeEmail and switches the
+// account to its pending address, which counts as verified.
+func (uc *AuthUseCase) ConfirmEmailChange(ctx context.Context, token string) error {
+	if uc.emailChangeTokens == nil || token == "" {
+		return domain.ErrOneTimeTokenInvalid
+	}
+
+	stored, err := uc.emailChangeTokens.FindByHash(ctx, domain.TokenPurposeEmailChange, uc.verificationService.HashToken(token))
+	if err != nil {
+		return contextError(err)
+	}
+
+	if stored.UsedAt != nil {
+		return domain.ErrOneTimeTokenUsed
+	}
+
+	now := uc.clock.Now()
+	if now.After(stored.ExpiresAt) {
+		return domain.ErrOneTimeTokenExpired
+	}
+
+	if err := uc.emailChangeTokens.MarkUsed(ctx, stored.ID, now); err != nil {
+		return contextError(err)
+	}
+
+	return contextError(uc.userRepo.ConfirmPendingEmail(ctx, stored.UserID))
+}
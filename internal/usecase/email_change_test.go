@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newEmailChangeUseCase(t *testing.T) (*AuthUseCase, *MockUserRepository, *domain.User) {
+	t.Helper()
+
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService,
+		WithEmailChangeConfirmation(NewMockTokenRepository(), security.NewVerificationService(), time.Hour),
+	)
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "old@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	user, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	return useCase, mockRepo, user
+}
+
+func assertLogin(t *testing.T, useCase *AuthUseCase, email string, want error) {
+	t.Helper()
+
+	_, err := useCase.Login(context.Background(), LoginRequest{Email: email, Password: "password123"})
+	if !errors.Is(err, want) {
+		t.Errorf("Login as %s: expected %v, got %v", email, want, err)
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_PendingUntilConfirmed(t *testing.T) {
+	useCase, mockRepo, user := newEmailChangeUseCase(t)
+
+	token, err := useCase.ChangeEmail(context.Background(), user.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token == "" {
+		t.Fatal("Expected a confirmation token")
+	}
+
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.Email != "old@example.com" || stored.PendingEmail != "new@example.com" {
+		t.Fatalf("Expected new address to be pending, got %+v", stored)
+	}
+	assertLogin(t, useCase, "old@example.com", nil)
+	assertLogin(t, useCase, "new@example.com", domain.ErrInvalidCredentials)
+}
+
+func TestAuthUseCase_ConfirmEmailChange(t *testing.T) {
+	useCase, mockRepo, user := newEmailChangeUseCase(t)
+
+	token, err := useCase.ChangeEmail(context.Background(), user.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.ConfirmEmailChange(context.Background(), token); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.Email != "new@example.com" || stored.PendingEmail != "" || !stored.EmailVerified {
+		t.Fatalf("Expected verified new address only, got %+v", stored)
+	}
+	assertLogin(t, useCase, "new@example.com", nil)
+	assertLogin(t, useCase, "old@example.com", domain.ErrInvalidCredentials)
+
+	if err := useCase.ConfirmEmailChange(context.Background(), token); !errors.Is(err, domain.ErrOneTimeTokenUsed) {
+		t.Errorf("Expected ErrOneTimeTokenUsed, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ConfirmEmailChange_Expired(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithEmailChangeConfirmation(NewMockTokenRepository(), security.NewVerificationService(), time.Hour),
+		WithClock(now),
+	)
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "old@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	token, err := useCase.ChangeEmail(context.Background(), resp.User.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	now.Advance(time.Hour + time.Second)
+	if err := useCase.ConfirmEmailChange(context.Background(), token); !errors.Is(err, domain.ErrOneTimeTokenExpired) {
+		t.Errorf("Expected ErrOneTimeTokenExpired, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_NewRequestInvalidatesOldToken(t *testing.T) {
+	useCase, mockRepo, user := newEmailChangeUseCase(t)
+
+	first, err := useCase.ChangeEmail(context.Background(), user.ID, "first@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := useCase.ChangeEmail(context.Background(), user.ID, "second@example.com", "password123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.ConfirmEmailChange(context.Background(), first); !errors.Is(err, domain.ErrOneTimeTokenUsed) {
+		t.Errorf("Expected the first token to be invalidated, got %v", err)
+	}
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.Email != "old@example.com" {
+		t.Errorf("Expected email to be unchanged, got %s", stored.Email)
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_CurrentAddressCancels(t *testing.T) {
+	useCase, mockRepo, user := newEmailChangeUseCase(t)
+
+	token, err := useCase.ChangeEmail(context.Background(), user.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := useCase.ChangeEmail(context.Background(), user.ID, "old@example.com", "password123"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.PendingEmail != "" {
+		t.Errorf("Expected pending address to be cleared, got %q", stored.PendingEmail)
+	}
+	if err := useCase.ConfirmEmailChange(context.Background(), token); err == nil {
+		t.Error("Expected the cancelled change's token to be rejected")
+	}
+}
+
+func TestAuthUseCase_ConfirmEmailChange_AddressTakenMeanwhile(t *testing.T) {
+	useCase, mockRepo, user := newEmailChangeUseCase(t)
+
+	token, err := useCase.ChangeEmail(context.Background(), user.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "new@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register second user: %v", err)
+	}
+
+	if err := useCase.ConfirmEmailChange(context.Background(), token); !errors.Is(err, domain.ErrUserAlreadyExists) {
+		t.Fatalf("Expected ErrUserAlreadyExists, got %v", err)
+	}
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.Email != "old@example.com" {
+		t.Errorf("Expected old address to remain the login email, got %s", stored.Email)
+	}
+}
+
+func TestAuthUseCase_ChangeEmail_MailsNewAddress(t *testing.T) {
+	mockRepo := NewMockUserRepository()
+	mailer := &MockMailer{}
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, security.NewJWTService("test-secret", "test-issuer", time.Hour),
+		WithEmailChangeConfirmation(NewMockTokenRepository(), security.NewVerificationService(), time.Hour),
+		WithMailer(mailer, "https://auth.example.com"),
+	)
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "old@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	token, err := useCase.ChangeEmail(context.Background(), resp.User.ID, "new@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "new@example.com" {
+		t.Fatalf("Expected one email to the new address, got %+v", mailer.sent)
+	}
+	if link := "https://auth.example.com/api/auth/confirm-email?token=" + token; !strings.Contains(mailer.sent[0].Body, link) {
+		t.Errorf("Expected body to contain %s, got %q", link, mailer.sent[0].Body)
+	}
+}
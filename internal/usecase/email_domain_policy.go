@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"strings"
+)
+
+// EmailDomainPolicy restricts the domains accounts can use. When Allow is
+// set, only its domains are accepted; Deny blocks its domains even if Allow
+// lists them too. Domains match exactly, ignoring case: allowing
+// example.com does not allow mail.example.com. The zero value accepts
+// every domain.
+type EmailDomainPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// WithEmailDomainPolicy makes Register and ChangeEmail refuse addresses the
+// policy rejects with ErrEmailDomainNotAllowed.
+func WithEmailDomainPolicy(policy EmailDomainPolicy) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.emailDomainPolicy = EmailDomainPolicy{
+			Allow: normalizeDomains(policy.Allow),
+			Deny:  normalizeDomains(policy.Deny),
+		}
+	}
+}
+
+// Allows reports whether the domain part of email passes the policy.
+func (p EmailDomainPolicy) Allows(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := normalizeDomain(email[at+1:])
+
+	if containsDomain(p.Deny, domain) {
+		return false
+	}
+	return len(p.Allow) == 0 || containsDomain(p.Allow, domain)
+}
+
+func containsDomain(domains []string, domain string) bool {
+	for _, d := range domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDomain also accepts domains written with a leading "@".
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = normalizeDomain(d); d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	return normalized
+}
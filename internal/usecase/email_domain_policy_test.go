@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func TestEmailDomainPolicy_Allows(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   EmailDomainPolicy
+		email    string
+		expected bool
+	}{
+		{"no lists", EmailDomainPolicy{}, "user@anything.example", true},
+		{"allowed domain", EmailDomainPolicy{Allow: []string{"corp.example"}}, "user@corp.example", true},
+		{"allowed domain, other case", EmailDomainPolicy{Allow: []string{"Corp.Example"}}, "user@CORP.example", true},
+		{"outside the allowlist", EmailDomainPolicy{Allow: []string{"corp.example"}}, "user@gmail.example", false},
+		{"subdomain of an allowed domain", EmailDomainPolicy{Allow: []string{"corp.example"}}, "user@mail.corp.example", false},
+		{"blocked domain", EmailDomainPolicy{Deny: []string{"@mailinator.example"}}, "user@Mailinator.example", false},
+		{"outside the denylist", EmailDomainPolicy{Deny: []string{"mailinator.example"}}, "user@corp.example", true},
+		{"both lists, allowed", EmailDomainPolicy{Allow: []string{"corp.example", "partner.example"}, Deny: []string{"partner.example"}}, "user@corp.example", true},
+		{"both lists, denied wins", EmailDomainPolicy{Allow: []string{"corp.example", "partner.example"}, Deny: []string{"partner.example"}}, "user@partner.example", false},
+		{"both lists, in neither", EmailDomainPolicy{Allow: []string{"corp.example"}, Deny: []string{"mailinator.example"}}, "user@gmail.example", false},
+	}
+
+	for _, tt := range tests {
+		var uc AuthUseCase
+		WithEmailDomainPolicy(tt.policy)(&uc)
+		if got := uc.emailDomainPolicy.Allows(tt.email); got != tt.expected {
+			t.Errorf("%s: expected %v for %s, got %v", tt.name, tt.expected, tt.email, got)
+		}
+	}
+}
+
+func TestAuthUseCase_Register_EmailDomainPolicy(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithEmailDomainPolicy(EmailDomainPolicy{Allow: []string{"corp.example"}}))
+	ctx := context.Background()
+
+	if _, err := useCase.Register(ctx, RegisterRequest{Email: "blocked@gmail.example", Password: "password123"}); !errors.Is(err, domain.ErrEmailDomainNotAllowed) {
+		t.Errorf("Expected ErrEmailDomainNotAllowed, got %v", err)
+	}
+
+	resp, err := useCase.Register(ctx, RegisterRequest{Email: "Employee@Corp.Example", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// An email change cannot leave the allowed domains either.
+	if _, err := useCase.ChangeEmail(ctx, resp.User.ID, "employee@gmail.example", "password123"); !errors.Is(err, domain.ErrEmailDomainNotAllowed) {
+		t.Errorf("Expected ErrEmailDomainNotAllowed, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// contextError marks an error caused by the request's context ending as
+// domain.ErrTimeout, keeping the context error in the chain so callers can
+// tell a canceled request from an expired deadline. Other errors are
+// returned unchanged.
+func contextError(err error) error {
+	if err == nil || errors.Is(err, domain.ErrTimeout) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", domain.ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// Introspect checks an access token the way the auth middleware does, for
+// services that validate our tokens without holding the signing key. Every
+// reason a token fails, including its user being deleted or having changed
+// their password since, is ErrInvalidToken: the caller only learns that the
+// token is not active.
+func (uc *AuthUseCase) Introspect(ctx context.Context, token string) (*security.Claims, error) {
+	if token == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	claims, err := uc.jwtService.ValidateToken(token)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, contextError(err)
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// LoginBackoffConfig sets the delay added to a failed login: BaseDelay after
+// the first consecutive failure for an identifier, multiplied by Multiplier
+// for each further one, up to MaxDelay. A zero MaxDelay means
+// DefaultLoginBackoffMax: the delay is always capped.
+type LoginBackoffConfig struct {
+	BaseDelay  time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration
+	// Clock defaults to the system clock.
+	Clock clock.Clock
+}
+
+// DefaultLoginBackoffMax keeps the delay well under the default request
+// timeout, so a slowed-down login still gets its 401.
+const DefaultLoginBackoffMax = 5 * time.Second
+
+// loginFailureMemory is how long an identifier's failures are remembered
+// after the last one.
+const loginFailureMemory = time.Hour
+
+// WithLoginBackoff slows down repeated failed logins for one identifier
+// instead of locking the account. A zero BaseDelay disables it.
+func WithLoginBackoff(config LoginBackoffConfig) AuthOption {
+	return func(uc *AuthUseCase) {
+		if config.BaseDelay <= 0 {
+			uc.loginBackoff = nil
+			return
+		}
+		uc.loginBackoff = newLoginBackoff(config)
+	}
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+// attemptTracker counts consecutive failed logins per key, or any other
+// event that has to be remembered for a while. The counters are in memory,
+// per instance. A key's count is forgotten once it has gone
+// memory without a failure; idle keys are swept lazily, as in RateLimiter.
+type attemptTracker struct {
+	memory    time.Duration
+	clock     clock.Clock
+	mu        sync.Mutex
+	failures  map[string]*loginFailures
+	lastSweep time.Time
+}
+
+func newAttemptTracker(memory time.Duration, c clock.Clock) *attemptTracker {
+	return &attemptTracker{
+		memory:   memory,
+		clock:    clock.OrReal(c),
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// fail records a failure for key and returns its consecutive count.
+func (t *attemptTracker) fail(key string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.sweep(now)
+
+	entry, ok := t.failures[key]
+	if !ok || now.Sub(entry.last) > t.memory {
+		entry = &loginFailures{}
+		t.failures[key] = entry
+	}
+	entry.count++
+	entry.last = now
+	return entry.count
+}
+
+// count returns the count for key and when it last failed, or zero once
+// the count has been forgotten.
+func (t *attemptTracker) count(key string) (int, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.failures[key]
+	if !ok || t.clock.Now().Sub(entry.last) > t.memory {
+		return 0, time.Time{}
+	}
+	return entry.count, entry.last
+}
+
+func (t *attemptTracker) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, key)
+}
+
+func (t *attemptTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.memory {
+		return
+	}
+	for key, entry := range t.failures {
+		if now.Sub(entry.last) > t.memory {
+			delete(t.failures, key)
+		}
+	}
+	t.lastSweep = now
+}
+
+// loginBackoff turns an identifier's consecutive failures into a delay.
+type loginBackoff struct {
+	config   LoginBackoffConfig
+	attempts *attemptTracker
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func newLoginBackoff(config LoginBackoffConfig) *loginBackoff {
+	if config.Multiplier < 1 {
+		config.Multiplier = 1
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultLoginBackoffMax
+	}
+	return &loginBackoff{
+		config:   config,
+		attempts: newAttemptTracker(loginFailureMemory, config.Clock),
+		sleep:    sleepContext,
+	}
+}
+
+// fail records a failure for key and returns the delay to apply to it.
+func (b *loginBackoff) fail(key string) time.Duration {
+	return b.delay(b.attempts.fail(key))
+}
+
+func (b *loginBackoff) delay(failures int) time.Duration {
+	// Compared as a float: after enough failures the product no longer
+	// fits in a Duration.
+	delay := float64(b.config.BaseDelay) * math.Pow(b.config.Multiplier, float64(failures-1))
+	if delay > float64(b.config.MaxDelay) {
+		return b.config.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+func (b *loginBackoff) reset(key string) {
+	b.attempts.reset(key)
+}
+
+// failLogin counts the failure against the login throttle and, for a known
+// user, in its stored failed_attempts, applies the backoff delay for
+// account, from loginAccountKey, and returns the error for the failed
+// login. The wait ends early if the client goes away.
+func (uc *AuthUseCase) failLogin(ctx context.Context, account string, user *domain.User) error {
+	if user != nil {
+		if count, err := uc.userRepo.IncrementFailedAttempts(ctx, user.ID); err == nil {
+			user.FailedAttempts = count
+		}
+	}
+	if uc.loginThrottle != nil {
+		uc.loginThrottle.fail(domain.ClientIPFromContext(ctx), account)
+	}
+	if uc.loginBackoff == nil {
+		return domain.ErrInvalidCredentials
+	}
+	if err := uc.loginBackoff.sleep(ctx, uc.loginBackoff.fail(account)); err != nil {
+		return contextError(err)
+	}
+	return domain.ErrInvalidCredentials
+}
+
+// clearLoginFailures forgets the failures counted by failLogin once user
+// has proven who they are.
+func (uc *AuthUseCase) clearLoginFailures(ctx context.Context, account string, user *domain.User) {
+	if uc.loginBackoff != nil {
+		uc.loginBackoff.reset(account)
+	}
+	if uc.loginThrottle != nil {
+		uc.loginThrottle.byAccount.reset(account)
+	}
+	if user.FailedAttempts > 0 {
+		// Like the rehash, a failed write must not fail the login.
+		if err := uc.userRepo.ResetFailedAttempts(ctx, user.ID); err == nil {
+			user.FailedAttempts = 0
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
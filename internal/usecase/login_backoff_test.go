@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// newBackoffUseCase records the delays instead of sleeping.
+func newBackoffUseCase(t *testing.T) (*AuthUseCase, *[]time.Duration) {
+	t.Helper()
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithLoginBackoff(LoginBackoffConfig{
+		BaseDelay:  100 * time.Millisecond,
+		Multiplier: 2,
+		MaxDelay:   time.Second,
+	}))
+
+	var delays []time.Duration
+	useCase.loginBackoff.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	return useCase, &delays
+}
+
+func TestAuthUseCase_Login_BackoffGrows(t *testing.T) {
+	useCase, delays := newBackoffUseCase(t)
+
+	for i := 0; i < 6; i++ {
+		_, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+		if err != domain.ErrInvalidCredentials {
+			t.Fatalf("Expected ErrInvalidCredentials, got %v", err)
+		}
+	}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	if len(*delays) != len(expected) {
+		t.Fatalf("Expected %d delays, got %v", len(expected), *delays)
+	}
+	for i, d := range *delays {
+		if d != expected[i] {
+			t.Errorf("Failure %d: expected a delay of %s, got %s", i+1, expected[i], d)
+		}
+	}
+
+	// Another identifier has its own counter, even for an unknown account.
+	useCase.Login(context.Background(), LoginRequest{Email: "unknown@example.com", Password: "password123"})
+	if got := (*delays)[len(*delays)-1]; got != 100*time.Millisecond {
+		t.Errorf("Expected a fresh counter for another identifier, got %s", got)
+	}
+}
+
+func TestAuthUseCase_Login_SuccessResetsBackoff(t *testing.T) {
+	useCase, delays := newBackoffUseCase(t)
+
+	useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+	useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+	useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+
+	if len(*delays) != 3 || (*delays)[2] != 100*time.Millisecond {
+		t.Errorf("Expected the delay to start over after a success, got %v", *delays)
+	}
+}
+
+func TestLoginBackoff_ForgetsIdleIdentifiers(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	backoff := newLoginBackoff(LoginBackoffConfig{BaseDelay: time.Second, Multiplier: 2, MaxDelay: time.Minute, Clock: now})
+
+	backoff.fail("test@example.com")
+	if d := backoff.fail("test@example.com"); d != 2*time.Second {
+		t.Fatalf("Expected the second failure to wait 2s, got %s", d)
+	}
+
+	now.Advance(loginFailureMemory + time.Second)
+	if d := backoff.fail("test@example.com"); d != time.Second {
+		t.Errorf("Expected failures to be forgotten after %s, got a delay of %s", loginFailureMemory, d)
+	}
+}
+
+func TestLoginBackoff_CappedWithoutMaxDelay(t *testing.T) {
+	backoff := newLoginBackoff(LoginBackoffConfig{BaseDelay: time.Second, Multiplier: 10})
+
+	for i := 1; i <= 100; i++ {
+		if d := backoff.delay(i); d <= 0 || d > DefaultLoginBackoffMax {
+			t.Fatalf("Failure %d: expected a delay capped at %s, got %s", i, DefaultLoginBackoffMax, d)
+		}
+	}
+}
+
+func TestAuthUseCase_Login_BackoffAbortsOnDisconnect(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithLoginBackoff(LoginBackoffConfig{
+		BaseDelay:  time.Hour,
+		Multiplier: 2,
+		MaxDelay:   time.Hour,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := useCase.Login(ctx, LoginRequest{Email: "unknown@example.com", Password: "password123"})
+	if !errors.Is(err, domain.ErrTimeout) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to end with the request, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_CountsFailedAttempts(t *testing.T) {
+	useCase, _ := newBackoffUseCase(t)
+	ctx := context.Background()
+
+	useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+	useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "wrongpassword"})
+
+	user, err := useCase.userRepo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to find user: %v", err)
+	}
+	if user.FailedAttempts != 2 {
+		t.Errorf("Expected 2 failed attempts, got %d", user.FailedAttempts)
+	}
+
+	if _, err := useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Expected login to succeed, got %v", err)
+	}
+	user, err = useCase.userRepo.FindByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("Failed to find user: %v", err)
+	}
+	if user.FailedAttempts != 0 {
+		t.Errorf("Expected failed attempts to be reset after a success, got %d", user.FailedAttempts)
+	}
+}
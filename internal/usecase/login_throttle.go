@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// LoginThrottleConfig refuses logins once too many have failed from one IP,
+// whatever the account, or against one account, whatever the IP. A zero
+// maximum disables that side.
+type LoginThrottleConfig struct {
+	MaxPerIP      int
+	MaxPerAccount int
+	// Window is how long a count lasts after its last failure, and so the
+	// longest a throttle holds.
+	Window time.Duration
+	// Clock defaults to the system clock.
+	Clock clock.Clock
+}
+
+// WithLoginThrottle enables the login throttle. It is disabled when both
+// maximums are zero or Window is not positive.
+func WithLoginThrottle(config LoginThrottleConfig) AuthOption {
+	return func(uc *AuthUseCase) {
+		if config.Window <= 0 || (config.MaxPerIP <= 0 && config.MaxPerAccount <= 0) {
+			uc.loginThrottle = nil
+			return
+		}
+		uc.loginThrottle = newLoginThrottle(config)
+	}
+}
+
+// loginThrottle keeps one attempt tracker keyed by client IP and another
+// keyed by login identifier.
+type loginThrottle struct {
+	config    LoginThrottleConfig
+	clock     clock.Clock
+	byIP      *attemptTracker
+	byAccount *attemptTracker
+}
+
+func newLoginThrottle(config LoginThrottleConfig) *loginThrottle {
+	return &loginThrottle{
+		config:    config,
+		clock:     clock.OrReal(config.Clock),
+		byIP:      newAttemptTracker(config.Window, config.Clock),
+		byAccount: newAttemptTracker(config.Window, config.Clock),
+	}
+}
+
+// check returns a *domain.LoginThrottledError when either limit is reached.
+// An empty ip skips the per-IP limit.
+func (t *loginThrottle) check(ip, account string) error {
+	retryAfter := t.retryAfter(t.byAccount, account, t.config.MaxPerAccount)
+	if ip != "" {
+		retryAfter = max(retryAfter, t.retryAfter(t.byIP, ip, t.config.MaxPerIP))
+	}
+	if retryAfter > 0 {
+		return &domain.LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (t *loginThrottle) retryAfter(tracker *attemptTracker, key string, limit int) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	count, last := tracker.count(key)
+	if count < limit {
+		return 0
+	}
+	return max(last.Add(t.config.Window).Sub(t.clock.Now()), time.Second)
+}
+
+func (t *loginThrottle) fail(ip, account string) {
+	if ip != "" && t.config.MaxPerIP > 0 {
+		t.byIP.fail(ip)
+	}
+	if t.config.MaxPerAccount > 0 {
+		t.byAccount.fail(account)
+	}
+}
+
+// checkLoginThrottle runs before the password is checked, so a throttled
+// login costs no hashing. account comes from loginAccountKey.
+func (uc *AuthUseCase) checkLoginThrottle(ctx context.Context, account string) error {
+	if uc.loginThrottle == nil {
+		return nil
+	}
+	return uc.loginThrottle.check(domain.ClientIPFromContext(ctx), account)
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newThrottleUseCase(t *testing.T, config LoginThrottleConfig) *AuthUseCase {
+	t.Helper()
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithLoginThrottle(config))
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		if _, err := useCase.Register(context.Background(), RegisterRequest{Email: email, Password: "password123"}); err != nil {
+			t.Fatalf("Failed to register %s: %v", email, err)
+		}
+	}
+	return useCase
+}
+
+func loginFrom(useCase *AuthUseCase, ip, email, password string) error {
+	ctx := domain.ContextWithClientIP(context.Background(), ip)
+	_, err := useCase.Login(ctx, LoginRequest{Email: email, Password: password})
+	return err
+}
+
+func TestAuthUseCase_Login_ThrottlesIPAcrossAccounts(t *testing.T) {
+	useCase := newThrottleUseCase(t, LoginThrottleConfig{MaxPerIP: 3, MaxPerAccount: 10, Window: time.Minute})
+
+	for _, email := range []string{"alice@example.com", "bob@example.com", "unknown@example.com"} {
+		if err := loginFrom(useCase, "203.0.113.7", email, "wrongpassword"); err != domain.ErrInvalidCredentials {
+			t.Fatalf("Expected ErrInvalidCredentials for %s, got %v", email, err)
+		}
+	}
+
+	err := loginFrom(useCase, "203.0.113.7", "alice@example.com", "password123")
+	var throttled *domain.LoginThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, domain.ErrLoginThrottled) {
+		t.Fatalf("Expected the IP to be throttled even with the right password, got %v", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Minute {
+		t.Errorf("Expected a retry delay within the window, got %s", throttled.RetryAfter)
+	}
+
+	if err := loginFrom(useCase, "198.51.100.1", "alice@example.com", "password123"); err != nil {
+		t.Errorf("Expected another IP to log in, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_ThrottlesAccountAcrossIPs(t *testing.T) {
+	now := clock.NewFake(time.Now())
+	useCase := newThrottleUseCase(t, LoginThrottleConfig{MaxPerIP: 10, MaxPerAccount: 3, Window: time.Minute, Clock: now})
+
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		if err := loginFrom(useCase, ip, "alice@example.com", "wrongpassword"); err != domain.ErrInvalidCredentials {
+			t.Fatalf("Expected ErrInvalidCredentials from %s, got %v", ip, err)
+		}
+	}
+
+	if err := loginFrom(useCase, "203.0.113.4", "alice@example.com", "password123"); !errors.Is(err, domain.ErrLoginThrottled) {
+		t.Fatalf("Expected the account to be throttled from a new IP, got %v", err)
+	}
+	if err := loginFrom(useCase, "203.0.113.4", "bob@example.com", "password123"); err != nil {
+		t.Errorf("Expected another account to log in, got %v", err)
+	}
+
+	now.Advance(time.Minute + time.Second)
+	if err := loginFrom(useCase, "203.0.113.4", "alice@example.com", "password123"); err != nil {
+		t.Errorf("Expected the throttle to lift after the window, got %v", err)
+	}
+}
+
+func TestWithLoginThrottle_Disabled(t *testing.T) {
+	useCase := newThrottleUseCase(t, LoginThrottleConfig{Window: time.Minute})
+	if useCase.loginThrottle != nil {
+		t.Error("Expected no throttle when both maximums are zero")
+	}
+}
+
+func TestAuthUseCase_Login_ThrottlesAccountAcrossIdentifiers(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithLoginThrottle(LoginThrottleConfig{MaxPerIP: 10, MaxPerAccount: 3, Window: time.Minute}))
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "alice@example.com", Username: "alice", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+
+	// Email and username name the same account, so they share its budget.
+	for _, identifier := range []string{"alice@example.com", "alice", "ALICE@example.com"} {
+		ctx := domain.ContextWithClientIP(context.Background(), "203.0.113.7")
+		if _, err := useCase.Login(ctx, LoginRequest{Identifier: identifier, Password: "wrongpassword"}); err != domain.ErrInvalidCredentials {
+			t.Fatalf("Expected ErrInvalidCredentials for %s, got %v", identifier, err)
+		}
+	}
+
+	ctx := domain.ContextWithClientIP(context.Background(), "198.51.100.1")
+	if _, err := useCase.Login(ctx, LoginRequest{Identifier: "alice", Password: "password123"}); !errors.Is(err, domain.ErrLoginThrottled) {
+		t.Errorf("Expected the account to be throttled whatever the identifier, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Paths the emailed links point to, relative to the public base URL.
+const (
+	verifyEmailPath        = "/api/auth/verify"
+	confirmEmailChangePath = "/api/auth/confirm-email"
+	resetPasswordPath      = "/reset-password"
+)
+
+// emailLink builds the absolute link carrying token that a user follows from
+// an email.
+func emailLink(baseURL, path, token string) string {
+	return strings.TrimRight(baseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func verificationEmail(link string, ttl time.Duration) (string, string) {
+	return "Verify your email address", fmt.Sprintf(
+		"Open this link to verify your email address:\n\n%s\n\nThe link expires in %s. If you did not create an account, you can ignore this message.\n",
+		link, ttl)
+}
+
+func emailChangeEmail(link string, ttl time.Duration) (string, string) {
+	return "Confirm your new email address", fmt.Sprintf(
+		"Open this link to make this address the one you sign in with:\n\n%s\n\nThe link expires in %s. Until then your previous address keeps working. If you did not ask for this change, you can ignore this message.\n",
+		link, ttl)
+}
+
+func passwordResetEmail(link string, ttl time.Duration) (string, string) {
+	return "Reset your password", fmt.Sprintf(
+		"Open this link to choose a new password:\n\n%s\n\nThe link expires in %s and works once. If you did not ask for a reset, you can ignore this message; your password has not changed.\n",
+		link, ttl)
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/oauth"
+)
+
+// WithIdentityProviders enables sign-in through the given providers, which
+// StartOAuth and CompleteOAuth look up by name.
+func WithIdentityProviders(providers ...domain.IdentityProvider) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.identityProviders = make(map[string]domain.IdentityProvider, len(providers))
+		for _, provider := range providers {
+			uc.identityProviders[provider.Name()] = provider
+		}
+	}
+}
+
+// OAuthRedirect starts a sign-in: the client is sent to URL, and State and
+// CodeVerifier must be kept until the provider redirects back.
+type OAuthRedirect struct {
+	URL          string
+	State        string
+	CodeVerifier string
+}
+
+func (uc *AuthUseCase) StartOAuth(providerName string) (*OAuthRedirect, error) {
+	provider, ok := uc.identityProviders[providerName]
+	if !ok {
+		return nil, domain.ErrUnknownIdentityProvider
+	}
+
+	state, err := oauth.NewState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oauth.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthRedirect{
+		URL:          provider.AuthCodeURL(state, oauth.CodeChallenge(verifier)),
+		State:        state,
+		CodeVerifier: verifier,
+	}, nil
+}
+
+// CompleteOAuth redeems the code the provider redirected back with and
+// signs in the account with the provider's email, creating it without a
+// password if there is none. The provider must have verified the email:
+// otherwise anyone could take over an account by claiming its address
+// with the provider.
+func (uc *AuthUseCase) CompleteOAuth(ctx context.Context, providerName, code, codeVerifier string) (*AuthResponse, error) {
+	provider, ok := uc.identityProviders[providerName]
+	if !ok {
+		return nil, domain.ErrUnknownIdentityProvider
+	}
+	if code == "" {
+		return nil, domain.ErrOAuthFailed
+	}
+
+	identity, err := provider.Exchange(ctx, code, codeVerifier)
+	if err != nil {
+		if errors.Is(err, domain.ErrOAuthFailed) {
+			return nil, err
+		}
+		return nil, contextError(err)
+	}
+	if !identity.EmailVerified {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	email := normalizeEmail(identity.Email)
+	user, err := uc.userRepo.FindByEmail(ctx, email)
+	if err == domain.ErrUserNotFound {
+		user, err = uc.createExternalUser(ctx, email, provider.Name())
+	}
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	if user.TOTPEnabled {
+		return uc.totpChallenge(user)
+	}
+	return uc.completeLogin(ctx, user)
+}
+
+func (uc *AuthUseCase) createExternalUser(ctx context.Context, email, provider string) (*domain.User, error) {
+	if !uc.emailDomainPolicy.Allows(email) {
+		return nil, domain.ErrEmailDomainNotAllowed
+	}
+
+	user, err := uc.userRepo.CreateExternal(ctx, email, provider)
+	if err != nil {
+		return nil, err
+	}
+	uc.recordAudit(ctx, domain.AuditActionRegister, user.ID, user.Email)
+
+	if err := uc.runRegisterHooks(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
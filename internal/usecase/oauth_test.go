@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/oauth"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// fakeIdentityProvider redeems any code for identity, as long as the
+// verifier matches the challenge it was last sent.
+type fakeIdentityProvider struct {
+	identity  domain.ExternalIdentity
+	challenge string
+}
+
+func (p *fakeIdentityProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeIdentityProvider) AuthCodeURL(state, codeChallenge string) string {
+	p.challenge = codeChallenge
+	return "https://idp.example/auth?state=" + url.QueryEscape(state)
+}
+
+func (p *fakeIdentityProvider) Exchange(ctx context.Context, code, codeVerifier string) (*domain.ExternalIdentity, error) {
+	if oauth.CodeChallenge(codeVerifier) != p.challenge {
+		return nil, domain.ErrOAuthFailed
+	}
+	identity := p.identity
+	return &identity, nil
+}
+
+func newOAuthTestUseCase(identity domain.ExternalIdentity) (*AuthUseCase, *fakeIdentityProvider) {
+	provider := &fakeIdentityProvider{identity: identity}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	return NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithIdentityProviders(provider)), provider
+}
+
+func TestAuthUseCase_OAuth_CreatesThenSignsIn(t *testing.T) {
+	useCase, _ := newOAuthTestUseCase(domain.ExternalIdentity{Subject: "1", Email: "Alice@Example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	redirect, err := useCase.StartOAuth("fake")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if redirect.State == "" || redirect.CodeVerifier == "" || redirect.State == redirect.CodeVerifier {
+		t.Fatalf("Expected a distinct random state and verifier, got %+v", redirect)
+	}
+
+	first, err := useCase.CompleteOAuth(ctx, "fake", "code", redirect.CodeVerifier)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.AccessToken == "" || first.User.Email != "alice@example.com" {
+		t.Errorf("Expected a token for alice@example.com, got %+v", first)
+	}
+
+	user, _ := useCase.userRepo.FindByEmail(ctx, "alice@example.com")
+	if user.Provider != "fake" || user.PasswordHash != "" || !user.EmailVerified {
+		t.Errorf("Expected a verified account without a password, got %+v", user)
+	}
+
+	redirect, _ = useCase.StartOAuth("fake")
+	second, err := useCase.CompleteOAuth(ctx, "fake", "code", redirect.CodeVerifier)
+	if err != nil || second.User.ID != first.User.ID {
+		t.Errorf("Expected the same account on the next sign-in, got %+v, %v", second, err)
+	}
+
+	// Without a password, the account cannot be signed into with one.
+	if _, err := useCase.Login(ctx, LoginRequest{Email: "alice@example.com", Password: ""}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := useCase.Login(ctx, LoginRequest{Email: "alice@example.com", Password: "hashed:"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestAuthUseCase_OAuth_SignsInExistingAccount(t *testing.T) {
+	useCase, _ := newOAuthTestUseCase(domain.ExternalIdentity{Subject: "1", Email: "bob@example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	registered, err := useCase.Register(ctx, RegisterRequest{Email: "bob@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+
+	redirect, _ := useCase.StartOAuth("fake")
+	resp, err := useCase.CompleteOAuth(ctx, "fake", "code", redirect.CodeVerifier)
+	if err != nil || resp.User.ID != registered.User.ID {
+		t.Errorf("Expected the registered account, got %+v, %v", resp, err)
+	}
+}
+
+func TestAuthUseCase_OAuth_Refusals(t *testing.T) {
+	ctx := context.Background()
+
+	useCase, _ := newOAuthTestUseCase(domain.ExternalIdentity{Subject: "1", Email: "eve@example.com", EmailVerified: false})
+	if _, err := useCase.StartOAuth("github"); !errors.Is(err, domain.ErrUnknownIdentityProvider) {
+		t.Errorf("Expected ErrUnknownIdentityProvider, got %v", err)
+	}
+
+	redirect, _ := useCase.StartOAuth("fake")
+	if _, err := useCase.CompleteOAuth(ctx, "fake", "code", "not-the-verifier"); !errors.Is(err, domain.ErrOAuthFailed) {
+		t.Errorf("Expected ErrOAuthFailed, got %v", err)
+	}
+	if _, err := useCase.CompleteOAuth(ctx, "fake", "code", redirect.CodeVerifier); !errors.Is(err, domain.ErrEmailNotVerified) {
+		t.Errorf("Expected ErrEmailNotVerified for an unverified email, got %v", err)
+	}
+	if _, err := useCase.userRepo.FindByEmail(ctx, "eve@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected no account to be created, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword re-authenticates with the current password, stores the new
+// one and revokes every token issued before: token_version is bumped, which
+// AuthMiddleware and Refresh check, and the user's sessions are ended. The
+// response carries fresh tokens so the caller stays signed in.
+func (uc *AuthUseCase) ChangePassword(ctx context.Context, userID int64, req ChangePasswordRequest) (*AuthResponse, error) {
+	validation := &domain.ValidationError{}
+	if req.CurrentPassword == "" {
+		validation.Add("current_password", domain.ErrRequiredField)
+	}
+	if req.NewPassword == "" {
+		validation.Add("new_password", domain.ErrRequiredField)
+	} else if err := validatePassword(req.NewPassword); err != nil {
+		validation.Add("new_password", err)
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	if err := uc.passwordService.Verify(user.PasswordHash, req.CurrentPassword); err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err := uc.passwordHistory.check(ctx, uc.passwordService, user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	replacedHash := user.PasswordHash
+
+	hashedPassword, err := uc.passwordService.Hash(req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := uc.userRepo.ChangePassword(ctx, user.ID, hashedPassword, domain.PasswordPolicyVersion)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	uc.passwordHistory.record(ctx, user.ID, replacedHash)
+	user.PasswordHash = hashedPassword
+	user.PasswordPolicyVersion = domain.PasswordPolicyVersion
+	user.TokenVersion = version
+
+	if err := uc.endUserSessions(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	uc.recordAudit(ctx, domain.AuditActionPasswordChange, user.ID, user.Email)
+
+	return uc.issueTokens(ctx, user)
+}
+
+// TokenVersion returns the token_version access tokens for userID must
+// carry. It implements the AuthMiddleware's token version check.
+func (uc *AuthUseCase) TokenVersion(ctx context.Context, userID int64) (int, error) {
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return 0, contextError(err)
+	}
+	return user.TokenVersion, nil
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestAuthUseCase_ChangePassword_RevokesOldTokens(t *testing.T) {
+	useCase, sessions, jwtService := newSessionTestUseCase(t)
+	ctx := context.Background()
+
+	old, err := useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	resp, err := useCase.ChangePassword(ctx, old.User.ID, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "newpassword456"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	version, err := useCase.TokenVersion(ctx, old.User.ID)
+	if err != nil || version != 1 {
+		t.Fatalf("Expected token_version 1, got %d (%v)", version, err)
+	}
+
+	oldClaims, err := jwtService.ValidateToken(old.AccessToken)
+	if err != nil {
+		t.Fatalf("Failed to validate the old token: %v", err)
+	}
+	if oldClaims.TokenVersion == version {
+		t.Error("Expected the old access token to carry a stale token_version")
+	}
+	newClaims, err := jwtService.ValidateToken(resp.AccessToken)
+	if err != nil || newClaims.TokenVersion != version {
+		t.Errorf("Expected the new access token to carry token_version %d, got %+v (%v)", version, newClaims, err)
+	}
+
+	if _, err := useCase.Refresh(ctx, RefreshRequest{RefreshToken: old.RefreshToken}); err != domain.ErrInvalidToken {
+		t.Errorf("Expected the old refresh token to be rejected, got %v", err)
+	}
+	if _, err := useCase.Refresh(ctx, RefreshRequest{RefreshToken: resp.RefreshToken}); err != nil {
+		t.Errorf("Expected the new refresh token to work, got %v", err)
+	}
+	if len(sessions.sessions) != 1 {
+		t.Errorf("Expected only the new session to remain, got %d", len(sessions.sessions))
+	}
+
+	if _, err := useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "newpassword456"}); err != nil {
+		t.Errorf("Expected login with the new password to succeed, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ChangePassword_Rejected(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+	ctx := context.Background()
+	user, _ := useCase.GetUserByEmail(ctx, "test@example.com")
+
+	_, err := useCase.ChangePassword(ctx, user.ID, ChangePasswordRequest{CurrentPassword: "wrongpassword", NewPassword: "newpassword456"})
+	if err != domain.ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	_, err = useCase.ChangePassword(ctx, user.ID, ChangePasswordRequest{NewPassword: "short"})
+	assertFieldErrors(t, err, map[string]error{
+		"current_password": domain.ErrRequiredField,
+		"new_password":     domain.ErrWeakPassword,
+	})
+
+	if version, _ := useCase.TokenVersion(ctx, user.ID); version != 0 {
+		t.Errorf("Expected token_version to stay 0, got %d", version)
+	}
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"log"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// passwordHistory refuses a new password matching one of the user's last
+// size passwords, the current one included: with a size of 3, the current
+// password and the two it replaced are refused. Zero disables the check.
+type passwordHistory struct {
+	repo domain.PasswordHistoryRepository
+	size int
+}
+
+// WithPasswordHistory makes ChangePassword refuse the user's last size
+// passwords with ErrPasswordReused.
+func WithPasswordHistory(repo domain.PasswordHistoryRepository, size int) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.passwordHistory = passwordHistory{repo: repo, size: size}
+	}
+}
+
+// WithPasswordResetHistory makes ResetPassword refuse the user's last size
+// passwords with ErrPasswordReused. It should share its repository and size
+// with WithPasswordHistory.
+func WithPasswordResetHistory(repo domain.PasswordHistoryRepository, size int) PasswordResetOption {
+	return func(uc *PasswordResetUseCase) {
+		uc.passwordHistory = passwordHistory{repo: repo, size: size}
+	}
+}
+
+func (h passwordHistory) enabled() bool {
+	return h.size > 0
+}
+
+// check compares password with the user's current hash and the replaced
+// ones kept, so it costs one hash verification per entry.
+func (h passwordHistory) check(ctx context.Context, hasher domain.PasswordHasher, user *domain.User, password string) error {
+	if !h.enabled() {
+		return nil
+	}
+
+	hashes := []string{user.PasswordHash}
+	if h.size > 1 {
+		previous, err := h.repo.Recent(ctx, user.ID, h.size-1)
+		if err != nil {
+			return contextError(err)
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && hasher.Verify(hash, password) == nil {
+			return domain.ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// record keeps the hash a password change replaced. It runs once the new
+// password is stored, so a failed write is logged rather than returned: the
+// change itself succeeded.
+func (h passwordHistory) record(ctx context.Context, userID int64, replacedHash string) {
+	if h.size <= 1 || replacedHash == "" {
+		return
+	}
+	if err := h.repo.Add(ctx, userID, replacedHash, h.size-1); err != nil {
+		log.Printf("Failed to record password history for user %d: %v", userID, err)
+	}
+}
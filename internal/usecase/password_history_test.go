@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// MockPasswordHistoryRepository keeps each user's replaced hashes, newest
+// first.
+type MockPasswordHistoryRepository struct {
+	hashes map[int64][]string
+}
+
+func NewMockPasswordHistoryRepository() *MockPasswordHistoryRepository {
+	return &MockPasswordHistoryRepository{hashes: make(map[int64][]string)}
+}
+
+func (m *MockPasswordHistoryRepository) Add(ctx context.Context, userID int64, passwordHash string, keep int) error {
+	hashes := append([]string{passwordHash}, m.hashes[userID]...)
+	if len(hashes) > keep {
+		hashes = hashes[:keep]
+	}
+	m.hashes[userID] = hashes
+	return nil
+}
+
+func (m *MockPasswordHistoryRepository) Recent(ctx context.Context, userID int64, limit int) ([]string, error) {
+	hashes := m.hashes[userID]
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return hashes, nil
+}
+
+func TestAuthUseCase_ChangePassword_History(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithPasswordHistory(NewMockPasswordHistoryRepository(), 3))
+	ctx := context.Background()
+
+	resp, err := useCase.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "password-1"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	userID := resp.User.ID
+
+	change := func(current, next string) error {
+		_, err := useCase.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+		return err
+	}
+
+	if err := change("password-1", "password-1"); !errors.Is(err, domain.ErrPasswordReused) {
+		t.Errorf("Expected the current password to be refused, got %v", err)
+	}
+	if err := change("password-1", "password-2"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := change("password-2", "password-1"); !errors.Is(err, domain.ErrPasswordReused) {
+		t.Errorf("Expected the previous password to be refused, got %v", err)
+	}
+	if err := change("password-2", "password-3"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := change("password-3", "password-4"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// password-1 is now older than the last 3 passwords.
+	if err := change("password-4", "password-1"); err != nil {
+		t.Errorf("Expected a password past the history to be accepted, got %v", err)
+	}
+}
+
+func TestPasswordResetUseCase_ResetPassword_History(t *testing.T) {
+	userRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+	hash, err := passwordService.Hash("oldpassword")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if _, err := userRepo.Create(context.Background(), "test@example.com", hash, 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	useCase := NewPasswordResetUseCase(userRepo, NewMockTokenRepository(), passwordService, security.NewVerificationService(), time.Hour,
+		WithPasswordResetHistory(NewMockPasswordHistoryRepository(), 3))
+	ctx := context.Background()
+
+	token, _ := useCase.RequestPasswordReset(ctx, "test@example.com")
+
+	err = useCase.ResetPassword(ctx, ResetPasswordRequest{Token: token, NewPassword: "oldpassword"})
+	if !errors.Is(err, domain.ErrPasswordReused) {
+		t.Fatalf("Expected ErrPasswordReused, got %v", err)
+	}
+
+	// The refused attempt leaves the link usable.
+	if err := useCase.ResetPassword(ctx, ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}); err != nil {
+		t.Fatalf("Expected the link to still work, got %v", err)
+	}
+
+	token, _ = useCase.RequestPasswordReset(ctx, "test@example.com")
+	err = useCase.ResetPassword(ctx, ResetPasswordRequest{Token: token, NewPassword: "oldpassword"})
+	if !errors.Is(err, domain.ErrPasswordReused) {
+		t.Errorf("Expected the replaced password to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// validatePassword enforces domain.PasswordPolicyVersion; bump that constant
+// whenever these rules get stricter.
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return domain.ErrWeakPassword
+	}
+	return nil
+}
+
+type PasswordResetUseCase struct {
+	userRepo            domain.UserRepository
+	tokenRepo           domain.TokenRepository
+	passwordService     domain.PasswordHasher
+	verificationService *security.VerificationService
+	tokenTTL            time.Duration
+	now                 func() time.Time
+	audit               domain.AuditLogger
+	mailer              domain.Mailer
+	linkBaseURL         string
+	passwordHistory     passwordHistory
+	// recentRequests remembers, per user ID, the last reset issued within
+	// the cooldown.
+	recentRequests *attemptTracker
+}
+
+type PasswordResetOption func(*PasswordResetUseCase)
+
+// WithPasswordResetAuditLogger records completed password resets.
+func WithPasswordResetAuditLogger(audit domain.AuditLogger) PasswordResetOption {
+	return func(uc *PasswordResetUseCase) {
+		uc.audit = audit
+	}
+}
+
+// WithPasswordResetMailer emails reset links, built on baseURL.
+func WithPasswordResetMailer(mailer domain.Mailer, baseURL string) PasswordResetOption {
+	return func(uc *PasswordResetUseCase) {
+		uc.mailer = mailer
+		uc.linkBaseURL = baseURL
+	}
+}
+
+// WithPasswordResetCooldown issues at most one reset link per account every
+// cooldown; requests in between are ignored without telling the caller. A
+// zero cooldown disables it. A nil clock is the system clock.
+func WithPasswordResetCooldown(cooldown time.Duration, c clock.Clock) PasswordResetOption {
+	return func(uc *PasswordResetUseCase) {
+		if cooldown <= 0 {
+			uc.recentRequests = nil
+			return
+		}
+		uc.recentRequests = newAttemptTracker(cooldown, c)
+	}
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func NewPasswordResetUseCase(
+	userRepo domain.UserRepository,
+	tokenRepo domain.TokenRepository,
+	passwordService domain.PasswordHasher,
+	verificationService *security.VerificationService,
+	tokenTTL time.Duration,
+	opts ...PasswordResetOption,
+) *PasswordResetUseCase {
+	uc := &PasswordResetUseCase{
+		userRepo:            userRepo,
+		tokenRepo:           tokenRepo,
+		passwordService:     passwordService,
+		verificationService: verificationService,
+		tokenTTL:            tokenTTL,
+		now:                 time.Now,
+	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
+}
+
+// RequestPasswordReset emails a reset link when a mailer is configured and
+// returns the token, or an empty string when no account matches or the
+// account is within its cooldown. Callers must respond identically in all
+// cases.
+func (uc *PasswordResetUseCase) RequestPasswordReset(ctx context.Context, email string) (string, error) {
+	email = normalizeEmail(email)
+	if email == "" {
+		return "", nil
+	}
+
+	user, err := uc.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if uc.recentRequests != nil {
+		key := strconv.FormatInt(user.ID, 10)
+		if count, _ := uc.recentRequests.count(key); count > 0 {
+			return "", nil
+		}
+		uc.recentRequests.fail(key)
+	}
+
+	token, tokenHash, err := uc.verificationService.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.tokenRepo.Create(ctx, &domain.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposePasswordReset,
+		TokenHash: tokenHash,
+		ExpiresAt: uc.now().Add(uc.tokenTTL),
+	})
+	if err != nil {
+		return "", contextError(err)
+	}
+
+	if uc.mailer != nil {
+		subject, body := passwordResetEmail(emailLink(uc.linkBaseURL, resetPasswordPath, token), uc.tokenTTL)
+		// Failing the request would tell the caller the account exists.
+		if err := uc.mailer.Send(user.Email, subject, body); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	}
+
+	return token, nil
+}
+
+// ValidateResetToken reports whether token would be accepted by
+// ResetPassword right now, without spending it.
+func (uc *PasswordResetUseCase) ValidateResetToken(ctx context.Context, token string) error {
+	_, err := uc.findResetToken(ctx, token, uc.now())
+	return err
+}
+
+func (uc *PasswordResetUseCase) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	if req.Token == "" {
+		return domain.ErrOneTimeTokenInvalid
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		return err
+	}
+
+	now := uc.now()
+	stored, err := uc.findResetToken(ctx, req.Token, now)
+	if err != nil {
+		return err
+	}
+
+	// Checked before the token is spent, so the user can pick another
+	// password with the same link.
+	var replacedHash string
+	if uc.passwordHistory.enabled() {
+		user, err := uc.userRepo.FindByID(ctx, stored.UserID)
+		if err != nil {
+			return contextError(err)
+		}
+		if err := uc.passwordHistory.check(ctx, uc.passwordService, user, req.NewPassword); err != nil {
+			return err
+		}
+		replacedHash = user.PasswordHash
+	}
+
+	hashedPassword, err := uc.passwordService.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.tokenRepo.MarkUsed(ctx, stored.ID, now); err != nil {
+		return contextError(err)
+	}
+
+	if _, err := uc.userRepo.ChangePassword(ctx, stored.UserID, hashedPassword, domain.PasswordPolicyVersion); err != nil {
+		return contextError(err)
+	}
+	uc.passwordHistory.record(ctx, stored.UserID, replacedHash)
+
+	if uc.audit != nil {
+		uc.audit.Record(domain.AuditEvent{
+			Action: domain.AuditActionPasswordChange,
+			UserID: stored.UserID,
+			IP:     domain.ClientIPFromContext(ctx),
+		})
+	}
+	return nil
+}
+
+// findResetToken returns the stored reset token for token if it is unused
+// and unexpired at now.
+func (uc *PasswordResetUseCase) findResetToken(ctx context.Context, token string, now time.Time) (*domain.OneTimeToken, error) {
+	if token == "" {
+		return nil, domain.ErrOneTimeTokenInvalid
+	}
+
+	stored, err := uc.tokenRepo.FindByHash(ctx, domain.TokenPurposePasswordReset, uc.verificationService.HashToken(token))
+	if err != nil {
+		return nil, contextError(err)
+	}
+	if stored.UsedAt != nil {
+		return nil, domain.ErrOneTimeTokenUsed
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, domain.ErrOneTimeTokenExpired
+	}
+	return stored, nil
+}
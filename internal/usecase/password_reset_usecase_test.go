@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/clock"
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newPasswordResetTestSetup(t *testing.T) (*PasswordResetUseCase, *MockUserRepository, *security.PasswordService) {
+	t.Helper()
+
+	userRepo := NewMockUserRepository()
+	passwordService := security.NewPasswordService()
+
+	hash, err := passwordService.Hash("oldpassword")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	if _, err := userRepo.Create(context.Background(), "test@example.com", hash, 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	useCase := NewPasswordResetUseCase(userRepo, NewMockTokenRepository(), passwordService, security.NewVerificationService(), time.Hour)
+	return useCase, userRepo, passwordService
+}
+
+func TestPasswordResetUseCase_ResetPassword_Success(t *testing.T) {
+	useCase, userRepo, passwordService := newPasswordResetTestSetup(t)
+
+	token, err := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if token == "" {
+		t.Fatal("Expected a reset token for an existing account")
+	}
+
+	if err := useCase.ResetPassword(context.Background(), ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	user, _ := userRepo.FindByEmail(context.Background(), "test@example.com")
+	if err := passwordService.Verify(user.PasswordHash, "newpassword123"); err != nil {
+		t.Error("Expected the new password to be stored")
+	}
+}
+
+func TestPasswordResetUseCase_ResetPassword_TokenReuse(t *testing.T) {
+	useCase, _, _ := newPasswordResetTestSetup(t)
+
+	token, _ := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+
+	if err := useCase.ResetPassword(context.Background(), ResetPasswordRequest{Token: token, NewPassword: "newpassword123"}); err != nil {
+		t.Fatalf("Expected first reset to succeed, got %v", err)
+	}
+
+	err := useCase.ResetPassword(context.Background(), ResetPasswordRequest{Token: token, NewPassword: "anotherpassword"})
+	if !errors.Is(err, domain.ErrOneTimeTokenUsed) {
+		t.Errorf("Expected ErrOneTimeTokenUsed, got %v", err)
+	}
+}
+
+func TestPasswordResetUseCase_ResetPassword_Expired(t *testing.T) {
+	useCase, userRepo, passwordService := newPasswordResetTestSetup(t)
+
+	token, _ := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+	useCase.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	err := useCase.ResetPassword(context.Background(), ResetPasswordRequest{Token: token, NewPassword: "newpassword123"})
+	if !errors.Is(err, domain.ErrOneTimeTokenExpired) {
+		t.Errorf("Expected ErrOneTimeTokenExpired, got %v", err)
+	}
+
+	user, _ := userRepo.FindByEmail(context.Background(), "test@example.com")
+	if err := passwordService.Verify(user.PasswordHash, "oldpassword"); err != nil {
+		t.Error("Expected the old password to remain valid")
+	}
+}
+
+func TestPasswordResetUseCase_RequestPasswordReset_UnknownEmail(t *testing.T) {
+	useCase, _, _ := newPasswordResetTestSetup(t)
+
+	token, err := useCase.RequestPasswordReset(context.Background(), "nonexistent@example.com")
+	if err != nil {
+		t.Errorf("Expected no error for unknown email, got %v", err)
+	}
+	if token != "" {
+		t.Error("Expected no token for unknown email")
+	}
+}
+
+func TestPasswordResetUseCase_ResetPassword_WeakPassword(t *testing.T) {
+	useCase, _, _ := newPasswordResetTestSetup(t)
+
+	token, _ := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+
+	err := useCase.ResetPassword(context.Background(), ResetPasswordRequest{Token: token, NewPassword: "short"})
+	if !errors.Is(err, domain.ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword, got %v", err)
+	}
+}
+
+func TestPasswordResetUseCase_RequestPasswordReset_SendsLink(t *testing.T) {
+	userRepo := NewMockUserRepository()
+	user, _ := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	mailer := &MockMailer{}
+	useCase := NewPasswordResetUseCase(userRepo, NewMockTokenRepository(), &MockPasswordHasher{}, security.NewVerificationService(), time.Hour,
+		WithPasswordResetMailer(mailer, "https://app.example.com"))
+
+	token, err := useCase.RequestPasswordReset(context.Background(), user.Email)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].To != user.Email {
+		t.Fatalf("Expected one email to %s, got %+v", user.Email, mailer.sent)
+	}
+	if link := "https://app.example.com/reset-password?token=" + token; !strings.Contains(mailer.sent[0].Body, link) {
+		t.Errorf("Expected body to contain %s, got %q", link, mailer.sent[0].Body)
+	}
+
+	if _, err := useCase.RequestPasswordReset(context.Background(), "nonexistent@example.com"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Errorf("Expected no email for an unknown address, got %d emails", len(mailer.sent))
+	}
+}
+
+func TestPasswordResetUseCase_RequestPasswordReset_SendFailureIsSilent(t *testing.T) {
+	userRepo := NewMockUserRepository()
+	user, _ := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	useCase := NewPasswordResetUseCase(userRepo, NewMockTokenRepository(), &MockPasswordHasher{}, security.NewVerificationService(), time.Hour,
+		WithPasswordResetMailer(&MockMailer{err: errors.New("smtp unavailable")}, "https://app.example.com"))
+
+	// An error here would reveal that the account exists.
+	if _, err := useCase.RequestPasswordReset(context.Background(), user.Email); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestPasswordResetUseCase_RequestPasswordReset_Cooldown(t *testing.T) {
+	now := clock.NewFake(time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+	userRepo := NewMockUserRepository()
+	if _, err := userRepo.Create(context.Background(), "test@example.com", "hash", 1); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	tokenRepo := NewMockTokenRepository()
+	mailer := &MockMailer{}
+	useCase := NewPasswordResetUseCase(userRepo, tokenRepo, security.NewPasswordService(), security.NewVerificationService(), time.Hour,
+		WithPasswordResetMailer(mailer, "https://app.example.com"),
+		WithPasswordResetCooldown(time.Minute, now))
+
+	first, err := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+	if err != nil || first == "" {
+		t.Fatalf("Expected a reset token, got %q, %v", first, err)
+	}
+	now.Advance(30 * time.Second)
+	second, err := useCase.RequestPasswordReset(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Expected no error within the cooldown, got %v", err)
+	}
+	if second != "" || len(tokenRepo.tokens) != 1 || len(mailer.sent) != 1 {
+		t.Fatalf("Expected one token and one email within the cooldown, got %d and %d", len(tokenRepo.tokens), len(mailer.sent))
+	}
+
+	now.Advance(31 * time.Second)
+	if third, err := useCase.RequestPasswordReset(context.Background(), "test@example.com"); err != nil || third == "" {
+		t.Errorf("Expected a new token once the cooldown passed, got %q, %v", third, err)
+	}
+	if len(mailer.sent) != 2 {
+		t.Errorf("Expected a second email after the cooldown, got %d", len(mailer.sent))
+	}
+}
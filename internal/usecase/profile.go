@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// UpdateProfileRequest is a partial update: a field left out (or null)
+// keeps its current value. An empty display name clears it.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+}
+
+func validateDisplayName(name string) error {
+	if utf8.RuneCountInString(name) > domain.MaxDisplayNameLength {
+		return domain.ErrDisplayNameTooLong
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return domain.ErrDisplayNameInvalid
+	}
+	return nil
+}
+
+// UpdateProfile applies the fields present in req and returns the updated
+// user. The repository takes every profile field, so the others are carried
+// over from the stored user.
+func (uc *AuthUseCase) UpdateProfile(ctx context.Context, userID int64, req UpdateProfileRequest) (*domain.User, error) {
+	validation := &domain.ValidationError{}
+	if req.DisplayName != nil {
+		trimmed := strings.TrimSpace(*req.DisplayName)
+		req.DisplayName = &trimmed
+		if err := validateDisplayName(trimmed); err != nil {
+			validation.Add("display_name", err)
+		}
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	changed := false
+	if req.DisplayName != nil && *req.DisplayName != user.DisplayName {
+		user.DisplayName = *req.DisplayName
+		changed = true
+	}
+	if !changed {
+		return user, nil
+	}
+
+	if err := uc.userRepo.UpdateProfile(ctx, user.ID, user.DisplayName); err != nil {
+		return nil, contextError(err)
+	}
+	return user, nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newProfileUseCase(t *testing.T) (*AuthUseCase, *MockUserRepository, *domain.User) {
+	t.Helper()
+
+	mockRepo := NewMockUserRepository()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService)
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "profile@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	user, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	return useCase, mockRepo, user
+}
+
+func stringPtr(s string) *string { return &s }
+
+func TestAuthUseCase_UpdateProfile_SetsAndClearsDisplayName(t *testing.T) {
+	useCase, mockRepo, user := newProfileUseCase(t)
+
+	updated, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{DisplayName: stringPtr("  Alice Martin ")})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.DisplayName != "Alice Martin" {
+		t.Errorf("Expected trimmed display name, got %q", updated.DisplayName)
+	}
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.DisplayName != "Alice Martin" || stored.Email != "profile@example.com" {
+		t.Errorf("Expected only the display name to change, got %+v", stored)
+	}
+
+	if _, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{DisplayName: stringPtr("")}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stored, _ = mockRepo.FindByID(context.Background(), user.ID)
+	if stored.DisplayName != "" {
+		t.Errorf("Expected display name to be cleared, got %q", stored.DisplayName)
+	}
+}
+
+func TestAuthUseCase_UpdateProfile_OmittedFieldUnchanged(t *testing.T) {
+	useCase, mockRepo, user := newProfileUseCase(t)
+	if _, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{DisplayName: stringPtr("Alice")}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	updated, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.DisplayName != "Alice" {
+		t.Errorf("Expected display name to be kept, got %q", updated.DisplayName)
+	}
+	stored, _ := mockRepo.FindByID(context.Background(), user.ID)
+	if stored.DisplayName != "Alice" {
+		t.Errorf("Expected stored display name to be kept, got %q", stored.DisplayName)
+	}
+}
+
+func TestAuthUseCase_UpdateProfile_Validation(t *testing.T) {
+	useCase, _, user := newProfileUseCase(t)
+
+	tests := []struct {
+		name string
+		in   string
+		want error
+	}{
+		{"too long", strings.Repeat("é", domain.MaxDisplayNameLength+1), domain.ErrDisplayNameTooLong},
+		{"control character", "Alice\nMartin", domain.ErrDisplayNameInvalid},
+	}
+	for _, tt := range tests {
+		_, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{DisplayName: stringPtr(tt.in)})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// The limit counts characters, not bytes.
+	if _, err := useCase.UpdateProfile(context.Background(), user.ID, UpdateProfileRequest{DisplayName: stringPtr(strings.Repeat("é", domain.MaxDisplayNameLength))}); err != nil {
+		t.Errorf("Expected %d characters to be accepted, got %v", domain.MaxDisplayNameLength, err)
+	}
+}
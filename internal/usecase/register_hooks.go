@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// RegisterHook runs deployment-specific logic once an account has been
+// created, such as provisioning default resources or subscribing the user to
+// a mailing list.
+type RegisterHook interface {
+	AfterRegister(user *domain.User) error
+}
+
+// RegisterHookFunc adapts a plain function to RegisterHook.
+type RegisterHookFunc func(user *domain.User) error
+
+func (f RegisterHookFunc) AfterRegister(user *domain.User) error {
+	return f(user)
+}
+
+// WithRegisterHooks adds hooks run in order after each successful
+// registration. Their errors are logged and ignored unless
+// WithFatalRegisterHooks is set.
+func WithRegisterHooks(hooks ...RegisterHook) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.registerHooks = append(uc.registerHooks, hooks...)
+	}
+}
+
+// WithFatalRegisterHooks makes Register fail on the first hook error. The
+// account has already been stored by then, so the client sees an error for a
+// user that exists.
+func WithFatalRegisterHooks(fatal bool) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.registerHooksFatal = fatal
+	}
+}
+
+func (uc *AuthUseCase) runRegisterHooks(user *domain.User) error {
+	for i, hook := range uc.registerHooks {
+		// Hooks get a copy so they cannot alter the response.
+		copied := *user
+		if err := hook.AfterRegister(&copied); err != nil {
+			if uc.registerHooksFatal {
+				return fmt.Errorf("register hook %d: %w", i, err)
+			}
+			log.Printf("Register hook %d failed for user %d: %v", i, user.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+type spyRegisterHook struct {
+	calls []*domain.User
+	err   error
+}
+
+func (h *spyRegisterHook) AfterRegister(user *domain.User) error {
+	h.calls = append(h.calls, user)
+	return h.err
+}
+
+func TestAuthUseCase_Register_RunsHooks(t *testing.T) {
+	first := &spyRegisterHook{}
+	second := &spyRegisterHook{}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithRegisterHooks(first, second))
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "Test@Example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i, hook := range []*spyRegisterHook{first, second} {
+		if len(hook.calls) != 1 {
+			t.Fatalf("Expected hook %d to be called once, got %d", i, len(hook.calls))
+		}
+		if got := hook.calls[0]; got.ID != resp.User.ID || got.Email != "test@example.com" {
+			t.Errorf("Expected hook %d to get the created user, got %+v", i, got)
+		}
+	}
+}
+
+func TestAuthUseCase_Register_HookErrorIsNotFatalByDefault(t *testing.T) {
+	failing := &spyRegisterHook{err: errors.New("mailing list unavailable")}
+	next := &spyRegisterHook{}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithRegisterHooks(failing, next))
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected hook error to be ignored, got %v", err)
+	}
+	if resp.AccessToken == "" {
+		t.Error("Expected an access token")
+	}
+	if len(next.calls) != 1 {
+		t.Error("Expected the following hook to still run")
+	}
+}
+
+func TestAuthUseCase_Register_FatalHookError(t *testing.T) {
+	hookErr := errors.New("provisioning failed")
+	failing := &spyRegisterHook{err: hookErr}
+	next := &spyRegisterHook{}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService,
+		WithRegisterHooks(failing, next),
+		WithFatalRegisterHooks(true))
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if !errors.Is(err, hookErr) {
+		t.Errorf("Expected the hook error, got %v", err)
+	}
+	if len(next.calls) != 0 {
+		t.Error("Expected hooks after a fatal error to be skipped")
+	}
+}
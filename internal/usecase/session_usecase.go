@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// SessionList is one page of a user's sessions.
+type SessionList struct {
+	Sessions []*domain.Session
+	Total    int64
+	Page     int
+	PageSize int
+}
+
+// WithSessions makes logins open a session and return a refresh token
+// alongside the access token.
+func WithSessions(store domain.SessionStore) AuthOption {
+	return func(uc *AuthUseCase) {
+		uc.sessions = store
+	}
+}
+
+// issueTokens builds the response for a user who has just authenticated,
+// opening a session when sessions are enabled.
+func (uc *AuthUseCase) issueTokens(ctx context.Context, user *domain.User) (*AuthResponse, error) {
+	if uc.sessions == nil {
+		token, err := uc.jwtService.GenerateSessionToken(user, 0, uc.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		return uc.newAuthResponse(user, token), nil
+	}
+
+	now := uc.clock.Now().UTC()
+	session := &domain.Session{
+		UserID:     user.ID,
+		UserAgent:  domain.UserAgentFromContext(ctx),
+		IP:         domain.ClientIPFromContext(ctx),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(uc.jwtService.RefreshDuration()),
+	}
+	if err := uc.sessions.Create(ctx, session); err != nil {
+		return nil, contextError(err)
+	}
+
+	refreshToken, refreshClaims, err := uc.jwtService.GenerateRefreshToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.sessions.Rotate(ctx, session.ID, session.RefreshTokenID, refreshClaims.ID, refreshClaims.ExpiresAt.Time, now); err != nil {
+		return nil, contextError(err)
+	}
+
+	return uc.sessionResponse(user, session, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token. The old refresh token stops matching the session; presenting it
+// again means it was stolen or replayed, so the whole session is ended.
+func (uc *AuthUseCase) Refresh(ctx context.Context, req RefreshRequest) (*AuthResponse, error) {
+	if uc.sessions == nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	claims, err := uc.jwtService.ValidateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+
+	session, err := uc.sessions.FindByID(ctx, claims.SessionID)
+	if err != nil {
+		if err == domain.ErrSessionNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if session.UserID != claims.UserID {
+		return nil, domain.ErrInvalidToken
+	}
+	if session.RefreshTokenID != claims.ID {
+		uc.endSession(ctx, session)
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if claims.TokenVersion != user.TokenVersion {
+		return nil, domain.ErrInvalidToken
+	}
+
+	refreshToken, refreshClaims, err := uc.jwtService.GenerateRefreshToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = uc.sessions.Rotate(ctx, session.ID, claims.ID, refreshClaims.ID, refreshClaims.ExpiresAt.Time, uc.clock.Now().UTC())
+	if err == domain.ErrSessionNotFound {
+		// A concurrent refresh with the same token won the rotation, so the
+		// token was presented twice: treat it as reuse.
+		uc.endSession(ctx, session)
+		return nil, domain.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	return uc.sessionResponse(user, session, refreshToken)
+}
+
+// ListSessions returns one page of the user's active sessions, most
+// recently used first, with the page size capped at maxPageSize.
+func (uc *AuthUseCase) ListSessions(ctx context.Context, userID int64, params pagination.Params) (*SessionList, error) {
+	page, pageSize := normalizePage(params.Page, params.PageSize)
+	if uc.sessions == nil {
+		return &SessionList{Sessions: []*domain.Session{}, Page: page, PageSize: pageSize}, nil
+	}
+
+	sessions, err := uc.sessions.ListByUser(ctx, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	total, err := uc.sessions.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	return &SessionList{
+		Sessions: sessions,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// RevokeSession ends one of the user's sessions and blacklists its refresh
+// token. Access tokens already issued for it stay valid until they expire.
+func (uc *AuthUseCase) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	if uc.sessions == nil {
+		return domain.ErrSessionNotFound
+	}
+
+	session, err := uc.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		return contextError(err)
+	}
+	// Someone else's session looks exactly like a missing one.
+	if session.UserID != userID {
+		return domain.ErrSessionNotFound
+	}
+
+	return uc.endSession(ctx, session)
+}
+
+func (uc *AuthUseCase) endSession(ctx context.Context, session *domain.Session) error {
+	uc.jwtService.RevokeID(session.RefreshTokenID, session.ExpiresAt)
+	return contextError(uc.sessions.Delete(ctx, session.ID))
+}
+
+// endUserSessions ends every session of userID, so their refresh tokens
+// stop working at once. Ended sessions drop out of the list, so it keeps
+// taking the first page until none is left.
+func (uc *AuthUseCase) endUserSessions(ctx context.Context, userID int64) error {
+	if uc.sessions == nil {
+		return nil
+	}
+	for {
+		sessions, err := uc.sessions.ListByUser(ctx, userID, maxPageSize, 0)
+		if err != nil {
+			return contextError(err)
+		}
+		if len(sessions) == 0 {
+			return nil
+		}
+		for _, session := range sessions {
+			if err := uc.endSession(ctx, session); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sessionResponse dates auth_time from the session's creation, the last
+// time the user signed in on it.
+func (uc *AuthUseCase) sessionResponse(user *domain.User, session *domain.Session, refreshToken string) (*AuthResponse, error) {
+	token, err := uc.jwtService.GenerateSessionToken(user, session.ID, session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := uc.newAuthResponse(user, token)
+	resp.RefreshToken = refreshToken
+	return resp, nil
+}
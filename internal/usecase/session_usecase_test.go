@@ -0,0 +1,423 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"github.com/valentinfrappart/securerestapi/internal/pagination"
+)
+
+type MockSessionStore struct {
+	mu       sync.Mutex
+	sessions map[int64]*domain.Session
+	nextID   int64
+	// found, when set, is called after every FindByID so tests can hold
+	// concurrent callers at the same point.
+	found func()
+}
+
+func NewMockSessionStore() *MockSessionStore {
+	return &MockSessionStore{
+		sessions: make(map[int64]*domain.Session),
+		nextID:   1,
+	}
+}
+
+func (m *MockSessionStore) Create(ctx context.Context, session *domain.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session.ID = m.nextID
+	m.nextID++
+	stored := *session
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *MockSessionStore) FindByID(ctx context.Context, id int64) (*domain.Session, error) {
+	m.mu.Lock()
+	session, ok := m.sessions[id]
+	var found domain.Session
+	if ok {
+		found = *session
+	}
+	m.mu.Unlock()
+
+	if m.found != nil {
+		m.found()
+	}
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &found, nil
+}
+
+func (m *MockSessionStore) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]*domain.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := m.activeSessions(userID)
+	if offset >= len(sessions) {
+		return []*domain.Session{}, nil
+	}
+	sessions = sessions[offset:]
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+func (m *MockSessionStore) CountByUser(ctx context.Context, userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.activeSessions(userID))), nil
+}
+
+// activeSessions returns copies of userID's unexpired sessions, most
+// recently used first.
+func (m *MockSessionStore) activeSessions(userID int64) []*domain.Session {
+	sessions := []*domain.Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.ExpiresAt.After(time.Now()) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions
+}
+
+func (m *MockSessionStore) Rotate(ctx context.Context, id int64, currentID, refreshTokenID string, expiresAt, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.RefreshTokenID != currentID {
+		return domain.ErrSessionNotFound
+	}
+	session.RefreshTokenID = refreshTokenID
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = usedAt
+	return nil
+}
+
+func (m *MockSessionStore) Delete(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[id]; !ok {
+		return domain.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func newSessionTestUseCase(t *testing.T) (*AuthUseCase, *MockSessionStore, *security.JWTService) {
+	t.Helper()
+
+	sessions := NewMockSessionStore()
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour,
+		security.WithRevocationStore(security.NewMemoryRevocationStore()))
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService, WithSessions(sessions))
+
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	return useCase, sessions, jwtService
+}
+
+func TestAuthUseCase_Login_OpensSession(t *testing.T) {
+	useCase, _, jwtService := newSessionTestUseCase(t)
+
+	ctx := domain.ContextWithUserAgent(domain.ContextWithClientIP(context.Background(), "203.0.113.7"), "curl/8.0")
+	resp, err := useCase.Login(ctx, LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.RefreshToken == "" {
+		t.Fatal("Expected a refresh token")
+	}
+
+	claims, err := jwtService.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("Expected valid access token, got %v", err)
+	}
+
+	list, err := useCase.ListSessions(context.Background(), resp.User.ID, pagination.Params{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Register opened one session, Login another.
+	if len(list.Sessions) != 2 || list.Total != 2 {
+		t.Fatalf("Expected 2 sessions, got %d of %d", len(list.Sessions), list.Total)
+	}
+
+	var current *domain.Session
+	for _, session := range list.Sessions {
+		if session.ID == claims.SessionID {
+			current = session
+		}
+	}
+	if current == nil {
+		t.Fatalf("Expected access token sid %d to match a listed session", claims.SessionID)
+	}
+	if current.UserAgent != "curl/8.0" || current.IP != "203.0.113.7" {
+		t.Errorf("Expected session metadata to be recorded, got %+v", current)
+	}
+}
+
+func TestAuthUseCase_Refresh_RotatesToken(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	refreshed, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got %v", err)
+	}
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatal("Expected a new refresh token")
+	}
+
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: refreshed.RefreshToken}); err != nil {
+		t.Fatalf("Expected rotated token to be usable, got %v", err)
+	}
+}
+
+// A refresh is not a sign-in: the new access token keeps the session's
+// auth_time.
+func TestAuthUseCase_Refresh_KeepsAuthTime(t *testing.T) {
+	useCase, sessions, jwtService := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	claims, err := jwtService.ValidateToken(login.Token)
+	if err != nil {
+		t.Fatalf("Expected valid access token, got %v", err)
+	}
+	signedIn := time.Now().Add(-time.Hour).Truncate(time.Second)
+	sessions.sessions[claims.SessionID].CreatedAt = signedIn
+
+	refreshed, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got %v", err)
+	}
+	claims, err = jwtService.ValidateToken(refreshed.Token)
+	if err != nil {
+		t.Fatalf("Expected valid access token, got %v", err)
+	}
+	if claims.AuthTime == nil || !claims.AuthTime.Time.Equal(signedIn) {
+		t.Errorf("Expected auth_time %s, got %v", signedIn, claims.AuthTime)
+	}
+}
+
+func TestAuthUseCase_Refresh_ReuseEndsSession(t *testing.T) {
+	useCase, sessions, _ := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	refreshed, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("Expected refresh to succeed, got %v", err)
+	}
+
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Fatalf("Expected ErrInvalidToken for a reused token, got %v", err)
+	}
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: refreshed.RefreshToken}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected the whole session to end after reuse, got %v", err)
+	}
+	if len(sessions.sessions) != 1 {
+		t.Errorf("Expected only the registration session to remain, got %d", len(sessions.sessions))
+	}
+}
+
+func TestAuthUseCase_Refresh_ConcurrentReuseEndsSession(t *testing.T) {
+	useCase, sessions, _ := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Hold both refreshes after the session lookup so both pass the token
+	// check before either rotates.
+	var lookedUp sync.WaitGroup
+	lookedUp.Add(2)
+	sessions.found = func() {
+		lookedUp.Done()
+		lookedUp.Wait()
+	}
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken})
+			results <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < 2; i++ {
+		err := <-results
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, domain.ErrInvalidToken):
+			t.Errorf("Expected ErrInvalidToken for the losing refresh, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("Expected exactly one refresh to succeed, got %d", succeeded)
+	}
+	if len(sessions.sessions) != 1 {
+		t.Errorf("Expected the reused session to be ended, %d sessions left", len(sessions.sessions))
+	}
+}
+
+func TestAuthUseCase_Refresh_RejectsAccessToken(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.Token}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ListSessions_Pages(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+
+	// Registration opened the first session.
+	var userID int64
+	for i := 0; i < 4; i++ {
+		login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		userID = login.User.ID
+	}
+
+	seen := map[int64]bool{}
+	for page := 1; page <= 3; page++ {
+		list, err := useCase.ListSessions(context.Background(), userID, pagination.Params{Page: page, PageSize: 2})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if list.Total != 5 || list.Page != page || list.PageSize != 2 {
+			t.Fatalf("Page %d: unexpected metadata %+v", page, list)
+		}
+		expected := 2
+		if page == 3 {
+			expected = 1
+		}
+		if len(list.Sessions) != expected {
+			t.Fatalf("Page %d: expected %d sessions, got %d", page, expected, len(list.Sessions))
+		}
+		for _, session := range list.Sessions {
+			if seen[session.ID] {
+				t.Errorf("Session %d listed on two pages", session.ID)
+			}
+			seen[session.ID] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected every session once across pages, got %d", len(seen))
+	}
+}
+
+func TestAuthUseCase_ListSessions_CapsPageSize(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+
+	list, err := useCase.ListSessions(context.Background(), 1, pagination.Params{Page: 1, PageSize: pagination.MaxPageSize + 50})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if list.PageSize != pagination.MaxPageSize {
+		t.Errorf("Expected page size capped at %d, got %d", pagination.MaxPageSize, list.PageSize)
+	}
+}
+
+func TestAuthUseCase_EndUserSessions_MoreThanOnePage(t *testing.T) {
+	useCase, sessions, _ := newSessionTestUseCase(t)
+
+	now := time.Now()
+	for i := 0; i < pagination.MaxPageSize+10; i++ {
+		session := &domain.Session{UserID: 1, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := sessions.Create(context.Background(), session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if err := useCase.endUserSessions(context.Background(), 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(sessions.sessions) != 0 {
+		t.Errorf("Expected every session to be ended, %d left", len(sessions.sessions))
+	}
+}
+
+func TestAuthUseCase_RevokeSession(t *testing.T) {
+	useCase, _, jwtService := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	claims, err := jwtService.ValidateRefreshToken(login.RefreshToken)
+	if err != nil {
+		t.Fatalf("Expected valid refresh token, got %v", err)
+	}
+
+	if err := useCase.RevokeSession(context.Background(), login.User.ID, claims.SessionID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := jwtService.ValidateRefreshToken(login.RefreshToken); !errors.Is(err, domain.ErrTokenRevoked) {
+		t.Errorf("Expected refresh token to be blacklisted, got %v", err)
+	}
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected refresh to fail after revocation, got %v", err)
+	}
+
+	list, _ := useCase.ListSessions(context.Background(), login.User.ID, pagination.Params{})
+	for _, session := range list.Sessions {
+		if session.ID == claims.SessionID {
+			t.Error("Expected revoked session to disappear from the list")
+		}
+	}
+}
+
+func TestAuthUseCase_RevokeSession_OtherUser(t *testing.T) {
+	useCase, _, jwtService := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	claims, _ := jwtService.ValidateRefreshToken(login.RefreshToken)
+
+	if err := useCase.RevokeSession(context.Background(), login.User.ID+1, claims.SessionID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Errorf("Expected session to survive, got %v", err)
+	}
+}
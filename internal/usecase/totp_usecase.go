@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+var errTOTPNotConfigured = errors.New("TOTP service is not configured")
+
+// maxTOTPAttempts is the number of wrong codes a pending token survives;
+// after that the login has to start again with the password.
+const maxTOTPAttempts = 5
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type TOTPVerifyRequest struct {
+	PendingToken string `json:"pending_token"`
+	Code         string `json:"code"`
+}
+
+// SetupTOTP generates a new secret for the user. 2FA stays disabled until
+// ConfirmTOTP receives a valid code, so a lost setup can simply be restarted.
+func (uc *AuthUseCase) SetupTOTP(ctx context.Context, userID int64) (*TOTPSetupResponse, error) {
+	if uc.totp == nil {
+		return nil, errTOTPNotConfigured
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	if user.TOTPEnabled {
+		return nil, domain.ErrTOTPAlreadyEnabled
+	}
+
+	secret, otpauthURL, err := uc.totp.GenerateSecret(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := uc.totp.EncryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.userRepo.SetTOTPSecret(ctx, user.ID, encrypted); err != nil {
+		return nil, contextError(err)
+	}
+
+	return &TOTPSetupResponse{Secret: secret, OTPAuthURL: otpauthURL}, nil
+}
+
+func (uc *AuthUseCase) ConfirmTOTP(ctx context.Context, userID int64, code string) error {
+	if uc.totp == nil {
+		return errTOTPNotConfigured
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return contextError(err)
+	}
+	if user.TOTPEnabled {
+		return domain.ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return domain.ErrTOTPNotEnrolled
+	}
+
+	if err := uc.verifyTOTPCode(user, code); err != nil {
+		return err
+	}
+
+	return contextError(uc.userRepo.EnableTOTP(ctx, user.ID))
+}
+
+// VerifyTOTP completes a login started with a password: it exchanges the
+// pending token and a valid code for an access token. A wrong code counts
+// as a failed login for the account, and the pending token is revoked after
+// maxTOTPAttempts of them.
+func (uc *AuthUseCase) VerifyTOTP(ctx context.Context, req TOTPVerifyRequest) (*AuthResponse, error) {
+	if uc.totp == nil {
+		return nil, errTOTPNotConfigured
+	}
+
+	claims, err := uc.jwtService.ValidatePendingToken(req.PendingToken)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+	// Checked here as well as through the revocation, which is a no-op
+	// without a revocation store.
+	if failures, _ := uc.totpAttempts.count(claims.ID); failures >= maxTOTPAttempts {
+		return nil, domain.ErrInvalidToken
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, claims.UserID)
+	if err != nil {
+		if err == domain.ErrUserNotFound {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, domain.ErrInvalidToken
+	}
+	account := loginAccountKey(user, "")
+	if err := uc.checkLoginThrottle(ctx, account); err != nil {
+		return nil, err
+	}
+
+	if err := uc.verifyTOTPCode(user, req.Code); err != nil {
+		if err != domain.ErrInvalidTOTPCode {
+			return nil, err
+		}
+		uc.recordAudit(ctx, domain.AuditActionLoginFailed, user.ID, user.Email)
+		if uc.totpAttempts.fail(claims.ID) >= maxTOTPAttempts {
+			uc.jwtService.Revoke(claims)
+		}
+		if err := uc.failLogin(ctx, account, user); err != domain.ErrInvalidCredentials {
+			return nil, err
+		}
+		return nil, domain.ErrInvalidTOTPCode
+	}
+	uc.clearLoginFailures(ctx, account, user)
+	uc.totpAttempts.reset(claims.ID)
+
+	// The pending token has done its job; don't let it start another attempt.
+	uc.jwtService.Revoke(claims)
+
+	return uc.completeLogin(ctx, user)
+}
+
+func (uc *AuthUseCase) verifyTOTPCode(user *domain.User, code string) error {
+	secret, err := uc.totp.DecryptSecret(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+	if !uc.totp.Verify(secret, code) {
+		return domain.ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+func (uc *AuthUseCase) totpChallenge(user *domain.User) (*AuthResponse, error) {
+	pending, err := uc.jwtService.GeneratePendingToken(user.ID, user.Email, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		ExpiresIn:    int64(security.PendingTokenDuration.Seconds()),
+		TOTPRequired: true,
+		PendingToken: pending,
+	}, nil
+}
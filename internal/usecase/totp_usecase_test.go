@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newTOTPTestUseCase(t *testing.T) (*AuthUseCase, *security.TOTPService, *domain.User) {
+	t.Helper()
+
+	mockRepo := NewMockUserRepository()
+	totp := security.NewTOTPService("SecureRestApi", "test-encryption-key")
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour,
+		security.WithRevocationStore(security.NewMemoryRevocationStore()))
+	useCase := NewAuthUseCase(mockRepo, &MockPasswordHasher{}, jwtService, WithTOTP(totp))
+
+	resp, err := useCase.Register(context.Background(), RegisterRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	user, _ := mockRepo.FindByID(context.Background(), resp.User.ID)
+	return useCase, totp, user
+}
+
+func enrollTOTP(t *testing.T, useCase *AuthUseCase, totp *security.TOTPService, user *domain.User) string {
+	t.Helper()
+
+	setup, err := useCase.SetupTOTP(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to set up TOTP: %v", err)
+	}
+	code, _ := totp.GenerateCode(setup.Secret, time.Now())
+	if err := useCase.ConfirmTOTP(context.Background(), user.ID, code); err != nil {
+		t.Fatalf("Failed to confirm TOTP: %v", err)
+	}
+	return setup.Secret
+}
+
+func TestAuthUseCase_SetupTOTP_StoresEncryptedSecret(t *testing.T) {
+	useCase, _, user := newTOTPTestUseCase(t)
+
+	setup, err := useCase.SetupTOTP(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if setup.Secret == "" || setup.OTPAuthURL == "" {
+		t.Fatalf("Expected secret and URL, got %+v", setup)
+	}
+	if user.TOTPSecret == "" || user.TOTPSecret == setup.Secret {
+		t.Error("Expected the stored secret to be encrypted")
+	}
+	if user.TOTPEnabled {
+		t.Error("Expected TOTP to stay disabled until confirmed")
+	}
+}
+
+func TestAuthUseCase_ConfirmTOTP(t *testing.T) {
+	useCase, _, user := newTOTPTestUseCase(t)
+
+	if err := useCase.ConfirmTOTP(context.Background(), user.ID, "123456"); !errors.Is(err, domain.ErrTOTPNotEnrolled) {
+		t.Errorf("Expected ErrTOTPNotEnrolled before setup, got %v", err)
+	}
+
+	if _, err := useCase.SetupTOTP(context.Background(), user.ID); err != nil {
+		t.Fatalf("Failed to set up TOTP: %v", err)
+	}
+	if err := useCase.ConfirmTOTP(context.Background(), user.ID, "000000"); !errors.Is(err, domain.ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+	if user.TOTPEnabled {
+		t.Error("Expected TOTP to stay disabled after a wrong code")
+	}
+}
+
+func TestAuthUseCase_SetupTOTP_AlreadyEnabled(t *testing.T) {
+	useCase, totp, user := newTOTPTestUseCase(t)
+	enrollTOTP(t, useCase, totp, user)
+
+	if !user.TOTPEnabled {
+		t.Fatal("Expected TOTP to be enabled")
+	}
+	if _, err := useCase.SetupTOTP(context.Background(), user.ID); !errors.Is(err, domain.ErrTOTPAlreadyEnabled) {
+		t.Errorf("Expected ErrTOTPAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestAuthUseCase_Login_TOTPRequired(t *testing.T) {
+	useCase, totp, user := newTOTPTestUseCase(t)
+	secret := enrollTOTP(t, useCase, totp, user)
+
+	resp, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.TOTPRequired || resp.PendingToken == "" {
+		t.Fatalf("Expected a TOTP challenge, got %+v", resp)
+	}
+	if resp.AccessToken != "" || resp.User != nil {
+		t.Error("Expected no access token or user before the second step")
+	}
+
+	// The confirmation code was consumed, so use the next period's code.
+	code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: "000000"}); !errors.Is(err, domain.ErrInvalidTOTPCode) {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+
+	final, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: code})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if final.AccessToken == "" || final.User.ID != user.ID {
+		t.Errorf("Expected access token for user %d, got %+v", user.ID, final)
+	}
+
+	if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: code}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected used pending token to be rejected, got %v", err)
+	}
+}
+
+func TestAuthUseCase_VerifyTOTP_RejectsAccessToken(t *testing.T) {
+	useCase, totp, user := newTOTPTestUseCase(t)
+	secret := enrollTOTP(t, useCase, totp, user)
+
+	access, _ := useCase.jwtService.GenerateToken(user.ID, user.Email, user.Role)
+	code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+
+	if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: access, Code: code}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestAuthUseCase_VerifyTOTP_RevokesPendingTokenAfterWrongCodes(t *testing.T) {
+	useCase, totp, user := newTOTPTestUseCase(t)
+	secret := enrollTOTP(t, useCase, totp, user)
+
+	resp, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i := 0; i < maxTOTPAttempts; i++ {
+		if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: "000000"}); !errors.Is(err, domain.ErrInvalidTOTPCode) {
+			t.Fatalf("Attempt %d: expected ErrInvalidTOTPCode, got %v", i+1, err)
+		}
+	}
+	if user.FailedAttempts != maxTOTPAttempts {
+		t.Errorf("Expected %d failed attempts on the account, got %d", maxTOTPAttempts, user.FailedAttempts)
+	}
+
+	code, _ := totp.GenerateCode(secret, time.Now().Add(30*time.Second))
+	if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: code}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected the pending token to be revoked, got %v", err)
+	}
+}
+
+func TestAuthUseCase_VerifyTOTP_WrongCodesCountAgainstThrottle(t *testing.T) {
+	useCase, totp, user := newTOTPTestUseCase(t)
+	enrollTOTP(t, useCase, totp, user)
+	useCase.loginThrottle = newLoginThrottle(LoginThrottleConfig{MaxPerAccount: 2, Window: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		resp, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := useCase.VerifyTOTP(context.Background(), TOTPVerifyRequest{PendingToken: resp.PendingToken, Code: "000000"}); !errors.Is(err, domain.ErrInvalidTOTPCode) {
+			t.Fatalf("Expected ErrInvalidTOTPCode, got %v", err)
+		}
+	}
+
+	_, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if !errors.Is(err, domain.ErrLoginThrottled) {
+		t.Errorf("Expected the login to be throttled after wrong codes, got %v", err)
+	}
+}
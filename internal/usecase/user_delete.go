@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// DeleteUser removes userID on behalf of the admin actorID and ends the
+// user's sessions, so their refresh tokens stop working at once. Access
+// tokens already issued are rejected by the auth middleware once the
+// account is gone. Admins cannot delete themselves, which also keeps the last
+// admin from locking everyone out.
+func (uc *AuthUseCase) DeleteUser(ctx context.Context, actorID, userID int64) error {
+	if actorID == userID {
+		return domain.ErrCannotDeleteSelf
+	}
+
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return contextError(err)
+	}
+
+	if err := uc.endUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := uc.userRepo.Delete(ctx, user.ID); err != nil {
+		return contextError(err)
+	}
+	uc.recordAudit(ctx, domain.AuditActionUserDelete, user.ID, user.Email)
+
+	return nil
+}
+
+// RestoreUser brings back a soft-deleted user. Their sessions ended with the
+// delete, so they have to log in again. It returns ErrUserNotFound when
+// userID is not a soft-deleted user, including under hard deletes.
+func (uc *AuthUseCase) RestoreUser(ctx context.Context, userID int64) (*domain.User, error) {
+	user, err := uc.userRepo.FindByIDIncludingDeleted(ctx, userID)
+	if err != nil {
+		return nil, contextError(err)
+	}
+	if user.DeletedAt == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	if err := uc.userRepo.Restore(ctx, user.ID); err != nil {
+		return nil, contextError(err)
+	}
+	uc.recordAudit(ctx, domain.AuditActionUserRestore, user.ID, user.Email)
+
+	restored, err := uc.userRepo.FindByID(ctx, user.ID)
+	return restored, contextError(err)
+}
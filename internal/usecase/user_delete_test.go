@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/database"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/repository"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func TestAuthUseCase_DeleteUser_EndsSessions(t *testing.T) {
+	useCase, sessions, _ := newSessionTestUseCase(t)
+
+	login, err := useCase.Login(context.Background(), LoginRequest{Email: "test@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.DeleteUser(context.Background(), 99, login.User.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(sessions.sessions) != 0 {
+		t.Errorf("Expected every session to be ended, %d left", len(sessions.sessions))
+	}
+	if _, err := useCase.Refresh(context.Background(), RefreshRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected the refresh token to be rejected, got %v", err)
+	}
+	if _, err := useCase.GetUserByID(context.Background(), login.User.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected the user to be gone, got %v", err)
+	}
+}
+
+func TestAuthUseCase_DeleteUser_Self(t *testing.T) {
+	useCase, sessions, _ := newSessionTestUseCase(t)
+
+	if err := useCase.DeleteUser(context.Background(), 1, 1); !errors.Is(err, domain.ErrCannotDeleteSelf) {
+		t.Errorf("Expected ErrCannotDeleteSelf, got %v", err)
+	}
+	if _, err := useCase.GetUserByID(context.Background(), 1); err != nil {
+		t.Errorf("Expected the user to be kept, got %v", err)
+	}
+	if len(sessions.sessions) != 1 {
+		t.Errorf("Expected the session to be kept, got %d", len(sessions.sessions))
+	}
+}
+
+func TestAuthUseCase_DeleteUser_Unknown(t *testing.T) {
+	useCase, _, _ := newSessionTestUseCase(t)
+
+	if err := useCase.DeleteUser(context.Background(), 1, 42); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestAuthUseCase_SoftDeletedUserCanBeRestored(t *testing.T) {
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(repository.NewInMemoryUserRepository(repository.WithInMemorySoftDelete()), &MockPasswordHasher{}, jwtService)
+	ctx := context.Background()
+	credentials := LoginRequest{Email: "test@example.com", Password: "password123"}
+
+	resp, err := useCase.Register(ctx, RegisterRequest{Email: credentials.Email, Password: credentials.Password})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	if err := useCase.DeleteUser(ctx, 99, resp.User.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The login fails like one for an unknown email.
+	if _, err := useCase.Login(ctx, credentials); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := useCase.GetUserByID(ctx, resp.User.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+
+	restored, err := useCase.RestoreUser(ctx, resp.User.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("Expected deleted_at to be cleared, got %v", restored.DeletedAt)
+	}
+	if _, err := useCase.Login(ctx, credentials); err != nil {
+		t.Errorf("Expected the restored user to log in, got %v", err)
+	}
+	if _, err := useCase.RestoreUser(ctx, resp.User.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("Expected restoring a live user to fail, got %v", err)
+	}
+}
+
+func TestAuthUseCase_RestoredUserKeepsPasswordHistory(t *testing.T) {
+	db, err := database.NewSQLiteDB(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(repository.NewSQLiteUserRepository(db, repository.WithSoftDelete()), &MockPasswordHasher{}, jwtService,
+		WithPasswordHistory(repository.NewSQLitePasswordHistoryRepository(db), 3))
+	ctx := context.Background()
+
+	resp, err := useCase.Register(ctx, RegisterRequest{Email: "test@example.com", Password: "password-1"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	userID := resp.User.ID
+	if _, err := useCase.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "password-1", NewPassword: "password-2"}); err != nil {
+		t.Fatalf("Failed to change password: %v", err)
+	}
+
+	if err := useCase.DeleteUser(ctx, 99, userID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if _, err := useCase.RestoreUser(ctx, userID); err != nil {
+		t.Fatalf("Failed to restore user: %v", err)
+	}
+
+	_, err = useCase.ChangePassword(ctx, userID, ChangePasswordRequest{CurrentPassword: "password-2", NewPassword: "password-1"})
+	if !errors.Is(err, domain.ErrPasswordReused) {
+		t.Errorf("Expected the password used before the deletion to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+// UserDTO is the public representation of a user. Fields are copied one by
+// one from domain.User, so a column added to the model stays private until
+// it is added here.
+type UserDTO struct {
+	ID            int64   `json:"id"`
+	Email         string  `json:"email"`
+	Username      *string `json:"username"`
+	PendingEmail  string  `json:"pending_email,omitempty"`
+	DisplayName   *string `json:"display_name"`
+	Role          string  `json:"role"`
+	Provider      string  `json:"provider,omitempty"`
+	EmailVerified bool    `json:"email_verified"`
+	TOTPEnabled   bool    `json:"totp_enabled"`
+	CreatedAt     string  `json:"created_at"`
+	UpdatedAt     string  `json:"updated_at"`
+	LastLoginAt   *string `json:"last_login_at"`
+	// PasswordKDF is only filled in for admins, by WithPasswordKDF.
+	PasswordKDF *PasswordKDFDTO `json:"password_kdf,omitempty"`
+}
+
+// PasswordKDFDTO reports how an account's password hash was made, not the
+// hash: the algorithm and its parameters, bcrypt's cost or Argon2id's
+// memory (KiB), iterations and parallelism.
+type PasswordKDFDTO struct {
+	Algorithm   string `json:"algorithm"`
+	Cost        int    `json:"cost,omitempty"`
+	Memory      uint32 `json:"memory,omitempty"`
+	Iterations  uint32 `json:"iterations,omitempty"`
+	Parallelism uint8  `json:"parallelism,omitempty"`
+}
+
+func NewUserDTO(user *domain.User) *UserDTO {
+	dto := &UserDTO{
+		ID:            user.ID,
+		Email:         user.Email,
+		PendingEmail:  user.PendingEmail,
+		Role:          user.Role,
+		Provider:      user.Provider,
+		EmailVerified: user.EmailVerified,
+		TOTPEnabled:   user.TOTPEnabled,
+		CreatedAt:     formatTime(user.CreatedAt),
+		UpdatedAt:     formatTime(user.UpdatedAt),
+	}
+	if user.Username != "" {
+		username := user.Username
+		dto.Username = &username
+	}
+	if user.DisplayName != "" {
+		displayName := user.DisplayName
+		dto.DisplayName = &displayName
+	}
+	if user.LastLoginAt != nil {
+		lastLoginAt := formatTime(*user.LastLoginAt)
+		dto.LastLoginAt = &lastLoginAt
+	}
+	return dto
+}
+
+// WithPasswordKDF adds the algorithm and parameters of user's password hash,
+// read from its prefix, so accounts due for a rehash can be spotted. It is
+// left out for an account without a password or a hash it cannot parse.
+func (dto *UserDTO) WithPasswordKDF(user *domain.User) *UserDTO {
+	info, err := security.DescribeHash(user.PasswordHash)
+	if err != nil {
+		return dto
+	}
+	dto.PasswordKDF = &PasswordKDFDTO{
+		Algorithm:   info.Algorithm,
+		Cost:        info.BcryptCost,
+		Memory:      info.Argon2.Memory,
+		Iterations:  info.Argon2.Iterations,
+		Parallelism: info.Argon2.Parallelism,
+	}
+	return dto
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+// How an import treats rows it cannot create. In skip mode the other rows
+// are still created; in fail mode nothing is created unless every row is
+// valid and new.
+const (
+	ImportModeSkip = "skip"
+	ImportModeFail = "fail"
+)
+
+// MaxImportBatchSize bounds one import. Every row costs a password hash, so
+// a batch must fit within the request timeout.
+const MaxImportBatchSize = 50
+
+// Per-row outcome of an import.
+const (
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+var (
+	errInvalidImportMode   = errors.New("must be skip or fail")
+	errImportBatchTooLarge = errors.New("must contain at most 50 users")
+	errDuplicateInBatch    = errors.New("email appears more than once in the batch")
+	errImportRejected      = errors.New("not created: the batch has failed rows")
+)
+
+type ImportUserRow struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type ImportUsersRequest struct {
+	Mode  string          `json:"mode"`
+	Users []ImportUserRow `json:"users"`
+}
+
+type ImportUserResult struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportUsersResult struct {
+	Mode    string             `json:"mode"`
+	Created int                `json:"created"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Results []ImportUserResult `json:"results"`
+}
+
+// Rejected reports a fail-mode batch that was not applied.
+func (r *ImportUsersResult) Rejected() bool {
+	return r.Mode == ImportModeFail && r.Created == 0 && r.Failed > 0
+}
+
+// ImportUsers creates accounts on behalf of an admin and reports the outcome
+// of every row, in order. Rows are checked before anything is written, so in
+// fail mode an invalid or duplicate row prevents the whole batch. The check
+// and the inserts are not one transaction: an address registered in between
+// is reported on its row like any other duplicate.
+func (uc *AuthUseCase) ImportUsers(ctx context.Context, req ImportUsersRequest) (*ImportUsersResult, error) {
+	if req.Mode == "" {
+		req.Mode = ImportModeSkip
+	}
+
+	validation := &domain.ValidationError{}
+	if req.Mode != ImportModeSkip && req.Mode != ImportModeFail {
+		validation.Add("mode", errInvalidImportMode)
+	}
+	switch {
+	case len(req.Users) == 0:
+		validation.Add("users", domain.ErrRequiredField)
+	case len(req.Users) > MaxImportBatchSize:
+		validation.Add("users", errImportBatchTooLarge)
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &ImportUsersResult{Mode: req.Mode, Results: make([]ImportUserResult, len(req.Users))}
+	seen := make(map[string]bool, len(req.Users))
+	for i := range req.Users {
+		row := &req.Users[i]
+		row.Email = normalizeEmail(row.Email)
+		if row.Role == "" {
+			row.Role = domain.RoleUser
+		}
+		result.Results[i].Email = row.Email
+
+		if err := validateImportRow(*row); err != nil {
+			result.fail(i, err)
+			continue
+		}
+		if seen[row.Email] {
+			result.duplicate(i, errDuplicateInBatch)
+			continue
+		}
+		seen[row.Email] = true
+
+		_, err := uc.userRepo.FindByEmail(ctx, row.Email)
+		switch {
+		case err == nil:
+			result.duplicate(i, domain.ErrUserAlreadyExists)
+		case err != domain.ErrUserNotFound:
+			return nil, contextError(err)
+		}
+	}
+
+	if result.Rejected() {
+		for i := range result.Results {
+			if result.Results[i].Status == "" {
+				result.skip(i, errImportRejected)
+			}
+		}
+		return result, nil
+	}
+
+	for i, row := range req.Users {
+		if result.Results[i].Status != "" {
+			continue
+		}
+		// Rows already created stay created; the rest report why they
+		// were not.
+		if err := ctx.Err(); err != nil {
+			result.fail(i, contextError(err))
+			continue
+		}
+		uc.importRow(ctx, result, i, row)
+	}
+
+	return result, nil
+}
+
+func (uc *AuthUseCase) importRow(ctx context.Context, result *ImportUsersResult, i int, row ImportUserRow) {
+	hashedPassword, err := uc.passwordService.Hash(row.Password)
+	if err != nil {
+		result.fail(i, err)
+		return
+	}
+
+	user, err := uc.userRepo.Create(ctx, row.Email, hashedPassword, domain.PasswordPolicyVersion)
+	if err != nil {
+		if err == domain.ErrUserAlreadyExists {
+			result.duplicate(i, err)
+			return
+		}
+		result.fail(i, contextError(err))
+		return
+	}
+	if row.Role != domain.RoleUser {
+		if err := uc.userRepo.SetRole(ctx, user.ID, row.Role); err != nil {
+			// The account exists; its ID lets the admin fix the role rather
+			// than import the row again.
+			result.fail(i, fmt.Errorf("created without the %s role: %w", row.Role, contextError(err)))
+			result.Results[i].ID = user.ID
+			return
+		}
+	}
+	uc.recordAudit(ctx, domain.AuditActionUserImport, user.ID, user.Email)
+
+	result.Results[i].Status = ImportStatusCreated
+	result.Results[i].ID = user.ID
+	result.Created++
+}
+
+func validateImportRow(row ImportUserRow) error {
+	validation := &domain.ValidationError{}
+	if err := validateEmail(row.Email); err != nil {
+		validation.Add("email", err)
+	}
+	if row.Password == "" {
+		validation.Add("password", domain.ErrRequiredField)
+	} else if err := validatePassword(row.Password); err != nil {
+		validation.Add("password", err)
+	}
+	if !domain.ValidRole(row.Role) {
+		validation.Add("role", domain.ErrInvalidRole)
+	}
+	return validation.Err()
+}
+
+// duplicate records an address that already has an account: skipped in skip
+// mode, a failure in fail mode.
+func (r *ImportUsersResult) duplicate(i int, err error) {
+	if r.Mode == ImportModeSkip {
+		r.skip(i, err)
+		return
+	}
+	r.fail(i, err)
+}
+
+func (r *ImportUsersResult) skip(i int, err error) {
+	r.Results[i].Status = ImportStatusSkipped
+	r.Results[i].Error = err.Error()
+	r.Skipped++
+}
+
+func (r *ImportUsersResult) fail(i int, err error) {
+	r.Results[i].Status = ImportStatusFailed
+	r.Results[i].Error = importErrorMessage(err)
+	r.Failed++
+}
+
+// importErrorMessage lists every field problem on one line.
+func importErrorMessage(err error) string {
+	var validationErr *domain.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err.Error()
+	}
+	messages := make([]string, len(validationErr.Fields))
+	for i, field := range validationErr.Fields {
+		messages[i] = field.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newImportUseCase(t *testing.T) (*AuthUseCase, *MockUserRepository) {
+	t.Helper()
+
+	mockRepo := NewMockUserRepository()
+	passwordService, err := security.NewPasswordServiceWithCost(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to create password service: %v", err)
+	}
+	jwtService := security.NewJWTService("test-secret", "test-issuer", 3600)
+
+	useCase := NewAuthUseCase(mockRepo, passwordService, jwtService)
+	if _, err := useCase.Register(context.Background(), RegisterRequest{Email: "existing@example.com", Password: "password123"}); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	return useCase, mockRepo
+}
+
+func mixedImportBatch(mode string) ImportUsersRequest {
+	return ImportUsersRequest{
+		Mode: mode,
+		Users: []ImportUserRow{
+			{Email: "New@Example.com", Password: "password123"},
+			{Email: "existing@example.com", Password: "password123"},
+			{Email: "not-an-email", Password: "password123"},
+			{Email: "ops@example.com", Password: "password123", Role: domain.RoleAdmin},
+		},
+	}
+}
+
+func TestAuthUseCase_ImportUsers_SkipMode(t *testing.T) {
+	useCase, mockRepo := newImportUseCase(t)
+
+	result, err := useCase.ImportUsers(context.Background(), mixedImportBatch(ImportModeSkip))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	wantStatuses := []string{ImportStatusCreated, ImportStatusSkipped, ImportStatusFailed, ImportStatusCreated}
+	for i, want := range wantStatuses {
+		if result.Results[i].Status != want {
+			t.Errorf("Row %d: expected status %q, got %+v", i, want, result.Results[i])
+		}
+	}
+	if result.Created != 2 || result.Skipped != 1 || result.Failed != 1 || result.Rejected() {
+		t.Errorf("Unexpected totals: %+v", result)
+	}
+	if result.Results[0].Email != "new@example.com" || result.Results[0].ID == 0 {
+		t.Errorf("Expected a normalized email and an ID, got %+v", result.Results[0])
+	}
+	if result.Results[2].Error != "email: is not a valid email address" {
+		t.Errorf("Expected the field error, got %q", result.Results[2].Error)
+	}
+
+	admin, err := mockRepo.FindByEmail(context.Background(), "ops@example.com")
+	if err != nil || admin.Role != domain.RoleAdmin {
+		t.Errorf("Expected ops@example.com to be an admin, got %+v, %v", admin, err)
+	}
+}
+
+func TestAuthUseCase_ImportUsers_FailModeCreatesNothing(t *testing.T) {
+	useCase, mockRepo := newImportUseCase(t)
+
+	result, err := useCase.ImportUsers(context.Background(), mixedImportBatch(ImportModeFail))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !result.Rejected() || result.Created != 0 {
+		t.Fatalf("Expected the batch to be rejected, got %+v", result)
+	}
+	wantStatuses := []string{ImportStatusSkipped, ImportStatusFailed, ImportStatusFailed, ImportStatusSkipped}
+	for i, want := range wantStatuses {
+		if result.Results[i].Status != want {
+			t.Errorf("Row %d: expected status %q, got %+v", i, want, result.Results[i])
+		}
+	}
+	if _, err := mockRepo.FindByEmail(context.Background(), "new@example.com"); err != domain.ErrUserNotFound {
+		t.Errorf("Expected no account to be created, got %v", err)
+	}
+}
+
+func TestAuthUseCase_ImportUsers_DuplicateWithinBatch(t *testing.T) {
+	useCase, _ := newImportUseCase(t)
+
+	result, err := useCase.ImportUsers(context.Background(), ImportUsersRequest{Users: []ImportUserRow{
+		{Email: "twice@example.com", Password: "password123"},
+		{Email: "TWICE@example.com", Password: "password123"},
+	}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Mode != ImportModeSkip || result.Results[0].Status != ImportStatusCreated || result.Results[1].Status != ImportStatusSkipped {
+		t.Errorf("Expected the second row to be skipped, got %+v", result)
+	}
+}
+
+func TestAuthUseCase_ImportUsers_InvalidRequest(t *testing.T) {
+	useCase, _ := newImportUseCase(t)
+
+	tooMany := make([]ImportUserRow, MaxImportBatchSize+1)
+	tests := []struct {
+		name  string
+		req   ImportUsersRequest
+		field string
+	}{
+		{"empty", ImportUsersRequest{}, "users"},
+		{"too large", ImportUsersRequest{Users: tooMany}, "users"},
+		{"unknown mode", ImportUsersRequest{Mode: "merge", Users: []ImportUserRow{{}}}, "mode"},
+	}
+	for _, tt := range tests {
+		_, err := useCase.ImportUsers(context.Background(), tt.req)
+
+		var validationErr *domain.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != tt.field {
+			t.Errorf("%s: expected a validation error on %q, got %v", tt.name, tt.field, err)
+		}
+	}
+}
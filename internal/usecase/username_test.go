@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+func newUsernameTestUseCase(t *testing.T) *AuthUseCase {
+	t.Helper()
+
+	jwtService := security.NewJWTService("test-secret", "test-issuer", time.Hour)
+	useCase := NewAuthUseCase(NewMockUserRepository(), &MockPasswordHasher{}, jwtService)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "alice@example.com", Username: "  Alice.Martin ", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	return useCase
+}
+
+func TestAuthUseCase_Login_ByUsername(t *testing.T) {
+	useCase := newUsernameTestUseCase(t)
+
+	for _, identifier := range []string{"alice.martin", "ALICE.MARTIN", "alice@example.com"} {
+		resp, err := useCase.Login(context.Background(), LoginRequest{Identifier: identifier, Password: "password123"})
+		if err != nil {
+			t.Errorf("Login as %q: expected no error, got %v", identifier, err)
+			continue
+		}
+		if resp.User.Username == nil || *resp.User.Username != "alice.martin" {
+			t.Errorf("Login as %q: expected the normalized username, got %v", identifier, resp.User.Username)
+		}
+	}
+
+	// The email field keeps working for existing clients.
+	if _, err := useCase.Login(context.Background(), LoginRequest{Email: "alice@example.com", Password: "password123"}); err != nil {
+		t.Errorf("Expected email login to succeed, got %v", err)
+	}
+
+	for _, req := range []LoginRequest{
+		{Identifier: "alice.martin", Password: "wrong-password"},
+		{Identifier: "bob", Password: "password123"},
+	} {
+		if _, err := useCase.Login(context.Background(), req); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Errorf("Login as %q: expected ErrInvalidCredentials, got %v", req.Identifier, err)
+		}
+	}
+}
+
+func TestAuthUseCase_Register_UsernameTaken(t *testing.T) {
+	useCase := newUsernameTestUseCase(t)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "other@example.com", Username: "ALICE.martin", Password: "password123"})
+	if !errors.Is(err, domain.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken, got %v", err)
+	}
+
+	// Usernames are optional, so any number of users can go without one.
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		if _, err := useCase.Register(context.Background(), RegisterRequest{Email: email, Password: "password123"}); err != nil {
+			t.Errorf("Expected %s to register without a username, got %v", email, err)
+		}
+	}
+}
+
+func TestAuthUseCase_Register_InvalidUsername(t *testing.T) {
+	useCase := newUsernameTestUseCase(t)
+
+	_, err := useCase.Register(context.Background(), RegisterRequest{Email: "other@example.com", Username: "bob@example.com", Password: "password123"})
+	if !errors.Is(err, domain.ErrUsernameInvalid) {
+		t.Errorf("Expected ErrUsernameInvalid, got %v", err)
+	}
+}
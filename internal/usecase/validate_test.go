@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+)
+
+func TestRegisterRequest_Validate(t *testing.T) {
+	valid := []RegisterRequest{
+		{Email: "user@example.com", Password: "password123"},
+		{Email: "  User@Example.com ", Username: " Alice_1 ", Password: "password123"},
+	}
+	for _, req := range valid {
+		if err := req.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", req, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		req      RegisterRequest
+		expected map[string]error
+	}{
+		{"empty", RegisterRequest{}, map[string]error{
+			"email":    domain.ErrRequiredField,
+			"password": domain.ErrRequiredField,
+		}},
+		{"every field wrong", RegisterRequest{Email: "not-an-email", Username: "a b", Password: "short"}, map[string]error{
+			"email":    domain.ErrInvalidEmail,
+			"username": domain.ErrUsernameInvalid,
+			"password": domain.ErrWeakPassword,
+		}},
+		{"email too long", RegisterRequest{Email: strings.Repeat("a", domain.MaxEmailLength) + "@example.com", Password: "password123"}, map[string]error{
+			"email": domain.ErrEmailTooLong,
+		}},
+		{"password too long", RegisterRequest{Email: "user@example.com", Password: strings.Repeat("a", maxPasswordLength+1)}, map[string]error{
+			"password": domain.ErrWeakPassword,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldErrors(t, tt.req.Validate(), tt.expected)
+		})
+	}
+}
+
+func TestLoginRequest_Validate(t *testing.T) {
+	valid := []LoginRequest{
+		{Identifier: "alice", Password: "x"},
+		{Email: "user@example.com", Password: "x"},
+	}
+	for _, req := range valid {
+		if err := req.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", req, err)
+		}
+	}
+
+	assertFieldErrors(t, LoginRequest{Identifier: "   "}.Validate(), map[string]error{
+		"identifier": domain.ErrRequiredField,
+		"password":   domain.ErrRequiredField,
+	})
+	assertFieldErrors(t, LoginRequest{Email: "user@example.com"}.Validate(), map[string]error{
+		"password": domain.ErrRequiredField,
+	})
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+type VerificationUseCase struct {
+	userRepo            domain.UserRepository
+	tokenRepo           domain.TokenRepository
+	verificationService *security.VerificationService
+	tokenTTL            time.Duration
+	now                 func() time.Time
+	mailer              domain.Mailer
+	linkBaseURL         string
+}
+
+type VerificationOption func(*VerificationUseCase)
+
+// WithVerificationMailer emails verification links, built on baseURL.
+func WithVerificationMailer(mailer domain.Mailer, baseURL string) VerificationOption {
+	return func(uc *VerificationUseCase) {
+		uc.mailer = mailer
+		uc.linkBaseURL = baseURL
+	}
+}
+
+func NewVerificationUseCase(
+	userRepo domain.UserRepository,
+	tokenRepo domain.TokenRepository,
+	verificationService *security.VerificationService,
+	tokenTTL time.Duration,
+	opts ...VerificationOption,
+) *VerificationUseCase {
+	uc := &VerificationUseCase{
+		userRepo:            userRepo,
+		tokenRepo:           tokenRepo,
+		verificationService: verificationService,
+		tokenTTL:            tokenTTL,
+		now:                 time.Now,
+	}
+	for _, opt := range opts {
+		opt(uc)
+	}
+	return uc
+}
+
+// RequestEmailVerification issues a new single-use token for the user,
+// emails it when a mailer is configured, and returns it.
+func (uc *VerificationUseCase) RequestEmailVerification(ctx context.Context, userID int64) (string, error) {
+	user, err := uc.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return "", contextError(err)
+	}
+
+	if user.EmailVerified {
+		return "", domain.ErrEmailAlreadyVerified
+	}
+
+	token, tokenHash, err := uc.verificationService.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = uc.tokenRepo.Create(ctx, &domain.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   domain.TokenPurposeEmailVerification,
+		TokenHash: tokenHash,
+		ExpiresAt: uc.now().Add(uc.tokenTTL),
+	})
+	if err != nil {
+		return "", contextError(err)
+	}
+
+	if uc.mailer != nil {
+		subject, body := verificationEmail(emailLink(uc.linkBaseURL, verifyEmailPath, token), uc.tokenTTL)
+		if err := uc.mailer.Send(user.Email, subject, body); err != nil {
+			return "", err
+		}
+	}
+
+	return token, nil
+}
+
+func (uc *VerificationUseCase) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return domain.ErrOneTimeTokenInvalid
+	}
+
+	stored, err := uc.tokenRepo.FindByHash(ctx, domain.TokenPurposeEmailVerification, uc.verificationService.HashToken(token))
+	if err != nil {
+		return contextError(err)
+	}
+
+	if stored.UsedAt != nil {
+		return domain.ErrOneTimeTokenUsed
+	}
+
+	now := uc.now()
+	if now.After(stored.ExpiresAt) {
+		return domain.ErrOneTimeTokenExpired
+	}
+
+	if err := uc.tokenRepo.MarkUsed(ctx, stored.ID, now); err != nil {
+		return contextError(err)
+	}
+
+	return contextError(uc.userRepo.SetEmailVerified(ctx, stored.UserID, true))
+}
@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valentinfrappart/securerestapi/internal/domain"
+	"github.com/valentinfrappart/securerestapi/internal/infrastructure/security"
+)
+
+type MockTokenRepository struct {
+	tokens map[int64]*domain.OneTimeToken
+	nextID int64
+}
+
+func NewMockTokenRepository() *MockTokenRepository {
+	return &MockTokenRepository{
+		tokens: make(map[int64]*domain.OneTimeToken),
+		nextID: 1,
+	}
+}
+
+func (m *MockTokenRepository) Create(ctx context.Context, token *domain.OneTimeToken) error {
+	token.ID = m.nextID
+	m.nextID++
+	stored := *token
+	m.tokens[token.ID] = &stored
+	return nil
+}
+
+func (m *MockTokenRepository) FindByHash(ctx context.Context, purpose, tokenHash string) (*domain.OneTimeToken, error) {
+	for _, token := range m.tokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrOneTimeTokenInvalid
+}
+
+func (m *MockTokenRepository) MarkUsed(ctx context.Context, id int64, usedAt time.Time) error {
+	token, exists := m.tokens[id]
+	if !exists || token.UsedAt != nil {
+		return domain.ErrOneTimeTokenUsed
+	}
+	token.UsedAt = &usedAt
+	return nil
+}
+
+func (m *MockTokenRepository) InvalidateUnused(ctx context.Context, userID int64, purpose string, usedAt time.Time) error {
+	for _, token := range m.tokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+type sentEmail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MockMailer captures messages instead of sending them; Send fails with err
+// when it is set.
+type MockMailer struct {
+	sent []sentEmail
+	err  error
+}
+
+func (m *MockMailer) Send(to, subject, body string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, sentEmail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+func newVerificationTestSetup(t *testing.T) (*VerificationUseCase, *MockUserRepository, *domain.User) {
+	t.Helper()
+
+	userRepo := NewMockUserRepository()
+	user, err := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	useCase := NewVerificationUseCase(userRepo, NewMockTokenRepository(), security.NewVerificationService(), time.Hour)
+	return useCase, userRepo, user
+}
+
+func TestVerificationUseCase_VerifyEmail_Success(t *testing.T) {
+	useCase, userRepo, user := newVerificationTestSetup(t)
+
+	token, err := useCase.RequestEmailVerification(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.VerifyEmail(context.Background(), token); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	verified, _ := userRepo.FindByID(context.Background(), user.ID)
+	if !verified.EmailVerified {
+		t.Error("Expected email to be verified")
+	}
+}
+
+func TestVerificationUseCase_VerifyEmail_AlreadyUsed(t *testing.T) {
+	useCase, _, user := newVerificationTestSetup(t)
+
+	token, err := useCase.RequestEmailVerification(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := useCase.VerifyEmail(context.Background(), token); err != nil {
+		t.Fatalf("Expected first verification to succeed, got %v", err)
+	}
+
+	err = useCase.VerifyEmail(context.Background(), token)
+	if !errors.Is(err, domain.ErrOneTimeTokenUsed) {
+		t.Errorf("Expected ErrOneTimeTokenUsed, got %v", err)
+	}
+}
+
+func TestVerificationUseCase_VerifyEmail_Expired(t *testing.T) {
+	useCase, userRepo, user := newVerificationTestSetup(t)
+
+	token, err := useCase.RequestEmailVerification(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	useCase.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	err = useCase.VerifyEmail(context.Background(), token)
+	if !errors.Is(err, domain.ErrOneTimeTokenExpired) {
+		t.Errorf("Expected ErrOneTimeTokenExpired, got %v", err)
+	}
+
+	unverified, _ := userRepo.FindByID(context.Background(), user.ID)
+	if unverified.EmailVerified {
+		t.Error("Expected email to remain unverified")
+	}
+}
+
+func TestVerificationUseCase_VerifyEmail_UnknownToken(t *testing.T) {
+	useCase, _, _ := newVerificationTestSetup(t)
+
+	err := useCase.VerifyEmail(context.Background(), "not-a-real-token")
+	if !errors.Is(err, domain.ErrOneTimeTokenInvalid) {
+		t.Errorf("Expected ErrOneTimeTokenInvalid, got %v", err)
+	}
+}
+
+func TestVerificationUseCase_RequestEmailVerification_AlreadyVerified(t *testing.T) {
+	useCase, userRepo, user := newVerificationTestSetup(t)
+	userRepo.SetEmailVerified(context.Background(), user.ID, true)
+
+	_, err := useCase.RequestEmailVerification(context.Background(), user.ID)
+	if !errors.Is(err, domain.ErrEmailAlreadyVerified) {
+		t.Errorf("Expected ErrEmailAlreadyVerified, got %v", err)
+	}
+}
+
+func TestVerificationUseCase_RequestEmailVerification_SendsLink(t *testing.T) {
+	userRepo := NewMockUserRepository()
+	user, _ := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	mailer := &MockMailer{}
+	useCase := NewVerificationUseCase(userRepo, NewMockTokenRepository(), security.NewVerificationService(), time.Hour,
+		WithVerificationMailer(mailer, "https://auth.example.com/"))
+
+	token, err := useCase.RequestEmailVerification(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(mailer.sent) != 1 {
+		t.Fatalf("Expected one email, got %d", len(mailer.sent))
+	}
+	sent := mailer.sent[0]
+	if sent.To != "test@example.com" {
+		t.Errorf("Expected email to test@example.com, got %s", sent.To)
+	}
+	link := "https://auth.example.com/api/auth/verify?token=" + url.QueryEscape(token)
+	if !strings.Contains(sent.Body, link) {
+		t.Errorf("Expected body to contain %s, got %q", link, sent.Body)
+	}
+}
+
+func TestVerificationUseCase_RequestEmailVerification_SendFailure(t *testing.T) {
+	userRepo := NewMockUserRepository()
+	user, _ := userRepo.Create(context.Background(), "test@example.com", "hash", 1)
+	sendErr := errors.New("smtp unavailable")
+	useCase := NewVerificationUseCase(userRepo, NewMockTokenRepository(), security.NewVerificationService(), time.Hour,
+		WithVerificationMailer(&MockMailer{err: sendErr}, "https://auth.example.com"))
+
+	if _, err := useCase.RequestEmailVerification(context.Background(), user.ID); !errors.Is(err, sendErr) {
+		t.Errorf("Expected the send error, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package version holds build information injected at link time:
+//
+//	go build -ldflags "-X github.com/valentinfrappart/securerestapi/internal/version.Version=v1.2.0 \
+//	  -X github.com/valentinfrappart/securerestapi/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/valentinfrappart/securerestapi/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// `make build` sets all three. Builds without the flags report "dev".
+package version
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// String formats the build information for logs.
+func String() string {
+	return Version + " (commit " + Commit + ", built " + BuildTime + ")"
+}